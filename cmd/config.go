@@ -2,6 +2,7 @@
 package cmd
 
 import (
+	"errors"
 	"os"
 	"slices"
 
@@ -82,14 +83,7 @@ var configValidateCmd = &cobra.Command{
 		}
 
 		// Validate the configuration
-		cfg := config.Config{
-			DBType:           viper.GetString("db.type"),
-			DBConnection:     viper.GetString("db.connection"),
-			Port:             viper.GetInt("server.port"),
-			SiteDomain:       viper.GetString("server.site_domain"),
-			AllowInsecureTLS: viper.GetBool("server.allow_insecure_tls"),
-			TrustedIPs:       viper.GetStringSlice("server.trusted_ips"),
-		}
+		cfg := loadConfig()
 
 		// Basic validation
 		if cfg.DBType != "sqlite3" && cfg.DBType != "mysql" {
@@ -107,6 +101,36 @@ var configValidateCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
+		if err := config.ValidateCORSOrigins(cfg.CORSOrigins); err != nil {
+			logger.Error("Error: Invalid CORS origins: %v", err)
+			os.Exit(1)
+		}
+		if err := config.ValidateWebhookURL(cfg.WebhookURL); err != nil {
+			logger.Error("Error: %v", err)
+			os.Exit(1)
+		}
+		if err := config.ValidateSiteDomains(cfg.SiteDomains); err != nil {
+			logger.Error("Error: Invalid site domains: %v", err)
+			os.Exit(1)
+		}
+		if _, err := config.NormalizeBasePath(cfg.BasePath); err != nil {
+			logger.Error("Error: %v", err)
+			os.Exit(1)
+		}
+		if err := config.ValidateCORSMethods(cfg.CORSMethods); err != nil {
+			logger.Error("Error: Invalid CORS methods: %v", err)
+			os.Exit(1)
+		}
+		if err := errors.Join(config.ValidateCORSHeaders(cfg.CORSHeaders), config.ValidateCORSHeaders(cfg.CORSExposedHeaders)); err != nil {
+			logger.Error("Error: Invalid CORS headers: %v", err)
+			os.Exit(1)
+		}
+		if cfg.CORSAllowAll {
+			logger.Warn("Warning: CORS allows every origin, without credentials; use this only in development")
+		} else if config.HasWildcardOrigin(cfg.CORSOrigins) {
+			logger.Warn("Warning: CORS origin \"*\" is combined with credentials, which browsers reject; list the allowed origins explicitly")
+		}
+
 		logLevel := viper.GetString("log.level")
 		validLogLevels := []string{"debug", "info", "warn", "error", "fatal", "panic"}
 		validLevel := slices.Contains(validLogLevels, logLevel)
@@ -117,11 +141,18 @@ var configValidateCmd = &cobra.Command{
 
 		logger.Info("Configuration file is valid")
 		logger.Info("Database Type: %s", cfg.DBType)
-		logger.Info("Database Connection: %s", cfg.DBConnection)
+		logger.Info("Database Connection: %s", config.RedactDSN(cfg.DBConnection))
 		logger.Info("Server Port: %d", cfg.Port)
 		logger.Info("Site Domain: %s", cfg.SiteDomain)
+		logger.Info("Additional Site Domains: %v", cfg.SiteDomains)
 		logger.Info("Allow Insecure TLS: %v", cfg.AllowInsecureTLS)
 		logger.Info("Trusted IPs: %v", cfg.TrustedIPs)
+		logger.Info("Trusted Proxies: %v", cfg.TrustedProxies)
+		logger.Info("CORS Origins: %v", cfg.CORSOrigins)
+		logger.Info("CORS Allow All: %v", cfg.CORSAllowAll)
+		logger.Info("Base Path: %s", cfg.BasePath)
+		logger.Info("Shutdown Timeout: %s", cfg.ShutdownTimeout)
+		logger.Info("Read Only: %v", cfg.ReadOnly)
 		logger.Info("Log Level: %s", logLevel)
 	},
 }
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/penwern/curate-preservation-api/database"
+	"github.com/penwern/curate-preservation-api/models"
+	"github.com/penwern/curate-preservation-api/pkg/logger"
+	"github.com/spf13/cobra"
+)
+
+var (
+	configDBName        string
+	configDBDescription string
+	configDBPreset      string
+)
+
+// configDBCmd represents the config-db command
+var configDBCmd = &cobra.Command{
+	Use:   "config-db",
+	Short: "Inspect and create preservation configs directly in the database",
+	Long: `Commands that read and write preservation configs directly in the database,
+without starting the HTTP server. Results are printed as JSON.
+
+They use the same --db-type and --db-connection settings as serve. Log lines also go
+to stdout, so pass --log-level warn when piping the JSON into another tool.`,
+}
+
+// configDBListCmd lists all preservation configs
+var configDBListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all preservation configs",
+	Args:  cobra.NoArgs,
+	Run: func(_ *cobra.Command, _ []string) {
+		withConfigDB(func(db *database.Database) (any, error) {
+			return db.ListConfigs()
+		})
+	},
+}
+
+// configDBGetCmd prints a single preservation config
+var configDBGetCmd = &cobra.Command{
+	Use:   "get ID",
+	Short: "Print a preservation config by ID",
+	Args:  cobra.ExactArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			logger.Error("Error: Invalid config ID '%s'", args[0])
+			os.Exit(1)
+		}
+
+		withConfigDB(func(db *database.Database) (any, error) {
+			return db.GetConfig(id)
+		})
+	},
+}
+
+// configDBCreateCmd creates a preservation config with default or preset A3M settings
+var configDBCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a preservation config",
+	Long: `Create a preservation config with the default A3M settings, or those of --preset,
+and print the stored config.`,
+	Args: cobra.NoArgs,
+	Run: func(_ *cobra.Command, _ []string) {
+		config := models.NewPreservationConfig(configDBName, configDBDescription)
+		if configDBPreset != "" {
+			var ok bool
+			config, ok = models.NewPreservationConfigFromPreset(configDBName, configDBDescription, configDBPreset)
+			if !ok {
+				logger.Error("Error: Unknown preset '%s'. Must be one of: %v", configDBPreset, models.PresetNames())
+				os.Exit(1)
+			}
+		}
+		if err := config.Validate(); err != nil {
+			logger.Error("Error: Invalid config: %v", err)
+			os.Exit(1)
+		}
+
+		withConfigDB(func(db *database.Database) (any, error) {
+			if err := db.CreateConfig(config); err != nil {
+				return nil, err
+			}
+			return db.GetConfig(config.ID)
+		})
+	},
+}
+
+// withConfigDB opens and migrates the configured database, runs fn, prints its result as
+// JSON and closes the database, exiting with an error status if anything fails
+func withConfigDB(fn func(db *database.Database) (any, error)) {
+	cfg := loadConfig()
+
+	db, err := database.New(cfg.DBType, cfg.DBConnection)
+	if err != nil {
+		logger.Error("Error: Failed to open database: %v", err)
+		os.Exit(1)
+	}
+	db.SetMaxConfigs(cfg.MaxConfigs)
+
+	result, runErr := fn(db)
+	if err := db.Close(); err != nil {
+		logger.Error("Failed to close database: %v", err)
+	}
+	if errors.Is(runErr, database.ErrNotFound) {
+		logger.Error("Error: Preservation config not found")
+		os.Exit(1)
+	}
+	if runErr != nil {
+		logger.Error("Error: %v", runErr)
+		os.Exit(1)
+	}
+
+	out, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		logger.Error("Error: Failed to encode result: %v", err)
+		os.Exit(1)
+	}
+	//nolint:forbidigo // Results are meant for scripts and go directly to stdout
+	fmt.Println(string(out))
+}
+
+func init() {
+	rootCmd.AddCommand(configDBCmd)
+	configDBCmd.AddCommand(configDBListCmd)
+	configDBCmd.AddCommand(configDBGetCmd)
+	configDBCmd.AddCommand(configDBCreateCmd)
+
+	configDBCreateCmd.Flags().StringVar(&configDBName, "name", "", "name of the new config (required)")
+	configDBCreateCmd.Flags().StringVar(&configDBDescription, "description", "", "description of the new config")
+	configDBCreateCmd.Flags().StringVar(&configDBPreset, "preset", "", "A3M preset to start from (default uses the standard defaults)")
+	if err := configDBCreateCmd.MarkFlagRequired("name"); err != nil {
+		logger.Error("Failed to mark name flag as required: %v", err)
+	}
+}
@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/penwern/curate-preservation-api/pkg/config"
+	"github.com/penwern/curate-preservation-api/pkg/logger"
+	"github.com/spf13/viper"
+)
+
+// envPrefix is prepended to every environment variable, e.g. CA4M_API_SERVER_PORT
+const envPrefix = "CA4M_API"
+
+// configKeys lists every viper key read into config.Config. Each one can be set through
+// the environment by upper-casing it, replacing dots with underscores and adding envPrefix.
+var configKeys = []string{
+	"db.type",
+	"db.connection",
+	"server.port",
+	"server.site_domain",
+	"server.site_domains",
+	"server.oidc_audience",
+	"server.cors_origins",
+	"server.cors_methods",
+	"server.cors_headers",
+	"server.cors_exposed_headers",
+	"server.cors_max_age",
+	"server.cors_allow_all",
+	"server.allow_insecure_tls",
+	"server.trusted_ips",
+	"server.trusted_proxies",
+	"server.auth_cache_ttl",
+	"server.auth_failure_limit",
+	"server.auth_failure_window",
+	"server.auth_retry_attempts",
+	"server.auth_timeout",
+	"server.auth_max_idle_conns",
+	"server.api_keys",
+	"server.tls_cert_file",
+	"server.tls_key_file",
+	"server.tls_min_version",
+	"server.max_name_length",
+	"server.max_description_length",
+	"server.max_configs",
+	"server.strict_content_type",
+	"server.strict_json",
+	"server.request_timeout",
+	"server.bulk_request_timeout",
+	"server.max_concurrent_requests",
+	"server.concurrency_queue_timeout",
+	"server.shutdown_timeout",
+	"server.base_path",
+	"server.health_at_root",
+	"server.default_config_file",
+	"server.background_migrations",
+	"server.skip_migrations",
+	"server.webhook_url",
+	"server.webhook_secret",
+	"server.read_only",
+	"log.level",
+	"log.file",
+	"log.max_size",
+	"log.max_age",
+	"log.max_backups",
+	"log.compress",
+	"log.format",
+}
+
+// bindEnv maps every config key to its environment variable
+func bindEnv() {
+	viper.SetEnvPrefix(envPrefix)
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	viper.AutomaticEnv()
+
+	for _, key := range configKeys {
+		if err := viper.BindEnv(key); err != nil {
+			logger.Error("Failed to bind %s to the environment: %v", key, err)
+		}
+	}
+}
+
+// getStringSlice handles viper's limitation with comma-separated environment variables
+func getStringSlice(key string) []string {
+	slice := viper.GetStringSlice(key)
+
+	// Environment values arrive as one string, which viper splits on whitespace rather
+	// than commas, so rejoin and split on commas instead
+	if !strings.Contains(strings.Join(slice, ""), ",") {
+		return slice
+	}
+	var result []string
+	for _, part := range strings.Split(strings.Join(slice, ","), ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			result = append(result, part)
+		}
+	}
+	return result
+}
+
+// loadConfig builds the server configuration from flags, environment and config file
+func loadConfig() config.Config {
+	return config.Config{
+		DBType:                  viper.GetString("db.type"),
+		DBConnection:            viper.GetString("db.connection"),
+		Port:                    viper.GetInt("server.port"),
+		CORSOrigins:             getStringSlice("server.cors_origins"),
+		CORSMethods:             getStringSlice("server.cors_methods"),
+		CORSHeaders:             getStringSlice("server.cors_headers"),
+		CORSExposedHeaders:      getStringSlice("server.cors_exposed_headers"),
+		CORSMaxAge:              viper.GetDuration("server.cors_max_age"),
+		CORSAllowAll:            viper.GetBool("server.cors_allow_all"),
+		SiteDomain:              viper.GetString("server.site_domain"),
+		SiteDomains:             getStringSlice("server.site_domains"),
+		OIDCAudience:            viper.GetString("server.oidc_audience"),
+		AllowInsecureTLS:        viper.GetBool("server.allow_insecure_tls"),
+		TrustedIPs:              getStringSlice("server.trusted_ips"),
+		TrustedProxies:          getStringSlice("server.trusted_proxies"),
+		AuthCacheTTL:            viper.GetDuration("server.auth_cache_ttl"),
+		AuthFailureLimit:        viper.GetInt("server.auth_failure_limit"),
+		AuthFailureWindow:       viper.GetDuration("server.auth_failure_window"),
+		AuthRetryAttempts:       viper.GetInt("server.auth_retry_attempts"),
+		AuthTimeout:             viper.GetDuration("server.auth_timeout"),
+		AuthMaxIdleConns:        viper.GetInt("server.auth_max_idle_conns"),
+		APIKeys:                 getStringSlice("server.api_keys"),
+		TLSCertFile:             viper.GetString("server.tls_cert_file"),
+		TLSKeyFile:              viper.GetString("server.tls_key_file"),
+		TLSMinVersion:           viper.GetString("server.tls_min_version"),
+		MaxNameLength:           viper.GetInt("server.max_name_length"),
+		MaxDescriptionLength:    viper.GetInt("server.max_description_length"),
+		MaxConfigs:              viper.GetInt("server.max_configs"),
+		StrictContentType:       viper.GetBool("server.strict_content_type"),
+		StrictJSON:              viper.GetBool("server.strict_json"),
+		RequestTimeout:          viper.GetDuration("server.request_timeout"),
+		BulkRequestTimeout:      viper.GetDuration("server.bulk_request_timeout"),
+		MaxConcurrentRequests:   viper.GetInt("server.max_concurrent_requests"),
+		ConcurrencyQueueTimeout: viper.GetDuration("server.concurrency_queue_timeout"),
+		ShutdownTimeout:         viper.GetDuration("server.shutdown_timeout"),
+		BasePath:                viper.GetString("server.base_path"),
+		HealthAtRoot:            viper.GetBool("server.health_at_root"),
+		DefaultConfigFile:       viper.GetString("server.default_config_file"),
+		BackgroundMigrations:    viper.GetBool("server.background_migrations"),
+		SkipMigrations:          viper.GetBool("server.skip_migrations"),
+		WebhookURL:              viper.GetString("server.webhook_url"),
+		WebhookSecret:           viper.GetString("server.webhook_secret"),
+		ReadOnly:                viper.GetBool("server.read_only"),
+		Log:                     loadLogConfig(),
+	}
+}
+
+// loadLogConfig reads the logging configuration from viper
+func loadLogConfig() config.LogConfig {
+	logLevel := viper.GetString("log.level")
+	if logLevel == "" {
+		logLevel = "info"
+	}
+	return config.LogConfig{
+		Level:      logLevel,
+		File:       viper.GetString("log.file"),
+		MaxSizeMB:  viper.GetInt("log.max_size"),
+		MaxAgeDays: viper.GetInt("log.max_age"),
+		MaxBackups: viper.GetInt("log.max_backups"),
+		Compress:   viper.GetBool("log.compress"),
+		Format:     viper.GetString("log.format"),
+	}
+}
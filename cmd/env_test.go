@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig_Environment(t *testing.T) {
+	bindEnv()
+
+	t.Setenv("CA4M_API_DB_TYPE", "mysql")
+	t.Setenv("CA4M_API_DB_CONNECTION", "user:pass@tcp(db:3306)/preservation")
+	t.Setenv("CA4M_API_SERVER_PORT", "8081")
+	t.Setenv("CA4M_API_SERVER_CORS_ORIGINS", "https://cells.example.com, https://admin.example.com")
+	t.Setenv("CA4M_API_SERVER_TRUSTED_IPS", "10.0.0.0/8,127.0.0.1")
+	t.Setenv("CA4M_API_SERVER_ALLOW_INSECURE_TLS", "true")
+	t.Setenv("CA4M_API_SERVER_AUTH_CACHE_TTL", "30s")
+	t.Setenv("CA4M_API_SERVER_AUTH_FAILURE_LIMIT", "3")
+	t.Setenv("CA4M_API_SERVER_TLS_MIN_VERSION", "1.3")
+	t.Setenv("CA4M_API_LOG_FILE", "-")
+	t.Setenv("CA4M_API_LOG_MAX_BACKUPS", "2")
+	t.Setenv("CA4M_API_LOG_COMPRESS", "true")
+	t.Setenv("CA4M_API_LOG_FORMAT", "json")
+
+	cfg := loadConfig()
+
+	if cfg.DBType != "mysql" {
+		t.Errorf("Expected DBType 'mysql', got '%s'", cfg.DBType)
+	}
+	if cfg.DBConnection != "user:pass@tcp(db:3306)/preservation" {
+		t.Errorf("Expected DBConnection from environment, got '%s'", cfg.DBConnection)
+	}
+	if cfg.Port != 8081 {
+		t.Errorf("Expected Port 8081, got %d", cfg.Port)
+	}
+	if want := []string{"https://cells.example.com", "https://admin.example.com"}; !slices.Equal(cfg.CORSOrigins, want) {
+		t.Errorf("Expected CORSOrigins %v, got %v", want, cfg.CORSOrigins)
+	}
+	if want := []string{"10.0.0.0/8", "127.0.0.1"}; !slices.Equal(cfg.TrustedIPs, want) {
+		t.Errorf("Expected TrustedIPs %v, got %v", want, cfg.TrustedIPs)
+	}
+	if !cfg.AllowInsecureTLS {
+		t.Error("Expected AllowInsecureTLS to be true")
+	}
+	if cfg.AuthCacheTTL != 30*time.Second {
+		t.Errorf("Expected AuthCacheTTL 30s, got %v", cfg.AuthCacheTTL)
+	}
+	if cfg.AuthFailureLimit != 3 {
+		t.Errorf("Expected AuthFailureLimit 3, got %d", cfg.AuthFailureLimit)
+	}
+	if cfg.TLSMinVersion != "1.3" {
+		t.Errorf("Expected TLSMinVersion '1.3', got '%s'", cfg.TLSMinVersion)
+	}
+	if cfg.Log.File != "-" {
+		t.Errorf("Expected Log.File '-', got '%s'", cfg.Log.File)
+	}
+	if cfg.Log.MaxBackups != 2 {
+		t.Errorf("Expected Log.MaxBackups 2, got %d", cfg.Log.MaxBackups)
+	}
+	if !cfg.Log.Compress {
+		t.Error("Expected Log.Compress to be true")
+	}
+	if cfg.Log.Format != "json" {
+		t.Errorf("Expected Log.Format 'json', got '%s'", cfg.Log.Format)
+	}
+}
+
+func TestLoadConfig_FlagDefaults(t *testing.T) {
+	bindEnv()
+
+	cfg := loadConfig()
+
+	if cfg.DBType != "sqlite3" {
+		t.Errorf("Expected default DBType 'sqlite3', got '%s'", cfg.DBType)
+	}
+	if cfg.Port != 6910 {
+		t.Errorf("Expected default Port 6910, got %d", cfg.Port)
+	}
+	if cfg.AuthCacheTTL != 5*time.Minute {
+		t.Errorf("Expected default AuthCacheTTL 5m, got %v", cfg.AuthCacheTTL)
+	}
+	if want := []string{"127.0.0.1", "::1"}; !slices.Equal(cfg.TrustedIPs, want) {
+		t.Errorf("Expected default TrustedIPs %v, got %v", want, cfg.TrustedIPs)
+	}
+	if cfg.Log.Level != "info" {
+		t.Errorf("Expected default Log.Level 'info', got '%s'", cfg.Log.Level)
+	}
+}
+
+func TestGetStringSlice(t *testing.T) {
+	bindEnv()
+
+	tests := []struct {
+		name  string
+		value string
+		want  []string
+	}{
+		{"single", "127.0.0.1", []string{"127.0.0.1"}},
+		{"comma separated", "127.0.0.1,::1", []string{"127.0.0.1", "::1"}},
+		{"comma and space separated", "127.0.0.1, ::1 ,10.0.0.0/8", []string{"127.0.0.1", "::1", "10.0.0.0/8"}},
+		{"trailing comma", "127.0.0.1,", []string{"127.0.0.1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CA4M_API_SERVER_TRUSTED_IPS", tt.value)
+			if got := getStringSlice("server.trusted_ips"); !slices.Equal(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
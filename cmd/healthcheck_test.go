@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCheckHealth(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer healthy.Close()
+
+	unhealthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unhealthy.Close()
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer slow.Close()
+
+	if err := checkHealth(healthy.URL, time.Second, false); err != nil {
+		t.Errorf("Expected healthy server to pass, got %v", err)
+	}
+	if err := checkHealth(unhealthy.URL, time.Second, false); err == nil {
+		t.Error("Expected error for 503 response")
+	}
+	if err := checkHealth(slow.URL, 50*time.Millisecond, false); err == nil {
+		t.Error("Expected error when the server responds after the timeout")
+	}
+	if err := checkHealth("http://127.0.0.1:1/api/v1/health", time.Second, false); err == nil {
+		t.Error("Expected error when nothing is listening")
+	}
+}
+
+func TestCheckHealth_InsecureTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	if err := checkHealth(server.URL, time.Second, false); err == nil {
+		t.Error("Expected certificate verification to fail for a self-signed server")
+	}
+	if err := checkHealth(server.URL, time.Second, true); err != nil {
+		t.Errorf("Expected insecure TLS to accept a self-signed server, got %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/penwern/curate-preservation-api/database"
+	"github.com/penwern/curate-preservation-api/pkg/logger"
+	"github.com/spf13/cobra"
+)
+
+// migrateCmd represents the migrate command
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Database migration commands",
+	Long: `Commands for inspecting and applying database schema migrations.
+
+They use the same --db-type and --db-connection settings as serve, and let schema
+changes be applied or rolled back separately from starting the server.`,
+}
+
+// migrateUpCmd applies all pending migrations
+var migrateUpCmd = &cobra.Command{
+	Use:   "up",
+	Short: "Apply all pending migrations",
+	Args:  cobra.NoArgs,
+	Run: func(_ *cobra.Command, _ []string) {
+		withMigrationDB(func(db *database.Database) error {
+			if err := db.MigrateUp(); err != nil {
+				return err
+			}
+			logger.Info("Migrations applied")
+			return nil
+		})
+	},
+}
+
+// migrateDownCmd rolls back the last N migrations
+var migrateDownCmd = &cobra.Command{
+	Use:   "down N",
+	Short: "Roll back the last N migrations",
+	Args:  cobra.ExactArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		steps, err := strconv.Atoi(args[0])
+		if err != nil || steps < 1 {
+			logger.Error("Error: N must be a positive number of migrations, got '%s'", args[0])
+			os.Exit(1)
+		}
+
+		withMigrationDB(func(db *database.Database) error {
+			if err := db.MigrateDown(steps); err != nil {
+				return err
+			}
+			logger.Info("Rolled back %d migration(s)", steps)
+			return nil
+		})
+	},
+}
+
+// migrateVersionCmd prints the current schema version
+var migrateVersionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the current schema version and dirty state",
+	Args:  cobra.NoArgs,
+	Run: func(_ *cobra.Command, _ []string) {
+		withMigrationDB(func(db *database.Database) error {
+			version, dirty, err := db.MigrateVersion()
+			if err != nil {
+				return err
+			}
+			//nolint:forbidigo // Version output is meant for scripts and goes directly to stdout
+			fmt.Printf("Version: %d\nDirty:   %v\n", version, dirty)
+			return nil
+		})
+	},
+}
+
+// migrateForceCmd sets the schema version without running migrations
+var migrateForceCmd = &cobra.Command{
+	Use:   "force V",
+	Short: "Set the schema version without running migrations",
+	Long: `Set the schema version to V and clear the dirty flag without running any migration.
+
+Use this after manually repairing the schema following a failed migration.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		version, err := strconv.Atoi(args[0])
+		if err != nil || version < 0 {
+			logger.Error("Error: V must be a non-negative schema version, got '%s'", args[0])
+			os.Exit(1)
+		}
+
+		withMigrationDB(func(db *database.Database) error {
+			if err := db.MigrateForce(version); err != nil {
+				return err
+			}
+			logger.Info("Schema version forced to %d", version)
+			return nil
+		})
+	},
+}
+
+// withMigrationDB opens the configured database without migrating it, runs fn and closes it,
+// exiting with an error status if anything fails
+func withMigrationDB(fn func(db *database.Database) error) {
+	cfg := loadConfig()
+
+	db, err := database.Open(cfg.DBType, cfg.DBConnection)
+	if err != nil {
+		logger.Error("Error: Failed to open database: %v", err)
+		os.Exit(1)
+	}
+
+	runErr := fn(db)
+	if err := db.Close(); err != nil {
+		logger.Error("Failed to close database: %v", err)
+	}
+	if runErr != nil {
+		logger.Error("Error: %v", runErr)
+		os.Exit(1)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+	migrateCmd.AddCommand(migrateUpCmd)
+	migrateCmd.AddCommand(migrateDownCmd)
+	migrateCmd.AddCommand(migrateVersionCmd)
+	migrateCmd.AddCommand(migrateForceCmd)
+}
@@ -3,8 +3,9 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"strings"
+	"time"
 
+	"github.com/penwern/curate-preservation-api/models"
 	"github.com/penwern/curate-preservation-api/pkg/logger"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -16,10 +17,52 @@ var (
 	dbConn           string
 	port             int
 	siteDomain       string
+	siteDomains      []string
+	oidcAudience     string
+	corsOrigins      []string
+	corsMethods      []string
+	corsHeaders      []string
+	corsExposed      []string
+	corsMaxAge       time.Duration
+	corsAllowAll     bool
 	logLevel         string
 	logFilePath      string
+	logMaxSize       int
+	logMaxAge        int
+	logMaxBackups    int
+	logCompress      bool
+	logFormat        string
 	allowInsecureTLS bool
 	trustedIPs       []string
+	trustedProxies   []string
+	authCacheTTL     time.Duration
+	authFailLimit    int
+	authFailWindow   time.Duration
+	authRetries      int
+	authTimeout      time.Duration
+	authMaxIdle      int
+	apiKeys          []string
+	tlsCertFile      string
+	tlsKeyFile       string
+	tlsMinVersion    string
+	maxNameLength    int
+	maxDescLength    int
+	maxConfigs       int
+	strictCT         bool
+	strictJSON       bool
+	requestTimeout   time.Duration
+	bulkTimeout      time.Duration
+	maxConcurrent    int
+	queueTimeout     time.Duration
+	shutdownTimeout  time.Duration
+	basePath         string
+	healthAtRoot     bool
+	defaultCfgFile   string
+	bgMigrations     bool
+	skipMigrations   bool
+	webhookURL       string
+	webhookSecret    string
+	readOnly         bool
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -50,9 +93,51 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&dbConn, "db-connection", "preservation_configs.db", "database connection string")
 	rootCmd.PersistentFlags().IntVar(&port, "port", 6910, "port to run the server on")
 	rootCmd.PersistentFlags().StringVar(&siteDomain, "site-domain", "https://localhost:8080", "site domain for Pydio Cells OIDC and user endpoints")
+	rootCmd.PersistentFlags().StringSliceVar(&siteDomains, "site-domains", nil, "comma-separated list of further Cells site domains that requests may select with the X-Cells-Domain header or their Origin")
+	rootCmd.PersistentFlags().StringVar(&oidcAudience, "oidc-audience", "", "expected audience of JWT access tokens validated locally (empty skips the audience check)")
 	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "log level (debug, info, warn, error, fatal, panic)")
-	rootCmd.PersistentFlags().StringVar(&logFilePath, "log-file", "", "log file path (default is /var/log/curate/curate-preservation-api.log)")
+	rootCmd.PersistentFlags().StringVar(&logFilePath, "log-file", "", "log file path, or \"-\" to log to stdout only (default is /var/log/curate/curate-preservation-api.log)")
+	rootCmd.PersistentFlags().IntVar(&logMaxSize, "log-max-size", 100, "size in megabytes at which the log file is rotated")
+	rootCmd.PersistentFlags().IntVar(&logMaxAge, "log-max-age", 0, "days to keep rotated log files (0 keeps them regardless of age)")
+	rootCmd.PersistentFlags().IntVar(&logMaxBackups, "log-max-backups", 5, "number of rotated log files to keep (0 keeps them all)")
+	rootCmd.PersistentFlags().BoolVar(&logCompress, "log-compress", false, "gzip compress rotated log files")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "console", "log file format (console or json); stdout always uses console")
 	rootCmd.PersistentFlags().BoolVar(&allowInsecureTLS, "allow-insecure-tls", false, "allow insecure TLS connections when making OIDC/Pydio requests")
+	rootCmd.PersistentFlags().DurationVar(&authCacheTTL, "auth-cache-ttl", 5*time.Minute, "maximum time validated user info is cached (capped by token expiry)")
+	rootCmd.PersistentFlags().IntVar(&authFailLimit, "auth-failure-limit", 10, "failed authentication attempts allowed per client IP before it is throttled")
+	rootCmd.PersistentFlags().DurationVar(&authFailWindow, "auth-failure-window", time.Minute, "period over which failed authentication attempts are counted")
+	rootCmd.PersistentFlags().IntVar(&authRetries, "auth-retry-attempts", 3, "tries per OIDC or Pydio request that fails with a network error or 5xx (1 disables retries)")
+	rootCmd.PersistentFlags().DurationVar(&authTimeout, "auth-timeout", 10*time.Second, "time each OIDC or Pydio request may take")
+	rootCmd.PersistentFlags().IntVar(&authMaxIdle, "auth-max-idle-conns", 10, "idle connections kept open to each Cells host for reuse by token validation")
+	rootCmd.PersistentFlags().StringSliceVar(&apiKeys, "api-keys", nil, "comma-separated list of static API keys accepted via the X-API-Key header (plaintext or sha256:<hex digest>)")
+	rootCmd.PersistentFlags().StringVar(&tlsCertFile, "tls-cert-file", "", "PEM certificate file; with --tls-key-file, serve HTTPS instead of plaintext HTTP")
+	rootCmd.PersistentFlags().StringVar(&tlsKeyFile, "tls-key-file", "", "PEM private key file for --tls-cert-file")
+	rootCmd.PersistentFlags().StringVar(&tlsMinVersion, "tls-min-version", "1.2", "minimum TLS version to negotiate (1.2 or 1.3)")
+	rootCmd.PersistentFlags().IntVar(&maxNameLength, "max-name-length", models.DefaultMaxNameLength, "maximum number of characters in a config name")
+	rootCmd.PersistentFlags().IntVar(&maxDescLength, "max-description-length", models.DefaultMaxDescriptionLength, "maximum number of characters in a config description")
+	rootCmd.PersistentFlags().IntVar(&maxConfigs, "max-configs", 0, "maximum number of configs that may exist; creating more fails with 409 (0 is unlimited)")
+	rootCmd.PersistentFlags().BoolVar(&strictCT, "strict-content-type", false, "reject request bodies not sent as application/json or application/yaml with 415")
+	rootCmd.PersistentFlags().BoolVar(&strictJSON, "strict-json", false, "reject config create and update bodies with unknown top-level fields with 400")
+	rootCmd.PersistentFlags().DurationVar(&requestTimeout, "request-timeout", 5*time.Second, "time a request may take before it is cancelled with 503 Service Unavailable")
+	rootCmd.PersistentFlags().DurationVar(&bulkTimeout, "bulk-request-timeout", time.Minute, "time a bulk create, export or import may take before it is cancelled with 503 Service Unavailable")
+	rootCmd.PersistentFlags().IntVar(&maxConcurrent, "max-concurrent-requests", 0, "maximum authenticated requests served at once; more wait briefly, then get 503 with Retry-After (0 is unlimited)")
+	rootCmd.PersistentFlags().DurationVar(&queueTimeout, "concurrency-queue-timeout", time.Second, "time a request over --max-concurrent-requests waits for another to finish before it gets 503")
+	rootCmd.PersistentFlags().DurationVar(&shutdownTimeout, "shutdown-timeout", 15*time.Second, "time shutdown waits for in-flight requests to finish before closing their connections")
+	rootCmd.PersistentFlags().StringVar(&basePath, "base-path", "", "path prefix to mount the API under, e.g. /preservation serves /preservation/api/v1")
+	rootCmd.PersistentFlags().BoolVar(&healthAtRoot, "health-at-root", false, "also serve the health, ready and version endpoints at /api/v1 without the base path")
+	rootCmd.PersistentFlags().StringVar(&defaultCfgFile, "default-config-file", "", "JSON or YAML preservation config to seed as the default config of a new database")
+	rootCmd.PersistentFlags().BoolVar(&bgMigrations, "background-migrations", false, "start listening before database migrations finish, answering 503 with Retry-After until they do")
+	rootCmd.PersistentFlags().BoolVar(&skipMigrations, "skip-migrations", false, "don't migrate the database on startup; refuse to start unless the schema is already current (apply migrations with the migrate command)")
+	rootCmd.PersistentFlags().StringVar(&webhookURL, "webhook-url", "", "URL to POST config created, updated and deleted events to")
+	rootCmd.PersistentFlags().StringVar(&webhookSecret, "webhook-secret", "", "key for the HMAC-SHA256 X-Webhook-Signature header on webhook events")
+	rootCmd.PersistentFlags().BoolVar(&readOnly, "read-only", false, "start in read-only maintenance mode: reads are served, config writes get 503 Service Unavailable")
+	rootCmd.PersistentFlags().StringSliceVar(&corsOrigins, "cors-origins", nil, "comma-separated list of origins allowed to make CORS requests (default allows http and https localhost:8080)")
+	rootCmd.PersistentFlags().StringSliceVar(&corsMethods, "cors-methods", nil, "comma-separated list of methods allowed in CORS requests (default GET,POST,PUT,DELETE,OPTIONS)")
+	rootCmd.PersistentFlags().StringSliceVar(&corsHeaders, "cors-headers", nil, "comma-separated list of request headers allowed in CORS requests (default the headers the API reads)")
+	rootCmd.PersistentFlags().StringSliceVar(&corsExposed, "cors-exposed-headers", nil, "comma-separated list of response headers exposed to CORS requests (default Link,ETag,X-Total-Count)")
+	rootCmd.PersistentFlags().DurationVar(&corsMaxAge, "cors-max-age", 5*time.Minute, "how long browsers may cache a CORS preflight response")
+	rootCmd.PersistentFlags().BoolVar(&corsAllowAll, "cors-allow-all", false, "development only: allow CORS requests from every origin, without credentials")
+	rootCmd.PersistentFlags().StringSliceVar(&trustedProxies, "trusted-proxies", nil, "comma-separated list of proxy IP addresses/CIDR ranges whose X-Forwarded-For and X-Real-IP headers are believed")
 	rootCmd.PersistentFlags().StringSliceVar(&trustedIPs, "trusted-ips", []string{"127.0.0.1", "::1"}, "comma-separated list of trusted IP addresses/CIDR ranges that bypass authentication")
 
 	// Bind flags to viper
@@ -68,15 +153,141 @@ func init() {
 	if err := viper.BindPFlag("server.site_domain", rootCmd.PersistentFlags().Lookup("site-domain")); err != nil {
 		logger.Error("Failed to bind server.site_domain flag: %v", err)
 	}
+	if err := viper.BindPFlag("server.site_domains", rootCmd.PersistentFlags().Lookup("site-domains")); err != nil {
+		logger.Error("Failed to bind server.site_domains flag: %v", err)
+	}
+	if err := viper.BindPFlag("server.oidc_audience", rootCmd.PersistentFlags().Lookup("oidc-audience")); err != nil {
+		logger.Error("Failed to bind server.oidc_audience flag: %v", err)
+	}
 	if err := viper.BindPFlag("log.level", rootCmd.PersistentFlags().Lookup("log-level")); err != nil {
 		logger.Error("Failed to bind log.level flag: %v", err)
 	}
 	if err := viper.BindPFlag("log.file", rootCmd.PersistentFlags().Lookup("log-file")); err != nil {
 		logger.Error("Failed to bind log.file flag: %v", err)
 	}
+	if err := viper.BindPFlag("log.max_size", rootCmd.PersistentFlags().Lookup("log-max-size")); err != nil {
+		logger.Error("Failed to bind log.max_size flag: %v", err)
+	}
+	if err := viper.BindPFlag("log.max_age", rootCmd.PersistentFlags().Lookup("log-max-age")); err != nil {
+		logger.Error("Failed to bind log.max_age flag: %v", err)
+	}
+	if err := viper.BindPFlag("log.max_backups", rootCmd.PersistentFlags().Lookup("log-max-backups")); err != nil {
+		logger.Error("Failed to bind log.max_backups flag: %v", err)
+	}
+	if err := viper.BindPFlag("log.compress", rootCmd.PersistentFlags().Lookup("log-compress")); err != nil {
+		logger.Error("Failed to bind log.compress flag: %v", err)
+	}
+	if err := viper.BindPFlag("log.format", rootCmd.PersistentFlags().Lookup("log-format")); err != nil {
+		logger.Error("Failed to bind log.format flag: %v", err)
+	}
 	if err := viper.BindPFlag("server.allow_insecure_tls", rootCmd.PersistentFlags().Lookup("allow-insecure-tls")); err != nil {
 		logger.Error("Failed to bind server.allow_insecure_tls flag: %v", err)
 	}
+	if err := viper.BindPFlag("server.auth_cache_ttl", rootCmd.PersistentFlags().Lookup("auth-cache-ttl")); err != nil {
+		logger.Error("Failed to bind server.auth_cache_ttl flag: %v", err)
+	}
+	if err := viper.BindPFlag("server.auth_failure_limit", rootCmd.PersistentFlags().Lookup("auth-failure-limit")); err != nil {
+		logger.Error("Failed to bind server.auth_failure_limit flag: %v", err)
+	}
+	if err := viper.BindPFlag("server.auth_failure_window", rootCmd.PersistentFlags().Lookup("auth-failure-window")); err != nil {
+		logger.Error("Failed to bind server.auth_failure_window flag: %v", err)
+	}
+	if err := viper.BindPFlag("server.auth_retry_attempts", rootCmd.PersistentFlags().Lookup("auth-retry-attempts")); err != nil {
+		logger.Error("Failed to bind server.auth_retry_attempts flag: %v", err)
+	}
+	if err := viper.BindPFlag("server.auth_timeout", rootCmd.PersistentFlags().Lookup("auth-timeout")); err != nil {
+		logger.Error("Failed to bind server.auth_timeout flag: %v", err)
+	}
+	if err := viper.BindPFlag("server.auth_max_idle_conns", rootCmd.PersistentFlags().Lookup("auth-max-idle-conns")); err != nil {
+		logger.Error("Failed to bind server.auth_max_idle_conns flag: %v", err)
+	}
+	if err := viper.BindPFlag("server.api_keys", rootCmd.PersistentFlags().Lookup("api-keys")); err != nil {
+		logger.Error("Failed to bind server.api_keys flag: %v", err)
+	}
+	if err := viper.BindPFlag("server.tls_cert_file", rootCmd.PersistentFlags().Lookup("tls-cert-file")); err != nil {
+		logger.Error("Failed to bind server.tls_cert_file flag: %v", err)
+	}
+	if err := viper.BindPFlag("server.tls_key_file", rootCmd.PersistentFlags().Lookup("tls-key-file")); err != nil {
+		logger.Error("Failed to bind server.tls_key_file flag: %v", err)
+	}
+	if err := viper.BindPFlag("server.tls_min_version", rootCmd.PersistentFlags().Lookup("tls-min-version")); err != nil {
+		logger.Error("Failed to bind server.tls_min_version flag: %v", err)
+	}
+	if err := viper.BindPFlag("server.max_name_length", rootCmd.PersistentFlags().Lookup("max-name-length")); err != nil {
+		logger.Error("Failed to bind server.max_name_length flag: %v", err)
+	}
+	if err := viper.BindPFlag("server.max_description_length", rootCmd.PersistentFlags().Lookup("max-description-length")); err != nil {
+		logger.Error("Failed to bind server.max_description_length flag: %v", err)
+	}
+	if err := viper.BindPFlag("server.max_configs", rootCmd.PersistentFlags().Lookup("max-configs")); err != nil {
+		logger.Error("Failed to bind server.max_configs flag: %v", err)
+	}
+	if err := viper.BindPFlag("server.strict_content_type", rootCmd.PersistentFlags().Lookup("strict-content-type")); err != nil {
+		logger.Error("Failed to bind server.strict_content_type flag: %v", err)
+	}
+	if err := viper.BindPFlag("server.strict_json", rootCmd.PersistentFlags().Lookup("strict-json")); err != nil {
+		logger.Error("Failed to bind server.strict_json flag: %v", err)
+	}
+	if err := viper.BindPFlag("server.request_timeout", rootCmd.PersistentFlags().Lookup("request-timeout")); err != nil {
+		logger.Error("Failed to bind server.request_timeout flag: %v", err)
+	}
+	if err := viper.BindPFlag("server.bulk_request_timeout", rootCmd.PersistentFlags().Lookup("bulk-request-timeout")); err != nil {
+		logger.Error("Failed to bind server.bulk_request_timeout flag: %v", err)
+	}
+	if err := viper.BindPFlag("server.max_concurrent_requests", rootCmd.PersistentFlags().Lookup("max-concurrent-requests")); err != nil {
+		logger.Error("Failed to bind server.max_concurrent_requests flag: %v", err)
+	}
+	if err := viper.BindPFlag("server.concurrency_queue_timeout", rootCmd.PersistentFlags().Lookup("concurrency-queue-timeout")); err != nil {
+		logger.Error("Failed to bind server.concurrency_queue_timeout flag: %v", err)
+	}
+	if err := viper.BindPFlag("server.shutdown_timeout", rootCmd.PersistentFlags().Lookup("shutdown-timeout")); err != nil {
+		logger.Error("Failed to bind server.shutdown_timeout flag: %v", err)
+	}
+	if err := viper.BindPFlag("server.base_path", rootCmd.PersistentFlags().Lookup("base-path")); err != nil {
+		logger.Error("Failed to bind server.base_path flag: %v", err)
+	}
+	if err := viper.BindPFlag("server.health_at_root", rootCmd.PersistentFlags().Lookup("health-at-root")); err != nil {
+		logger.Error("Failed to bind server.health_at_root flag: %v", err)
+	}
+	if err := viper.BindPFlag("server.default_config_file", rootCmd.PersistentFlags().Lookup("default-config-file")); err != nil {
+		logger.Error("Failed to bind server.default_config_file flag: %v", err)
+	}
+	if err := viper.BindPFlag("server.background_migrations", rootCmd.PersistentFlags().Lookup("background-migrations")); err != nil {
+		logger.Error("Failed to bind server.background_migrations flag: %v", err)
+	}
+	if err := viper.BindPFlag("server.skip_migrations", rootCmd.PersistentFlags().Lookup("skip-migrations")); err != nil {
+		logger.Error("Failed to bind server.skip_migrations flag: %v", err)
+	}
+	if err := viper.BindPFlag("server.webhook_url", rootCmd.PersistentFlags().Lookup("webhook-url")); err != nil {
+		logger.Error("Failed to bind server.webhook_url flag: %v", err)
+	}
+	if err := viper.BindPFlag("server.webhook_secret", rootCmd.PersistentFlags().Lookup("webhook-secret")); err != nil {
+		logger.Error("Failed to bind server.webhook_secret flag: %v", err)
+	}
+	if err := viper.BindPFlag("server.read_only", rootCmd.PersistentFlags().Lookup("read-only")); err != nil {
+		logger.Error("Failed to bind server.read_only flag: %v", err)
+	}
+	if err := viper.BindPFlag("server.cors_origins", rootCmd.PersistentFlags().Lookup("cors-origins")); err != nil {
+		logger.Error("Failed to bind server.cors_origins flag: %v", err)
+	}
+	if err := viper.BindPFlag("server.cors_methods", rootCmd.PersistentFlags().Lookup("cors-methods")); err != nil {
+		logger.Error("Failed to bind server.cors_methods flag: %v", err)
+	}
+	if err := viper.BindPFlag("server.cors_headers", rootCmd.PersistentFlags().Lookup("cors-headers")); err != nil {
+		logger.Error("Failed to bind server.cors_headers flag: %v", err)
+	}
+	if err := viper.BindPFlag("server.cors_exposed_headers", rootCmd.PersistentFlags().Lookup("cors-exposed-headers")); err != nil {
+		logger.Error("Failed to bind server.cors_exposed_headers flag: %v", err)
+	}
+	if err := viper.BindPFlag("server.cors_max_age", rootCmd.PersistentFlags().Lookup("cors-max-age")); err != nil {
+		logger.Error("Failed to bind server.cors_max_age flag: %v", err)
+	}
+	if err := viper.BindPFlag("server.cors_allow_all", rootCmd.PersistentFlags().Lookup("cors-allow-all")); err != nil {
+		logger.Error("Failed to bind server.cors_allow_all flag: %v", err)
+	}
+	if err := viper.BindPFlag("server.trusted_proxies", rootCmd.PersistentFlags().Lookup("trusted-proxies")); err != nil {
+		logger.Error("Failed to bind server.trusted_proxies flag: %v", err)
+	}
 	if err := viper.BindPFlag("server.trusted_ips", rootCmd.PersistentFlags().Lookup("trusted-ips")); err != nil {
 		logger.Error("Failed to bind server.trusted_ips flag: %v", err)
 	}
@@ -99,20 +310,13 @@ func initConfig() {
 		viper.SetConfigName(".preservation-api")
 	}
 
-	viper.SetEnvPrefix("CA4M_API")
-	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
-	viper.AutomaticEnv()
+	bindEnv()
 
 	// If a config file is found, read it in.
 	if err := viper.ReadInConfig(); err == nil {
 		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 	}
 
-	// Initialize logger with the configured level and file path
-	logLevel := viper.GetString("log.level")
-	if logLevel == "" {
-		logLevel = "info"
-	}
-	logFilePath := viper.GetString("log.file")
-	logger.Initialize(logLevel, logFilePath)
+	// Initialize logger with the configured level, file path and rotation settings
+	logger.InitializeWithConfig(loadLogConfig())
 }
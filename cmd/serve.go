@@ -3,16 +3,16 @@ package cmd
 import (
 	"os"
 	"os/signal"
-	"strings"
 	"syscall"
 
-	"github.com/penwern/curate-preservation-api/pkg/config"
 	"github.com/penwern/curate-preservation-api/pkg/logger"
 	"github.com/penwern/curate-preservation-api/server"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
+// serveDryRun validates the configuration and database, then exits without listening
+var serveDryRun bool
+
 // serveCmd represents the serve command
 var serveCmd = &cobra.Command{
 	Use:   "serve",
@@ -20,7 +20,11 @@ var serveCmd = &cobra.Command{
 	Long: `Start the preservation API server with the specified configuration.
 	
 The server will listen on the configured port and handle REST API requests
-for managing preservation configurations and workflows.`,
+for managing preservation configurations and workflows.
+
+With --dry-run the server is set up as usual, connecting to the database and
+running migrations, and then exits without listening: 0 if everything is in
+order, or 1 on the first error. Use it to check a deployment before it takes traffic.`,
 	Run: func(_ *cobra.Command, _ []string) {
 		runServer()
 	},
@@ -28,34 +32,19 @@ for managing preservation configurations and workflows.`,
 
 func init() {
 	rootCmd.AddCommand(serveCmd)
-}
-
-// getStringSlice handles viper's limitation with comma-separated environment variables
-func getStringSlice(key string) []string {
-	slice := viper.GetStringSlice(key)
-
-	// If we got a slice with one element that contains commas, split it
-	if len(slice) == 1 && strings.Contains(slice[0], ",") {
-		parts := strings.Split(slice[0], ",")
-		result := make([]string, len(parts))
-		for i, part := range parts {
-			result[i] = strings.TrimSpace(part)
-		}
-		return result
-	}
-
-	return slice
+	serveCmd.Flags().BoolVar(&serveDryRun, "dry-run", false, "validate the configuration, connect to the database and run migrations, then exit without serving")
 }
 
 func runServer() {
 	// Load configuration from viper
-	cfg := config.Config{
-		DBType:           viper.GetString("db.type"),
-		DBConnection:     viper.GetString("db.connection"),
-		Port:             viper.GetInt("server.port"),
-		SiteDomain:       viper.GetString("server.site_domain"),
-		AllowInsecureTLS: viper.GetBool("server.allow_insecure_tls"),
-		TrustedIPs:       getStringSlice("server.trusted_ips"),
+	cfg := loadConfig()
+
+	// Log everything in effect, without secrets, so misconfiguration can be spotted
+	logger.GetLogger().Infow("Effective configuration", "config", cfg.Redacted())
+
+	if serveDryRun {
+		// Migration errors must be known before exiting
+		cfg.BackgroundMigrations = false
 	}
 
 	// Create and start the server
@@ -64,6 +53,14 @@ func runServer() {
 		logger.Fatal("Failed to create server: %v", err)
 	}
 
+	if serveDryRun {
+		if err := srv.Shutdown(); err != nil {
+			logger.Fatal("Server shutdown failed: %v", err)
+		}
+		logger.Info("Dry run succeeded: configuration and database are ready")
+		return
+	}
+
 	// Start the server in a goroutine
 	go func() {
 		logger.Info("===========================================")
@@ -1,34 +1,78 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
 	"runtime"
 
+	"github.com/penwern/curate-preservation-api/pkg/logger"
 	"github.com/penwern/curate-preservation-api/pkg/version"
 	"github.com/spf13/cobra"
 )
 
+var (
+	versionJSON   bool
+	versionOutput string
+)
+
+// versionInfo is the machine-readable form of the version command output
+type versionInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+	OSArch    string `json:"os_arch"`
+}
+
 // versionCmd represents the version command
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Print version information",
 	Long:  `Display version, build time, and commit information for the Curate Preservation API.`,
 	Run: func(_ *cobra.Command, _ []string) {
-		//nolint:forbidigo // Version command needs to output directly to stdout
-		fmt.Printf("Curate Preservation API\n")
-		//nolint:forbidigo // Version command needs to output directly to stdout
-		fmt.Printf("Version:    %s\n", version.Version())
-		//nolint:forbidigo // Version command needs to output directly to stdout
-		fmt.Printf("Git Commit: %s\n", version.Commit())
-		//nolint:forbidigo // Version command needs to output directly to stdout
-		fmt.Printf("Build Date: %s\n", version.BuildTime())
-		//nolint:forbidigo // Version command needs to output directly to stdout
-		fmt.Printf("Go Version: %s\n", runtime.Version())
-		//nolint:forbidigo // Version command needs to output directly to stdout
-		fmt.Printf("OS/Arch:    %s/%s\n", runtime.GOOS, runtime.GOARCH)
+		format := versionOutput
+		if versionJSON {
+			format = "json"
+		}
+		if err := printVersion(os.Stdout, format); err != nil {
+			logger.Error("Error: %v", err)
+			os.Exit(1)
+		}
 	},
 }
 
+// printVersion writes the version information to w as "text" or "json"
+func printVersion(w io.Writer, format string) error {
+	info := versionInfo{
+		Version:   version.Version(),
+		Commit:    version.Commit(),
+		BuildDate: version.BuildTime(),
+		GoVersion: runtime.Version(),
+		OSArch:    runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	switch format {
+	case "json":
+		return json.NewEncoder(w).Encode(info)
+	case "text", "":
+		_, err := fmt.Fprintf(w, "Curate Preservation API\n"+
+			"Version:    %s\n"+
+			"Git Commit: %s\n"+
+			"Build Date: %s\n"+
+			"Go Version: %s\n"+
+			"OS/Arch:    %s\n",
+			info.Version, info.Commit, info.BuildDate, info.GoVersion, info.OSArch)
+		return err
+	default:
+		return fmt.Errorf("unknown output format '%s', must be 'text' or 'json'", format)
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(versionCmd)
+
+	versionCmd.Flags().StringVarP(&versionOutput, "output", "o", "text", "output format (text or json)")
+	versionCmd.Flags().BoolVar(&versionJSON, "json", false, "output as JSON (same as --output json)")
 }
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/penwern/curate-preservation-api/pkg/version"
+)
+
+func TestPrintVersion_Text(t *testing.T) {
+	var buf bytes.Buffer
+	if err := printVersion(&buf, "text"); err != nil {
+		t.Fatalf("Failed to print version: %v", err)
+	}
+
+	output := buf.String()
+	for _, want := range []string{
+		"Curate Preservation API\n",
+		"Version:    " + version.Version() + "\n",
+		"Go Version: " + runtime.Version() + "\n",
+		"OS/Arch:    " + runtime.GOOS + "/" + runtime.GOARCH + "\n",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q, got %q", want, output)
+		}
+	}
+}
+
+func TestPrintVersion_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := printVersion(&buf, "json"); err != nil {
+		t.Fatalf("Failed to print version: %v", err)
+	}
+
+	var info map[string]string
+	if err := json.Unmarshal(buf.Bytes(), &info); err != nil {
+		t.Fatalf("Expected JSON output, got %q: %v", buf.String(), err)
+	}
+
+	if info["version"] != version.Version() {
+		t.Errorf("Expected version '%s', got '%s'", version.Version(), info["version"])
+	}
+	if info["os_arch"] != runtime.GOOS+"/"+runtime.GOARCH {
+		t.Errorf("Expected os_arch '%s/%s', got '%s'", runtime.GOOS, runtime.GOARCH, info["os_arch"])
+	}
+	for _, key := range []string{"commit", "build_date", "go_version"} {
+		if _, ok := info[key]; !ok {
+			t.Errorf("Expected '%s' in JSON output", key)
+		}
+	}
+}
+
+func TestPrintVersion_UnknownFormat(t *testing.T) {
+	var buf bytes.Buffer
+	if err := printVersion(&buf, "xml"); err == nil {
+		t.Error("Expected error for unknown output format")
+	}
+}
@@ -2,18 +2,27 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"embed"
 	"errors"
 	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
 
-	_ "github.com/go-sql-driver/mysql" // required for MySQL driver registration
+	mysqldriver "github.com/go-sql-driver/mysql" // also registers the MySQL driver
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database"
 	"github.com/golang-migrate/migrate/v4/database/mysql"
 	"github.com/golang-migrate/migrate/v4/database/sqlite3"
+	"github.com/golang-migrate/migrate/v4/source"
 	"github.com/golang-migrate/migrate/v4/source/iofs"
 	_ "github.com/mattn/go-sqlite3" // required for SQLite driver registration
+	"github.com/penwern/curate-preservation-api/pkg/clock"
+	"github.com/penwern/curate-preservation-api/pkg/config"
 	"github.com/penwern/curate-preservation-api/pkg/logger"
 )
 
@@ -24,6 +33,10 @@ const (
 	DBTypeMySQL = "mysql"
 )
 
+// connMaxLifetime is how long a pooled connection is reused before it is replaced, so that
+// connections broken by a database restart or an idle timeout are recycled
+const connMaxLifetime = 5 * time.Minute
+
 // Embed migration files
 //
 //go:embed migrations/sqlite3/*.sql
@@ -34,70 +47,229 @@ var mysqlMigrations embed.FS
 
 // Database represents a database connection
 type Database struct {
-	db     *sql.DB
-	dbType string
+	// mu guards db, which Ping replaces when the pool can no longer reach the database
+	mu         sync.RWMutex
+	db         *sql.DB
+	dbType     string
+	connString string
+	closed     bool
+	// maxConfigs caps the number of configs that creating more may leave; zero is unlimited
+	maxConfigs int64
+	// clock stamps configs' created_at and updated_at
+	clock clock.Clock
 }
 
-// New creates a new database connection
+// New creates a new database connection and applies any pending migrations
 func New(dbType, connString string) (*Database, error) {
+	database, err := Open(dbType, connString)
+	if err != nil {
+		return nil, err
+	}
+
+	// Run migrations
+	logger.Info("Running database migrations...")
+	if err := database.MigrateUp(); err != nil {
+		return nil, fmt.Errorf("failed to run migrations: %w", err)
+	}
+
+	logger.Info("Database migrations completed successfully")
+
+	database.WarnIfReadOnly(context.Background())
+	return database, nil
+}
+
+// Open creates a new database connection without running migrations
+func Open(dbType, connString string) (*Database, error) {
 	if dbType != DBTypeSQLite && dbType != DBTypeMySQL {
 		return nil, errors.New("unsupported database type, must be 'sqlite3' or 'mysql'")
 	}
+	if dbType == DBTypeMySQL {
+		dsn, err := normalizeMySQLDSN(connString)
+		if err != nil {
+			return nil, err
+		}
+		connString = dsn
+	}
+
+	// The connection string may hold a password, so only a redacted copy is logged
+	logger.Info("Connecting to %s database: %s", dbType, config.RedactDSN(connString))
+	db, err := openPool(context.Background(), dbType, connString)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Successfully connected to %s database", dbType)
+
+	return &Database{
+		db:         db,
+		dbType:     dbType,
+		connString: connString,
+		clock:      clock.UTC{},
+	}, nil
+}
 
-	logger.Info("Connecting to %s database: %s", dbType, connString)
+// SetMaxConfigs limits how many configs there may be: creating configs beyond the limit fails
+// with ErrQuotaExceeded, while configs that already exist are kept. Zero or less removes the
+// limit. Call it before the database is in use. The limit is a guardrail rather than a hard
+// guarantee: on MySQL, concurrent creates may each see room for their configs.
+func (d *Database) SetMaxConfigs(maxConfigs int) {
+	d.maxConfigs = int64(max(maxConfigs, 0))
+}
+
+// SetClock replaces the clock used for the timestamps written with configs, which is the
+// wall clock in UTC by default. Call it before the database is in use.
+func (d *Database) SetClock(c clock.Clock) {
+	d.clock = c
+}
+
+// openPool opens a connection pool and checks that the database can be reached
+func openPool(ctx context.Context, dbType, connString string) (*sql.DB, error) {
 	db, err := sql.Open(dbType, connString)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
+	if dbType == DBTypeSQLite && isMemoryDSN(connString) {
+		// Every connection to an in-memory database gets a database of its own, which is gone
+		// once the connection closes. Keep a single connection open for good so that every
+		// query sees the same data; database/sql queues callers for it, serializing access.
+		db.SetMaxOpenConns(1)
+		db.SetConnMaxLifetime(0)
+	} else {
+		db.SetConnMaxLifetime(connMaxLifetime)
+	}
 
 	// Test the connection
-	if err := db.Ping(); err != nil {
+	if err := db.PingContext(ctx); err != nil {
+		if closeErr := db.Close(); closeErr != nil {
+			logger.Error("Failed to close unreachable database pool: %v", closeErr)
+		}
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
+	return db, nil
+}
+
+// normalizeMySQLDSN checks that a MySQL connection string is well formed and turns on
+// parseTime, without which timestamps can't be scanned into time.Time. As with the driver
+// itself, times are read in UTC unless the DSN picks another location with loc. With UTC, the
+// session time zone is set to match unless the DSN sets time_zone, so that TIMESTAMP columns
+// store the times written rather than shifting them by the server's zone.
+func normalizeMySQLDSN(connString string) (string, error) {
+	cfg, err := mysqldriver.ParseDSN(connString)
+	if err != nil {
+		return "", fmt.Errorf("invalid MySQL connection string, expected user:password@tcp(host:3306)/dbname: %w", err)
+	}
+	if cfg.DBName == "" {
+		return "", errors.New("invalid MySQL connection string: missing database name, expected user:password@tcp(host:3306)/dbname")
+	}
+	if !cfg.ParseTime {
+		logger.Debug("Enabling parseTime on the MySQL connection string")
+		cfg.ParseTime = true
+	}
+	if _, ok := cfg.Params["time_zone"]; !ok && cfg.Loc == time.UTC {
+		if cfg.Params == nil {
+			cfg.Params = make(map[string]string)
+		}
+		cfg.Params["time_zone"] = "'+00:00'"
+	}
+	return cfg.FormatDSN(), nil
+}
+
+// isMemoryDSN reports whether a SQLite connection string opens an in-memory database,
+// such as ":memory:", "file::memory:" or "file:name?mode=memory"
+func isMemoryDSN(connString string) bool {
+	path, query, _ := strings.Cut(connString, "?")
+	if path == ":memory:" || path == "file::memory:" {
+		return true
+	}
+	values, err := url.ParseQuery(query)
+	return err == nil && values.Get("mode") == "memory"
+}
 
-	logger.Info("Successfully connected to %s database", dbType)
+// conn returns the current connection pool
+func (d *Database) conn() *sql.DB {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.db
+}
 
-	database := &Database{
-		db:     db,
-		dbType: dbType,
+// Ping checks that the database can be reached. Broken connections are normally replaced
+// by the pool itself; if the pool can no longer be used at all, Ping opens a fresh one so
+// that the server recovers once the database is back. An in-memory SQLite database is never
+// reopened, as a fresh pool would hold a new, empty database.
+func (d *Database) Ping(ctx context.Context) error {
+	pingErr := d.conn().PingContext(ctx)
+	if pingErr == nil {
+		return nil
 	}
 
-	// Run migrations
-	logger.Info("Running database migrations...")
-	if err := database.runMigrations(); err != nil {
-		return nil, fmt.Errorf("failed to run migrations: %w", err)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed || (d.dbType == DBTypeSQLite && isMemoryDSN(d.connString)) {
+		return pingErr
 	}
 
-	logger.Info("Database migrations completed successfully")
-	return database, nil
+	logger.Warn("Database ping failed, reconnecting: %v", pingErr)
+	db, err := openPool(ctx, d.dbType, d.connString)
+	if err != nil {
+		return errors.Join(pingErr, err)
+	}
+
+	old := d.db
+	d.db = db
+	// Closing waits for queries already running on the old pool, so don't hold up the caller
+	go func() {
+		if err := old.Close(); err != nil {
+			logger.Debug("Failed to close replaced database pool: %v", err)
+		}
+	}()
+
+	logger.Info("Reconnected to %s database", d.dbType)
+	return nil
 }
 
-// Close closes the database connection
+// Close closes the database connection. Ping does not reconnect a closed database.
 func (d *Database) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.closed = true
 	return d.db.Close()
 }
 
-// runMigrations runs all pending database migrations
-func (d *Database) runMigrations() error {
+// newMigrate creates a migrate instance over the embedded migrations for this database.
+// It must not be closed, as that would also close the shared connection.
+func (d *Database) newMigrate() (*migrate.Migrate, error) {
 	var driver database.Driver
 	var err error
 
 	switch d.dbType {
 	case DBTypeSQLite:
-		driver, err = sqlite3.WithInstance(d.db, &sqlite3.Config{})
+		driver, err = sqlite3.WithInstance(d.conn(), &sqlite3.Config{})
 		if err != nil {
-			return fmt.Errorf("failed to create sqlite3 driver: %w", err)
+			return nil, fmt.Errorf("failed to create sqlite3 driver: %w", err)
 		}
 	case DBTypeMySQL:
-		driver, err = mysql.WithInstance(d.db, &mysql.Config{})
+		driver, err = mysql.WithInstance(d.conn(), &mysql.Config{})
 		if err != nil {
-			return fmt.Errorf("failed to create mysql driver: %w", err)
+			return nil, fmt.Errorf("failed to create mysql driver: %w", err)
 		}
 	default:
-		return errors.New("unsupported database type for migrations")
+		return nil, errors.New("unsupported database type for migrations")
 	}
 
-	// Use embedded migrations
+	sourceDriver, err := d.migrationSource()
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := migrate.NewWithInstance("iofs", sourceDriver, d.dbType, driver)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migrate instance: %w", err)
+	}
+	return m, nil
+}
+
+// migrationSource returns the migrations embedded for this database's type
+func (d *Database) migrationSource() (source.Driver, error) {
 	var migrationFS embed.FS
 	var migrationPath string
 
@@ -108,21 +280,176 @@ func (d *Database) runMigrations() error {
 	case DBTypeMySQL:
 		migrationFS = mysqlMigrations
 		migrationPath = "migrations/mysql"
+	default:
+		return nil, errors.New("unsupported database type for migrations")
 	}
 
 	sourceDriver, err := iofs.New(migrationFS, migrationPath)
 	if err != nil {
-		return fmt.Errorf("failed to create iofs source driver: %w", err)
+		return nil, fmt.Errorf("failed to create iofs source driver: %w", err)
 	}
+	return sourceDriver, nil
+}
 
-	m, err := migrate.NewWithInstance("iofs", sourceDriver, d.dbType, driver)
+// latestMigration returns the version of the newest migration built into the binary
+func (d *Database) latestMigration() (uint, error) {
+	sourceDriver, err := d.migrationSource()
 	if err != nil {
-		return fmt.Errorf("failed to create migrate instance: %w", err)
+		return 0, err
+	}
+	defer sourceDriver.Close()
+
+	version, err := sourceDriver.First()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read migrations: %w", err)
+	}
+	for {
+		next, err := sourceDriver.Next(version)
+		if errors.Is(err, os.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to read migrations: %w", err)
+		}
+		version = next
 	}
+}
 
-	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
+// MigrateUp applies all pending migrations
+func (d *Database) MigrateUp() error {
+	return d.MigrateUpContext(context.Background())
+}
+
+// MigrateUpContext is like MigrateUp, but once ctx is done no further migrations are started.
+// The migration already running is left to finish, so that the schema isn't left dirty, and
+// the error returned then wraps ctx.Err().
+func (d *Database) MigrateUpContext(ctx context.Context) error {
+	m, err := d.newMigrate()
+	if err != nil {
+		return err
+	}
+
+	stop := context.AfterFunc(ctx, func() { m.GracefulStop <- true })
+	defer stop()
+
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
 		return fmt.Errorf("failed to run migrations: %w", err)
 	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("migrations stopped before finishing: %w", err)
+	}
+	return nil
+}
+
+// MigrateDown rolls back the given number of applied migrations
+func (d *Database) MigrateDown(steps int) error {
+	if steps < 1 {
+		return errors.New("number of migrations to roll back must be at least 1")
+	}
 
+	m, err := d.newMigrate()
+	if err != nil {
+		return err
+	}
+
+	if err := m.Steps(-steps); err != nil {
+		return fmt.Errorf("failed to roll back migrations: %w", err)
+	}
+	return nil
+}
+
+// MigrateVersion returns the current schema version and whether the last migration failed
+// part way, leaving the schema dirty. The version is 0 if no migration has been applied.
+func (d *Database) MigrateVersion() (uint, bool, error) {
+	m, err := d.newMigrate()
+	if err != nil {
+		return 0, false, err
+	}
+
+	version, dirty, err := m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, dirty, nil
+}
+
+// MigrationStatus describes the database schema against the migrations built into the binary
+type MigrationStatus struct {
+	// Version is the applied schema version, 0 if no migration has been applied
+	Version uint `json:"version"`
+	// Dirty is set when the last migration failed part way
+	Dirty bool `json:"dirty"`
+	// Latest is the version of the newest migration built into the binary
+	Latest uint `json:"latest"`
+}
+
+// Current reports whether the schema is clean and at the version the binary expects
+func (s MigrationStatus) Current() bool {
+	return !s.Dirty && s.Version == s.Latest
+}
+
+// MigrationStatus returns the schema version and dirty flag, as MigrateVersion does, along with
+// the latest version the binary has migrations for. It reads the version straight from the
+// migrations table, which is cheap and takes no migration lock, so it is safe to call while
+// serving requests.
+func (d *Database) MigrationStatus(ctx context.Context) (MigrationStatus, error) {
+	latest, err := d.latestMigration()
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+	status := MigrationStatus{Latest: latest}
+
+	var version int64
+	err = d.conn().QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &status.Dirty)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return MigrationStatus{}, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	status.Version = uint(version)
+	return status, nil
+}
+
+// ErrSchemaNotCurrent is returned by CheckSchema when the schema is not at the version the
+// binary expects
+var ErrSchemaNotCurrent = errors.New("database schema is not current")
+
+// CheckSchema verifies, without migrating, that the schema is clean and at the latest version
+// the binary has migrations for. The error returned otherwise wraps ErrSchemaNotCurrent and
+// says which migrate command brings the schema in line. Unlike MigrationStatus, it also works
+// on a database that has never been migrated.
+func (d *Database) CheckSchema() error {
+	latest, err := d.latestMigration()
+	if err != nil {
+		return err
+	}
+	version, dirty, err := d.MigrateVersion()
+	if err != nil {
+		return err
+	}
+	status := MigrationStatus{Version: version, Dirty: dirty, Latest: latest}
+	switch {
+	case status.Dirty:
+		return fmt.Errorf("%w: version %d is dirty after a failed migration; fix the schema and run 'migrate force'", ErrSchemaNotCurrent, status.Version)
+	case status.Version < status.Latest:
+		return fmt.Errorf("%w: version %d is behind version %d expected by this binary; run 'migrate up'", ErrSchemaNotCurrent, status.Version, status.Latest)
+	case status.Version > status.Latest:
+		return fmt.Errorf("%w: version %d is ahead of version %d expected by this binary; upgrade the binary or run 'migrate down'", ErrSchemaNotCurrent, status.Version, status.Latest)
+	}
+	return nil
+}
+
+// MigrateForce sets the schema version without running any migration and clears the dirty
+// flag. It is used to recover after manually fixing a failed migration.
+func (d *Database) MigrateForce(version int) error {
+	m, err := d.newMigrate()
+	if err != nil {
+		return err
+	}
+
+	if err := m.Force(version); err != nil {
+		return fmt.Errorf("failed to force schema version: %w", err)
+	}
 	return nil
 }
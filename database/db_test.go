@@ -1,12 +1,21 @@
 package database
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	transferservice "github.com/penwern/curate-preservation-api/common/proto/a3m/gen/go/a3m/api/transferservice/v1beta1"
 	"github.com/penwern/curate-preservation-api/models"
+	"github.com/penwern/curate-preservation-api/pkg/clock"
 	"github.com/penwern/curate-preservation-api/pkg/logger"
 )
 
@@ -49,6 +58,117 @@ func TestNew_SQLite(t *testing.T) {
 	}
 }
 
+func TestNew_SQLiteInMemory(t *testing.T) {
+	logger.Initialize("debug", "/tmp/curate-preservation-api.log")
+
+	db, err := New(testDBType, ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	config := models.NewPreservationConfig("In Memory", "")
+	if err := db.CreateConfig(config); err != nil {
+		t.Fatalf("CreateConfig failed: %v", err)
+	}
+
+	// Concurrent queries would each open a connection to a new, empty database if the
+	// pool were allowed more than one
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := db.GetConfig(config.ID)
+			if err == nil && got.Name != config.Name {
+				err = fmt.Errorf("expected name %q, got %q", config.Name, got.Name)
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Concurrent read of in-memory config failed: %v", err)
+		}
+	}
+
+	if stats := db.conn().Stats(); stats.MaxOpenConnections != 1 {
+		t.Errorf("Expected in-memory database to use a single connection, got max %d", stats.MaxOpenConnections)
+	}
+}
+
+func TestIsMemoryDSN(t *testing.T) {
+	tests := map[string]bool{
+		":memory:":                           true,
+		"file::memory:":                      true,
+		"file::memory:?cache=shared":         true,
+		"file:test?mode=memory&cache=shared": true,
+		"test.db":                            false,
+		"file:test.db?mode=rwc":              false,
+		"/var/lib/memory.db":                 false,
+	}
+	for dsn, want := range tests {
+		if got := isMemoryDSN(dsn); got != want {
+			t.Errorf("isMemoryDSN(%q) = %v, want %v", dsn, got, want)
+		}
+	}
+}
+
+func TestNormalizeMySQLDSN(t *testing.T) {
+	tests := []struct {
+		name    string
+		dsn     string
+		want    string
+		wantErr bool
+	}{
+		{name: "adds parseTime and time zone", dsn: "user:pass@tcp(db:3306)/preservation", want: "user:pass@tcp(db:3306)/preservation?parseTime=true&time_zone=%27%2B00%3A00%27"},
+		{name: "overrides parseTime=false", dsn: "user:pass@tcp(db:3306)/preservation?parseTime=false", want: "user:pass@tcp(db:3306)/preservation?parseTime=true&time_zone=%27%2B00%3A00%27"},
+		{name: "keeps time zone", dsn: "user:pass@tcp(db:3306)/preservation?time_zone=%27Europe%2FLondon%27", want: "user:pass@tcp(db:3306)/preservation?parseTime=true&time_zone=%27Europe%2FLondon%27"},
+		{name: "keeps other params", dsn: "user:pass@tcp(db:3306)/preservation?parseTime=true&loc=Local&timeout=5s", want: "user:pass@tcp(db:3306)/preservation?loc=Local&parseTime=true&timeout=5s"},
+		{name: "missing database", dsn: "user:pass@tcp(db:3306)/", wantErr: true},
+		{name: "malformed", dsn: "user:pass@db:3306/preservation", wantErr: true},
+		{name: "unknown param value", dsn: "user:pass@tcp(db:3306)/preservation?parseTime=maybe", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeMySQLDSN(tt.dsn)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("normalizeMySQLDSN(%q) error = %v, wantErr %v", tt.dsn, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("normalizeMySQLDSN(%q) = %q, want %q", tt.dsn, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpen_RedactsPasswordInLog(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "test.log")
+	logger.Initialize("info", logPath)
+	defer logger.Initialize("debug", "/tmp/curate-preservation-api.log")
+
+	// Nothing listens on the port, so connecting fails once the DSN has been logged
+	db, err := Open(DBTypeMySQL, "user:s3cret-pass@tcp(127.0.0.1:1)/preservation?timeout=100ms")
+	if err == nil {
+		db.Close()
+		t.Fatal("Expected connecting to a closed port to fail")
+	}
+
+	content, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	if !strings.Contains(string(content), "Connecting to mysql database") {
+		t.Fatalf("Expected the connection to be logged, got %s", content)
+	}
+	if strings.Contains(string(content), "s3cret-pass") {
+		t.Errorf("Expected the password to be redacted from the log, got %s", content)
+	}
+}
+
 func TestNew_UnsupportedDBType(t *testing.T) {
 	_, err := New("postgres", "connection-string")
 	if err == nil {
@@ -159,6 +279,197 @@ func TestDatabase_ListConfigs(t *testing.T) {
 	}
 }
 
+func TestDatabase_CreateConfigs(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	configs := []*models.PreservationConfig{
+		models.NewPreservationConfig("Bulk 1", "Description 1"),
+		models.NewPreservationConfig("Bulk 2", "Description 2"),
+	}
+
+	if err := db.CreateConfigs(configs); err != nil {
+		t.Fatalf("CreateConfigs failed: %v", err)
+	}
+
+	for _, config := range configs {
+		if config.ID == 0 {
+			t.Fatalf("Expected config %q to be assigned an ID", config.Name)
+		}
+		retrieved, err := db.GetConfig(config.ID)
+		if err != nil {
+			t.Fatalf("GetConfig failed: %v", err)
+		}
+		if retrieved.Name != config.Name {
+			t.Errorf("Expected name %s, got %s", config.Name, retrieved.Name)
+		}
+	}
+}
+
+func TestDatabase_CreateConfigs_RollsBackOnFailure(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	// Make the second insert fail
+	if _, err := db.conn().Exec(`CREATE TRIGGER fail_insert BEFORE INSERT ON preservation_configs
+		WHEN NEW.name = 'Fail' BEGIN SELECT RAISE(ABORT, 'forced failure'); END`); err != nil {
+		t.Fatalf("Failed to create trigger: %v", err)
+	}
+
+	configs := []*models.PreservationConfig{
+		models.NewPreservationConfig("Bulk 1", ""),
+		models.NewPreservationConfig("Fail", ""),
+	}
+	if err := db.CreateConfigs(configs); err == nil {
+		t.Fatal("Expected CreateConfigs to fail")
+	}
+
+	list, err := db.ListConfigs()
+	if err != nil {
+		t.Fatalf("ListConfigs failed: %v", err)
+	}
+	if len(list) != 1 {
+		t.Errorf("Expected only the default config after rollback, got %d configs", len(list))
+	}
+	if configs[0].ID != 0 {
+		t.Errorf("Expected rolled back config ID to be reset, got %d", configs[0].ID)
+	}
+}
+
+func TestDatabase_ImportConfigs(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	existing := models.NewPreservationConfig("Existing", "Original")
+	if err := db.CreateConfig(existing); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+
+	for _, upsert := range []bool{false, true} {
+		configs := []*models.PreservationConfig{
+			models.NewPreservationConfig("Existing", "Imported"),
+			models.NewPreservationConfig(fmt.Sprintf("New %v", upsert), ""),
+		}
+		actions, err := db.ImportConfigs(configs, upsert)
+		if err != nil {
+			t.Fatalf("ImportConfigs(upsert=%v) failed: %v", upsert, err)
+		}
+
+		expectedExisting := ImportSkipped
+		expectedDescription := "Original"
+		if upsert {
+			expectedExisting = ImportUpdated
+			expectedDescription = "Imported"
+		}
+		if actions[0] != expectedExisting || actions[1] != ImportCreated {
+			t.Errorf("upsert=%v: unexpected actions %v", upsert, actions)
+		}
+		if configs[0].ID != existing.ID {
+			t.Errorf("upsert=%v: expected matched ID %d, got %d", upsert, existing.ID, configs[0].ID)
+		}
+
+		retrieved, err := db.GetConfig(existing.ID)
+		if err != nil {
+			t.Fatalf("GetConfig failed: %v", err)
+		}
+		if retrieved.Description != expectedDescription {
+			t.Errorf("upsert=%v: expected description %q, got %q", upsert, expectedDescription, retrieved.Description)
+		}
+	}
+}
+
+func TestDatabase_MaxConfigs(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	// The migrations insert a default config, leaving room for two more
+	db.SetMaxConfigs(3)
+	if err := db.CreateConfig(models.NewPreservationConfig("First", "")); err != nil {
+		t.Fatalf("CreateConfig failed: %v", err)
+	}
+
+	bulk := []*models.PreservationConfig{
+		models.NewPreservationConfig("Bulk 1", ""),
+		models.NewPreservationConfig("Bulk 2", ""),
+	}
+	if err := db.CreateConfigs(bulk); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("Expected ErrQuotaExceeded for a bulk create over the limit, got %v", err)
+	}
+	if bulk[0].ID != 0 {
+		t.Errorf("Expected rolled back config ID to be reset, got %d", bulk[0].ID)
+	}
+
+	last := models.NewPreservationConfig("Last", "")
+	if err := db.CreateConfig(last); err != nil {
+		t.Fatalf("CreateConfig failed: %v", err)
+	}
+	over := models.NewPreservationConfig("Over", "")
+	if err := db.CreateConfig(over); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("Expected ErrQuotaExceeded at the limit, got %v", err)
+	}
+	if over.ID != 0 {
+		t.Errorf("Expected rolled back config ID to be reset, got %d", over.ID)
+	}
+
+	// Imports may still update existing configs, but not add new ones
+	if _, err := db.ImportConfigs([]*models.PreservationConfig{models.NewPreservationConfig("Last", "Updated")}, true); err != nil {
+		t.Errorf("Expected an update-only import to succeed at the limit, got %v", err)
+	}
+	if _, err := db.ImportConfigs([]*models.PreservationConfig{models.NewPreservationConfig("Imported", "")}, true); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected ErrQuotaExceeded for an import adding a config, got %v", err)
+	}
+
+	count, err := db.CountConfigs()
+	if err != nil {
+		t.Fatalf("CountConfigs failed: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 configs, got %d", count)
+	}
+
+	db.SetMaxConfigs(0)
+	if err := db.CreateConfig(models.NewPreservationConfig("Unlimited", "")); err != nil {
+		t.Errorf("Expected no limit after SetMaxConfigs(0), got %v", err)
+	}
+}
+
+func TestDatabase_MaxUpdatedAt(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	initial, err := db.MaxUpdatedAt()
+	if err != nil {
+		t.Fatalf("MaxUpdatedAt failed: %v", err)
+	}
+	if initial.IsZero() {
+		t.Fatal("Expected the default config to give a last modified time")
+	}
+
+	config := models.NewPreservationConfig("Latest", "")
+	if err := db.CreateConfig(config); err != nil {
+		t.Fatalf("CreateConfig failed: %v", err)
+	}
+	created, err := db.MaxUpdatedAt()
+	if err != nil {
+		t.Fatalf("MaxUpdatedAt failed: %v", err)
+	}
+	if !created.Equal(config.UpdatedAt) {
+		t.Errorf("Expected the new config's updated_at %v, got %v", config.UpdatedAt, created)
+	}
+
+	// Deleting leaves no updated_at behind, but still counts as a change
+	if err := db.DeleteConfig(config.ID); err != nil {
+		t.Fatalf("DeleteConfig failed: %v", err)
+	}
+	deleted, err := db.MaxUpdatedAt()
+	if err != nil {
+		t.Fatalf("MaxUpdatedAt failed: %v", err)
+	}
+	if !deleted.After(created) {
+		t.Errorf("Expected the deletion to move the last modified time past %v, got %v", created, deleted)
+	}
+}
+
 func TestDatabase_UpdateConfig(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
@@ -200,6 +511,47 @@ func TestDatabase_UpdateConfig(t *testing.T) {
 	}
 }
 
+func TestDatabase_UpdateConfig_VersionConflict(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	config := models.NewPreservationConfig("Versioned", "")
+	if err := db.CreateConfig(config); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+
+	// Two writers load the same version
+	first, err := db.GetConfig(config.ID)
+	if err != nil {
+		t.Fatalf("GetConfig failed: %v", err)
+	}
+	second, err := db.GetConfig(config.ID)
+	if err != nil {
+		t.Fatalf("GetConfig failed: %v", err)
+	}
+
+	first.Description = "first"
+	if err := db.UpdateConfig(first); err != nil {
+		t.Fatalf("First update failed: %v", err)
+	}
+	if first.Version != 2 {
+		t.Errorf("Expected version 2 after update, got %d", first.Version)
+	}
+
+	second.Description = "second"
+	if err := db.UpdateConfig(second); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("Expected ErrVersionConflict for stale update, got %v", err)
+	}
+
+	stored, err := db.GetConfig(config.ID)
+	if err != nil {
+		t.Fatalf("GetConfig failed: %v", err)
+	}
+	if stored.Description != "first" || stored.Version != 2 {
+		t.Errorf("Expected first update to survive, got description %q version %d", stored.Description, stored.Version)
+	}
+}
+
 func TestDatabase_UpdateConfig_NotFound(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
@@ -250,6 +602,116 @@ func TestDatabase_DeleteConfig_NotFound(t *testing.T) {
 	}
 }
 
+func TestDatabase_DeleteConfigIfVersion(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	config := models.NewPreservationConfig("To Delete", "Will be deleted")
+	if err := db.CreateConfig(config); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+	staleVersion := config.Version
+	config.Description = "Changed"
+	if err := db.UpdateConfig(config); err != nil {
+		t.Fatalf("Failed to update config: %v", err)
+	}
+
+	if err := db.DeleteConfigIfVersion(config.ID, staleVersion); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("Expected ErrVersionConflict for a stale version, got %v", err)
+	}
+	if _, err := db.GetConfig(config.ID); err != nil {
+		t.Fatalf("Expected the config to survive a stale delete: %v", err)
+	}
+
+	if err := db.DeleteConfigIfVersion(config.ID, config.Version); err != nil {
+		t.Fatalf("DeleteConfigIfVersion failed: %v", err)
+	}
+	if _, err := db.GetConfig(config.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after deletion, got %v", err)
+	}
+	if err := db.DeleteConfigIfVersion(config.ID, config.Version); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting a missing config, got %v", err)
+	}
+}
+
+func TestDatabase_DeleteConfigs(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	var ids []int64
+	for _, name := range []string{"First", "Second", "Kept"} {
+		config := models.NewPreservationConfig(name, "")
+		if err := db.CreateConfig(config); err != nil {
+			t.Fatalf("Failed to create config: %v", err)
+		}
+		ids = append(ids, config.ID)
+	}
+
+	deleted, notFound, err := db.DeleteConfigs([]int64{ids[1], 9999, ids[0]})
+	if err != nil {
+		t.Fatalf("DeleteConfigs failed: %v", err)
+	}
+	if !slices.Equal(deleted, []int64{ids[1], ids[0]}) {
+		t.Errorf("Expected %v deleted, got %v", []int64{ids[1], ids[0]}, deleted)
+	}
+	if !slices.Equal(notFound, []int64{9999}) {
+		t.Errorf("Expected [9999] not found, got %v", notFound)
+	}
+	if _, err := db.GetConfig(ids[0]); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after deletion, got %v", err)
+	}
+	if _, err := db.GetConfig(ids[2]); err != nil {
+		t.Errorf("Expected the unlisted config to be kept: %v", err)
+	}
+}
+
+func TestDatabase_SetConfigActive(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	retired := models.NewPreservationConfig("Retired", "Kept for audit")
+	current := models.NewPreservationConfig("Current", "")
+	for _, config := range []*models.PreservationConfig{retired, current} {
+		if err := db.CreateConfig(config); err != nil {
+			t.Fatalf("Failed to create config %s: %v", config.Name, err)
+		}
+	}
+
+	if err := db.SetConfigActive(retired.ID, false); err != nil {
+		t.Fatalf("SetConfigActive failed: %v", err)
+	}
+	got, err := db.GetConfig(retired.ID)
+	if err != nil {
+		t.Fatalf("Expected deactivated config to stay readable, got %v", err)
+	}
+	if got.Active {
+		t.Error("Expected config to be inactive")
+	}
+	if got.Version != retired.Version+1 {
+		t.Errorf("Expected version %d, got %d", retired.Version+1, got.Version)
+	}
+	if got.Description != "Kept for audit" {
+		t.Errorf("Expected other fields to be kept, got description %q", got.Description)
+	}
+
+	configs, err := db.FilterConfigs(ConfigFilter{Fields: map[string]bool{"active": true}}, "", "", 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to filter configs: %v", err)
+	}
+	for _, config := range configs {
+		if config.ID == retired.ID {
+			t.Error("Expected inactive config to be filtered out")
+		}
+	}
+	if !slices.Contains(configNames(configs), "Current") {
+		t.Errorf("Expected active config to be listed, got %v", configNames(configs))
+	}
+
+	if err := db.SetConfigActive(999, false); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for non-existent config, got %v", err)
+	}
+}
+
 func TestDatabase_Close(t *testing.T) {
 	tmpDir := t.TempDir()
 	dbPath := filepath.Join(tmpDir, "test.db")
@@ -328,4 +790,982 @@ func TestDatabase_ConfigWithCustomA3MValues(t *testing.T) {
 	if retrievedConfig.A3MConfig.ThumbnailMode != transferservice.ProcessingConfig_THUMBNAIL_MODE_DO_NOT_GENERATE {
 		t.Errorf("Expected ThumbnailMode DO_NOT_GENERATE, got %v", retrievedConfig.A3MConfig.ThumbnailMode)
 	}
+
+	if !retrievedConfig.A3MConfig.Equal(&config.A3MConfig) {
+		t.Error("Expected every A3M value to be preserved")
+	}
+}
+
+func TestDatabase_MigrateUpContextStopped(t *testing.T) {
+	logger.Initialize("debug", "/tmp/curate-preservation-api.log")
+
+	db, err := Open(testDBType, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := db.MigrateUpContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected migrating with a cancelled context to stop, got %v", err)
+	}
+	if version, dirty, err := db.MigrateVersion(); err != nil || version != 0 || dirty {
+		t.Errorf("Expected no migrations applied and a clean schema, got %d (dirty: %v, error: %v)", version, dirty, err)
+	}
+}
+
+func TestDatabase_Migrations(t *testing.T) {
+	logger.Initialize("debug", "/tmp/curate-preservation-api.log")
+
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	db, err := Open(testDBType, dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	version, dirty, err := db.MigrateVersion()
+	if err != nil {
+		t.Fatalf("Failed to read version of unmigrated database: %v", err)
+	}
+	if version != 0 || dirty {
+		t.Errorf("Expected version 0 and clean before migrating, got %d (dirty: %v)", version, dirty)
+	}
+	if err := db.CheckSchema(); !errors.Is(err, ErrSchemaNotCurrent) || !strings.Contains(err.Error(), "migrate up") {
+		t.Errorf("Expected an unmigrated schema to fail the check, suggesting migrate up, got %v", err)
+	}
+
+	if err := db.MigrateUp(); err != nil {
+		t.Fatalf("Failed to migrate up: %v", err)
+	}
+	latest, dirty, err := db.MigrateVersion()
+	if err != nil {
+		t.Fatalf("Failed to read version: %v", err)
+	}
+	if latest == 0 || dirty {
+		t.Fatalf("Expected a clean non-zero version after migrating, got %d (dirty: %v)", latest, dirty)
+	}
+
+	status, err := db.MigrationStatus(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to read migration status: %v", err)
+	}
+	if status.Version != latest || status.Latest != latest || !status.Current() {
+		t.Errorf("Expected a current schema at version %d, got %+v", latest, status)
+	}
+	if err := db.CheckSchema(); err != nil {
+		t.Errorf("Expected a migrated schema to pass the check, got %v", err)
+	}
+
+	// Migrating again is a no-op
+	if err := db.MigrateUp(); err != nil {
+		t.Errorf("Expected repeated migrate up to succeed, got %v", err)
+	}
+
+	if err := db.MigrateDown(2); err != nil {
+		t.Fatalf("Failed to migrate down: %v", err)
+	}
+	if version, _, _ := db.MigrateVersion(); version != latest-2 {
+		t.Errorf("Expected version %d after rolling back 2, got %d", latest-2, version)
+	}
+	if status, _ := db.MigrationStatus(context.Background()); status.Version != latest-2 || status.Latest != latest || status.Current() {
+		t.Errorf("Expected the rolled back schema to be behind version %d, got %+v", latest, status)
+	}
+	if err := db.CheckSchema(); !errors.Is(err, ErrSchemaNotCurrent) {
+		t.Errorf("Expected the rolled back schema to fail the check, got %v", err)
+	}
+
+	if err := db.MigrateDown(0); err == nil {
+		t.Error("Expected error when rolling back 0 migrations")
+	}
+
+	if err := db.MigrateForce(int(latest)); err != nil {
+		t.Fatalf("Failed to force version: %v", err)
+	}
+	if version, dirty, _ := db.MigrateVersion(); version != latest || dirty {
+		t.Errorf("Expected forced version %d and clean, got %d (dirty: %v)", latest, version, dirty)
+	}
+
+	// A schema newer than the binary, or one left dirty, fails the check too
+	if err := db.MigrateForce(int(latest) + 1); err != nil {
+		t.Fatalf("Failed to force version: %v", err)
+	}
+	if err := db.CheckSchema(); !errors.Is(err, ErrSchemaNotCurrent) || !strings.Contains(err.Error(), "ahead") {
+		t.Errorf("Expected a newer schema to fail the check, got %v", err)
+	}
+	if _, err := db.db.Exec("UPDATE schema_migrations SET version = ?, dirty = ?", latest, true); err != nil {
+		t.Fatalf("Failed to mark the schema dirty: %v", err)
+	}
+	if err := db.CheckSchema(); !errors.Is(err, ErrSchemaNotCurrent) || !strings.Contains(err.Error(), "dirty") {
+		t.Errorf("Expected a dirty schema to fail the check, got %v", err)
+	}
+}
+
+func TestDatabase_NullBooleansBackfilled(t *testing.T) {
+	logger.Initialize("debug", "/tmp/curate-preservation-api.log")
+
+	db, err := Open(testDBType, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	// Migrate to the schema before the NOT NULL constraints were added
+	if err := db.MigrateUp(); err != nil {
+		t.Fatalf("Failed to migrate up: %v", err)
+	}
+	latest, _, err := db.MigrateVersion()
+	if err != nil {
+		t.Fatalf("Failed to read version: %v", err)
+	}
+	if err := db.MigrateDown(int(latest) - 5); err != nil {
+		t.Fatalf("Failed to migrate down: %v", err)
+	}
+
+	// A row written before its columns existed
+	result, err := db.conn().Exec(`INSERT INTO preservation_configs (name, description, examine_contents, normalize, compress_aip) VALUES ('Legacy', '', NULL, NULL, NULL)`)
+	if err != nil {
+		t.Fatalf("Failed to insert row with NULL columns: %v", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		t.Fatalf("Failed to get inserted ID: %v", err)
+	}
+
+	if _, err := db.GetConfig(id); err == nil {
+		t.Fatal("Expected GetConfig to fail on NULL booleans before the migration")
+	}
+
+	if err := db.MigrateUp(); err != nil {
+		t.Fatalf("Failed to migrate up: %v", err)
+	}
+
+	config, err := db.GetConfig(id)
+	if err != nil {
+		t.Fatalf("GetConfig failed after migration: %v", err)
+	}
+	if config.A3MConfig.ExamineContents {
+		t.Error("Expected NULL examine_contents to be backfilled as false")
+	}
+	if !config.A3MConfig.Normalize {
+		t.Error("Expected NULL normalize to be backfilled as true")
+	}
+	if config.CompressAIP {
+		t.Error("Expected NULL compress_aip to be backfilled as false")
+	}
+
+	if _, err := db.conn().Exec(`INSERT INTO preservation_configs (name, normalize) VALUES ('Invalid', NULL)`); err == nil {
+		t.Error("Expected inserting a NULL boolean to fail after the migration")
+	}
+
+	// IDs keep increasing across the table rebuild
+	config = models.NewPreservationConfig("After Migration", "")
+	if err := db.CreateConfig(config); err != nil {
+		t.Fatalf("CreateConfig failed: %v", err)
+	}
+	if config.ID <= id {
+		t.Errorf("Expected ID greater than %d, got %d", id, config.ID)
+	}
+}
+
+func TestDatabase_ListConfigsSorted(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	for _, name := range []string{"Charlie", "Alpha", "Bravo"} {
+		if err := db.CreateConfig(models.NewPreservationConfig(name, "")); err != nil {
+			t.Fatalf("Failed to create config %s: %v", name, err)
+		}
+	}
+
+	names := func(configs []*models.PreservationConfig) []string {
+		result := make([]string, 0, len(configs))
+		for _, config := range configs {
+			result = append(result, config.Name)
+		}
+		return result
+	}
+
+	tests := []struct {
+		name      string
+		sortField string
+		order     string
+		limit     int
+		offset    int
+		want      []string
+	}{
+		{"default is id ascending", "", "", 0, 0, []string{"Default Configuration", "Charlie", "Alpha", "Bravo"}},
+		{"name ascending", "name", "asc", 0, 0, []string{"Alpha", "Bravo", "Charlie", "Default Configuration"}},
+		{"name descending", "name", "DESC", 0, 0, []string{"Default Configuration", "Charlie", "Bravo", "Alpha"}},
+		{"id descending", "id", "desc", 0, 0, []string{"Bravo", "Alpha", "Charlie", "Default Configuration"}},
+		{"limit", "name", "", 2, 0, []string{"Alpha", "Bravo"}},
+		{"limit and offset", "name", "", 2, 1, []string{"Bravo", "Charlie"}},
+		{"offset only", "name", "", 0, 3, []string{"Default Configuration"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configs, err := db.ListConfigsSorted(tt.sortField, tt.order, tt.limit, tt.offset)
+			if err != nil {
+				t.Fatalf("Failed to list configs: %v", err)
+			}
+			if got := names(configs); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	invalid := []struct {
+		sortField string
+		order     string
+		limit     int
+	}{
+		{"name; DROP TABLE preservation_configs", "", 0},
+		{"description", "", 0},
+		{"name", "sideways", 0},
+	}
+	for _, tt := range invalid {
+		if _, err := db.ListConfigsSorted(tt.sortField, tt.order, tt.limit, 0); !errors.Is(err, ErrInvalidSort) {
+			t.Errorf("Expected ErrInvalidSort for sort %q order %q limit %d, got %v", tt.sortField, tt.order, tt.limit, err)
+		}
+	}
+
+	if _, err := db.ListConfigsSorted("name", "", -1, 0); !errors.Is(err, ErrInvalidLimit) {
+		t.Errorf("Expected ErrInvalidLimit for negative limit, got %v", err)
+	}
+}
+
+func TestDatabase_ListConfigsAfter(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	for _, name := range []string{"Charlie", "Alpha", "Bravo"} {
+		if err := db.CreateConfig(models.NewPreservationConfig(name, "")); err != nil {
+			t.Fatalf("Failed to create config %s: %v", name, err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		afterID int64
+		limit   int
+		want    []int64
+	}{
+		{"from the start", 0, 0, []int64{1, 2, 3, 4}},
+		{"after an id", 2, 0, []int64{3, 4}},
+		{"limit", 1, 2, []int64{2, 3}},
+		{"past the end", 4, 10, []int64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configs, err := db.ListConfigsAfter(tt.afterID, tt.limit)
+			if err != nil {
+				t.Fatalf("Failed to list configs: %v", err)
+			}
+			ids := make([]int64, 0, len(configs))
+			for _, config := range configs {
+				ids = append(ids, config.ID)
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, ids)
+			}
+		})
+	}
+
+	if _, err := db.ListConfigsAfter(0, -1); !errors.Is(err, ErrInvalidLimit) {
+		t.Errorf("Expected ErrInvalidLimit for negative limit, got %v", err)
+	}
+}
+
+func TestDatabase_ContextCancelled(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := db.GetConfigContext(ctx, 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled from GetConfigContext, got %v", err)
+	}
+	if _, err := db.FilterConfigsContext(ctx, ConfigFilter{}, "", "", 0, 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled from FilterConfigsContext, got %v", err)
+	}
+	if err := db.CreateConfigsContext(ctx, []*models.PreservationConfig{models.NewPreservationConfig("Cancelled", "")}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled from CreateConfigsContext, got %v", err)
+	}
+}
+
+func TestDatabase_PingReconnects(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	config := models.NewPreservationConfig("Survives Reconnect", "")
+	if err := db.CreateConfig(config); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+
+	// Simulate losing the pool, as if the database had gone away
+	if err := db.conn().Close(); err != nil {
+		t.Fatalf("Failed to close pool: %v", err)
+	}
+	if _, err := db.GetConfig(config.ID); err == nil {
+		t.Fatal("Expected an error querying a closed pool")
+	}
+
+	if err := db.Ping(context.Background()); err != nil {
+		t.Fatalf("Expected Ping to reconnect, got %v", err)
+	}
+	if _, err := db.GetConfig(config.ID); err != nil {
+		t.Errorf("Expected queries to work after reconnecting, got %v", err)
+	}
+}
+
+func TestDatabase_PingAfterClose(t *testing.T) {
+	db := setupTestDB(t)
+	if err := db.Close(); err != nil {
+		t.Fatalf("Failed to close database: %v", err)
+	}
+
+	if err := db.Ping(context.Background()); err == nil {
+		t.Error("Expected Ping to fail on a closed database instead of reconnecting")
+	}
+}
+
+func TestDatabase_PingInMemoryDoesNotReconnect(t *testing.T) {
+	logger.Initialize("debug", "/tmp/curate-preservation-api.log")
+
+	db, err := New(testDBType, ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	pool := db.conn()
+	if err := pool.Close(); err != nil {
+		t.Fatalf("Failed to close pool: %v", err)
+	}
+
+	// Reconnecting would swap in a new, empty database, so the ping error is returned instead
+	if err := db.Ping(context.Background()); err == nil {
+		t.Error("Expected Ping to fail on a lost in-memory database instead of reconnecting")
+	}
+	if db.conn() != pool {
+		t.Error("Expected the in-memory pool not to be replaced")
+	}
+}
+
+func TestDatabase_CountConfigs(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	// The migrations seed the default config
+	count, err := db.CountConfigs()
+	if err != nil {
+		t.Fatalf("Failed to count configs: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 config, got %d", count)
+	}
+
+	if err := db.CreateConfig(models.NewPreservationConfig("Counted", "")); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+	if count, _ := db.CountConfigs(); count != 2 {
+		t.Errorf("Expected 2 configs, got %d", count)
+	}
+}
+
+func TestDatabase_FilterConfigs(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	normalized := models.NewPreservationConfig("Normalized", "")
+	normalized.A3MConfig.Normalize = true
+	normalized.A3MConfig.ExamineContents = false
+	unnormalized := models.NewPreservationConfig("Unnormalized", "")
+	unnormalized.A3MConfig.Normalize = false
+	unnormalized.A3MConfig.ExamineContents = false
+	examined := models.NewPreservationConfig("Examined", "")
+	examined.A3MConfig.Normalize = true
+	examined.A3MConfig.ExamineContents = true
+	for _, config := range []*models.PreservationConfig{normalized, unnormalized, examined} {
+		if err := db.CreateConfig(config); err != nil {
+			t.Fatalf("Failed to create config %s: %v", config.Name, err)
+		}
+	}
+
+	configs, err := db.FilterConfigs(ConfigFilter{Fields: map[string]bool{"normalize": true, "examine_contents": false}}, "name", "", 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to filter configs: %v", err)
+	}
+	var names []string
+	for _, config := range configs {
+		if !config.A3MConfig.Normalize || config.A3MConfig.ExamineContents {
+			t.Errorf("Config %s does not match the filters", config.Name)
+		}
+		names = append(names, config.Name)
+	}
+	if !slices.Contains(names, "Normalized") || slices.Contains(names, "Unnormalized") || slices.Contains(names, "Examined") {
+		t.Errorf("Unexpected filtered configs: %v", names)
+	}
+
+	count, err := db.CountFilteredConfigs(ConfigFilter{Fields: map[string]bool{"normalize": true, "examine_contents": false}})
+	if err != nil {
+		t.Fatalf("Failed to count filtered configs: %v", err)
+	}
+	if count != int64(len(configs)) {
+		t.Errorf("Expected filtered count %d, got %d", len(configs), count)
+	}
+
+	// Filters combine with paging
+	page, err := db.FilterConfigs(ConfigFilter{Fields: map[string]bool{"normalize": true}}, "name", "desc", 1, 0)
+	if err != nil {
+		t.Fatalf("Failed to filter configs with a limit: %v", err)
+	}
+	if len(page) != 1 || page[0].Name != "Normalized" {
+		t.Errorf("Expected first page to be [Normalized], got %d configs", len(page))
+	}
+
+	if _, err := db.FilterConfigs(ConfigFilter{Fields: map[string]bool{"name = name OR 1": true}}, "", "", 0, 0); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("Expected ErrInvalidFilter for an unknown field, got %v", err)
+	}
+}
+
+func TestDatabase_FilterConfigsByTag(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	legal := models.NewPreservationConfig("Legal", "")
+	legal.Tags = []string{"legal", "media"}
+	media := models.NewPreservationConfig("Media", "")
+	media.Tags = []string{"media"}
+	// "_" is a LIKE wildcard, so this mustn't match a filter on "a_b"
+	similar := models.NewPreservationConfig("Similar", "")
+	similar.Tags = []string{"a-b"}
+	for _, config := range []*models.PreservationConfig{legal, media, similar} {
+		if err := db.CreateConfig(config); err != nil {
+			t.Fatalf("Failed to create config %s: %v", config.Name, err)
+		}
+	}
+
+	names := func(tag string) []string {
+		t.Helper()
+		configs, err := db.FilterConfigs(ConfigFilter{Tag: tag}, "name", "", 0, 0)
+		if err != nil {
+			t.Fatalf("Failed to filter configs by tag %q: %v", tag, err)
+		}
+		var names []string
+		for _, config := range configs {
+			names = append(names, config.Name)
+		}
+		return names
+	}
+	if got := names("media"); !slices.Equal(got, []string{"Legal", "Media"}) {
+		t.Errorf("Expected [Legal Media] tagged media, got %v", got)
+	}
+	if got := names("legal"); !slices.Equal(got, []string{"Legal"}) {
+		t.Errorf("Expected [Legal] tagged legal, got %v", got)
+	}
+	if got := names("a_b"); len(got) != 0 {
+		t.Errorf("Expected no configs tagged a_b, got %v", got)
+	}
+	if count, err := db.CountFilteredConfigs(ConfigFilter{Tag: "media"}); err != nil || count != 2 {
+		t.Errorf("Expected 2 configs tagged media, got %d (%v)", count, err)
+	}
+
+	// Tags are read back and can be changed
+	retrieved, err := db.GetConfig(legal.ID)
+	if err != nil {
+		t.Fatalf("Failed to get config: %v", err)
+	}
+	if !slices.Equal(retrieved.Tags, []string{"legal", "media"}) {
+		t.Errorf("Expected tags [legal media], got %v", retrieved.Tags)
+	}
+	retrieved.Tags = nil
+	if err := db.UpdateConfig(retrieved); err != nil {
+		t.Fatalf("Failed to update config: %v", err)
+	}
+	if retrieved, err = db.GetConfig(legal.ID); err != nil || retrieved.Tags == nil || len(retrieved.Tags) != 0 {
+		t.Errorf("Expected cleared tags to read back empty, got %v (%v)", retrieved.Tags, err)
+	}
+}
+
+func TestDatabase_ListConfigsByDateRange(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	created := map[string]time.Time{
+		"December": time.Date(2023, 12, 31, 23, 59, 59, 0, time.UTC),
+		"January":  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		"Late":     time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC),
+		"February": time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+	}
+	for name, createdAt := range created {
+		config := models.NewPreservationConfig(name, "")
+		if err := db.CreateConfig(config); err != nil {
+			t.Fatalf("Failed to create config %s: %v", name, err)
+		}
+		if _, err := db.db.Exec("UPDATE preservation_configs SET created_at = ? WHERE id = ?", createdAt, config.ID); err != nil {
+			t.Fatalf("Failed to backdate config %s: %v", name, err)
+		}
+	}
+
+	names := func(after, before time.Time) []string {
+		t.Helper()
+		configs, err := db.ListConfigsByDateRange(after, before)
+		if err != nil {
+			t.Fatalf("Failed to list configs from %v to %v: %v", after, before, err)
+		}
+		var names []string
+		for _, config := range configs {
+			names = append(names, config.Name)
+		}
+		return names
+	}
+
+	january, february := created["January"], created["February"]
+	// The range includes its start but not its end, so consecutive months don't overlap
+	if got := names(january, february); !slices.Equal(got, []string{"January", "Late"}) {
+		t.Errorf("Expected [January Late] in January, got %v", got)
+	}
+	// The seeded default config was created now, so it is the only later one
+	if got := names(february, time.Time{}); !slices.Equal(got, []string{"February", "Default Configuration"}) {
+		t.Errorf("Expected [February Default Configuration] from February on, got %v", got)
+	}
+	if got := names(time.Time{}, january); !slices.Equal(got, []string{"December"}) {
+		t.Errorf("Expected [December] before January, got %v", got)
+	}
+	if count, err := db.CountFilteredConfigs(ConfigFilter{CreatedAfter: january, CreatedBefore: february}); err != nil || count != 2 {
+		t.Errorf("Expected 2 configs created in January, got %d (%v)", count, err)
+	}
+}
+
+func TestDatabase_ReadOnly(t *testing.T) {
+	logger.Initialize("debug", "/tmp/curate-preservation-api.log")
+
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := New(testDBType, dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	config := models.NewPreservationConfig("Existing", "")
+	if err := db.CreateConfig(config); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+	if err := db.CheckWritable(context.Background()); err != nil {
+		t.Errorf("Expected a writable database to pass the probe, got %v", err)
+	}
+	db.Close()
+
+	// mode=ro opens the file the way SQLite does when it lacks write permission
+	readOnly, err := New(testDBType, "file:"+dbPath+"?mode=ro")
+	if err != nil {
+		t.Fatalf("Expected a read-only database to open, got %v", err)
+	}
+	defer readOnly.Close()
+
+	if err := readOnly.CheckWritable(context.Background()); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Expected ErrReadOnly from the probe, got %v", err)
+	}
+	if _, err := readOnly.GetConfig(config.ID); err != nil {
+		t.Errorf("Expected configs to stay readable, got %v", err)
+	}
+	if err := readOnly.CreateConfig(models.NewPreservationConfig("New", "")); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Expected ErrReadOnly creating a config, got %v", err)
+	}
+	if err := readOnly.UpdateConfig(config); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Expected ErrReadOnly updating a config, got %v", err)
+	}
+}
+
+func TestDatabase_ForEachConfig(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	for _, name := range []string{"Second", "Third"} {
+		if err := db.CreateConfig(models.NewPreservationConfig(name, "")); err != nil {
+			t.Fatalf("Failed to create config %s: %v", name, err)
+		}
+	}
+
+	var ids []int64
+	err := db.ForEachConfig(func(config *models.PreservationConfig) error {
+		ids = append(ids, config.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to iterate configs: %v", err)
+	}
+	if !slices.Equal(ids, []int64{1, 2, 3}) {
+		t.Errorf("Expected configs 1, 2 and 3 in order, got %v", ids)
+	}
+
+	// The first error from the callback stops the iteration and is returned
+	errStop := errors.New("stop")
+	visited := 0
+	err = db.ForEachConfig(func(config *models.PreservationConfig) error {
+		visited++
+		if config.ID == 2 {
+			return errStop
+		}
+		return nil
+	})
+	if !errors.Is(err, errStop) {
+		t.Errorf("Expected the callback's error, got %v", err)
+	}
+	if visited != 2 {
+		t.Errorf("Expected the iteration to stop after 2 configs, visited %d", visited)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = db.ForEachConfigContext(ctx, func(*models.PreservationConfig) error {
+		t.Error("Expected no config to be read once the context is cancelled")
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestDatabase_Timestamps(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	// A clock in another zone, to check the stamps are written in UTC
+	createdAt := time.Date(2025, 3, 14, 9, 26, 53, 589000000, time.UTC)
+	fake := clock.NewFake(createdAt.In(time.FixedZone("UTC+2", 2*60*60)))
+	db.SetClock(fake)
+
+	config := models.NewPreservationConfig("Timestamped", "")
+	if err := db.CreateConfig(config); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+
+	created, err := db.GetConfig(config.ID)
+	if err != nil {
+		t.Fatalf("Failed to get config: %v", err)
+	}
+	if created.CreatedAt != createdAt || created.UpdatedAt != createdAt {
+		t.Errorf("Expected created_at and updated_at %v, got created %v updated %v", createdAt, created.CreatedAt, created.UpdatedAt)
+	}
+	if config.CreatedAt != createdAt || config.UpdatedAt != createdAt {
+		t.Errorf("Expected CreateConfig to set both timestamps to %v, got %v and %v", createdAt, config.CreatedAt, config.UpdatedAt)
+	}
+
+	fake.Advance(time.Minute)
+	created.Description = "Changed"
+	if err := db.UpdateConfig(created); err != nil {
+		t.Fatalf("Failed to update config: %v", err)
+	}
+
+	updated, err := db.GetConfig(config.ID)
+	if err != nil {
+		t.Fatalf("Failed to get updated config: %v", err)
+	}
+	if want := createdAt.Add(time.Minute); updated.UpdatedAt != want {
+		t.Errorf("Expected updated_at %v, got %v", want, updated.UpdatedAt)
+	}
+	if updated.CreatedAt != createdAt {
+		t.Errorf("Expected created_at to stay %v, got %v", createdAt, updated.CreatedAt)
+	}
+	if !updated.UpdatedAt.Equal(created.UpdatedAt) {
+		t.Errorf("Expected UpdateConfig to set UpdatedAt to the stored %v, got %v", updated.UpdatedAt, created.UpdatedAt)
+	}
+
+	fake.Advance(time.Minute)
+	if err := db.SetConfigActive(config.ID, false); err != nil {
+		t.Fatalf("Failed to deactivate config: %v", err)
+	}
+	deactivated, err := db.GetConfig(config.ID)
+	if err != nil {
+		t.Fatalf("Failed to get deactivated config: %v", err)
+	}
+	if want := createdAt.Add(2 * time.Minute); deactivated.UpdatedAt != want {
+		t.Errorf("Expected updated_at %v after deactivating, got %v", want, deactivated.UpdatedAt)
+	}
+
+}
+
+func TestDatabase_TimestampsUTC(t *testing.T) {
+	// Have the driver return times in another zone, as a MySQL DSN with loc=Local may
+	db, err := New(testDBType, "file:"+filepath.Join(t.TempDir(), "test.db")+"?_loc=Asia/Tokyo")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+
+	config := models.NewPreservationConfig("Zoned", "")
+	if err := db.CreateConfig(config); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+
+	stored, err := db.GetConfig(config.ID)
+	if err != nil {
+		t.Fatalf("Failed to get config: %v", err)
+	}
+	if stored.CreatedAt.Location() != time.UTC || stored.UpdatedAt.Location() != time.UTC {
+		t.Errorf("Expected UTC timestamps, got %v and %v", stored.CreatedAt, stored.UpdatedAt)
+	}
+	if !stored.CreatedAt.Equal(config.CreatedAt) {
+		t.Errorf("Expected the stored instant %v, got %v", config.CreatedAt, stored.CreatedAt)
+	}
+
+	data, err := json.Marshal(stored)
+	if err != nil {
+		t.Fatalf("Failed to marshal config: %v", err)
+	}
+	want := `"created_at":"` + config.CreatedAt.Format(time.RFC3339Nano) + `"`
+	if !strings.Contains(string(data), want) || !strings.HasSuffix(want, `Z"`) {
+		t.Errorf("Expected JSON to contain %s, got %s", want, data)
+	}
+}
+
+func TestDatabase_SearchConfigs(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	for _, c := range []struct{ name, description string }{
+		{"Images 100%", "Thumbnails for photographs"},
+		{"Audio", "Preserve audio_files as-is"},
+		{"Photographs", "Normalize and compress"},
+	} {
+		if err := db.CreateConfig(models.NewPreservationConfig(c.name, c.description)); err != nil {
+			t.Fatalf("CreateConfig failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		term     string
+		expected []string
+	}{
+		{"photo", []string{"Images 100%", "Photographs"}},
+		{"PHOTO normalize", []string{"Photographs"}},
+		{"100%", []string{"Images 100%"}},
+		{"audio_", []string{"Audio"}},
+		// Wildcards match literally
+		{"%", []string{"Images 100%"}},
+		{"_", []string{"Audio"}},
+		{"video", nil},
+	}
+	for _, tt := range tests {
+		configs, err := db.SearchConfigs(tt.term, SearchFields())
+		if err != nil {
+			t.Fatalf("SearchConfigs(%q) failed: %v", tt.term, err)
+		}
+		if names := configNames(configs); !equalNames(names, tt.expected) {
+			t.Errorf("SearchConfigs(%q): expected %v, got %v", tt.term, tt.expected, names)
+		}
+	}
+
+	if _, err := db.SearchConfigs("   ", nil); !errors.Is(err, ErrInvalidSearch) {
+		t.Errorf("Expected ErrInvalidSearch for a blank term, got %v", err)
+	}
+
+	fieldTests := []struct {
+		fields   []string
+		expected []string
+	}{
+		// Only the name is searched by default
+		{nil, []string{"Photographs"}},
+		{[]string{"name"}, []string{"Photographs"}},
+		{[]string{"description"}, []string{"Images 100%"}},
+		{[]string{"description", "name", "name"}, []string{"Images 100%", "Photographs"}},
+	}
+	for _, tt := range fieldTests {
+		configs, err := db.SearchConfigs("photo", tt.fields)
+		if err != nil {
+			t.Fatalf("SearchConfigs in %v failed: %v", tt.fields, err)
+		}
+		if names := configNames(configs); !equalNames(names, tt.expected) {
+			t.Errorf("SearchConfigs in %v: expected %v, got %v", tt.fields, tt.expected, names)
+		}
+	}
+	// Only allowlisted fields reach the query
+	for _, fields := range [][]string{{"tags"}, {"name", "name) OR (1=1"}, {""}} {
+		if _, err := db.SearchConfigs("photo", fields); !errors.Is(err, ErrUnknownSearchField) {
+			t.Errorf("Expected ErrUnknownSearchField for fields %q, got %v", fields, err)
+		}
+	}
+}
+
+func TestDatabase_FullTextSearch(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	images := models.NewPreservationConfig("Images", "Thumbnails for photographs")
+	for _, config := range []*models.PreservationConfig{
+		images,
+		models.NewPreservationConfig("Audio", "Preserve recordings"),
+		models.NewPreservationConfig("Photographs", "Normalize and compress"),
+	} {
+		if err := db.CreateConfig(config); err != nil {
+			t.Fatalf("CreateConfig failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		term     string
+		expected []string
+	}{
+		{"photo", []string{"Images", "Photographs"}},
+		{"Photographs, normalize!", []string{"Photographs"}},
+		// Operators are searched for as words
+		{"audio OR images", nil},
+		{"NOT", nil},
+		// Nothing to match in the index, so LIKE is used
+		{"!!", nil},
+	}
+	for _, tt := range tests {
+		configs, err := db.FullTextSearch(tt.term, SearchFields())
+		if err != nil {
+			t.Fatalf("FullTextSearch(%q) failed: %v", tt.term, err)
+		}
+		if names := configNames(configs); !equalNames(names, tt.expected) {
+			t.Errorf("FullTextSearch(%q): expected %v, got %v", tt.term, tt.expected, names)
+		}
+	}
+
+	// The index follows updates and deletes
+	images.Name = "Scans"
+	images.Description = "Flatbed scanner output"
+	if err := db.UpdateConfig(images); err != nil {
+		t.Fatalf("UpdateConfig failed: %v", err)
+	}
+	// Only the name is searched by default, still matching word prefixes
+	for _, term := range []string{"photo", "PHOTOGRAPHS"} {
+		if configs, err := db.FullTextSearch(term, nil); err != nil || !equalNames(configNames(configs), []string{"Photographs"}) {
+			t.Errorf("Expected a name-only search for %q to match [Photographs], got %v (%v)", term, configNames(configs), err)
+		}
+	}
+	if configs, _ := db.FullTextSearch("graphs", []string{"name"}); len(configs) != 0 {
+		t.Errorf("Expected a name-only search to match word prefixes, got %v", configNames(configs))
+	}
+	if configs, _ := db.FullTextSearch("flatbed", []string{"description"}); !equalNames(configNames(configs), []string{"Scans"}) {
+		t.Errorf("Expected a description-only search to match [Scans], got %v", configNames(configs))
+	}
+
+	if configs, _ := db.FullTextSearch("scanner", SearchFields()); !equalNames(configNames(configs), []string{"Scans"}) {
+		t.Errorf("Expected updated config to be found, got %v", configNames(configs))
+	}
+	if configs, _ := db.FullTextSearch("thumbnails", SearchFields()); len(configs) != 0 {
+		t.Errorf("Expected old description to be gone from the index, got %v", configNames(configs))
+	}
+	if err := db.DeleteConfig(images.ID); err != nil {
+		t.Fatalf("DeleteConfig failed: %v", err)
+	}
+	if configs, _ := db.FullTextSearch("scanner", SearchFields()); len(configs) != 0 {
+		t.Errorf("Expected deleted config to be gone from the index, got %v", configNames(configs))
+	}
+
+	// Without the index, search falls back to LIKE. The index is dropped directly, since
+	// migrating down past it would also drop columns added since.
+	if _, err := db.conn().Exec(`DROP TABLE preservation_configs_fts`); err != nil {
+		t.Fatalf("Failed to drop full-text index: %v", err)
+	}
+	configs, err := db.FullTextSearch("graphs", nil)
+	if err != nil {
+		t.Fatalf("FullTextSearch without index failed: %v", err)
+	}
+	if names := configNames(configs); !equalNames(names, []string{"Photographs"}) {
+		t.Errorf("Expected LIKE fallback to match substrings, got %v", names)
+	}
+}
+
+// configNames returns the names of configs, in order
+func configNames(configs []*models.PreservationConfig) []string {
+	var names []string
+	for _, config := range configs {
+		names = append(names, config.Name)
+	}
+	return names
+}
+
+// equalNames reports whether a and b hold the same names in the same order
+func equalNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDatabase_SeedDefaultConfig(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	houseStyle := models.NewPreservationConfig("House Style", "Our default")
+	houseStyle.A3MConfig.ExamineContents = true
+
+	// The untouched built-in default is replaced, keeping its ID
+	seeded, err := db.SeedDefaultConfig(houseStyle)
+	if err != nil {
+		t.Fatalf("SeedDefaultConfig failed: %v", err)
+	}
+	if !seeded {
+		t.Fatal("Expected the built-in default to be replaced")
+	}
+	configs, err := db.ListConfigs()
+	if err != nil {
+		t.Fatalf("ListConfigs failed: %v", err)
+	}
+	if len(configs) != 1 || configs[0].ID != 1 || configs[0].Name != "House Style" || !configs[0].A3MConfig.ExamineContents {
+		t.Fatalf("Expected only the seeded config with ID 1, got %+v", configs)
+	}
+
+	// Seeding again changes nothing
+	seeded, err = db.SeedDefaultConfig(models.NewPreservationConfig("House Style", "Changed"))
+	if err != nil {
+		t.Fatalf("Second SeedDefaultConfig failed: %v", err)
+	}
+	if seeded {
+		t.Error("Expected seeding to be a no-op once the default has been seeded")
+	}
+	if config, _ := db.GetConfig(1); config.Description != "Our default" {
+		t.Errorf("Expected the seeded config to be unchanged, got description %q", config.Description)
+	}
+}
+
+func TestDatabase_SeedDefaultConfig_EmptyOrInUse(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	// An edited built-in default is left alone
+	builtIn, err := db.GetConfig(1)
+	if err != nil {
+		t.Fatalf("GetConfig failed: %v", err)
+	}
+	builtIn.Description = "Edited"
+	if err := db.UpdateConfig(builtIn); err != nil {
+		t.Fatalf("UpdateConfig failed: %v", err)
+	}
+	if seeded, err := db.SeedDefaultConfig(models.NewPreservationConfig("House Style", "")); err != nil || seeded {
+		t.Errorf("Expected an edited default not to be replaced, got seeded %v, err %v", seeded, err)
+	}
+
+	// An empty table is seeded
+	if err := db.DeleteConfig(1); err != nil {
+		t.Fatalf("DeleteConfig failed: %v", err)
+	}
+	houseStyle := models.NewPreservationConfig("House Style", "")
+	if seeded, err := db.SeedDefaultConfig(houseStyle); err != nil || !seeded {
+		t.Fatalf("Expected an empty table to be seeded, got seeded %v, err %v", seeded, err)
+	}
+	if count, _ := db.CountConfigs(); count != 1 {
+		t.Errorf("Expected 1 config, got %d", count)
+	}
+	if houseStyle.ID == 0 {
+		t.Error("Expected the seeded config to be assigned an ID")
+	}
 }
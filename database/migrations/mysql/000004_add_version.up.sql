@@ -0,0 +1,3 @@
+-- +migrate Up
+ALTER TABLE preservation_configs
+ADD COLUMN version INT NOT NULL DEFAULT 1;
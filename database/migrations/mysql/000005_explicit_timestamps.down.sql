@@ -0,0 +1,3 @@
+-- +migrate Down
+ALTER TABLE preservation_configs
+MODIFY updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP;
@@ -0,0 +1,4 @@
+-- +migrate Up
+-- updated_at is now set by the application so both backends behave the same
+ALTER TABLE preservation_configs
+MODIFY updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP;
@@ -0,0 +1,17 @@
+-- +migrate Down
+ALTER TABLE preservation_configs
+    MODIFY assign_uuids_to_directories BOOLEAN DEFAULT TRUE,
+    MODIFY examine_contents BOOLEAN DEFAULT FALSE,
+    MODIFY generate_transfer_structure_report BOOLEAN DEFAULT TRUE,
+    MODIFY document_empty_directories BOOLEAN DEFAULT TRUE,
+    MODIFY extract_packages BOOLEAN DEFAULT TRUE,
+    MODIFY delete_packages_after_extraction BOOLEAN DEFAULT FALSE,
+    MODIFY identify_transfer BOOLEAN DEFAULT TRUE,
+    MODIFY identify_submission_and_metadata BOOLEAN DEFAULT TRUE,
+    MODIFY identify_before_normalization BOOLEAN DEFAULT TRUE,
+    MODIFY normalize BOOLEAN DEFAULT TRUE,
+    MODIFY transcribe_files BOOLEAN DEFAULT TRUE,
+    MODIFY perform_policy_checks_on_originals BOOLEAN DEFAULT TRUE,
+    MODIFY perform_policy_checks_on_preservation_derivatives BOOLEAN DEFAULT TRUE,
+    MODIFY perform_policy_checks_on_access_derivatives BOOLEAN DEFAULT TRUE,
+    MODIFY compress_aip BOOLEAN DEFAULT FALSE;
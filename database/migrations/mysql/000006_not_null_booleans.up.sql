@@ -0,0 +1,34 @@
+-- +migrate Up
+-- Rows written before a column existed could hold NULL, which can't be scanned into a bool
+UPDATE preservation_configs SET assign_uuids_to_directories = TRUE WHERE assign_uuids_to_directories IS NULL;
+UPDATE preservation_configs SET examine_contents = FALSE WHERE examine_contents IS NULL;
+UPDATE preservation_configs SET generate_transfer_structure_report = TRUE WHERE generate_transfer_structure_report IS NULL;
+UPDATE preservation_configs SET document_empty_directories = TRUE WHERE document_empty_directories IS NULL;
+UPDATE preservation_configs SET extract_packages = TRUE WHERE extract_packages IS NULL;
+UPDATE preservation_configs SET delete_packages_after_extraction = FALSE WHERE delete_packages_after_extraction IS NULL;
+UPDATE preservation_configs SET identify_transfer = TRUE WHERE identify_transfer IS NULL;
+UPDATE preservation_configs SET identify_submission_and_metadata = TRUE WHERE identify_submission_and_metadata IS NULL;
+UPDATE preservation_configs SET identify_before_normalization = TRUE WHERE identify_before_normalization IS NULL;
+UPDATE preservation_configs SET normalize = TRUE WHERE normalize IS NULL;
+UPDATE preservation_configs SET transcribe_files = TRUE WHERE transcribe_files IS NULL;
+UPDATE preservation_configs SET perform_policy_checks_on_originals = TRUE WHERE perform_policy_checks_on_originals IS NULL;
+UPDATE preservation_configs SET perform_policy_checks_on_preservation_derivatives = TRUE WHERE perform_policy_checks_on_preservation_derivatives IS NULL;
+UPDATE preservation_configs SET perform_policy_checks_on_access_derivatives = TRUE WHERE perform_policy_checks_on_access_derivatives IS NULL;
+UPDATE preservation_configs SET compress_aip = FALSE WHERE compress_aip IS NULL;
+
+ALTER TABLE preservation_configs
+    MODIFY assign_uuids_to_directories BOOLEAN NOT NULL DEFAULT TRUE,
+    MODIFY examine_contents BOOLEAN NOT NULL DEFAULT FALSE,
+    MODIFY generate_transfer_structure_report BOOLEAN NOT NULL DEFAULT TRUE,
+    MODIFY document_empty_directories BOOLEAN NOT NULL DEFAULT TRUE,
+    MODIFY extract_packages BOOLEAN NOT NULL DEFAULT TRUE,
+    MODIFY delete_packages_after_extraction BOOLEAN NOT NULL DEFAULT FALSE,
+    MODIFY identify_transfer BOOLEAN NOT NULL DEFAULT TRUE,
+    MODIFY identify_submission_and_metadata BOOLEAN NOT NULL DEFAULT TRUE,
+    MODIFY identify_before_normalization BOOLEAN NOT NULL DEFAULT TRUE,
+    MODIFY normalize BOOLEAN NOT NULL DEFAULT TRUE,
+    MODIFY transcribe_files BOOLEAN NOT NULL DEFAULT TRUE,
+    MODIFY perform_policy_checks_on_originals BOOLEAN NOT NULL DEFAULT TRUE,
+    MODIFY perform_policy_checks_on_preservation_derivatives BOOLEAN NOT NULL DEFAULT TRUE,
+    MODIFY perform_policy_checks_on_access_derivatives BOOLEAN NOT NULL DEFAULT TRUE,
+    MODIFY compress_aip BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,3 @@
+-- +migrate Down
+ALTER TABLE preservation_configs
+DROP INDEX ft_preservation_configs_name_description;
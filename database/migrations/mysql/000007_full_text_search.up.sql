@@ -0,0 +1,3 @@
+-- +migrate Up
+ALTER TABLE preservation_configs
+ADD FULLTEXT INDEX ft_preservation_configs_name_description (name, description);
@@ -0,0 +1,3 @@
+-- +migrate Up
+ALTER TABLE preservation_configs
+ADD COLUMN active BOOLEAN NOT NULL DEFAULT TRUE;
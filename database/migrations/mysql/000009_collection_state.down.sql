@@ -0,0 +1,3 @@
+-- +migrate Down
+DROP INDEX idx_preservation_configs_updated_at ON preservation_configs;
+DROP TABLE IF EXISTS preservation_configs_state;
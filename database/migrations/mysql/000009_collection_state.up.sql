@@ -0,0 +1,10 @@
+-- +migrate Up
+-- A single row recording when a config was last deleted, which leaves no updated_at behind,
+-- so that the list endpoint can tell when the collection last changed
+CREATE TABLE IF NOT EXISTS preservation_configs_state (
+    id INT PRIMARY KEY,
+    last_deleted_at TIMESTAMP(6) NULL
+);
+INSERT INTO preservation_configs_state (id) VALUES (1);
+
+CREATE INDEX idx_preservation_configs_updated_at ON preservation_configs (updated_at);
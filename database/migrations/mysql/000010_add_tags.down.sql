@@ -0,0 +1,3 @@
+-- +migrate Down
+ALTER TABLE preservation_configs
+DROP COLUMN tags;
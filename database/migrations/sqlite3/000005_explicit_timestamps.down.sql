@@ -0,0 +1,6 @@
+-- +migrate Down
+CREATE TRIGGER IF NOT EXISTS update_preservation_configs_updated_at
+AFTER UPDATE ON preservation_configs
+BEGIN
+    UPDATE preservation_configs SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
@@ -0,0 +1,3 @@
+-- +migrate Up
+-- updated_at is now set by the application so both backends behave the same
+DROP TRIGGER IF EXISTS update_preservation_configs_updated_at;
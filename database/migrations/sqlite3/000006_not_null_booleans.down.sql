@@ -0,0 +1,65 @@
+-- +migrate Down
+-- Restores the nullable columns; NULLs backfilled on the way up are kept
+CREATE TABLE preservation_configs_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    description TEXT,
+    assign_uuids_to_directories BOOLEAN DEFAULT TRUE,
+    examine_contents BOOLEAN DEFAULT FALSE,
+    generate_transfer_structure_report BOOLEAN DEFAULT TRUE,
+    document_empty_directories BOOLEAN DEFAULT TRUE,
+    extract_packages BOOLEAN DEFAULT TRUE,
+    delete_packages_after_extraction BOOLEAN DEFAULT FALSE,
+    identify_transfer BOOLEAN DEFAULT TRUE,
+    identify_submission_and_metadata BOOLEAN DEFAULT TRUE,
+    identify_before_normalization BOOLEAN DEFAULT TRUE,
+    normalize BOOLEAN DEFAULT TRUE,
+    transcribe_files BOOLEAN DEFAULT TRUE,
+    perform_policy_checks_on_originals BOOLEAN DEFAULT TRUE,
+    perform_policy_checks_on_preservation_derivatives BOOLEAN DEFAULT TRUE,
+    perform_policy_checks_on_access_derivatives BOOLEAN DEFAULT TRUE,
+    thumbnail_mode INT DEFAULT 1,
+    aip_compression_level INT DEFAULT 1,
+    aip_compression_algorithm INT DEFAULT 5,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    compress_aip BOOLEAN DEFAULT FALSE,
+    version INTEGER NOT NULL DEFAULT 1
+);
+
+INSERT INTO preservation_configs_new (
+    id, name, description, assign_uuids_to_directories, examine_contents, generate_transfer_structure_report, document_empty_directories, extract_packages, delete_packages_after_extraction, identify_transfer, identify_submission_and_metadata, identify_before_normalization, normalize, transcribe_files, perform_policy_checks_on_originals, perform_policy_checks_on_preservation_derivatives, perform_policy_checks_on_access_derivatives, thumbnail_mode, aip_compression_level, aip_compression_algorithm, created_at, updated_at, compress_aip, version
+) SELECT
+    id,
+    name,
+    description,
+    assign_uuids_to_directories,
+    examine_contents,
+    generate_transfer_structure_report,
+    document_empty_directories,
+    extract_packages,
+    delete_packages_after_extraction,
+    identify_transfer,
+    identify_submission_and_metadata,
+    identify_before_normalization,
+    normalize,
+    transcribe_files,
+    perform_policy_checks_on_originals,
+    perform_policy_checks_on_preservation_derivatives,
+    perform_policy_checks_on_access_derivatives,
+    thumbnail_mode,
+    aip_compression_level,
+    aip_compression_algorithm,
+    created_at,
+    updated_at,
+    compress_aip,
+    version
+FROM preservation_configs;
+
+-- Keep the AUTOINCREMENT high-water mark so deleted IDs are not reused
+UPDATE sqlite_sequence
+SET seq = (SELECT seq FROM sqlite_sequence WHERE name = 'preservation_configs')
+WHERE name = 'preservation_configs_new';
+
+DROP TABLE preservation_configs;
+ALTER TABLE preservation_configs_new RENAME TO preservation_configs;
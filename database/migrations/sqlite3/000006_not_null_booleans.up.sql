@@ -0,0 +1,67 @@
+-- +migrate Up
+-- Rows written before a column existed could hold NULL, which can't be scanned into a bool.
+-- SQLite can't alter a column's constraints, so the table is rebuilt with NULLs replaced
+-- by the column defaults.
+CREATE TABLE preservation_configs_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    description TEXT,
+    assign_uuids_to_directories BOOLEAN NOT NULL DEFAULT TRUE,
+    examine_contents BOOLEAN NOT NULL DEFAULT FALSE,
+    generate_transfer_structure_report BOOLEAN NOT NULL DEFAULT TRUE,
+    document_empty_directories BOOLEAN NOT NULL DEFAULT TRUE,
+    extract_packages BOOLEAN NOT NULL DEFAULT TRUE,
+    delete_packages_after_extraction BOOLEAN NOT NULL DEFAULT FALSE,
+    identify_transfer BOOLEAN NOT NULL DEFAULT TRUE,
+    identify_submission_and_metadata BOOLEAN NOT NULL DEFAULT TRUE,
+    identify_before_normalization BOOLEAN NOT NULL DEFAULT TRUE,
+    normalize BOOLEAN NOT NULL DEFAULT TRUE,
+    transcribe_files BOOLEAN NOT NULL DEFAULT TRUE,
+    perform_policy_checks_on_originals BOOLEAN NOT NULL DEFAULT TRUE,
+    perform_policy_checks_on_preservation_derivatives BOOLEAN NOT NULL DEFAULT TRUE,
+    perform_policy_checks_on_access_derivatives BOOLEAN NOT NULL DEFAULT TRUE,
+    thumbnail_mode INT DEFAULT 1,
+    aip_compression_level INT DEFAULT 1,
+    aip_compression_algorithm INT DEFAULT 5,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    compress_aip BOOLEAN NOT NULL DEFAULT FALSE,
+    version INTEGER NOT NULL DEFAULT 1
+);
+
+INSERT INTO preservation_configs_new (
+    id, name, description, assign_uuids_to_directories, examine_contents, generate_transfer_structure_report, document_empty_directories, extract_packages, delete_packages_after_extraction, identify_transfer, identify_submission_and_metadata, identify_before_normalization, normalize, transcribe_files, perform_policy_checks_on_originals, perform_policy_checks_on_preservation_derivatives, perform_policy_checks_on_access_derivatives, thumbnail_mode, aip_compression_level, aip_compression_algorithm, created_at, updated_at, compress_aip, version
+) SELECT
+    id,
+    name,
+    description,
+    COALESCE(assign_uuids_to_directories, TRUE),
+    COALESCE(examine_contents, FALSE),
+    COALESCE(generate_transfer_structure_report, TRUE),
+    COALESCE(document_empty_directories, TRUE),
+    COALESCE(extract_packages, TRUE),
+    COALESCE(delete_packages_after_extraction, FALSE),
+    COALESCE(identify_transfer, TRUE),
+    COALESCE(identify_submission_and_metadata, TRUE),
+    COALESCE(identify_before_normalization, TRUE),
+    COALESCE(normalize, TRUE),
+    COALESCE(transcribe_files, TRUE),
+    COALESCE(perform_policy_checks_on_originals, TRUE),
+    COALESCE(perform_policy_checks_on_preservation_derivatives, TRUE),
+    COALESCE(perform_policy_checks_on_access_derivatives, TRUE),
+    thumbnail_mode,
+    aip_compression_level,
+    aip_compression_algorithm,
+    created_at,
+    updated_at,
+    COALESCE(compress_aip, FALSE),
+    version
+FROM preservation_configs;
+
+-- Keep the AUTOINCREMENT high-water mark so deleted IDs are not reused
+UPDATE sqlite_sequence
+SET seq = (SELECT seq FROM sqlite_sequence WHERE name = 'preservation_configs')
+WHERE name = 'preservation_configs_new';
+
+DROP TABLE preservation_configs;
+ALTER TABLE preservation_configs_new RENAME TO preservation_configs;
@@ -0,0 +1,6 @@
+-- +migrate Down
+DROP TRIGGER IF EXISTS preservation_configs_fts_after_insert;
+DROP TRIGGER IF EXISTS preservation_configs_fts_after_update;
+DROP TRIGGER IF EXISTS preservation_configs_fts_before_delete;
+DROP TRIGGER IF EXISTS preservation_configs_fts_before_update;
+DROP TABLE IF EXISTS preservation_configs_fts;
@@ -0,0 +1,34 @@
+-- +migrate Up
+-- Full-text index over name and description, kept in sync by triggers. FTS4 is used as it is
+-- compiled into the driver by default, whereas FTS5 needs the sqlite_fts5 build tag.
+CREATE VIRTUAL TABLE IF NOT EXISTS preservation_configs_fts USING fts4(
+    content="preservation_configs",
+    name,
+    description
+);
+
+INSERT INTO preservation_configs_fts(preservation_configs_fts) VALUES('rebuild');
+
+CREATE TRIGGER IF NOT EXISTS preservation_configs_fts_before_update
+BEFORE UPDATE ON preservation_configs
+BEGIN
+    DELETE FROM preservation_configs_fts WHERE docid = OLD.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS preservation_configs_fts_before_delete
+BEFORE DELETE ON preservation_configs
+BEGIN
+    DELETE FROM preservation_configs_fts WHERE docid = OLD.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS preservation_configs_fts_after_update
+AFTER UPDATE ON preservation_configs
+BEGIN
+    INSERT INTO preservation_configs_fts(docid, name, description) VALUES (NEW.id, NEW.name, NEW.description);
+END;
+
+CREATE TRIGGER IF NOT EXISTS preservation_configs_fts_after_insert
+AFTER INSERT ON preservation_configs
+BEGIN
+    INSERT INTO preservation_configs_fts(docid, name, description) VALUES (NEW.id, NEW.name, NEW.description);
+END;
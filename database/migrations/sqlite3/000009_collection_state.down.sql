@@ -0,0 +1,3 @@
+-- +migrate Down
+DROP INDEX IF EXISTS idx_preservation_configs_updated_at;
+DROP TABLE IF EXISTS preservation_configs_state;
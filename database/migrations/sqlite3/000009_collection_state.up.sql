@@ -0,0 +1,10 @@
+-- +migrate Up
+-- A single row recording when a config was last deleted, which leaves no updated_at behind,
+-- so that the list endpoint can tell when the collection last changed
+CREATE TABLE IF NOT EXISTS preservation_configs_state (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    last_deleted_at TIMESTAMP NULL
+);
+INSERT INTO preservation_configs_state (id) VALUES (1);
+
+CREATE INDEX IF NOT EXISTS idx_preservation_configs_updated_at ON preservation_configs (updated_at);
@@ -0,0 +1,4 @@
+-- +migrate Up
+-- Tags are stored as a sorted JSON array of strings, e.g. ["legal","media"]
+ALTER TABLE preservation_configs
+ADD COLUMN tags VARCHAR(1024) NOT NULL DEFAULT '[]';
@@ -0,0 +1,69 @@
+// Package database – detecting a database that can be read but not written
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/penwern/curate-preservation-api/pkg/logger"
+)
+
+// ErrReadOnly is returned when a write fails because the database only allows reading,
+// such as a SQLite file on a read-only filesystem or a MySQL server started with read_only
+var ErrReadOnly = errors.New("database is read-only")
+
+// MySQL error numbers for writes refused by a read-only server
+const (
+	mysqlErrOptionPreventsStatement = 1290 // ER_OPTION_PREVENTS_STATEMENT, e.g. --read-only
+	mysqlErrReadOnlyTransaction     = 1792 // ER_CANT_EXECUTE_IN_READ_ONLY_TRANSACTION
+)
+
+// isReadOnlyError reports whether err is the driver's error for a write to a read-only database
+func isReadOnlyError(err error) bool {
+	if isSQLiteReadOnlyError(err) {
+		return true
+	}
+	var mysqlErr *mysqldriver.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlErrOptionPreventsStatement || mysqlErr.Number == mysqlErrReadOnlyTransaction
+	}
+	return false
+}
+
+// readOnlyError wraps err in ErrReadOnly if the database refused a write for being read-only,
+// keeping the driver's message for the logs. Other errors are returned as they are.
+func readOnlyError(err error) error {
+	if err != nil && isReadOnlyError(err) {
+		return fmt.Errorf("%w: %v", ErrReadOnly, err)
+	}
+	return err
+}
+
+// CheckWritable probes whether the database accepts writes by updating the migrations table
+// in a transaction that is always rolled back, so nothing is changed. It returns ErrReadOnly
+// if the database can only be read. SQLite in particular opens a file it cannot write without
+// complaint, and only fails once something is written.
+func (d *Database) CheckWritable(ctx context.Context) error {
+	tx, err := d.conn().BeginTx(ctx, nil)
+	if err != nil {
+		return readOnlyError(err)
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE schema_migrations SET dirty = dirty`)
+	if rbErr := tx.Rollback(); rbErr != nil {
+		logger.Error("Failed to roll back write probe: %v", rbErr)
+	}
+	return readOnlyError(err)
+}
+
+// WarnIfReadOnly logs a warning if CheckWritable finds the database can't be written, so that
+// an operator learns of it at startup rather than from the first failed write
+func (d *Database) WarnIfReadOnly(ctx context.Context) {
+	if err := d.CheckWritable(ctx); errors.Is(err, ErrReadOnly) {
+		logger.Warn("The %s database appears to be read-only; configs can be read but creating or changing them will fail: %v", d.dbType, err)
+	} else if err != nil {
+		logger.Warn("Failed to check whether the database is writable: %v", err)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build cgo
+
+package database
+
+import (
+	"errors"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// isSQLiteReadOnlyError reports whether err is SQLite's error for a write to a read-only database
+func isSQLiteReadOnlyError(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrReadonly
+}
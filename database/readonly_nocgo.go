@@ -0,0 +1,9 @@
+//go:build !cgo
+
+package database
+
+// isSQLiteReadOnlyError reports false: without cgo the SQLite driver is a stub that can't
+// open a database, so it never reports a read-only one
+func isSQLiteReadOnlyError(error) bool {
+	return false
+}
@@ -1,8 +1,15 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/penwern/curate-preservation-api/models"
 	"github.com/penwern/curate-preservation-api/pkg/logger"
@@ -11,8 +18,169 @@ import (
 // ErrNotFound is returned when a preservation config is not found in the database
 var ErrNotFound = errors.New("preservation config not found")
 
+// ErrInvalidSort is returned when a list is requested with an unknown sort field or order
+var ErrInvalidSort = errors.New("invalid sort")
+
+// ErrInvalidLimit is returned when a list is requested with a negative limit or offset
+var ErrInvalidLimit = errors.New("invalid limit")
+
+// ErrInvalidFilter is returned when a list is filtered on an unknown field
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// ErrVersionConflict is returned when an update was based on a stale version of a config
+var ErrVersionConflict = errors.New("preservation config was modified concurrently")
+
+// ErrQuotaExceeded is returned when creating configs would exceed the limit set by SetMaxConfigs
+var ErrQuotaExceeded = errors.New("preservation config limit reached")
+
+// execer is satisfied by both *sql.DB and *sql.Tx so statements can run in or out of a transaction
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// withTx runs fn inside a transaction, committing if it returns nil and rolling back otherwise
+func (d *Database) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := d.conn().BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			logger.Error("Failed to roll back transaction: %v", rbErr)
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
 // CreateConfig creates a new preservation configuration in the database
 func (d *Database) CreateConfig(config *models.PreservationConfig) error {
+	return d.CreateConfigContext(context.Background(), config)
+}
+
+// CreateConfigContext is like CreateConfig, but the query is cancelled when ctx is done
+func (d *Database) CreateConfigContext(ctx context.Context, config *models.PreservationConfig) error {
+	err := d.withTx(ctx, func(tx *sql.Tx) error {
+		if err := d.createConfig(ctx, tx, config); err != nil {
+			return err
+		}
+		return d.checkQuota(ctx, tx)
+	})
+	if err != nil {
+		// The ID assigned before the rollback doesn't exist
+		config.ID = 0
+	}
+	return err
+}
+
+// checkQuota returns ErrQuotaExceeded if the configs visible to tx, including any it has just
+// inserted, exceed the configured limit. Checking after the inserts lets a single count cover
+// however many configs were created.
+func (d *Database) checkQuota(ctx context.Context, tx *sql.Tx) error {
+	if d.maxConfigs == 0 {
+		return nil
+	}
+	var count int64
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM preservation_configs`).Scan(&count); err != nil {
+		return fmt.Errorf("failed to count preservation configs: %w", err)
+	}
+	if count > d.maxConfigs {
+		return fmt.Errorf("%w: at most %d configs are allowed", ErrQuotaExceeded, d.maxConfigs)
+	}
+	return nil
+}
+
+// builtInDefaultName is the name of the default config inserted by the migrations
+const builtInDefaultName = "Default Configuration"
+
+// SeedDefaultConfig stores config as the initial default config of a new database. It is
+// inserted when there are no configs, or replaces the built-in default inserted by the
+// migrations if that is the only config and has never been updated. Otherwise nothing is
+// changed, so seeding again at every startup is harmless. It reports whether config was stored.
+func (d *Database) SeedDefaultConfig(config *models.PreservationConfig) (bool, error) {
+	return d.SeedDefaultConfigContext(context.Background(), config)
+}
+
+// SeedDefaultConfigContext is like SeedDefaultConfig, but the query is cancelled when ctx is done
+func (d *Database) SeedDefaultConfigContext(ctx context.Context, config *models.PreservationConfig) (bool, error) {
+	seeded := false
+	err := d.withTx(ctx, func(tx *sql.Tx) error {
+		var count int64
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM preservation_configs`).Scan(&count); err != nil {
+			return fmt.Errorf("failed to count preservation configs: %w", err)
+		}
+
+		switch count {
+		case 0:
+			if err := d.createConfig(ctx, tx, config); err != nil {
+				return err
+			}
+		case 1:
+			var id int64
+			err := tx.QueryRowContext(ctx, `SELECT id FROM preservation_configs WHERE name = ? AND version = 1`, builtInDefaultName).Scan(&id)
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("failed to look up built-in default config: %w", err)
+			}
+			config.ID = id
+			config.Version = 1
+			if err := d.updateConfig(ctx, tx, config); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+		seeded = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	if seeded {
+		logger.Info("Seeded default preservation config '%s' (ID: %d)", config.Name, config.ID)
+	}
+	return seeded, nil
+}
+
+// CreateConfigs creates several preservation configurations in a single transaction.
+// Either all configs are created and assigned IDs, or none are.
+func (d *Database) CreateConfigs(configs []*models.PreservationConfig) error {
+	return d.CreateConfigsContext(context.Background(), configs)
+}
+
+// CreateConfigsContext is like CreateConfigs, but the query is cancelled when ctx is done
+func (d *Database) CreateConfigsContext(ctx context.Context, configs []*models.PreservationConfig) error {
+	logger.Debug("Creating %d preservation configs", len(configs))
+
+	err := d.withTx(ctx, func(tx *sql.Tx) error {
+		for i, config := range configs {
+			if err := d.createConfig(ctx, tx, config); err != nil {
+				return fmt.Errorf("failed to create config %d (%s): %w", i, config.Name, err)
+			}
+		}
+		return d.checkQuota(ctx, tx)
+	})
+	if err != nil {
+		// IDs assigned before the rollback don't exist
+		for _, config := range configs {
+			config.ID = 0
+		}
+		return err
+	}
+
+	logger.Debug("Successfully created %d preservation configs", len(configs))
+	return nil
+}
+
+// createConfig inserts a preservation configuration using the given executor and assigns its ID
+func (d *Database) createConfig(ctx context.Context, ex execer, config *models.PreservationConfig) error {
 	logger.Debug("Creating new preservation config: %s", config.Name)
 
 	query := `
@@ -35,10 +203,23 @@ func (d *Database) CreateConfig(config *models.PreservationConfig) error {
 		thumbnail_mode,
 		aip_compression_level,
 		aip_compression_algorithm,
-		compress_aip
-	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		compress_aip,
+		active,
+		tags,
+		created_at,
+		updated_at
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
-	result, err := d.db.Exec(
+	tags, err := encodeTags(config.Tags)
+	if err != nil {
+		return err
+	}
+
+	// Set timestamps here rather than relying on column defaults, which differ between backends
+
+	now := d.clock.Now().UTC()
+	result, err := ex.ExecContext(
+		ctx,
 		query,
 		config.Name,
 		config.Description,
@@ -60,10 +241,14 @@ func (d *Database) CreateConfig(config *models.PreservationConfig) error {
 		config.A3MConfig.AipCompressionLevel,
 		config.A3MConfig.AipCompressionAlgorithm,
 		config.CompressAIP,
+		config.Active,
+		tags,
+		now,
+		now,
 	)
 	if err != nil {
 		logger.Error("Failed to create preservation config '%s': %v", config.Name, err)
-		return err
+		return readOnlyError(err)
 	}
 
 	// Get the auto-generated ID and assign it to the config
@@ -73,17 +258,28 @@ func (d *Database) CreateConfig(config *models.PreservationConfig) error {
 		return err
 	}
 	config.ID = id
+	config.Version = 1
+	config.CreatedAt = now
+	config.UpdatedAt = now
 
 	logger.Debug("Successfully created preservation config '%s' with ID: %d", config.Name, config.ID)
 	return nil
 }
 
-// GetConfig retrieves a preservation configuration by ID
-func (d *Database) GetConfig(id int64) (*models.PreservationConfig, error) {
-	logger.Debug("Fetching preservation config with ID: %d", id)
+// encodeTags returns tags as the JSON array stored in the tags column
+func encodeTags(tags []string) (string, error) {
+	if tags == nil {
+		tags = []string{}
+	}
+	data, err := json.Marshal(tags)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode tags: %w", err)
+	}
+	return string(data), nil
+}
 
-	query := `
-	SELECT 
+// configColumns are the preservation_configs columns read into a models.PreservationConfig, in scanConfig order
+const configColumns = `
 		id, name, description, 
 		assign_uuids_to_directories,
 		examine_contents,
@@ -103,13 +299,22 @@ func (d *Database) GetConfig(id int64) (*models.PreservationConfig, error) {
 		aip_compression_level,
 		aip_compression_algorithm,
 		compress_aip,
+		active,
+		tags,
+		version,
 		created_at,
-		updated_at
-	FROM preservation_configs
-	WHERE id = ?`
+		updated_at`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
 
+// scanConfig reads a row selected with configColumns
+func scanConfig(row rowScanner) (*models.PreservationConfig, error) {
 	var config models.PreservationConfig
-	err := d.db.QueryRow(query, id).Scan(
+	var tags string
+	err := row.Scan(
 		&config.ID,
 		&config.Name,
 		&config.Description,
@@ -131,9 +336,42 @@ func (d *Database) GetConfig(id int64) (*models.PreservationConfig, error) {
 		&config.A3MConfig.AipCompressionLevel,
 		&config.A3MConfig.AipCompressionAlgorithm,
 		&config.CompressAIP,
+		&config.Active,
+		&tags,
+		&config.Version,
 		&config.CreatedAt,
 		&config.UpdatedAt,
 	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(tags), &config.Tags); err != nil {
+		return nil, fmt.Errorf("invalid tags for config %d: %w", config.ID, err)
+	}
+	if config.Tags == nil {
+		config.Tags = []string{}
+	}
+	// Drivers return times in the location the connection is set up with, so the same
+	// instant would otherwise be serialized differently depending on the backend
+	config.CreatedAt = config.CreatedAt.UTC()
+	config.UpdatedAt = config.UpdatedAt.UTC()
+	return &config, nil
+}
+
+// GetConfig retrieves a preservation configuration by ID
+func (d *Database) GetConfig(id int64) (*models.PreservationConfig, error) {
+	return d.GetConfigContext(context.Background(), id)
+}
+
+// GetConfigContext is like GetConfig, but the query is cancelled when ctx is done
+func (d *Database) GetConfigContext(ctx context.Context, id int64) (*models.PreservationConfig, error) {
+	logger.Debug("Fetching preservation config with ID: %d", id)
+
+	query := `SELECT ` + configColumns + `
+	FROM preservation_configs
+	WHERE id = ?`
+
+	config, err := scanConfig(d.conn().QueryRowContext(ctx, query, id))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			logger.Debug("Preservation config not found: %d", id)
@@ -144,95 +382,325 @@ func (d *Database) GetConfig(id int64) (*models.PreservationConfig, error) {
 	}
 
 	logger.Debug("Successfully fetched preservation config: %s (ID: %d)", config.Name, config.ID)
-	return &config, nil
+	return config, nil
 }
 
 // ListConfigs retrieves all preservation configurations
 func (d *Database) ListConfigs() ([]*models.PreservationConfig, error) {
-	query := `
-	SELECT 
-		id, name, description, 
-		assign_uuids_to_directories,
-		examine_contents,
-		generate_transfer_structure_report,
-		document_empty_directories,
-		extract_packages,
-		delete_packages_after_extraction,
-		identify_transfer,
-		identify_submission_and_metadata,
-		identify_before_normalization,
-		normalize,
-		transcribe_files,
-		perform_policy_checks_on_originals,
-		perform_policy_checks_on_preservation_derivatives,
-		perform_policy_checks_on_access_derivatives,
-		thumbnail_mode,
-		aip_compression_level,
-		aip_compression_algorithm,
-		compress_aip,
-		created_at,
-		updated_at
+	return d.ListConfigsContext(context.Background())
+}
+
+// ListConfigsContext is like ListConfigs, but the query is cancelled when ctx is done
+func (d *Database) ListConfigsContext(ctx context.Context) ([]*models.PreservationConfig, error) {
+	return d.queryConfigs(ctx, `SELECT `+configColumns+`
 	FROM preservation_configs
+	ORDER BY id`)
+}
+
+// CountConfigs returns the total number of preservation configurations
+func (d *Database) CountConfigs() (int64, error) {
+	return d.CountConfigsContext(context.Background())
+}
+
+// CountConfigsContext is like CountConfigs, but the query is cancelled when ctx is done
+func (d *Database) CountConfigsContext(ctx context.Context) (int64, error) {
+	return d.CountFilteredConfigsContext(ctx, ConfigFilter{})
+}
+
+// CountFilteredConfigs returns the number of preservation configurations matching filter,
+// as accepted by FilterConfigs
+func (d *Database) CountFilteredConfigs(filter ConfigFilter) (int64, error) {
+	return d.CountFilteredConfigsContext(context.Background(), filter)
+}
+
+// CountFilteredConfigsContext is like CountFilteredConfigs, but the query is cancelled when ctx is done
+func (d *Database) CountFilteredConfigsContext(ctx context.Context, filter ConfigFilter) (int64, error) {
+	where, args, err := filterClause(filter)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	if err := d.conn().QueryRowContext(ctx, `SELECT COUNT(*) FROM preservation_configs`+where, args...).Scan(&count); err != nil {
+		logger.Error("Failed to count preservation configs: %v", err)
+		return 0, err
+	}
+	return count, nil
+}
+
+// ConfigFilter selects the preservation configurations FilterConfigs returns. The zero value
+// matches every config.
+type ConfigFilter struct {
+	// Fields maps boolean column names such as "normalize" (see FilterFields) to the value
+	// they must have
+	Fields map[string]bool
+	// Tag, unless empty, is a normalized tag the config must have
+	Tag string
+	// CreatedAfter and CreatedBefore, unless zero, bound when the config was created: from
+	// CreatedAfter inclusive up to CreatedBefore exclusive, so consecutive ranges don't overlap
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
+// filterColumns are the boolean columns FilterConfigs accepts. Only these names ever
+// reach the WHERE clause.
+var filterColumns = map[string]bool{
+	"assign_uuids_to_directories":                       true,
+	"examine_contents":                                  true,
+	"generate_transfer_structure_report":                true,
+	"document_empty_directories":                        true,
+	"extract_packages":                                  true,
+	"delete_packages_after_extraction":                  true,
+	"identify_transfer":                                 true,
+	"identify_submission_and_metadata":                  true,
+	"identify_before_normalization":                     true,
+	"normalize":                                         true,
+	"transcribe_files":                                  true,
+	"perform_policy_checks_on_originals":                true,
+	"perform_policy_checks_on_preservation_derivatives": true,
+	"perform_policy_checks_on_access_derivatives":       true,
+	"compress_aip":                                      true,
+	"active":                                            true,
+}
+
+// FilterFields returns the boolean field names accepted by FilterConfigs, in sorted order
+func FilterFields() []string {
+	fields := make([]string, 0, len(filterColumns))
+	for field := range filterColumns {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// filterClause builds the WHERE clause selecting the configs that match filter, or an empty
+// string if it matches every config. Unknown fields return ErrInvalidFilter.
+func filterClause(filter ConfigFilter) (string, []any, error) {
+	// Sort so the same filters always produce the same statement
+	fields := make([]string, 0, len(filter.Fields))
+	for field := range filter.Fields {
+		if !filterColumns[field] {
+			return "", nil, fmt.Errorf("%w: unknown filter field %q", ErrInvalidFilter, field)
+		}
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	conditions := make([]string, 0, len(fields))
+	args := make([]any, 0, len(fields))
+	for _, field := range fields {
+		conditions = append(conditions, field+" = ?")
+		args = append(args, filter.Fields[field])
+	}
+	if filter.Tag != "" {
+		// Tags are stored as a JSON array, so the quotes match the whole tag
+		conditions = append(conditions, `tags LIKE ? ESCAPE '!'`)
+		args = append(args, `%"`+escapeLike(filter.Tag)+`"%`)
+	}
+	if !filter.CreatedAfter.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.CreatedAfter.UTC())
+	}
+	if !filter.CreatedBefore.IsZero() {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, filter.CreatedBefore.UTC())
+	}
+
+	if len(conditions) == 0 {
+		return "", nil, nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args, nil
+}
+
+// sortColumns maps the sort fields accepted by ListConfigsSorted to their columns.
+// Only these names ever reach the ORDER BY clause.
+var sortColumns = map[string]string{
+	"id":         "id",
+	"name":       "name",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+// SortFields returns the field names accepted by ListConfigsSorted, in sorted order
+func SortFields() []string {
+	fields := make([]string, 0, len(sortColumns))
+	for field := range sortColumns {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// ListConfigsSorted retrieves preservation configurations ordered by sortField ("id" if empty)
+// in order "asc" (default) or "desc", ties broken by id. A positive limit caps the number of
+// configs returned and offset skips that many first. Unknown fields or orders return ErrInvalidSort
+// and a negative limit or offset ErrInvalidLimit.
+func (d *Database) ListConfigsSorted(sortField, order string, limit, offset int) ([]*models.PreservationConfig, error) {
+	return d.FilterConfigs(ConfigFilter{}, sortField, order, limit, offset)
+}
+
+// FilterConfigs retrieves the preservation configurations matching filter, sorted and paged
+// as by ListConfigsSorted. Unknown filter fields return ErrInvalidFilter.
+func (d *Database) FilterConfigs(filter ConfigFilter, sortField, order string, limit, offset int) ([]*models.PreservationConfig, error) {
+	return d.FilterConfigsContext(context.Background(), filter, sortField, order, limit, offset)
+}
+
+// FilterConfigsContext is like FilterConfigs, but the query is cancelled when ctx is done
+func (d *Database) FilterConfigsContext(ctx context.Context, filter ConfigFilter, sortField, order string, limit, offset int) ([]*models.PreservationConfig, error) {
+	where, args, err := filterClause(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	if sortField == "" {
+		sortField = "id"
+	}
+	column, ok := sortColumns[sortField]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown sort field %q", ErrInvalidSort, sortField)
+	}
+
+	direction := "ASC"
+	switch strings.ToLower(order) {
+	case "", "asc":
+	case "desc":
+		direction = "DESC"
+	default:
+		return nil, fmt.Errorf("%w: order must be 'asc' or 'desc', got %q", ErrInvalidSort, order)
+	}
+	if limit < 0 || offset < 0 {
+		return nil, fmt.Errorf("%w: limit and offset must not be negative", ErrInvalidLimit)
+	}
+
+	query := `SELECT ` + configColumns + `
+	FROM preservation_configs` + where + `
+	ORDER BY ` + column + ` ` + direction
+	if column != "id" {
+		query += `, id ` + direction
+	}
+
+	if limit > 0 || offset > 0 {
+		if limit == 0 {
+			// Both backends need a LIMIT before OFFSET
+			limit = math.MaxInt
+		}
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, limit, offset)
+	}
+
+	return d.queryConfigs(ctx, query, args...)
+}
+
+// ListConfigsByDateRange retrieves the preservation configurations created from after up to,
+// but not including, before, oldest first. A zero time leaves that end of the range open.
+func (d *Database) ListConfigsByDateRange(after, before time.Time) ([]*models.PreservationConfig, error) {
+	return d.ListConfigsByDateRangeContext(context.Background(), after, before)
+}
+
+// ListConfigsByDateRangeContext is like ListConfigsByDateRange, but the query is cancelled when ctx is done
+func (d *Database) ListConfigsByDateRangeContext(ctx context.Context, after, before time.Time) ([]*models.PreservationConfig, error) {
+	return d.FilterConfigsContext(ctx, ConfigFilter{CreatedAfter: after, CreatedBefore: before}, "created_at", "", 0, 0)
+}
+
+// ListConfigsAfter retrieves preservation configurations with an id greater than afterID,
+// in id order, for keyset pagination. Unlike an OFFSET, the cost does not grow with the
+// position in the table. A positive limit caps the number of configs returned.
+func (d *Database) ListConfigsAfter(afterID int64, limit int) ([]*models.PreservationConfig, error) {
+	return d.ListConfigsAfterContext(context.Background(), afterID, limit)
+}
+
+// ListConfigsAfterContext is like ListConfigsAfter, but the query is cancelled when ctx is done
+func (d *Database) ListConfigsAfterContext(ctx context.Context, afterID int64, limit int) ([]*models.PreservationConfig, error) {
+	if limit < 0 {
+		return nil, fmt.Errorf("%w: limit must not be negative", ErrInvalidLimit)
+	}
+
+	query := `SELECT ` + configColumns + `
+	FROM preservation_configs
+	WHERE id > ?
 	ORDER BY id`
+	args := []any{afterID}
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+
+	return d.queryConfigs(ctx, query, args...)
+}
 
-	rows, err := d.db.Query(query)
+// queryConfigs runs a query selecting configColumns and scans every row
+func (d *Database) queryConfigs(ctx context.Context, query string, args ...any) ([]*models.PreservationConfig, error) {
+	var configs []*models.PreservationConfig
+	err := d.eachConfig(ctx, func(config *models.PreservationConfig) error {
+		configs = append(configs, config)
+		return nil
+	}, query, args...)
 	if err != nil {
 		return nil, err
 	}
+
+	logger.Debug("Successfully fetched %d preservation configs", len(configs))
+	return configs, nil
+}
+
+// ForEachConfig calls fn with each preservation configuration in id order, reading them one
+// row at a time rather than all at once as ListConfigs does. It stops at the first error fn
+// returns and returns that error. The rows hold a connection until the iteration ends, so fn
+// must not use the database itself: an in-memory SQLite database has only the one connection,
+// and the call would never return.
+func (d *Database) ForEachConfig(fn func(*models.PreservationConfig) error) error {
+	return d.ForEachConfigContext(context.Background(), fn)
+}
+
+// ForEachConfigContext is like ForEachConfig, but the query is cancelled when ctx is done
+func (d *Database) ForEachConfigContext(ctx context.Context, fn func(*models.PreservationConfig) error) error {
+	return d.eachConfig(ctx, fn, `SELECT `+configColumns+`
+	FROM preservation_configs
+	ORDER BY id`)
+}
+
+// eachConfig runs a query selecting configColumns and calls fn with each config as its row is
+// scanned, stopping at the first error
+func (d *Database) eachConfig(ctx context.Context, fn func(*models.PreservationConfig) error, query string, args ...any) error {
+	rows, err := d.conn().QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
 	defer func() {
 		if err := rows.Close(); err != nil {
 			logger.Error("Failed to close rows: %v", err)
 		}
 	}()
 
-	var configs []*models.PreservationConfig
 	for rows.Next() {
-		var config models.PreservationConfig
-		err := rows.Scan(
-			&config.ID,
-			&config.Name,
-			&config.Description,
-			&config.A3MConfig.AssignUuidsToDirectories,
-			&config.A3MConfig.ExamineContents,
-			&config.A3MConfig.GenerateTransferStructureReport,
-			&config.A3MConfig.DocumentEmptyDirectories,
-			&config.A3MConfig.ExtractPackages,
-			&config.A3MConfig.DeletePackagesAfterExtraction,
-			&config.A3MConfig.IdentifyTransfer,
-			&config.A3MConfig.IdentifySubmissionAndMetadata,
-			&config.A3MConfig.IdentifyBeforeNormalization,
-			&config.A3MConfig.Normalize,
-			&config.A3MConfig.TranscribeFiles,
-			&config.A3MConfig.PerformPolicyChecksOnOriginals,
-			&config.A3MConfig.PerformPolicyChecksOnPreservationDerivatives,
-			&config.A3MConfig.PerformPolicyChecksOnAccessDerivatives,
-			&config.A3MConfig.ThumbnailMode,
-			&config.A3MConfig.AipCompressionLevel,
-			&config.A3MConfig.AipCompressionAlgorithm,
-			&config.CompressAIP,
-			&config.CreatedAt,
-			&config.UpdatedAt,
-		)
+		config, err := scanConfig(rows)
 		if err != nil {
 			logger.Error("Failed to scan preservation config row: %v", err)
-			return nil, err
+			return err
+		}
+		if err := fn(config); err != nil {
+			return err
 		}
-		configs = append(configs, &config)
 	}
 
 	if err := rows.Err(); err != nil {
 		logger.Error("Error iterating over preservation config rows: %v", err)
-		return nil, err
+		return err
 	}
-
-	logger.Debug("Successfully fetched %d preservation configs", len(configs))
-	return configs, nil
+	return nil
 }
 
-// UpdateConfig updates an existing preservation configuration
+// UpdateConfig updates an existing preservation configuration. The update only applies
+// if config.Version still matches the stored version, otherwise ErrVersionConflict is
+// returned. On success config.Version is incremented to the new stored version.
 func (d *Database) UpdateConfig(config *models.PreservationConfig) error {
+	return d.UpdateConfigContext(context.Background(), config)
+}
+
+// UpdateConfigContext is like UpdateConfig, but the query is cancelled when ctx is done
+func (d *Database) UpdateConfigContext(ctx context.Context, config *models.PreservationConfig) error {
 	// First check if the config exists
-	_, err := d.GetConfig(config.ID)
+	_, err := d.GetConfigContext(ctx, config.ID)
 	if err != nil {
 		if errors.Is(err, ErrNotFound) {
 			return ErrNotFound
@@ -240,6 +708,12 @@ func (d *Database) UpdateConfig(config *models.PreservationConfig) error {
 		return err
 	}
 
+	return d.updateConfig(ctx, d.conn(), config)
+}
+
+// updateConfig writes all fields of a preservation configuration using the given executor,
+// guarded by and incrementing its version
+func (d *Database) updateConfig(ctx context.Context, ex execer, config *models.PreservationConfig) error {
 	query := `
 	UPDATE preservation_configs SET
 		name = ?,
@@ -261,10 +735,21 @@ func (d *Database) UpdateConfig(config *models.PreservationConfig) error {
 		thumbnail_mode = ?,
 		aip_compression_level = ?,
 		aip_compression_algorithm = ?,
-		compress_aip = ?
-	WHERE id = ?`
+		compress_aip = ?,
+		active = ?,
+		tags = ?,
+		updated_at = ?,
+		version = version + 1
+	WHERE id = ? AND version = ?`
+
+	tags, err := encodeTags(config.Tags)
+	if err != nil {
+		return err
+	}
 
-	_, err = d.db.Exec(
+	now := d.clock.Now().UTC()
+	result, err := ex.ExecContext(
+		ctx,
 		query,
 		config.Name,
 		config.Description,
@@ -286,16 +771,65 @@ func (d *Database) UpdateConfig(config *models.PreservationConfig) error {
 		config.A3MConfig.AipCompressionLevel,
 		config.A3MConfig.AipCompressionAlgorithm,
 		config.CompressAIP,
+		config.Active,
+		tags,
+		now,
 		config.ID,
+		config.Version,
 	)
+	if err != nil {
+		return readOnlyError(err)
+	}
 
-	return err
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		logger.Debug("Preservation config %d is no longer at version %d", config.ID, config.Version)
+		return ErrVersionConflict
+	}
+
+	config.Version++
+	config.UpdatedAt = now
+	return nil
+}
+
+// SetConfigActive marks a preservation configuration active or inactive, leaving its other
+// fields as they are. Inactive configs stay readable; they are only flagged as retired.
+// The version is incremented, since the config has changed.
+func (d *Database) SetConfigActive(id int64, active bool) error {
+	return d.SetConfigActiveContext(context.Background(), id, active)
+}
+
+// SetConfigActiveContext is like SetConfigActive, but the query is cancelled when ctx is done
+func (d *Database) SetConfigActiveContext(ctx context.Context, id int64, active bool) error {
+	query := `UPDATE preservation_configs SET active = ?, updated_at = ?, version = version + 1 WHERE id = ?`
+	result, err := d.conn().ExecContext(ctx, query, active, d.clock.Now().UTC(), id)
+	if err != nil {
+		logger.Error("Failed to set active on preservation config %d: %v", id, err)
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
 }
 
 // DeleteConfig deletes a preservation configuration by ID
 func (d *Database) DeleteConfig(id int64) error {
+	return d.DeleteConfigContext(context.Background(), id)
+}
+
+// DeleteConfigContext is like DeleteConfig, but the query is cancelled when ctx is done
+func (d *Database) DeleteConfigContext(ctx context.Context, id int64) error {
 	// Check if the config exists
-	_, err := d.GetConfig(id)
+	_, err := d.GetConfigContext(ctx, id)
 	if err != nil {
 		if errors.Is(err, ErrNotFound) {
 			return ErrNotFound
@@ -303,8 +837,192 @@ func (d *Database) DeleteConfig(id int64) error {
 		return err
 	}
 
-	// Delete the config
-	query := `DELETE FROM preservation_configs WHERE id = ?`
-	_, err = d.db.Exec(query, id)
-	return err
+	// Delete the config, recording when so that MaxUpdatedAt reflects the deletion
+	return d.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM preservation_configs WHERE id = ?`, id); err != nil {
+			return err
+		}
+		return d.recordDeletion(ctx, tx)
+	})
+}
+
+// DeleteConfigIfVersion deletes a preservation configuration by ID only if it is still at
+// version, returning ErrVersionConflict if it has been modified since and ErrNotFound if
+// it doesn't exist
+func (d *Database) DeleteConfigIfVersion(id, version int64) error {
+	return d.DeleteConfigIfVersionContext(context.Background(), id, version)
+}
+
+// DeleteConfigIfVersionContext is like DeleteConfigIfVersion, but the query is cancelled when ctx is done
+func (d *Database) DeleteConfigIfVersionContext(ctx context.Context, id, version int64) error {
+	return d.withTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `DELETE FROM preservation_configs WHERE id = ? AND version = ?`, id, version)
+		if err != nil {
+			return err
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			// Tell a missing config from one at another version
+			var current int64
+			err := tx.QueryRowContext(ctx, `SELECT version FROM preservation_configs WHERE id = ?`, id).Scan(&current)
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNotFound
+			}
+			if err != nil {
+				return err
+			}
+			logger.Debug("Preservation config %d is at version %d, not %d", id, current, version)
+			return ErrVersionConflict
+		}
+		return d.recordDeletion(ctx, tx)
+	})
+}
+
+// DeleteConfigs deletes the preservation configurations with the given IDs in a single
+// transaction, returning the IDs deleted and those that didn't exist, each in the order given.
+// On error nothing is deleted.
+func (d *Database) DeleteConfigs(ids []int64) ([]int64, []int64, error) {
+	return d.DeleteConfigsContext(context.Background(), ids)
+}
+
+// DeleteConfigsContext is like DeleteConfigs, but the query is cancelled when ctx is done
+func (d *Database) DeleteConfigsContext(ctx context.Context, ids []int64) ([]int64, []int64, error) {
+	logger.Debug("Deleting %d preservation configs", len(ids))
+	deleted, notFound := make([]int64, 0, len(ids)), []int64{}
+	err := d.withTx(ctx, func(tx *sql.Tx) error {
+		for _, id := range ids {
+			result, err := tx.ExecContext(ctx, `DELETE FROM preservation_configs WHERE id = ?`, id)
+			if err != nil {
+				return fmt.Errorf("failed to delete config %d: %w", id, err)
+			}
+			rows, err := result.RowsAffected()
+			if err != nil {
+				return err
+			}
+			if rows == 0 {
+				notFound = append(notFound, id)
+			} else {
+				deleted = append(deleted, id)
+			}
+		}
+		if len(deleted) == 0 {
+			return nil
+		}
+		return d.recordDeletion(ctx, tx)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return deleted, notFound, nil
+}
+
+// recordDeletion notes that configs were just deleted, for MaxUpdatedAt
+func (d *Database) recordDeletion(ctx context.Context, ex execer) error {
+	if _, err := ex.ExecContext(ctx, `UPDATE preservation_configs_state SET last_deleted_at = ? WHERE id = 1`, d.clock.Now().UTC()); err != nil {
+		return fmt.Errorf("failed to record config deletion: %w", err)
+	}
+	return nil
+}
+
+// MaxUpdatedAt returns when the collection of configs last changed: the latest updated_at of
+// any config, or the time a config was last deleted if that is later. It is the zero time if
+// there have been no configs. This is far cheaper than listing the configs to compare them.
+func (d *Database) MaxUpdatedAt() (time.Time, error) {
+	return d.MaxUpdatedAtContext(context.Background())
+}
+
+// MaxUpdatedAtContext is like MaxUpdatedAt, but the query is cancelled when ctx is done
+func (d *Database) MaxUpdatedAtContext(ctx context.Context) (time.Time, error) {
+	// MAX() would lose the column type, which the SQLite driver needs to return a time
+	var updatedAt time.Time
+	err := d.conn().QueryRowContext(ctx, `SELECT updated_at FROM preservation_configs ORDER BY updated_at DESC LIMIT 1`).Scan(&updatedAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, fmt.Errorf("failed to get latest config update: %w", err)
+	}
+
+	var deletedAt sql.NullTime
+	if err := d.conn().QueryRowContext(ctx, `SELECT last_deleted_at FROM preservation_configs_state WHERE id = 1`).Scan(&deletedAt); err != nil {
+		return time.Time{}, fmt.Errorf("failed to get latest config deletion: %w", err)
+	}
+
+	if deletedAt.Valid && deletedAt.Time.After(updatedAt) {
+		return deletedAt.Time.UTC(), nil
+	}
+	return updatedAt.UTC(), nil
+}
+
+// ImportAction describes what ImportConfigs did with a single config
+type ImportAction string
+
+// Possible ImportConfigs outcomes
+const (
+	ImportCreated ImportAction = "created"
+	ImportUpdated ImportAction = "updated"
+	ImportSkipped ImportAction = "skipped"
+)
+
+// ImportConfigs applies configs in a single transaction, matching existing configs by name.
+// New names are inserted. Existing names are overwritten when upsert is set and skipped otherwise.
+// Each config's ID is set to the row it was created as or matched to, and the returned actions
+// are in the same order as configs. On error nothing is applied.
+func (d *Database) ImportConfigs(configs []*models.PreservationConfig, upsert bool) ([]ImportAction, error) {
+	return d.ImportConfigsContext(context.Background(), configs, upsert)
+}
+
+// ImportConfigsContext is like ImportConfigs, but the query is cancelled when ctx is done
+func (d *Database) ImportConfigsContext(ctx context.Context, configs []*models.PreservationConfig, upsert bool) ([]ImportAction, error) {
+	logger.Debug("Importing %d preservation configs (upsert: %v)", len(configs), upsert)
+
+	actions := make([]ImportAction, len(configs))
+	err := d.withTx(ctx, func(tx *sql.Tx) error {
+		created := false
+		for i, config := range configs {
+			id, version, err := findConfigByName(ctx, tx, config.Name)
+			switch {
+			case errors.Is(err, ErrNotFound):
+				if err := d.createConfig(ctx, tx, config); err != nil {
+					return fmt.Errorf("failed to create config %d (%s): %w", i, config.Name, err)
+				}
+				actions[i] = ImportCreated
+				created = true
+			case err != nil:
+				return fmt.Errorf("failed to look up config %d (%s): %w", i, config.Name, err)
+			case upsert:
+				config.ID = id
+				config.Version = version
+				if err := d.updateConfig(ctx, tx, config); err != nil {
+					return fmt.Errorf("failed to update config %d (%s): %w", i, config.Name, err)
+				}
+				actions[i] = ImportUpdated
+			default:
+				config.ID = id
+				config.Version = version
+				actions[i] = ImportSkipped
+			}
+		}
+		// Only new configs count against the limit, so updates still apply when it's reached
+		if created {
+			return d.checkQuota(ctx, tx)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Debug("Successfully imported %d preservation configs", len(configs))
+	return actions, nil
+}
+
+// findConfigByName returns the ID and version of the oldest config with the given name
+func findConfigByName(ctx context.Context, tx *sql.Tx, name string) (int64, int64, error) {
+	var id, version int64
+	err := tx.QueryRowContext(ctx, `SELECT id, version FROM preservation_configs WHERE name = ? ORDER BY id LIMIT 1`, name).Scan(&id, &version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, 0, ErrNotFound
+	}
+	return id, version, err
 }
@@ -0,0 +1,214 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/penwern/curate-preservation-api/models"
+	"github.com/penwern/curate-preservation-api/pkg/logger"
+)
+
+// ErrInvalidSearch is returned when a search term has nothing to search for
+var ErrInvalidSearch = errors.New("invalid search")
+
+// ErrUnknownSearchField is returned when asked to search a field not among SearchFields.
+// It wraps ErrInvalidSearch.
+var ErrUnknownSearchField = fmt.Errorf("%w: unknown field", ErrInvalidSearch)
+
+// mysqlMinTokenSize is InnoDB's default innodb_ft_min_token_size. Shorter words are not
+// indexed, so searches for them fall back to LIKE.
+const mysqlMinTokenSize = 3
+
+// searchColumns maps the field names accepted by SearchConfigs to their columns. Only these
+// columns are ever written into a search query.
+var searchColumns = map[string]string{
+	"name":        "name",
+	"description": "description",
+}
+
+// defaultSearchFields are searched when no fields are given
+var defaultSearchFields = []string{"name"}
+
+// SearchFields returns the field names accepted by SearchConfigs, in sorted order
+func SearchFields() []string {
+	fields := make([]string, 0, len(searchColumns))
+	for field := range searchColumns {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// searchColumnList returns the columns of the given search fields, without repeats, or of
+// the default fields if none are given
+func searchColumnList(fields []string) ([]string, error) {
+	if len(fields) == 0 {
+		fields = defaultSearchFields
+	}
+	columns := make([]string, 0, len(fields))
+	for _, field := range fields {
+		column, ok := searchColumns[field]
+		if !ok {
+			return nil, fmt.Errorf("%w %q", ErrUnknownSearchField, field)
+		}
+		if !slices.Contains(columns, column) {
+			columns = append(columns, column)
+		}
+	}
+	return columns, nil
+}
+
+// SearchConfigs retrieves the preservation configurations where every whitespace-separated
+// word of term appears, ignoring case, in at least one of fields (see SearchFields), in id
+// order. No fields searches just the name. It matches substrings with LIKE, so it scans the whole
+// table; see FullTextSearch for an indexed search.
+func (d *Database) SearchConfigs(term string, fields []string) ([]*models.PreservationConfig, error) {
+	return d.SearchConfigsContext(context.Background(), term, fields)
+}
+
+// SearchConfigsContext is like SearchConfigs, but the query is cancelled when ctx is done
+func (d *Database) SearchConfigsContext(ctx context.Context, term string, fields []string) ([]*models.PreservationConfig, error) {
+	words := strings.Fields(term)
+	if len(words) == 0 {
+		return nil, fmt.Errorf("%w: search term is empty", ErrInvalidSearch)
+	}
+	columns, err := searchColumnList(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	conditions := make([]string, 0, len(words))
+	args := make([]any, 0, len(columns)*len(words))
+	for _, word := range words {
+		pattern := "%" + escapeLike(word) + "%"
+		matches := make([]string, len(columns))
+		for i, column := range columns {
+			matches[i] = column + ` LIKE ? ESCAPE '!'`
+			args = append(args, pattern)
+		}
+		conditions = append(conditions, "("+strings.Join(matches, " OR ")+")")
+	}
+
+	query := `SELECT ` + configColumns + `
+	FROM preservation_configs
+	WHERE ` + strings.Join(conditions, " AND ") + `
+	ORDER BY id`
+	return d.queryConfigs(ctx, query, args...)
+}
+
+// FullTextSearch retrieves the preservation configurations where each word of term starts a
+// word of one of fields (see SearchFields; no fields searches just the name), in id order,
+// using the backend's full-text index: an FTS4 table on SQLite and a FULLTEXT index on MySQL.
+// Words are split on anything other than letters and digits. It falls back to SearchConfigs
+// when the index is missing or cannot serve the search, such as words shorter than MySQL
+// indexes or, on MySQL, fields that leave out one of the indexed columns, which its index
+// only covers together.
+func (d *Database) FullTextSearch(term string, fields []string) ([]*models.PreservationConfig, error) {
+	return d.FullTextSearchContext(context.Background(), term, fields)
+}
+
+// FullTextSearchContext is like FullTextSearch, but the query is cancelled when ctx is done
+func (d *Database) FullTextSearchContext(ctx context.Context, term string, fields []string) ([]*models.PreservationConfig, error) {
+	if strings.TrimSpace(term) == "" {
+		return nil, fmt.Errorf("%w: search term is empty", ErrInvalidSearch)
+	}
+	columns, err := searchColumnList(fields)
+	if err != nil {
+		return nil, err
+	}
+	tokens := strings.FieldsFunc(term, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(tokens) == 0 {
+		return d.SearchConfigsContext(ctx, term, fields)
+	}
+
+	available, err := d.hasFullTextIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !available {
+		logger.Debug("Full-text index unavailable, searching with LIKE")
+		return d.SearchConfigsContext(ctx, term, fields)
+	}
+
+	var query, match string
+	switch d.dbType {
+	case DBTypeSQLite:
+		// Quote each token so words such as OR and NOT aren't read as operators. A single
+		// column is searched with a column filter instead, which FTS4 only allows on an
+		// unquoted word but which also stops the word being read as an operator.
+		phrases := make([]string, len(tokens))
+		for i, token := range tokens {
+			if len(columns) < len(searchColumns) {
+				phrases[i] = columns[0] + ":" + token + "*"
+			} else {
+				phrases[i] = `"` + token + `*"`
+			}
+		}
+		match = strings.Join(phrases, " ")
+		query = `SELECT ` + configColumns + `
+		FROM preservation_configs
+		WHERE id IN (SELECT docid FROM preservation_configs_fts WHERE preservation_configs_fts MATCH ?)
+		ORDER BY id`
+	case DBTypeMySQL:
+		if len(columns) < len(searchColumns) {
+			logger.Debug("Full-text index covers name and description together, searching %v with LIKE", columns)
+			return d.SearchConfigsContext(ctx, term, fields)
+		}
+		words := make([]string, len(tokens))
+		for i, token := range tokens {
+			if len([]rune(token)) < mysqlMinTokenSize {
+				logger.Debug("Search word %q is too short for the full-text index, searching with LIKE", token)
+				return d.SearchConfigsContext(ctx, term, fields)
+			}
+			words[i] = "+" + token + "*"
+		}
+		match = strings.Join(words, " ")
+		query = `SELECT ` + configColumns + `
+		FROM preservation_configs
+		WHERE MATCH(name, description) AGAINST (? IN BOOLEAN MODE)
+		ORDER BY id`
+	default:
+		return d.SearchConfigsContext(ctx, term, fields)
+	}
+
+	return d.queryConfigs(ctx, query, match)
+}
+
+// hasFullTextIndex reports whether the full-text index created by the migrations exists
+func (d *Database) hasFullTextIndex(ctx context.Context) (bool, error) {
+	var query string
+	switch d.dbType {
+	case DBTypeSQLite:
+		query = `SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'preservation_configs_fts'`
+	case DBTypeMySQL:
+		query = `SELECT 1 FROM information_schema.statistics
+		WHERE table_schema = DATABASE() AND table_name = 'preservation_configs' AND index_type = 'FULLTEXT'
+		LIMIT 1`
+	default:
+		return false, nil
+	}
+
+	var exists int
+	err := d.conn().QueryRowContext(ctx, query).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		logger.Error("Failed to check for full-text index: %v", err)
+		return false, err
+	}
+	return true, nil
+}
+
+// escapeLike escapes the LIKE wildcards in s, using '!' as the escape character
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
@@ -2,8 +2,15 @@
 package models
 
 import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
 	transferservice "github.com/penwern/curate-preservation-api/common/proto/a3m/gen/go/a3m/api/transferservice/v1beta1"
 	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 // A3MProcessingConfig is a thin wrapper around the generated ProcessingConfig
@@ -26,9 +33,15 @@ func (c *A3MProcessingConfig) MarshalJSON() ([]byte, error) {
 
 // UnmarshalJSON parses the JSON data and populates the A3MProcessingConfig
 // This is called automatically when the A3MProcessingConfig is unmarshaled from JSON
+// Boolean fields also accept the forms A3MBool does
 func (c *A3MProcessingConfig) UnmarshalJSON(data []byte) error {
+	data, err := normalizeA3MBools(data)
+	if err != nil {
+		return err
+	}
+
 	var proto transferservice.ProcessingConfig
-	err := protojson.UnmarshalOptions{
+	err = protojson.UnmarshalOptions{
 		DiscardUnknown: true,
 	}.Unmarshal(data, &proto)
 	if err != nil {
@@ -38,6 +51,224 @@ func (c *A3MProcessingConfig) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// UnmarshalJSONWithDefaults parses a possibly partial a3m_config object like UnmarshalJSON,
+// but gives the fields it omits their NewA3MProcessingConfig defaults rather than zero values.
+// Use it for input, where an omitted field means "the default"; UnmarshalJSON suits complete
+// configs, such as those this API returns.
+func (c *A3MProcessingConfig) UnmarshalJSONWithDefaults(data []byte) error {
+	if err := c.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	c.MergeDefaults(fields)
+	return nil
+}
+
+// MergeDefaults gives every field that fields does not mention its NewA3MProcessingConfig
+// default, leaving the others as they are. fields is the decoded a3m_config object that c was
+// read from, which is the only way to tell an omitted field from one given as false or 0:
+// the proto holds the zero value for both. A null counts as omitted, as it does for protojson.
+func (c *A3MProcessingConfig) MergeDefaults(fields map[string]any) {
+	defaults := NewA3MProcessingConfig()
+	source := (*transferservice.ProcessingConfig)(&defaults).ProtoReflect()
+	target := (*transferservice.ProcessingConfig)(c).ProtoReflect()
+
+	descriptors := target.Descriptor().Fields()
+	for i := range descriptors.Len() {
+		field := descriptors.Get(i)
+		if !hasA3MField(fields, string(field.Name())) {
+			target.Set(field, source.Get(field))
+		}
+	}
+}
+
+// hasA3MField reports whether fields gives a non-null value for the named A3M field
+func hasA3MField(fields map[string]any, name string) bool {
+	for key, value := range fields {
+		if value != nil && A3MFieldNameMatches(key, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// a3mField returns the descriptor of the A3M field key names, in either snake_case or
+// lowerCamelCase, or nil if there is no such field
+func a3mField(key string) protoreflect.FieldDescriptor {
+	fields := (&transferservice.ProcessingConfig{}).ProtoReflect().Descriptor().Fields()
+	for i := range fields.Len() {
+		if field := fields.Get(i); A3MFieldNameMatches(key, string(field.Name())) {
+			return field
+		}
+	}
+	return nil
+}
+
+// A3MFieldName returns the snake_case name of the A3M field key names, in either snake_case
+// or lowerCamelCase, or key itself if it names no field
+func A3MFieldName(key string) string {
+	if field := a3mField(key); field != nil {
+		return string(field.Name())
+	}
+	return key
+}
+
+// IsA3MBoolField reports whether key names a boolean A3M field, such as normalize
+func IsA3MBoolField(key string) bool {
+	field := a3mField(key)
+	return field != nil && field.Kind() == protoreflect.BoolKind
+}
+
+// A3MBool converts a value given for a boolean A3M field to a bool. Loosely typed clients send
+// booleans as strings or numbers, so besides true and false it accepts the strings "true",
+// "false", "1" and "0" and the numbers 1 and 0. Anything else, such as "yes" or 2, is too
+// ambiguous to guess at, and ok is false.
+func A3MBool(value any) (b bool, ok bool) {
+	switch value := value.(type) {
+	case bool:
+		return value, true
+	case string:
+		switch value {
+		case "true", "1":
+			return true, true
+		case "false", "0":
+			return false, true
+		}
+	case float64:
+		switch value {
+		case 1:
+			return true, true
+		case 0:
+			return false, true
+		}
+	case json.Number:
+		return A3MBool(value.String())
+	case int:
+		return A3MBool(float64(value))
+	}
+	return false, false
+}
+
+// normalizeA3MBools rewrites the boolean fields of an a3m_config JSON object given in a form
+// A3MBool accepts as JSON booleans, which is all protojson takes. Data that isn't an object is
+// returned as it is for protojson to report.
+func normalizeA3MBools(data []byte) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return data, nil
+	}
+
+	changed := false
+	for key, raw := range fields {
+		if !IsA3MBoolField(key) {
+			continue
+		}
+		var value any
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil, err
+		}
+		if _, isBool := value.(bool); isBool || value == nil {
+			continue
+		}
+		b, ok := A3MBool(value)
+		if !ok {
+			return nil, A3MValueError(key, value)
+		}
+		fields[key] = json.RawMessage(strconv.FormatBool(b))
+		changed = true
+	}
+	if !changed {
+		return data, nil
+	}
+	return json.Marshal(fields)
+}
+
+// A3MValueError returns the field error for a value given for the A3M field key that can't
+// be converted to the field's type, such as a string that isn't a boolean for normalize.
+// The error names the field as its snake_case JSON name, whichever form key takes.
+func A3MValueError(key string, value any) FieldError {
+	name, expected := key, "a valid value"
+	if field := a3mField(key); field != nil {
+		name = string(field.Name())
+		if field.Kind() == protoreflect.BoolKind {
+			expected = "true or false"
+		} else {
+			expected = "a number"
+		}
+	}
+	return FieldError{
+		Field:   name,
+		Message: fmt.Sprintf("%s must be %s, got %s", name, expected, jsonTypeName(value)),
+	}
+}
+
+// jsonTypeName describes the JSON type of a decoded value, for error messages
+func jsonTypeName(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "a boolean"
+	case float64, json.Number:
+		return fmt.Sprintf("the number %v", value)
+	case string:
+		return fmt.Sprintf("the string %q", value)
+	case []any:
+		return "an array"
+	case map[string]any:
+		return "an object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// NameA3MEnums replaces the numeric enum values in a decoded a3m_config object, as emitted in
+// responses, with their names, e.g. a thumbnailMode of 1 with "THUMBNAIL_MODE_GENERATE".
+// Numbers may be float64 or json.Number; values that aren't a known number are left as they are.
+func NameA3MEnums(a3m map[string]any) {
+	fields := (&transferservice.ProcessingConfig{}).ProtoReflect().Descriptor().Fields()
+	for i := range fields.Len() {
+		field := fields.Get(i)
+		if field.Kind() != protoreflect.EnumKind {
+			continue
+		}
+		for key, value := range a3m {
+			if !A3MFieldNameMatches(key, string(field.Name())) {
+				continue
+			}
+			var number float64
+			switch value := value.(type) {
+			case float64:
+				number = value
+			case json.Number:
+				n, err := value.Float64()
+				if err != nil {
+					continue
+				}
+				number = n
+			default:
+				continue
+			}
+			if number != float64(int32(number)) {
+				continue
+			}
+			if enumValue := field.Enum().Values().ByNumber(protoreflect.EnumNumber(int32(number))); enumValue != nil {
+				a3m[key] = string(enumValue.Name())
+			}
+		}
+	}
+}
+
+// A3MFieldNameMatches reports whether key names the A3M field with the given snake_case name.
+// It accepts the snake_case names as well as the lowerCamelCase ones emitted in responses,
+// ignoring case and underscores.
+func A3MFieldNameMatches(key, name string) bool {
+	return strings.EqualFold(strings.ReplaceAll(key, "_", ""), strings.ReplaceAll(name, "_", ""))
+}
+
 // NewA3MProcessingConfig creates a new A3MProcessingConfig with default values
 func NewA3MProcessingConfig() A3MProcessingConfig {
 	return A3MProcessingConfig{
@@ -60,3 +291,71 @@ func NewA3MProcessingConfig() A3MProcessingConfig {
 		AipCompressionAlgorithm:                      transferservice.ProcessingConfig_AIP_COMPRESSION_ALGORITHM_S7_BZIP2,
 	}
 }
+
+// Valid range for AipCompressionLevel, matching the 7-Zip compression levels used by a3m
+const (
+	MinAIPCompressionLevel = 0
+	MaxAIPCompressionLevel = 9
+)
+
+// Validate checks that enum fields hold known values and numeric fields are in range,
+// returning the first problem found
+func (c *A3MProcessingConfig) Validate() error {
+	if errs := c.FieldErrors(); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// FieldErrors checks the same as Validate but returns every problem found
+func (c *A3MProcessingConfig) FieldErrors() []FieldError {
+	var errs []FieldError
+	if c.AipCompressionLevel < MinAIPCompressionLevel || c.AipCompressionLevel > MaxAIPCompressionLevel {
+		errs = append(errs, FieldError{
+			Field: "aip_compression_level",
+			Message: fmt.Sprintf("aip_compression_level must be between %d and %d, got %d",
+				MinAIPCompressionLevel, MaxAIPCompressionLevel, c.AipCompressionLevel),
+		})
+	}
+	if _, ok := transferservice.ProcessingConfig_AIPCompressionAlgorithm_name[int32(c.AipCompressionAlgorithm)]; !ok {
+		errs = append(errs, FieldError{
+			Field:   "aip_compression_algorithm",
+			Message: fmt.Sprintf("aip_compression_algorithm %d is not a known algorithm", c.AipCompressionAlgorithm),
+		})
+	}
+	if _, ok := transferservice.ProcessingConfig_ThumbnailMode_name[int32(c.ThumbnailMode)]; !ok {
+		errs = append(errs, FieldError{
+			Field:   "thumbnail_mode",
+			Message: fmt.Sprintf("thumbnail_mode %d is not a known mode", c.ThumbnailMode),
+		})
+	}
+	return errs
+}
+
+// CompressesAIP reports whether the configured algorithm actually compresses the AIP.
+// The uncompressed, plain tar and 7-Zip copy algorithms only package it.
+func (c *A3MProcessingConfig) CompressesAIP() bool {
+	switch c.AipCompressionAlgorithm {
+	case transferservice.ProcessingConfig_AIP_COMPRESSION_ALGORITHM_TAR_BZIP2,
+		transferservice.ProcessingConfig_AIP_COMPRESSION_ALGORITHM_TAR_GZIP,
+		transferservice.ProcessingConfig_AIP_COMPRESSION_ALGORITHM_S7_BZIP2,
+		transferservice.ProcessingConfig_AIP_COMPRESSION_ALGORITHM_S7_LZMA:
+		return true
+	default:
+		return false
+	}
+}
+
+// Clone returns a deep copy of c, so that changes to the copy don't affect c
+func (c *A3MProcessingConfig) Clone() *A3MProcessingConfig {
+	if c == nil {
+		return nil
+	}
+	clone := proto.Clone((*transferservice.ProcessingConfig)(c)).(*transferservice.ProcessingConfig)
+	return (*A3MProcessingConfig)(clone)
+}
+
+// Equal reports whether c and other hold the same value in every field
+func (c *A3MProcessingConfig) Equal(other *A3MProcessingConfig) bool {
+	return proto.Equal((*transferservice.ProcessingConfig)(c), (*transferservice.ProcessingConfig)(other))
+}
@@ -2,9 +2,13 @@ package models
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
 	"testing"
 
 	transferservice "github.com/penwern/curate-preservation-api/common/proto/a3m/gen/go/a3m/api/transferservice/v1beta1"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 func TestNewA3MProcessingConfig(t *testing.T) {
@@ -234,6 +238,187 @@ func TestA3MProcessingConfig_UnmarshalJSON_PartialData(t *testing.T) {
 	}
 }
 
+func TestA3MProcessingConfig_UnmarshalJSONWithDefaults(t *testing.T) {
+	partialJSON := `{
+		"examine_contents": true,
+		"normalize": false,
+		"aipCompressionLevel": 0,
+		"transcribe_files": null
+	}`
+
+	config := A3MProcessingConfig{}
+	if err := config.UnmarshalJSONWithDefaults([]byte(partialJSON)); err != nil {
+		t.Fatalf("Failed to unmarshal partial JSON: %v", err)
+	}
+
+	// Given fields keep their values, even when false or 0
+	if !config.ExamineContents {
+		t.Error("Expected ExamineContents to be true")
+	}
+	if config.Normalize {
+		t.Error("Expected Normalize to stay explicitly false")
+	}
+	if config.AipCompressionLevel != 0 {
+		t.Errorf("Expected AipCompressionLevel to stay explicitly 0, got %d", config.AipCompressionLevel)
+	}
+
+	// Omitted and null fields take the defaults
+	defaults := NewA3MProcessingConfig()
+	if config.AssignUuidsToDirectories != defaults.AssignUuidsToDirectories {
+		t.Error("Expected AssignUuidsToDirectories to take its default")
+	}
+	if config.TranscribeFiles != defaults.TranscribeFiles {
+		t.Error("Expected a null TranscribeFiles to take its default")
+	}
+	if config.ThumbnailMode != defaults.ThumbnailMode || config.AipCompressionAlgorithm != defaults.AipCompressionAlgorithm {
+		t.Errorf("Expected enums to take their defaults, got %v and %v", config.ThumbnailMode, config.AipCompressionAlgorithm)
+	}
+
+	if err := config.UnmarshalJSONWithDefaults([]byte(`{"normalize": "yes"}`)); err == nil {
+		t.Error("Expected an error for a mistyped field")
+	}
+}
+
+func TestNameA3MEnums(t *testing.T) {
+	a3m := map[string]any{
+		"thumbnailMode":           float64(3),
+		"aipCompressionAlgorithm": json.Number("6"),
+		"aipCompressionLevel":     float64(1),
+		"normalize":               true,
+	}
+	NameA3MEnums(a3m)
+
+	want := map[string]any{
+		"thumbnailMode":           "THUMBNAIL_MODE_DO_NOT_GENERATE",
+		"aipCompressionAlgorithm": "AIP_COMPRESSION_ALGORITHM_S7_BZIP2",
+		"aipCompressionLevel":     float64(1),
+		"normalize":               true,
+	}
+	if fmt.Sprint(a3m) != fmt.Sprint(want) {
+		t.Errorf("Expected %v, got %v", want, a3m)
+	}
+
+	// Unknown numbers and values that are already names are left alone
+	a3m = map[string]any{"thumbnailMode": float64(99), "aip_compression_algorithm": "AIP_COMPRESSION_ALGORITHM_S7_BZIP2"}
+	NameA3MEnums(a3m)
+	if a3m["thumbnailMode"] != float64(99) || a3m["aip_compression_algorithm"] != "AIP_COMPRESSION_ALGORITHM_S7_BZIP2" {
+		t.Errorf("Expected the values to be unchanged, got %v", a3m)
+	}
+}
+
+func TestA3MValueError(t *testing.T) {
+	tests := []struct {
+		key   string
+		value any
+		want  FieldError
+	}{
+		{"normalize", "abc", FieldError{Field: "normalize", Message: `normalize must be true or false, got the string "abc"`}},
+		{"examineContents", []any{true}, FieldError{Field: "examine_contents", Message: "examine_contents must be true or false, got an array"}},
+		{"aipCompressionLevel", map[string]any{}, FieldError{Field: "aip_compression_level", Message: "aip_compression_level must be a number, got an object"}},
+		{"unknown", true, FieldError{Field: "unknown", Message: "unknown must be a valid value, got a boolean"}},
+	}
+	for _, tt := range tests {
+		if got := A3MValueError(tt.key, tt.value); got != tt.want {
+			t.Errorf("A3MValueError(%q, %v) = %+v, want %+v", tt.key, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestA3MBool(t *testing.T) {
+	tests := []struct {
+		value  any
+		want   bool
+		wantOK bool
+	}{
+		{true, true, true},
+		{false, false, true},
+		{"true", true, true},
+		{"false", false, true},
+		{"1", true, true},
+		{"0", false, true},
+		{float64(1), true, true},
+		{float64(0), false, true},
+		{json.Number("1"), true, true},
+		{1, true, true},
+		{"yes", false, false},
+		{"t", false, false},
+		{"", false, false},
+		{float64(2), false, false},
+		{float64(0.5), false, false},
+		{nil, false, false},
+	}
+	for _, tt := range tests {
+		got, ok := A3MBool(tt.value)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("A3MBool(%#v) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestA3MProcessingConfig_UnmarshalJSONBools(t *testing.T) {
+	var config A3MProcessingConfig
+	if err := json.Unmarshal([]byte(`{"normalize": "false", "examine_contents": 1, "extractPackages": "1", "aipCompressionLevel": 5}`), &config); err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+	if config.Normalize || !config.ExamineContents || !config.ExtractPackages || config.AipCompressionLevel != 5 {
+		t.Errorf("Expected the boolean-ish values to be converted, got %v", &config)
+	}
+
+	for _, data := range []string{`{"normalize": "yes"}`, `{"examineContents": 2}`} {
+		err := json.Unmarshal([]byte(data), &config)
+		var fieldErr FieldError
+		if !errors.As(err, &fieldErr) {
+			t.Errorf("Expected a field error for %s, got %v", data, err)
+		}
+	}
+}
+
+func TestA3MProcessingConfig_MergeDefaults(t *testing.T) {
+	config := A3MProcessingConfig{ExamineContents: true}
+	config.MergeDefaults(map[string]any{"examine_contents": true, "deletePackagesAfterExtraction": false})
+
+	expected := NewA3MProcessingConfig()
+	expected.ExamineContents = true
+	if !config.Equal(&expected) {
+		t.Errorf("Expected the defaults with examine_contents set, got %v", &config)
+	}
+
+	// With no fields given, every field takes its default
+	empty := A3MProcessingConfig{Normalize: false, AipCompressionLevel: 9}
+	empty.MergeDefaults(nil)
+	defaults := NewA3MProcessingConfig()
+	if !empty.Equal(&defaults) {
+		t.Errorf("Expected the defaults, got %v", &empty)
+	}
+}
+
+func TestConfigBundleEntry_UnmarshalJSON(t *testing.T) {
+	var entry ConfigBundleEntry
+	if err := json.Unmarshal([]byte(`{"name": "Imported", "compress_aip": true, "a3m_config": {"normalize": false}}`), &entry); err != nil {
+		t.Fatalf("Failed to unmarshal entry: %v", err)
+	}
+	if entry.Name != "Imported" || !entry.CompressAIP {
+		t.Errorf("Expected the top-level fields to be decoded, got %+v", entry)
+	}
+	if entry.A3MConfig == nil {
+		t.Fatal("Expected an A3M config")
+	}
+	if entry.A3MConfig.Normalize {
+		t.Error("Expected normalize to stay explicitly false")
+	}
+	if !entry.A3MConfig.AssignUuidsToDirectories {
+		t.Error("Expected omitted A3M fields to take their defaults")
+	}
+
+	var bare ConfigBundleEntry
+	if err := json.Unmarshal([]byte(`{"name": "Bare"}`), &bare); err != nil {
+		t.Fatalf("Failed to unmarshal entry: %v", err)
+	}
+	if bare.A3MConfig != nil {
+		t.Error("Expected no A3M config when the entry has none")
+	}
+}
+
 func TestA3MProcessingConfig_UnmarshalJSON_DiscardUnknown(t *testing.T) {
 	// Test with unknown fields that should be discarded
 	jsonWithUnknown := `{
@@ -258,3 +443,133 @@ func TestA3MProcessingConfig_UnmarshalJSON_DiscardUnknown(t *testing.T) {
 		t.Errorf("Expected AipCompressionLevel to be 7, got %d", config.AipCompressionLevel)
 	}
 }
+
+func TestDiffA3MConfig(t *testing.T) {
+	a := NewA3MProcessingConfig()
+	b := NewA3MProcessingConfig()
+
+	if diff := DiffA3MConfig(&a, &b); len(diff) != 0 {
+		t.Errorf("Expected identical configs to have no differences, got %v", diff)
+	}
+
+	b.Normalize = false
+	b.AipCompressionLevel = 9
+	b.ThumbnailMode = transferservice.ProcessingConfig_THUMBNAIL_MODE_DO_NOT_GENERATE
+
+	diff := DiffA3MConfig(&a, &b)
+	expected := map[string][2]any{
+		"normalize":             {true, false},
+		"aip_compression_level": {int32(1), int32(9)},
+		"thumbnail_mode":        {int32(1), int32(3)},
+	}
+	if len(diff) != len(expected) {
+		t.Fatalf("Expected %d differences, got %v", len(expected), diff)
+	}
+	for field, values := range expected {
+		if diff[field] != values {
+			t.Errorf("Expected %s to be %v, got %v", field, values, diff[field])
+		}
+	}
+}
+
+func TestNonDefaultA3MFields(t *testing.T) {
+	config := NewA3MProcessingConfig()
+	if fields := NonDefaultA3MFields(&config); len(fields) != 0 {
+		t.Errorf("Expected the defaults to have no non-default fields, got %v", fields)
+	}
+
+	config.ExamineContents = !config.ExamineContents
+	config.AipCompressionLevel = 9
+	fields := NonDefaultA3MFields(&config)
+	expected := []string{"aip_compression_level", "examine_contents"}
+	if !slices.Equal(fields, expected) {
+		t.Errorf("Expected non-default fields %v, got %v", expected, fields)
+	}
+}
+
+func TestA3MProcessingConfig_Clone(t *testing.T) {
+	original := NewA3MProcessingConfig()
+	clone := original.Clone()
+
+	if !clone.Equal(&original) {
+		t.Fatal("Expected clone to equal the original")
+	}
+
+	clone.Normalize = false
+	clone.AipCompressionLevel = 9
+	clone.ThumbnailMode = transferservice.ProcessingConfig_THUMBNAIL_MODE_DO_NOT_GENERATE
+
+	if !original.Normalize {
+		t.Error("Expected changing the clone's Normalize to leave the original unchanged")
+	}
+	if original.AipCompressionLevel != 1 {
+		t.Errorf("Expected original AipCompressionLevel 1, got %d", original.AipCompressionLevel)
+	}
+	if original.ThumbnailMode != transferservice.ProcessingConfig_THUMBNAIL_MODE_GENERATE {
+		t.Errorf("Expected original ThumbnailMode GENERATE, got %v", original.ThumbnailMode)
+	}
+
+	var nilConfig *A3MProcessingConfig
+	if nilConfig.Clone() != nil {
+		t.Error("Expected clone of nil to be nil")
+	}
+}
+
+func TestA3MProcessingConfig_Equal(t *testing.T) {
+	base := NewA3MProcessingConfig()
+
+	// Changing any single field, enums included, must make the configs differ
+	fields := (*transferservice.ProcessingConfig)(&base).ProtoReflect().Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		t.Run(string(fd.Name()), func(t *testing.T) {
+			changed := base.Clone()
+			m := (*transferservice.ProcessingConfig)(changed).ProtoReflect()
+			v := m.Get(fd)
+			switch fd.Kind() {
+			case protoreflect.BoolKind:
+				m.Set(fd, protoreflect.ValueOfBool(!v.Bool()))
+			case protoreflect.Int32Kind:
+				m.Set(fd, protoreflect.ValueOfInt32(int32(v.Int())+1))
+			case protoreflect.EnumKind:
+				m.Set(fd, protoreflect.ValueOfEnum(v.Enum()+1))
+			default:
+				t.Fatalf("Unhandled field kind %v", fd.Kind())
+			}
+
+			if base.Equal(changed) || changed.Equal(&base) {
+				t.Errorf("Expected configs differing in %s not to be equal", fd.Name())
+			}
+		})
+	}
+
+	other := NewA3MProcessingConfig()
+	if !base.Equal(&other) {
+		t.Error("Expected two default configs to be equal")
+	}
+}
+
+func TestA3MProcessingConfig_FieldErrors(t *testing.T) {
+	config := NewA3MProcessingConfig()
+	if errs := config.FieldErrors(); len(errs) != 0 {
+		t.Errorf("Expected defaults to be valid, got %v", errs)
+	}
+
+	config.AipCompressionLevel = 10
+	config.AipCompressionAlgorithm = transferservice.ProcessingConfig_AIPCompressionAlgorithm(99)
+	config.ThumbnailMode = transferservice.ProcessingConfig_ThumbnailMode(99)
+
+	errs := config.FieldErrors()
+	fields := make([]string, 0, len(errs))
+	for _, err := range errs {
+		fields = append(fields, err.Field)
+	}
+	if expected := "[aip_compression_level aip_compression_algorithm thumbnail_mode]"; fmt.Sprint(fields) != expected {
+		t.Errorf("Expected errors for fields %s, got %v", expected, errs)
+	}
+
+	// Validate reports only the first problem
+	if err := config.Validate(); err == nil || err.Error() != errs[0].Message {
+		t.Errorf("Expected Validate to return %q, got %v", errs[0].Message, err)
+	}
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ConfigBundleSchemaVersion is the current version of the export bundle format
+const ConfigBundleSchemaVersion = 1
+
+// ConfigBundle is a portable export of preservation configurations.
+// It deliberately omits IDs and timestamps so it can be imported into another environment.
+type ConfigBundle struct {
+	SchemaVersion int                 `json:"schema_version"`
+	ExportedAt    time.Time           `json:"exported_at"`
+	Configs       []ConfigBundleEntry `json:"configs"`
+}
+
+// ConfigBundleEntry is a single preservation configuration within a bundle.
+// Its fields match the create request body, so entries can also be sent to the bulk create endpoint.
+type ConfigBundleEntry struct {
+	Name        string               `json:"name"`
+	Description string               `json:"description"`
+	CompressAIP bool                 `json:"compress_aip"`
+	Active      bool                 `json:"active"`
+	Tags        []string             `json:"tags,omitempty"`
+	A3MConfig   *A3MProcessingConfig `json:"a3m_config"`
+}
+
+// UnmarshalJSON decodes an entry the way the import endpoint reads it, so that a3m_config
+// fields the entry omits take their defaults instead of false or 0
+func (e *ConfigBundleEntry) UnmarshalJSON(data []byte) error {
+	// entry has the same fields without this method, so decoding into it doesn't recurse
+	type entry ConfigBundleEntry
+	var decoded struct {
+		entry
+		A3MConfig json.RawMessage `json:"a3m_config"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	*e = ConfigBundleEntry(decoded.entry)
+	e.A3MConfig = nil
+	if len(decoded.A3MConfig) > 0 && string(decoded.A3MConfig) != "null" {
+		e.A3MConfig = &A3MProcessingConfig{}
+		if err := e.A3MConfig.UnmarshalJSONWithDefaults(decoded.A3MConfig); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewConfigBundle creates a bundle of the given configs stamped with the current schema version and time
+func NewConfigBundle(configs []*PreservationConfig) *ConfigBundle {
+	bundle := &ConfigBundle{
+		SchemaVersion: ConfigBundleSchemaVersion,
+		ExportedAt:    time.Now().UTC(),
+		Configs:       make([]ConfigBundleEntry, 0, len(configs)),
+	}
+	for _, config := range configs {
+		bundle.Configs = append(bundle.Configs, NewConfigBundleEntry(config))
+	}
+	return bundle
+}
+
+// NewConfigBundleEntry creates the bundle entry for a config, which shares its A3M config
+func NewConfigBundleEntry(config *PreservationConfig) ConfigBundleEntry {
+	return ConfigBundleEntry{
+		Name:        config.Name,
+		Description: config.Description,
+		CompressAIP: config.CompressAIP,
+		Active:      config.Active,
+		Tags:        config.Tags,
+		A3MConfig:   &config.A3MConfig,
+	}
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"slices"
+
+	transferservice "github.com/penwern/curate-preservation-api/common/proto/a3m/gen/go/a3m/api/transferservice/v1beta1"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// DiffA3MConfig compares two A3M configurations field by field and returns the fields
+// that differ, keyed by their snake_case name, with the values from a and b.
+// Enum values are reported as numbers, matching the JSON encoding.
+func DiffA3MConfig(a, b *A3MProcessingConfig) map[string][2]any {
+	diff := make(map[string][2]any)
+
+	ma := (*transferservice.ProcessingConfig)(a).ProtoReflect()
+	mb := (*transferservice.ProcessingConfig)(b).ProtoReflect()
+	fields := ma.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		va, vb := ma.Get(fd), mb.Get(fd)
+		if va.Equal(vb) {
+			continue
+		}
+		diff[string(fd.Name())] = [2]any{diffValue(fd, va), diffValue(fd, vb)}
+	}
+	return diff
+}
+
+// NonDefaultA3MFields returns the snake_case names, in sorted order, of the A3M fields whose
+// values differ from the system defaults of NewA3MProcessingConfig
+func NonDefaultA3MFields(c *A3MProcessingConfig) []string {
+	defaults := NewA3MProcessingConfig()
+	fields := make([]string, 0)
+	for field := range DiffA3MConfig(&defaults, c) {
+		fields = append(fields, field)
+	}
+	slices.Sort(fields)
+	return fields
+}
+
+// DiffConfigs compares two preservation configurations, returning the name, description,
+// compress_aip, active and tags fields that differ plus any differing A3M fields prefixed with "a3m_config.".
+// IDs and timestamps are not compared.
+func DiffConfigs(a, b *PreservationConfig) map[string][2]any {
+	diff := make(map[string][2]any)
+	if a.Name != b.Name {
+		diff["name"] = [2]any{a.Name, b.Name}
+	}
+	if a.Description != b.Description {
+		diff["description"] = [2]any{a.Description, b.Description}
+	}
+	if a.CompressAIP != b.CompressAIP {
+		diff["compress_aip"] = [2]any{a.CompressAIP, b.CompressAIP}
+	}
+	if a.Active != b.Active {
+		diff["active"] = [2]any{a.Active, b.Active}
+	}
+	if !slices.Equal(a.Tags, b.Tags) {
+		diff["tags"] = [2]any{a.Tags, b.Tags}
+	}
+	for field, values := range DiffA3MConfig(&a.A3MConfig, &b.A3MConfig) {
+		diff["a3m_config."+field] = values
+	}
+	return diff
+}
+
+// diffValue converts a proto field value to a plain Go value for reporting
+func diffValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) any {
+	if fd.Kind() == protoreflect.EnumKind {
+		return int32(v.Enum())
+	}
+	return v.Interface()
+}
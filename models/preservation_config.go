@@ -1,16 +1,54 @@
 package models
 
 import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
+	"unicode/utf8"
+
+	transferservice "github.com/penwern/curate-preservation-api/common/proto/a3m/gen/go/a3m/api/transferservice/v1beta1"
+	"google.golang.org/protobuf/proto"
+)
+
+// Default limits on the length of a config's name and description, counted in characters
+const (
+	DefaultMaxNameLength        = 255
+	DefaultMaxDescriptionLength = 4096
+)
+
+// Limits on a config's tags
+const (
+	MaxTags      = 20
+	MaxTagLength = 32
 )
 
+// tagPattern is the form of a normalized tag, e.g. "legal" or "born-digital"
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// FieldError describes a problem with a single field of a config
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error returns the message, which names the field
+func (e FieldError) Error() string {
+	return e.Message
+}
+
 // PreservationConfig represents a preservation configuration stored in the database
 type PreservationConfig struct {
 	ID          int64               `json:"id"`
 	Name        string              `json:"name"`
 	Description string              `json:"description"`
 	CompressAIP bool                `json:"compress_aip"`
+	Active      bool                `json:"active"`
+	Tags        []string            `json:"tags"`
 	A3MConfig   A3MProcessingConfig `json:"a3m_config"`
+	Version     int64               `json:"version"`
 	CreatedAt   time.Time           `json:"created_at"`
 	UpdatedAt   time.Time           `json:"updated_at"`
 }
@@ -21,6 +59,117 @@ func NewPreservationConfig(name, description string) *PreservationConfig {
 		Name:        name,
 		Description: description,
 		CompressAIP: false,
+		Active:      true,
+		Tags:        []string{},
 		A3MConfig:   NewA3MProcessingConfig(),
 	}
 }
+
+// Clone returns a deep copy of c, so that changes to the copy don't affect c
+func (c *PreservationConfig) Clone() *PreservationConfig {
+	if c == nil {
+		return nil
+	}
+	clone := &PreservationConfig{
+		ID:          c.ID,
+		Name:        c.Name,
+		Description: c.Description,
+		CompressAIP: c.CompressAIP,
+		Active:      c.Active,
+		Tags:        slices.Clone(c.Tags),
+		Version:     c.Version,
+		CreatedAt:   c.CreatedAt,
+		UpdatedAt:   c.UpdatedAt,
+	}
+	proto.Merge((*transferservice.ProcessingConfig)(&clone.A3MConfig), (*transferservice.ProcessingConfig)(&c.A3MConfig))
+	return clone
+}
+
+// ToA3MConfig returns a copy of the A3M processing config as the transfer service's
+// ProcessingConfig, ready to send to A3M
+func (c *PreservationConfig) ToA3MConfig() *transferservice.ProcessingConfig {
+	return (*transferservice.ProcessingConfig)(c.A3MConfig.Clone())
+}
+
+// NormalizeName trims leading and trailing whitespace from a config name and collapses
+// internal runs of whitespace to a single space, so near-duplicate names compare equal
+func NormalizeName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// NormalizeTags lowercases and trims each tag, dropping repeats and sorting them, so that the
+// same set of tags is always stored the same way. Tags may use lowercase letters, digits, '-'
+// and '_', starting with a letter or digit, and be at most MaxTagLength characters; a config
+// may have at most MaxTags.
+func NormalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if len(tag) > MaxTagLength {
+			return nil, fmt.Errorf("tag %q must be at most %d characters", tag, MaxTagLength)
+		}
+		if !tagPattern.MatchString(tag) {
+			return nil, fmt.Errorf("tag %q must start with a letter or digit and contain only letters, digits, '-' and '_'", tag)
+		}
+		if !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	if len(normalized) > MaxTags {
+		return nil, fmt.Errorf("a config may have at most %d tags, got %d", MaxTags, len(normalized))
+	}
+	slices.Sort(normalized)
+	return normalized, nil
+}
+
+// Validate checks that the config has a name and a valid A3M configuration
+func (c *PreservationConfig) Validate() error {
+	if c.Name == "" {
+		return errors.New("name is required")
+	}
+	if err := c.A3MConfig.Validate(); err != nil {
+		return fmt.Errorf("invalid a3m_config: %w", err)
+	}
+	return c.ValidateCompression()
+}
+
+// ValidateCompression checks that CompressAIP does not contradict the A3M compression algorithm.
+// A compression level with compress_aip false is allowed: it is part of the defaults and is
+// simply unused when the AIP is not compressed.
+func (c *PreservationConfig) ValidateCompression() error {
+	if c.CompressAIP && !c.A3MConfig.CompressesAIP() {
+		return fmt.Errorf("compress_aip is true but aip_compression_algorithm %s does not compress the AIP; "+
+			"choose a compressing algorithm such as %s, or set compress_aip to false",
+			c.A3MConfig.AipCompressionAlgorithm,
+			transferservice.ProcessingConfig_AIP_COMPRESSION_ALGORITHM_S7_BZIP2)
+	}
+	return nil
+}
+
+// ValidateLengths checks that the name and description are no longer than the given number
+// of characters, returning the first problem found
+func (c *PreservationConfig) ValidateLengths(maxName, maxDescription int) error {
+	if errs := c.LengthErrors(maxName, maxDescription); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// LengthErrors checks the same as ValidateLengths but returns every problem found.
+// Length is counted in runes, so multi-byte characters count once.
+func (c *PreservationConfig) LengthErrors(maxName, maxDescription int) []FieldError {
+	var errs []FieldError
+	if n := utf8.RuneCountInString(c.Name); n > maxName {
+		errs = append(errs, FieldError{
+			Field:   "name",
+			Message: fmt.Sprintf("name must be at most %d characters, got %d", maxName, n),
+		})
+	}
+	if n := utf8.RuneCountInString(c.Description); n > maxDescription {
+		errs = append(errs, FieldError{
+			Field:   "description",
+			Message: fmt.Sprintf("description must be at most %d characters, got %d", maxDescription, n),
+		})
+	}
+	return errs
+}
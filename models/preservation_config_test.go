@@ -2,6 +2,9 @@ package models
 
 import (
 	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
@@ -26,6 +29,10 @@ func TestNewPreservationConfig(t *testing.T) {
 		t.Errorf("Expected CompressAIP to be false by default, got %v", config.CompressAIP)
 	}
 
+	if !config.Active {
+		t.Error("Expected new config to be active")
+	}
+
 	if config.ID != 0 {
 		t.Errorf("Expected ID to be 0 for new config, got %d", config.ID)
 	}
@@ -189,3 +196,209 @@ func TestPreservationConfig_LongStrings(t *testing.T) {
 		t.Error("Long description not preserved after JSON round-trip")
 	}
 }
+
+func TestPreservationConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		modify      func(c *PreservationConfig)
+		expectError bool
+	}{
+		{name: "Defaults are valid", modify: func(_ *PreservationConfig) {}},
+		{name: "Missing name", modify: func(c *PreservationConfig) { c.Name = "" }, expectError: true},
+		{name: "Compression level too high", modify: func(c *PreservationConfig) { c.A3MConfig.AipCompressionLevel = 10 }, expectError: true},
+		{name: "Compression level negative", modify: func(c *PreservationConfig) { c.A3MConfig.AipCompressionLevel = -1 }, expectError: true},
+		{
+			name: "Unknown thumbnail mode",
+			modify: func(c *PreservationConfig) {
+				c.A3MConfig.ThumbnailMode = transferservice.ProcessingConfig_ThumbnailMode(99)
+			},
+			expectError: true,
+		},
+		{
+			name: "Unknown compression algorithm",
+			modify: func(c *PreservationConfig) {
+				c.A3MConfig.AipCompressionAlgorithm = transferservice.ProcessingConfig_AIPCompressionAlgorithm(99)
+			},
+			expectError: true,
+		},
+		{
+			name: "Compression requested with copy algorithm",
+			modify: func(c *PreservationConfig) {
+				c.CompressAIP = true
+				c.A3MConfig.AipCompressionAlgorithm = transferservice.ProcessingConfig_AIP_COMPRESSION_ALGORITHM_S7_COPY
+			},
+			expectError: true,
+		},
+		{
+			name: "Compression requested with compressing algorithm",
+			modify: func(c *PreservationConfig) {
+				c.CompressAIP = true
+				c.A3MConfig.AipCompressionAlgorithm = transferservice.ProcessingConfig_AIP_COMPRESSION_ALGORITHM_TAR_GZIP
+			},
+		},
+		{name: "Compression level without compression", modify: func(c *PreservationConfig) { c.A3MConfig.AipCompressionLevel = 9 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewPreservationConfig("Test Config", "")
+			tt.modify(config)
+
+			err := config.Validate()
+			if tt.expectError && err == nil {
+				t.Error("Expected error, got nil")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestPreservationConfig_ValidateLengths(t *testing.T) {
+	tests := []struct {
+		name        string
+		configName  string
+		description string
+		expectError bool
+	}{
+		{name: "Within limits", configName: "abc", description: "abcde"},
+		{name: "Name over limit", configName: "abcd", expectError: true},
+		{name: "Description over limit", configName: "abc", description: "abcdef", expectError: true},
+		{name: "Multi-byte characters counted once", configName: "中文字", description: "🌟🌟🌟🌟🌟"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewPreservationConfig(tt.configName, tt.description)
+
+			err := config.ValidateLengths(3, 5)
+			if tt.expectError && err == nil {
+				t.Error("Expected error, got nil")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	tests := map[string]string{
+		"My Config":     "My Config",
+		"  My Config  ": "My Config",
+		"My \t  Config": "My Config",
+		"\n":            "",
+		"测试配置  🚀":       "测试配置 🚀",
+	}
+
+	for input, expected := range tests {
+		if got := NormalizeName(input); got != expected {
+			t.Errorf("NormalizeName(%q): expected %q, got %q", input, expected, got)
+		}
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	got, err := NormalizeTags([]string{" Media ", "legal", "media", "born-digital"})
+	if err != nil {
+		t.Fatalf("NormalizeTags failed: %v", err)
+	}
+	if want := []string{"born-digital", "legal", "media"}; !slices.Equal(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	tooMany := make([]string, MaxTags+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("tag%d", i)
+	}
+	for _, tags := range [][]string{{""}, {"-leading"}, {"two words"}, {"quote\""}, {strings.Repeat("a", MaxTagLength+1)}, tooMany} {
+		if _, err := NormalizeTags(tags); err == nil {
+			t.Errorf("Expected NormalizeTags(%q) to fail", tags)
+		}
+	}
+}
+
+func TestPresets(t *testing.T) {
+	presets := Presets()
+	found := make(map[string]bool, len(presets))
+	for name := range presets {
+		found[name] = true
+	}
+	for _, name := range []string{"default", "full", "minimal", "access"} {
+		if !found[name] {
+			t.Errorf("Expected preset %q to exist", name)
+		}
+	}
+	if len(PresetNames()) != len(presets) {
+		t.Errorf("Expected %d preset names, got %d", len(presets), len(PresetNames()))
+	}
+
+	for _, name := range PresetNames() {
+		config, ok := NewPreservationConfigFromPreset("Test", "", name)
+		if !ok {
+			t.Fatalf("Expected preset %q to build a config", name)
+		}
+		if err := config.Validate(); err != nil {
+			t.Errorf("Expected preset %q to be valid, got %v", name, err)
+		}
+		if PresetDescription(name) == "" {
+			t.Errorf("Expected preset %q to have a description", name)
+		}
+	}
+
+	minimal, _ := NewPresetA3MConfig("minimal")
+	if minimal.Normalize {
+		t.Error("Expected minimal preset not to normalize")
+	}
+	// Each call returns an independent copy
+	minimal.Normalize = true
+	if again, _ := NewPresetA3MConfig("minimal"); again.Normalize {
+		t.Error("Expected modifying a preset copy not to affect later copies")
+	}
+
+	if _, ok := NewPreservationConfigFromPreset("Test", "", "unknown"); ok {
+		t.Error("Expected unknown preset to be rejected")
+	}
+}
+
+func TestPreservationConfig_ToA3MConfig(t *testing.T) {
+	config := NewPreservationConfig("Test", "")
+	config.A3MConfig.AipCompressionLevel = 7
+
+	a3m := config.ToA3MConfig()
+	if a3m.GetAipCompressionLevel() != 7 {
+		t.Errorf("Expected AipCompressionLevel 7, got %d", a3m.GetAipCompressionLevel())
+	}
+	if !config.A3MConfig.Equal((*A3MProcessingConfig)(a3m)) {
+		t.Error("Expected the proto to hold the same values as the config")
+	}
+
+	a3m.Normalize = false
+	if !config.A3MConfig.Normalize {
+		t.Error("Expected changes to the proto to leave the config unchanged")
+	}
+}
+
+func TestPreservationConfig_Clone(t *testing.T) {
+	original := NewPreservationConfig("Original", "Before")
+	original.Tags = []string{"legal"}
+	original.A3MConfig.AipCompressionLevel = 7
+
+	clone := original.Clone()
+	if len(DiffConfigs(original, clone)) != 0 || clone.ID != original.ID || clone.Version != original.Version {
+		t.Fatalf("Expected the clone to equal the original, got %+v", clone)
+	}
+
+	clone.Name = "Changed"
+	clone.Tags[0] = "born-digital"
+	clone.A3MConfig.AipCompressionLevel = 9
+	if original.Name != "Original" || original.Tags[0] != "legal" || original.A3MConfig.AipCompressionLevel != 7 {
+		t.Errorf("Expected changes to the clone to leave the original unchanged, got %+v", original)
+	}
+
+	var nilConfig *PreservationConfig
+	if nilConfig.Clone() != nil {
+		t.Error("Expected clone of nil to be nil")
+	}
+}
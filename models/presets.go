@@ -0,0 +1,144 @@
+package models
+
+import (
+	"sort"
+
+	transferservice "github.com/penwern/curate-preservation-api/common/proto/a3m/gen/go/a3m/api/transferservice/v1beta1"
+)
+
+// preset describes a named A3M configuration template
+type preset struct {
+	description string
+	build       func() A3MProcessingConfig
+}
+
+// presets are the named starting points for new configs. Each build returns a fresh value
+// built from a literal so that callers never share or copy proto state.
+var presets = map[string]preset{
+	"default": {
+		description: "The standard defaults used when no preset is given",
+		build:       NewA3MProcessingConfig,
+	},
+	"full": {
+		description: "Full preservation: examine contents and run every identification, normalization and policy check",
+		build: func() A3MProcessingConfig {
+			return A3MProcessingConfig{
+				AssignUuidsToDirectories:                     true,
+				ExamineContents:                              true,
+				GenerateTransferStructureReport:              true,
+				DocumentEmptyDirectories:                     true,
+				ExtractPackages:                              true,
+				DeletePackagesAfterExtraction:                false,
+				IdentifyTransfer:                             true,
+				IdentifySubmissionAndMetadata:                true,
+				IdentifyBeforeNormalization:                  true,
+				Normalize:                                    true,
+				TranscribeFiles:                              true,
+				PerformPolicyChecksOnOriginals:               true,
+				PerformPolicyChecksOnPreservationDerivatives: true,
+				PerformPolicyChecksOnAccessDerivatives:       true,
+				ThumbnailMode:                                transferservice.ProcessingConfig_THUMBNAIL_MODE_GENERATE,
+				AipCompressionLevel:                          5,
+				AipCompressionAlgorithm:                      transferservice.ProcessingConfig_AIP_COMPRESSION_ALGORITHM_S7_BZIP2,
+			}
+		},
+	},
+	"minimal": {
+		description: "Minimal processing: identify files only, without normalization, transcription, policy checks or thumbnails",
+		build: func() A3MProcessingConfig {
+			return A3MProcessingConfig{
+				AssignUuidsToDirectories:                     true,
+				ExamineContents:                              false,
+				GenerateTransferStructureReport:              true,
+				DocumentEmptyDirectories:                     true,
+				ExtractPackages:                              true,
+				DeletePackagesAfterExtraction:                false,
+				IdentifyTransfer:                             true,
+				IdentifySubmissionAndMetadata:                true,
+				IdentifyBeforeNormalization:                  false,
+				Normalize:                                    false,
+				TranscribeFiles:                              false,
+				PerformPolicyChecksOnOriginals:               false,
+				PerformPolicyChecksOnPreservationDerivatives: false,
+				PerformPolicyChecksOnAccessDerivatives:       false,
+				ThumbnailMode:                                transferservice.ProcessingConfig_THUMBNAIL_MODE_DO_NOT_GENERATE,
+				AipCompressionLevel:                          1,
+				AipCompressionAlgorithm:                      transferservice.ProcessingConfig_AIP_COMPRESSION_ALGORITHM_S7_BZIP2,
+			}
+		},
+	},
+	"access": {
+		description: "Access-focused: normalize and check access derivatives, skipping preservation derivative checks",
+		build: func() A3MProcessingConfig {
+			return A3MProcessingConfig{
+				AssignUuidsToDirectories:                     true,
+				ExamineContents:                              false,
+				GenerateTransferStructureReport:              true,
+				DocumentEmptyDirectories:                     true,
+				ExtractPackages:                              true,
+				DeletePackagesAfterExtraction:                false,
+				IdentifyTransfer:                             true,
+				IdentifySubmissionAndMetadata:                true,
+				IdentifyBeforeNormalization:                  true,
+				Normalize:                                    true,
+				TranscribeFiles:                              false,
+				PerformPolicyChecksOnOriginals:               false,
+				PerformPolicyChecksOnPreservationDerivatives: false,
+				PerformPolicyChecksOnAccessDerivatives:       true,
+				ThumbnailMode:                                transferservice.ProcessingConfig_THUMBNAIL_MODE_GENERATE,
+				AipCompressionLevel:                          1,
+				AipCompressionAlgorithm:                      transferservice.ProcessingConfig_AIP_COMPRESSION_ALGORITHM_S7_BZIP2,
+			}
+		},
+	},
+}
+
+// Presets returns a fresh copy of every named A3M configuration template
+func Presets() map[string]A3MProcessingConfig {
+	result := make(map[string]A3MProcessingConfig, len(presets))
+	for name, p := range presets {
+		result[name] = p.build()
+	}
+	return result
+}
+
+// PresetNames returns the preset names in sorted order
+func PresetNames() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PresetDescription returns a human-readable description of the named preset
+func PresetDescription(name string) string {
+	return presets[name].description
+}
+
+// NewPresetA3MConfig returns a fresh copy of the named preset, or false if it does not exist
+func NewPresetA3MConfig(name string) (*A3MProcessingConfig, bool) {
+	p, ok := presets[name]
+	if !ok {
+		return nil, false
+	}
+	c := p.build()
+	return &c, true
+}
+
+// NewPreservationConfigFromPreset creates a new preservation configuration whose A3M settings
+// start from the named preset. It reports false if the preset does not exist.
+func NewPreservationConfigFromPreset(name, description, presetName string) (*PreservationConfig, bool) {
+	p, ok := presets[presetName]
+	if !ok {
+		return nil, false
+	}
+	return &PreservationConfig{
+		Name:        name,
+		Description: description,
+		CompressAIP: false,
+		Tags:        []string{},
+		A3MConfig:   p.build(),
+	}, true
+}
@@ -0,0 +1,132 @@
+package models
+
+import (
+	transferservice "github.com/penwern/curate-preservation-api/common/proto/a3m/gen/go/a3m/api/transferservice/v1beta1"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// JSONSchemaDialect is the JSON Schema version that ConfigSchema follows
+const JSONSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// ConfigSchema returns a JSON Schema describing a preservation config as accepted by the
+// create and update endpoints, with name and description limited to the given lengths.
+// The a3m_config properties are generated from the ProcessingConfig proto, so fields added
+// there appear here without further changes; defaults come from NewA3MProcessingConfig.
+func ConfigSchema(maxNameLength, maxDescriptionLength int) map[string]any {
+	return map[string]any{
+		"$schema":  JSONSchemaDialect,
+		"title":    "PreservationConfig",
+		"type":     "object",
+		"required": []string{"name"},
+		"properties": map[string]any{
+			"id": map[string]any{
+				"type":     "integer",
+				"readOnly": true,
+			},
+			"name": map[string]any{
+				"type":      "string",
+				"minLength": 1,
+				"maxLength": maxNameLength,
+			},
+			"description": map[string]any{
+				"type":      "string",
+				"maxLength": maxDescriptionLength,
+				"default":   "",
+			},
+			"compress_aip": map[string]any{
+				"type":        "boolean",
+				"default":     false,
+				"description": "Compress the AIP; requires a compressing aip_compression_algorithm",
+			},
+			"active": map[string]any{
+				"type":        "boolean",
+				"default":     true,
+				"description": "Whether the config is in use; retired configs are inactive",
+			},
+			"tags": map[string]any{
+				"type": "array",
+				"items": map[string]any{
+					"type":      "string",
+					"pattern":   tagPattern.String(),
+					"maxLength": MaxTagLength,
+				},
+				"maxItems":    MaxTags,
+				"uniqueItems": true,
+				"default":     []string{},
+				"description": "Labels for organizing configs, e.g. legal or media; lowercased when saved",
+			},
+			"a3m_config": A3MConfigSchema(),
+			"version": map[string]any{
+				"type":     "integer",
+				"readOnly": true,
+			},
+			"created_at": map[string]any{
+				"type":     "string",
+				"format":   "date-time",
+				"readOnly": true,
+			},
+			"updated_at": map[string]any{
+				"type":     "string",
+				"format":   "date-time",
+				"readOnly": true,
+			},
+		},
+	}
+}
+
+// A3MConfigSchema returns a JSON Schema describing the A3M processing config, built from the
+// ProcessingConfig proto descriptor. Enums list their numeric values, which is how configs
+// are read and written, with the matching names in x-enumNames.
+func A3MConfigSchema() map[string]any {
+	defaults := NewA3MProcessingConfig()
+	message := (*transferservice.ProcessingConfig)(&defaults).ProtoReflect()
+	fields := message.Descriptor().Fields()
+
+	properties := make(map[string]any, fields.Len())
+	for i := range fields.Len() {
+		field := fields.Get(i)
+		properties[string(field.Name())] = fieldSchema(field, message.Get(field))
+	}
+	return map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+}
+
+// fieldSchema describes a single proto field, with defaultValue as its default
+func fieldSchema(field protoreflect.FieldDescriptor, defaultValue protoreflect.Value) map[string]any {
+	switch field.Kind() {
+	case protoreflect.BoolKind:
+		return map[string]any{
+			"type":    "boolean",
+			"default": defaultValue.Bool(),
+		}
+	case protoreflect.EnumKind:
+		values := field.Enum().Values()
+		numbers := make([]int32, 0, values.Len())
+		names := make([]string, 0, values.Len())
+		for i := range values.Len() {
+			numbers = append(numbers, int32(values.Get(i).Number()))
+			names = append(names, string(values.Get(i).Name()))
+		}
+		return map[string]any{
+			"type":        "integer",
+			"enum":        numbers,
+			"x-enumNames": names,
+			"default":     int32(defaultValue.Enum()),
+		}
+	case protoreflect.Int32Kind:
+		schema := map[string]any{
+			"type":    "integer",
+			"default": defaultValue.Int(),
+		}
+		if field.Name() == "aip_compression_level" {
+			schema["minimum"] = MinAIPCompressionLevel
+			schema["maximum"] = MaxAIPCompressionLevel
+		}
+		return schema
+	default:
+		// An empty schema accepts any value, so a field of a new kind is at least listed
+		return map[string]any{}
+	}
+}
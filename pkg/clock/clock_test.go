@@ -0,0 +1,29 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUTC(t *testing.T) {
+	if loc := (UTC{}).Now().Location(); loc != time.UTC {
+		t.Errorf("Expected the time in UTC, got %v", loc)
+	}
+}
+
+func TestFake(t *testing.T) {
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	fake := NewFake(start)
+
+	if got := fake.Now(); !got.Equal(start) {
+		t.Errorf("Expected %v, got %v", start, got)
+	}
+	fake.Advance(90 * time.Second)
+	if got, want := fake.Now(), start.Add(90*time.Second); !got.Equal(want) {
+		t.Errorf("Expected %v after advancing, got %v", want, got)
+	}
+	fake.Set(start)
+	if got := fake.Now(); !got.Equal(start) {
+		t.Errorf("Expected %v after setting, got %v", start, got)
+	}
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// NormalizeBasePath returns basePath as the server mounts it: with a leading slash and no
+// trailing slash, or empty to serve from the root. It must be a plain path, without a
+// query, fragment or route patterns.
+func NormalizeBasePath(basePath string) (string, error) {
+	basePath = strings.Trim(strings.TrimSpace(basePath), "/")
+	if basePath == "" {
+		return "", nil
+	}
+	basePath = "/" + basePath
+
+	u, err := url.Parse(basePath)
+	if err != nil {
+		return "", fmt.Errorf("invalid base path %q: %w", basePath, err)
+	}
+	if u.EscapedPath() != basePath || u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("invalid base path %q: must be a path of URL-safe characters only", basePath)
+	}
+	if strings.ContainsAny(basePath, "{}*%") || strings.Contains(basePath, "//") {
+		return "", fmt.Errorf("invalid base path %q: must not contain route patterns or empty segments", basePath)
+	}
+	for _, segment := range strings.Split(basePath[1:], "/") {
+		if segment == "." || segment == ".." {
+			return "", fmt.Errorf("invalid base path %q: must not contain . or .. segments", basePath)
+		}
+	}
+	return basePath, nil
+}
@@ -0,0 +1,37 @@
+package config
+
+import "testing"
+
+func TestNormalizeBasePath(t *testing.T) {
+	tests := []struct {
+		basePath string
+		expected string
+		wantErr  bool
+	}{
+		{"", "", false},
+		{"/", "", false},
+		{"preservation", "/preservation", false},
+		{"/preservation/", "/preservation", false},
+		{" /curate/preservation ", "/curate/preservation", false},
+		{"/preservation?x=1", "", true},
+		{"/preservation#top", "", true},
+		{"/{tenant}", "", true},
+		{"/files/*", "", true},
+		{"/a//b", "", true},
+		{"/../admin", "", true},
+		{"/with space", "", true},
+		{"/with%20space", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.basePath, func(t *testing.T) {
+			got, err := NormalizeBasePath(tt.basePath)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NormalizeBasePath(%q) error = %v, wantErr %v", tt.basePath, err, tt.wantErr)
+			}
+			if got != tt.expected {
+				t.Errorf("NormalizeBasePath(%q) = %q, want %q", tt.basePath, got, tt.expected)
+			}
+		})
+	}
+}
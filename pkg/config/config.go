@@ -1,20 +1,114 @@
 // Package config provides the Config struct for application configuration.
 package config
 
+import "time"
+
 // Config holds the server configuration
 // DBType: "sqlite3" or "mysql"
 // DBConnection: Connection string for the database
 // Port: Port for the HTTP server
 // CORSOrigins: Allowed origins for CORS requests
+// CORSMethods: Methods allowed in CORS requests (empty uses GET, POST, PUT, DELETE and OPTIONS)
+// CORSHeaders: Request headers allowed in CORS requests (empty uses the headers the API reads)
+// CORSExposedHeaders: Response headers exposed to CORS requests (empty uses Link, ETag, X-Total-Count, Location and Preference-Applied)
+// CORSMaxAge: How long browsers may cache a preflight response (zero uses 5 minutes)
+// CORSAllowAll: Development mode that allows every origin, without credentials
 // SiteDomain: Domain for Pydio Cells OIDC and user endpoints
+// SiteDomains: Further Cells site domains a request may select with X-Cells-Domain or its Origin
+// OIDCAudience: Expected "aud" claim when validating JWT access tokens locally (empty skips the check)
 // TrustedIPs: List of IP addresses/CIDR ranges that bypass authentication
+// TrustedProxies: Proxy IP addresses/CIDR ranges whose X-Forwarded-For and X-Real-IP headers are believed
 // AllowInsecureTLS: Whether to allow insecure TLS connections when making OIDC/Pydio requests
+// AuthCacheTTL: Maximum time validated user info is cached (zero uses the 5 minute default)
+// AuthFailureLimit: Failed authentication attempts allowed per client IP within AuthFailureWindow (zero uses 10)
+// AuthFailureWindow: Period over which failed authentication attempts are counted (zero uses 1 minute)
+// AuthRetryAttempts: Tries per OIDC or Pydio request that fails with a network error or 5xx (zero uses 3)
+// AuthTimeout: Time each OIDC or Pydio request may take (zero uses 10 seconds)
+// AuthMaxIdleConns: Idle connections kept open to each Cells host for reuse (zero uses 10)
+// APIKeys: Static keys accepted via the X-API-Key header, plaintext or "sha256:<hex digest>"
+// TLSCertFile: PEM certificate file; with TLSKeyFile the server terminates TLS itself
+// TLSKeyFile: PEM private key file for TLSCertFile
+// TLSMinVersion: Minimum TLS version to negotiate, "1.2" (default) or "1.3"
+// MaxNameLength: Maximum characters in a config name (zero uses 255)
+// MaxDescriptionLength: Maximum characters in a config description (zero uses 4096)
+// MaxConfigs: Maximum number of configs that may exist; creating more fails (zero is unlimited)
+// StrictContentType: Whether request bodies must be declared as JSON or YAML (otherwise 415)
+// StrictJSON: Whether create and update reject unknown top-level fields with 400
+// RequestTimeout: Time a request may take before it is cancelled with 503 (zero uses 5 seconds)
+// BulkRequestTimeout: Time a bulk create, export or import may take before it is cancelled with 503 (zero uses 1 minute)
+// MaxConcurrentRequests: Maximum authenticated requests served at once; more wait, then get 503 (zero is unlimited)
+// ConcurrencyQueueTimeout: Time a request over MaxConcurrentRequests waits for a slot before 503 (zero uses 1 second)
+// ShutdownTimeout: Time shutdown waits for in-flight requests before closing their connections (zero uses 15 seconds)
+// BasePath: Path prefix the API is mounted under, e.g. "/preservation" (empty serves from the root)
+// HealthAtRoot: Whether health, ready and version are also served without BasePath
+// DefaultConfigFile: JSON or YAML preservation config seeded as the default instead of the built-in one
+// BackgroundMigrations: Whether migrations run after the server starts listening, answering 503 until done
+// SkipMigrations: Whether startup only checks the schema is current instead of migrating it, for running migrations separately
+// WebhookURL: URL config change events are posted to (empty disables the webhook)
+// WebhookSecret: Key for the HMAC-SHA256 signature of webhook events (empty leaves them unsigned)
+// ReadOnly: Whether the server starts in read-only maintenance mode, rejecting config writes with 503
+// Log: Logging level, file and rotation settings
 type Config struct {
-	DBType           string   `json:"db_type"`            // "sqlite3" or "mysql"
-	DBConnection     string   `json:"db_connection"`      // Connection string for the database
-	Port             int      `json:"port"`               // Port for the HTTP server
-	CORSOrigins      []string `json:"cors_origins"`       // Allowed origins for CORS requests
-	SiteDomain       string   `json:"site_domain"`        // Domain for Pydio Cells OIDC and user endpoints
-	TrustedIPs       []string `json:"trusted_ips"`        // IP addresses/CIDR ranges that bypass authentication
-	AllowInsecureTLS bool     `json:"allow_insecure_tls"` // Whether to allow insecure TLS connections
+	DBType                  string        `json:"db_type"`                   // "sqlite3" or "mysql"
+	DBConnection            string        `json:"db_connection"`             // Connection string for the database
+	Port                    int           `json:"port"`                      // Port for the HTTP server
+	CORSOrigins             []string      `json:"cors_origins"`              // Allowed origins for CORS requests
+	CORSMethods             []string      `json:"cors_methods"`              // Methods allowed in CORS requests
+	CORSHeaders             []string      `json:"cors_headers"`              // Request headers allowed in CORS requests
+	CORSExposedHeaders      []string      `json:"cors_exposed_headers"`      // Response headers exposed to CORS requests
+	CORSMaxAge              time.Duration `json:"cors_max_age"`              // How long browsers may cache a preflight response
+	CORSAllowAll            bool          `json:"cors_allow_all"`            // Whether every origin is allowed, without credentials
+	SiteDomain              string        `json:"site_domain"`               // Domain for Pydio Cells OIDC and user endpoints
+	SiteDomains             []string      `json:"site_domains"`              // Further Cells site domains requests may authenticate against
+	OIDCAudience            string        `json:"oidc_audience"`             // Expected audience for locally validated JWTs
+	TrustedIPs              []string      `json:"trusted_ips"`               // IP addresses/CIDR ranges that bypass authentication
+	TrustedProxies          []string      `json:"trusted_proxies"`           // Proxies whose forwarding headers are believed
+	AllowInsecureTLS        bool          `json:"allow_insecure_tls"`        // Whether to allow insecure TLS connections
+	AuthCacheTTL            time.Duration `json:"auth_cache_ttl"`            // Maximum time validated user info is cached
+	AuthFailureLimit        int           `json:"auth_failure_limit"`        // Failed auth attempts allowed per client IP per window
+	AuthFailureWindow       time.Duration `json:"auth_failure_window"`       // Period over which failed auth attempts are counted
+	AuthRetryAttempts       int           `json:"auth_retry_attempts"`       // Tries per upstream auth request on transient failures
+	AuthTimeout             time.Duration `json:"auth_timeout"`              // Time each upstream auth request may take
+	AuthMaxIdleConns        int           `json:"auth_max_idle_conns"`       // Idle connections kept open to each Cells host
+	APIKeys                 []string      `json:"api_keys"`                  // Static keys accepted via the X-API-Key header
+	TLSCertFile             string        `json:"tls_cert_file"`             // PEM certificate file for serving HTTPS
+	TLSKeyFile              string        `json:"tls_key_file"`              // PEM private key file for serving HTTPS
+	TLSMinVersion           string        `json:"tls_min_version"`           // Minimum TLS version, "1.2" or "1.3"
+	MaxNameLength           int           `json:"max_name_length"`           // Maximum characters in a config name
+	MaxDescriptionLength    int           `json:"max_description_length"`    // Maximum characters in a config description
+	MaxConfigs              int           `json:"max_configs"`               // Maximum number of configs, zero for unlimited
+	StrictContentType       bool          `json:"strict_content_type"`       // Whether request bodies must be declared as JSON or YAML
+	StrictJSON              bool          `json:"strict_json"`               // Whether unknown top-level fields in config bodies are rejected
+	RequestTimeout          time.Duration `json:"request_timeout"`           // Time a request may take before it is cancelled
+	BulkRequestTimeout      time.Duration `json:"bulk_request_timeout"`      // Time a bulk create, export or import may take
+	MaxConcurrentRequests   int           `json:"max_concurrent_requests"`   // Maximum authenticated requests served at once, zero for unlimited
+	ConcurrencyQueueTimeout time.Duration `json:"concurrency_queue_timeout"` // Time a request over the limit waits before it is shed
+	ShutdownTimeout         time.Duration `json:"shutdown_timeout"`          // Time shutdown waits for in-flight requests to finish
+	BasePath                string        `json:"base_path"`                 // Path prefix the API is mounted under
+	HealthAtRoot            bool          `json:"health_at_root"`            // Whether health, ready and version are also served without BasePath
+	DefaultConfigFile       string        `json:"default_config_file"`       // Preservation config seeded as the default
+	BackgroundMigrations    bool          `json:"background_migrations"`     // Whether migrations run in the background after startup
+	SkipMigrations          bool          `json:"skip_migrations"`           // Whether startup checks the schema version instead of migrating
+	WebhookURL              string        `json:"webhook_url"`               // URL config change events are posted to
+	WebhookSecret           string        `json:"webhook_secret"`            // Key for signing webhook events
+	ReadOnly                bool          `json:"read_only"`                 // Whether config writes are rejected for maintenance
+	Log                     LogConfig     `json:"log"`                       // Logging level, file and rotation settings
+}
+
+// LogConfig holds the logging configuration
+// Level: Minimum level to log (debug, info, warn, error, fatal, panic)
+// File: Path of the active log file (empty uses the default path, "-" logs to stdout only)
+// MaxSizeMB: Size in megabytes at which the log file is rotated (zero uses 100)
+// MaxAgeDays: Days to keep rotated log files (zero keeps them regardless of age)
+// MaxBackups: Number of rotated log files to keep (zero keeps them all)
+// Compress: Whether rotated log files are gzip compressed
+// Format: Log file encoding, "console" (default) or "json"; stdout always uses console
+type LogConfig struct {
+	Level      string `json:"level"`        // Minimum level to log
+	File       string `json:"file"`         // Path of the active log file
+	MaxSizeMB  int    `json:"max_size_mb"`  // Size in megabytes at which the log file is rotated
+	MaxAgeDays int    `json:"max_age_days"` // Days to keep rotated log files
+	MaxBackups int    `json:"max_backups"`  // Number of rotated log files to keep
+	Compress   bool   `json:"compress"`     // Whether rotated log files are gzip compressed
+	Format     string `json:"format"`       // Log file encoding, "console" or "json"
 }
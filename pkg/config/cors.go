@@ -0,0 +1,100 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// WildcardOrigin allows CORS requests from any origin
+const WildcardOrigin = "*"
+
+// DefaultCORSMaxAge is how long browsers may cache a preflight response when no max age is
+// configured. It is the longest value none of the major browsers ignore.
+const DefaultCORSMaxAge = 5 * time.Minute
+
+// DefaultCORSMethods are the methods allowed in CORS requests when none are configured
+var DefaultCORSMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
+
+// DefaultCORSHeaders are the request headers allowed in CORS requests when none are configured
+var DefaultCORSHeaders = []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "X-API-Key", "If-Match", "If-None-Match", "Idempotency-Key", "Content-Encoding", "Prefer"}
+
+// DefaultCORSExposedHeaders are the response headers exposed to CORS requests when none are configured
+var DefaultCORSExposedHeaders = []string{"Link", "ETag", "X-Total-Count", "Location", "Preference-Applied"}
+
+// ValidateCORSOrigin checks that origin is "*" or an http(s) scheme and host with no path,
+// query or fragment, which is the form browsers send in the Origin header
+func ValidateCORSOrigin(origin string) error {
+	if origin == WildcardOrigin {
+		return nil
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return fmt.Errorf("invalid CORS origin %q: %w", origin, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid CORS origin %q: must start with http:// or https://", origin)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid CORS origin %q: missing host", origin)
+	}
+	if u.User != nil || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("invalid CORS origin %q: must be a scheme and host only, without a path", origin)
+	}
+	return nil
+}
+
+// ValidateCORSOrigins validates every origin and reports all malformed entries together
+func ValidateCORSOrigins(origins []string) error {
+	var errs []error
+	for _, origin := range origins {
+		if err := ValidateCORSOrigin(origin); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// HasWildcardOrigin reports whether origins allows any origin
+func HasWildcardOrigin(origins []string) bool {
+	return slices.Contains(origins, WildcardOrigin)
+}
+
+// ValidateCORSMethods checks that every method is a valid HTTP method name
+func ValidateCORSMethods(methods []string) error {
+	var errs []error
+	for _, method := range methods {
+		if !isToken(method) {
+			errs = append(errs, fmt.Errorf("invalid CORS method %q", method))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ValidateCORSHeaders checks that every header is a valid HTTP header name or "*"
+func ValidateCORSHeaders(headers []string) error {
+	var errs []error
+	for _, header := range headers {
+		if header != WildcardOrigin && !isToken(header) {
+			errs = append(errs, fmt.Errorf("invalid CORS header %q", header))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// isToken reports whether s is an RFC 9110 token, the syntax of method and header names
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r <= ' ' || r > '~' || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, r) {
+			return false
+		}
+	}
+	return true
+}
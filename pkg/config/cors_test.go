@@ -0,0 +1,95 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateCORSOrigin(t *testing.T) {
+	tests := []struct {
+		origin  string
+		wantErr bool
+	}{
+		{"*", false},
+		{"https://cells.example.com", false},
+		{"http://localhost:8080", false},
+		{"https://cells.example.com/", false},
+		{"https://*.example.com", false},
+		{"cells.example.com", true},
+		{"localhost:8080", true},
+		{"ftp://cells.example.com", true},
+		{"https://", true},
+		{"https://cells.example.com/app", true},
+		{"https://cells.example.com?x=1", true},
+		{"https://user@cells.example.com", true},
+		{"", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			err := ValidateCORSOrigin(tt.origin)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCORSOrigin(%q) error = %v, wantErr %v", tt.origin, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateCORSOrigins(t *testing.T) {
+	if err := ValidateCORSOrigins([]string{"https://a.example.com", "http://localhost:3000"}); err != nil {
+		t.Errorf("Expected valid origins to pass, got %v", err)
+	}
+
+	err := ValidateCORSOrigins([]string{"a.example.com", "https://b.example.com", "https://c.example.com/path"})
+	if err == nil {
+		t.Fatal("Expected an error for malformed origins")
+	}
+	if !strings.Contains(err.Error(), "a.example.com") || !strings.Contains(err.Error(), "c.example.com/path") {
+		t.Errorf("Expected every malformed origin to be reported, got %v", err)
+	}
+	if strings.Contains(err.Error(), "b.example.com") {
+		t.Errorf("Expected valid origin not to be reported, got %v", err)
+	}
+}
+
+func TestHasWildcardOrigin(t *testing.T) {
+	if !HasWildcardOrigin([]string{"https://a.example.com", "*"}) {
+		t.Error("Expected wildcard to be detected")
+	}
+	if HasWildcardOrigin([]string{"https://a.example.com"}) {
+		t.Error("Expected no wildcard")
+	}
+}
+
+func TestValidateCORSMethods(t *testing.T) {
+	if err := ValidateCORSMethods(DefaultCORSMethods); err != nil {
+		t.Errorf("Expected the default methods to pass, got %v", err)
+	}
+	if err := ValidateCORSMethods([]string{"PATCH", "PROPFIND"}); err != nil {
+		t.Errorf("Expected extension methods to pass, got %v", err)
+	}
+
+	err := ValidateCORSMethods([]string{"GET POST", "", "PUT"})
+	if err == nil {
+		t.Fatal("Expected an error for malformed methods")
+	}
+	if !strings.Contains(err.Error(), `"GET POST"`) || !strings.Contains(err.Error(), `""`) {
+		t.Errorf("Expected every malformed method to be reported, got %v", err)
+	}
+	if strings.Contains(err.Error(), "PUT") {
+		t.Errorf("Expected valid method not to be reported, got %v", err)
+	}
+}
+
+func TestValidateCORSHeaders(t *testing.T) {
+	for _, headers := range [][]string{DefaultCORSHeaders, DefaultCORSExposedHeaders, {"X-Request-Id"}, {"*"}} {
+		if err := ValidateCORSHeaders(headers); err != nil {
+			t.Errorf("Expected %v to pass, got %v", headers, err)
+		}
+	}
+	for _, header := range []string{"X-Request-Id:", "X Request", "X-Ünicode", ""} {
+		if err := ValidateCORSHeaders([]string{header}); err == nil {
+			t.Errorf("Expected an error for header %q", header)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedactDSN(t *testing.T) {
+	tests := []struct {
+		dsn  string
+		want string
+	}{
+		{"root:secret@tcp(db:3306)/preservation?parseTime=true", "root:xxxxx@tcp(db:3306)/preservation?parseTime=true"},
+		{"root:p@ss:w/rd?@tcp(db:3306)/preservation", "root:xxxxx@tcp(db:3306)/preservation"},
+		{"root@tcp(db:3306)/preservation", "root@tcp(db:3306)/preservation"},
+		{"user:secret@unix(/var/run/mysqld/mysqld.sock)/preservation", "user:xxxxx@unix(/var/run/mysqld/mysqld.sock)/preservation"},
+		{"postgres://user:secret@db:5432/preservation?sslmode=disable", "postgres://user:xxxxx@db:5432/preservation?sslmode=disable"},
+		{"postgres://user@db/preservation", "postgres://user@db/preservation"},
+		{"/var/lib/preservation/db.sqlite", "/var/lib/preservation/db.sqlite"},
+		{"file:test.db?_auth&_auth_user=admin&_auth_pass=secret", "file:test.db?_auth&_auth_user=admin&_auth_pass=xxxxx"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dsn, func(t *testing.T) {
+			if got := RedactDSN(tt.dsn); got != tt.want {
+				t.Errorf("RedactDSN(%q) = %q, want %q", tt.dsn, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_Redacted(t *testing.T) {
+	cfg := Config{
+		DBType:       "mysql",
+		DBConnection: "root:secret@tcp(db:3306)/preservation",
+		SiteDomain:   "https://cells.example.com",
+		APIKeys:      []string{"plain-key", "sha256:abcd"},
+		TrustedIPs:   []string{"10.0.0.1"},
+	}
+
+	redacted := cfg.Redacted()
+	if strings.Contains(redacted.DBConnection, "secret") {
+		t.Errorf("Expected the database password to be masked, got %q", redacted.DBConnection)
+	}
+	for _, key := range redacted.APIKeys {
+		if key != RedactedValue {
+			t.Errorf("Expected API keys to be masked, got %q", key)
+		}
+	}
+	if len(redacted.APIKeys) != len(cfg.APIKeys) {
+		t.Errorf("Expected %d masked API keys, got %d", len(cfg.APIKeys), len(redacted.APIKeys))
+	}
+	if redacted.SiteDomain != cfg.SiteDomain {
+		t.Errorf("Expected other fields to be kept, got site domain %q", redacted.SiteDomain)
+	}
+
+	// The original is untouched
+	if cfg.APIKeys[0] != "plain-key" || cfg.DBConnection != "root:secret@tcp(db:3306)/preservation" {
+		t.Errorf("Expected the original config to be unchanged, got %+v", cfg)
+	}
+	redacted.TrustedIPs[0] = "changed"
+	if cfg.TrustedIPs[0] != "10.0.0.1" {
+		t.Error("Expected the redacted config not to share slices with the original")
+	}
+}
@@ -0,0 +1,27 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// ValidateSiteDomains checks that every additional site domain is an http(s) URL with a host,
+// such as https://cells.example.com, and reports all malformed entries together
+func ValidateSiteDomains(domains []string) error {
+	var errs []error
+	for _, domain := range domains {
+		u, err := url.Parse(domain)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("invalid site domain %q: %w", domain, err))
+		case u.Scheme != "http" && u.Scheme != "https":
+			errs = append(errs, fmt.Errorf("invalid site domain %q: must start with http:// or https://", domain))
+		case u.Host == "":
+			errs = append(errs, fmt.Errorf("invalid site domain %q: missing host", domain))
+		case u.User != nil || u.RawQuery != "" || u.Fragment != "":
+			errs = append(errs, fmt.Errorf("invalid site domain %q: must not have credentials, a query or a fragment", domain))
+		}
+	}
+	return errors.Join(errs...)
+}
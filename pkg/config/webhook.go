@@ -0,0 +1,25 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// ValidateWebhookURL checks that a webhook URL, if one is configured, is an absolute
+// http(s) URL with a host
+func ValidateWebhookURL(webhookURL string) error {
+	if webhookURL == "" {
+		return nil
+	}
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return fmt.Errorf("invalid webhook URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid webhook URL %q: must start with http:// or https://", RedactDSN(webhookURL))
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid webhook URL %q: missing host", RedactDSN(webhookURL))
+	}
+	return nil
+}
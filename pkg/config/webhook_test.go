@@ -0,0 +1,26 @@
+package config
+
+import "testing"
+
+func TestValidateWebhookURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		wantErr bool
+	}{
+		{"", false},
+		{"https://hooks.example.com/preservation", false},
+		{"http://workflow:8080/events?source=ca4m", false},
+		{"hooks.example.com/preservation", true},
+		{"ftp://hooks.example.com", true},
+		{"https://", true},
+		{"://bad", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			err := ValidateWebhookURL(tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateWebhookURL(%q) error = %v, wantErr %v", tt.url, err, tt.wantErr)
+			}
+		})
+	}
+}
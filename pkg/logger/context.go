@@ -0,0 +1,32 @@
+package logger
+
+import (
+	"context"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"go.uber.org/zap"
+)
+
+// contextKey is the context key under which a request-scoped logger is stored
+type contextKey struct{}
+
+// NewContext returns a copy of ctx that carries l, to be returned by FromContext
+func NewContext(ctx context.Context, l *zap.SugaredLogger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the logger stored in ctx by NewContext. Without one, it returns the
+// global logger, tagged with the chi request ID when ctx carries one.
+func FromContext(ctx context.Context) *zap.SugaredLogger {
+	if l, ok := ctx.Value(contextKey{}).(*zap.SugaredLogger); ok {
+		return l
+	}
+
+	// The global logger skips a frame for the package-level wrappers; callers of the
+	// returned logger log directly, so undo that to report the right caller
+	l := GetLogger().Desugar().WithOptions(zap.AddCallerSkip(-1)).Sugar()
+	if id := middleware.GetReqID(ctx); id != "" {
+		return l.With("request_id", id)
+	}
+	return l
+}
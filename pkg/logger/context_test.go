@@ -0,0 +1,72 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/penwern/curate-preservation-api/pkg/config"
+)
+
+func TestFromContext_RequestID(t *testing.T) {
+	tmpDir := t.TempDir()
+	logPath := filepath.Join(tmpDir, "test.log")
+
+	InitializeWithConfig(config.LogConfig{Level: "info", File: logPath, Format: "json"})
+
+	ctx := context.WithValue(context.Background(), middleware.RequestIDKey, "host/abc-000001")
+	FromContext(ctx).Infof("tagged %s", "message")
+
+	content, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+
+	var entry map[string]any
+	if err := json.Unmarshal(bytes.TrimSpace(content), &entry); err != nil {
+		t.Fatalf("Expected a JSON log line, got %q: %v", content, err)
+	}
+	if entry["msg"] != "tagged message" {
+		t.Errorf("Expected msg 'tagged message', got %v", entry["msg"])
+	}
+	if entry["request_id"] != "host/abc-000001" {
+		t.Errorf("Expected request_id 'host/abc-000001', got %v", entry["request_id"])
+	}
+	if caller, _ := entry["caller"].(string); !strings.HasPrefix(caller, "logger/context_test.go") {
+		t.Errorf("Expected caller in context_test.go, got %v", entry["caller"])
+	}
+}
+
+func TestFromContext_NoRequestID(t *testing.T) {
+	tmpDir := t.TempDir()
+	logPath := filepath.Join(tmpDir, "test.log")
+
+	InitializeWithConfig(config.LogConfig{Level: "info", File: logPath, Format: "json"})
+
+	FromContext(context.Background()).Info("untagged message")
+
+	content, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	if strings.Contains(string(content), "request_id") {
+		t.Errorf("Expected no request_id without one in the context, got %q", content)
+	}
+}
+
+func TestNewContext(t *testing.T) {
+	tmpDir := t.TempDir()
+	Initialize("info", filepath.Join(tmpDir, "test.log"))
+
+	stored := With("component", "test")
+	ctx := NewContext(context.Background(), stored)
+
+	if got := FromContext(ctx); got != stored {
+		t.Error("Expected FromContext to return the logger stored by NewContext")
+	}
+}
@@ -6,20 +6,87 @@ package logger
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/penwern/curate-preservation-api/pkg/config"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	// DefaultFile is the log file used when no path is configured
+	DefaultFile = "/var/log/curate/curate-preservation-api.log"
+	// NoFile as the log file path disables file logging, leaving only stdout
+	NoFile = "-"
 )
 
 // Global logger instance
 var log *zap.SugaredLogger
 
-// Initialize sets up the logger with the given log level and log file path
+// Initialize sets up the logger with the given log level and log file path,
+// using the default rotation settings
 func Initialize(level string, logFilePath string) {
+	InitializeWithConfig(config.LogConfig{Level: level, File: logFilePath})
+}
+
+// InitializeWithConfig sets up the logger from a logging configuration. The log file is
+// rotated once it reaches MaxSizeMB, and old files are pruned by MaxAgeDays and MaxBackups.
+// If File is NoFile, only stdout is logged to and no file or directory is created.
+func InitializeWithConfig(cfg config.LogConfig) {
+	zapLevel := parseLevel(cfg.Level)
+
+	// Console encoder config (minimal fields for journald)
+	consoleEncoderConfig := zap.NewProductionEncoderConfig()
+	consoleEncoderConfig.TimeKey = ""
+	consoleEncoderConfig.LevelKey = ""
+	consoleEncoderConfig.CallerKey = ""
+	consoleEncoder := zapcore.NewConsoleEncoder(consoleEncoderConfig)
+
+	// Outputs
+	consoleSyncer := zapcore.AddSync(os.Stdout)
+
+	// Cores
+	consoleCore := zapcore.NewCore(consoleEncoder, consoleSyncer, zapLevel)
+	core := consoleCore
+	if cfg.File != NoFile {
+		// Tee core
+		core = zapcore.NewTee(consoleCore, newFileCore(cfg, zapLevel))
+	}
+
+	logger := zap.New(core, zap.AddCaller(), zap.AddCallerSkip(1))
+	log = logger.Sugar()
+}
+
+// parseLevel maps a level name to a zap level, defaulting to info
+func parseLevel(level string) zapcore.Level {
+	switch level {
+	case "debug", "Debug", "DEBUG":
+		return zapcore.DebugLevel
+	case "info", "Info", "INFO":
+		return zapcore.InfoLevel
+	case "warn", "Warn", "WARN":
+		return zapcore.WarnLevel
+	case "error", "Error", "ERROR":
+		return zapcore.ErrorLevel
+	case "fatal", "Fatal", "FATAL":
+		return zapcore.FatalLevel
+	case "panic", "Panic", "PANIC":
+		return zapcore.PanicLevel
+	default:
+		return zapcore.InfoLevel
+	}
+}
+
+// newFileCore creates the log file and its directory and returns a core writing to it
+// through a rotating writer
+func newFileCore(cfg config.LogConfig, zapLevel zapcore.Level) zapcore.Core {
+	logFilePath := cfg.File
+
 	// Use default log file path if not provided
 	if logFilePath == "" {
-		logFilePath = "/var/log/curate/curate-preservation-api.log"
+		logFilePath = DefaultFile
 	}
 
 	// Validate and clean the log file path to prevent directory traversal
@@ -39,32 +106,6 @@ func Initialize(level string, logFilePath string) {
 		panic("failed to create log directory: " + err.Error())
 	}
 
-	// Parse log level
-	var zapLevel zapcore.Level
-	switch level {
-	case "debug", "Debug", "DEBUG":
-		zapLevel = zapcore.DebugLevel
-	case "info", "Info", "INFO":
-		zapLevel = zapcore.InfoLevel
-	case "warn", "Warn", "WARN":
-		zapLevel = zapcore.WarnLevel
-	case "error", "Error", "ERROR":
-		zapLevel = zapcore.ErrorLevel
-	case "fatal", "Fatal", "FATAL":
-		zapLevel = zapcore.FatalLevel
-	case "panic", "Panic", "PANIC":
-		zapLevel = zapcore.PanicLevel
-	default:
-		zapLevel = zapcore.InfoLevel
-	}
-
-	// Console encoder config (minimal fields for journald)
-	consoleEncoderConfig := zap.NewProductionEncoderConfig()
-	consoleEncoderConfig.TimeKey = ""
-	consoleEncoderConfig.LevelKey = ""
-	consoleEncoderConfig.CallerKey = ""
-	consoleEncoder := zapcore.NewConsoleEncoder(consoleEncoderConfig)
-
 	// File encoder config (full fields)
 	fileEncoderConfig := zap.NewProductionEncoderConfig()
 	fileEncoderConfig.TimeKey = "timestamp"
@@ -73,25 +114,33 @@ func Initialize(level string, logFilePath string) {
 	}
 	fileEncoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
 	fileEncoderConfig.EncodeCaller = zapcore.ShortCallerEncoder
-	fileEncoder := zapcore.NewConsoleEncoder(fileEncoderConfig)
+	var fileEncoder zapcore.Encoder
+	switch strings.ToLower(cfg.Format) {
+	case "json":
+		// One JSON object per line, with structured fields as top-level keys
+		fileEncoder = zapcore.NewJSONEncoder(fileEncoderConfig)
+	default:
+		fileEncoder = zapcore.NewConsoleEncoder(fileEncoderConfig)
+	}
 
-	// Outputs
-	consoleSyncer := zapcore.AddSync(os.Stdout)
+	// Create the active file up front so it gets 0600 permissions and open errors surface
+	// here; lumberjack keeps the mode of the existing file when it rotates
 	file, err := os.OpenFile(logFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
 	if err != nil {
 		panic("failed to open log file: " + err.Error())
 	}
-	fileSyncer := zapcore.AddSync(file)
-
-	// Cores
-	consoleCore := zapcore.NewCore(consoleEncoder, consoleSyncer, zapLevel)
-	fileCore := zapcore.NewCore(fileEncoder, fileSyncer, zapLevel)
-
-	// Tee core
-	core := zapcore.NewTee(consoleCore, fileCore)
-
-	logger := zap.New(core, zap.AddCaller(), zap.AddCallerSkip(1))
-	log = logger.Sugar()
+	if err := file.Close(); err != nil {
+		panic("failed to open log file: " + err.Error())
+	}
+	fileSyncer := zapcore.AddSync(&lumberjack.Logger{
+		Filename:   logFilePath,
+		MaxSize:    cfg.MaxSizeMB,
+		MaxAge:     cfg.MaxAgeDays,
+		MaxBackups: cfg.MaxBackups,
+		Compress:   cfg.Compress,
+	})
+
+	return zapcore.NewCore(fileEncoder, fileSyncer, zapLevel)
 }
 
 // GetLogger returns the global logger instance
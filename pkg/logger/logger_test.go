@@ -1,10 +1,14 @@
 package logger
 
 import (
+	"bytes"
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/penwern/curate-preservation-api/pkg/config"
 )
 
 func TestInitialize_ValidLevels(t *testing.T) {
@@ -68,6 +72,25 @@ func TestInitialize_EmptyLogPath(t *testing.T) {
 	Initialize("info", "")
 }
 
+func TestInitialize_NoFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Chdir(tmpDir)
+
+	Initialize("info", NoFile)
+	Info("stdout only")
+
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to read directory: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected no log file to be created, found %d entries", len(entries))
+	}
+	if GetLogger() == nil {
+		t.Error("Expected logger to be initialized")
+	}
+}
+
 func TestInitialize_RelativePath(t *testing.T) {
 	// This test modifies the working directory and cannot be run in parallel
 	tmpDir := t.TempDir()
@@ -207,6 +230,40 @@ func TestWith_StructuredLogging(t *testing.T) {
 	}
 }
 
+func TestWith_StructuredLoggingJSON(t *testing.T) {
+	tmpDir := t.TempDir()
+	logPath := filepath.Join(tmpDir, "test.log")
+
+	InitializeWithConfig(config.LogConfig{Level: "info", File: logPath, Format: "json"})
+
+	With("key1", "value1", "key2", 42).Info("structured message")
+
+	content, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+
+	var entry map[string]any
+	if err := json.Unmarshal(bytes.TrimSpace(content), &entry); err != nil {
+		t.Fatalf("Expected a JSON log line, got %q: %v", content, err)
+	}
+	if entry["msg"] != "structured message" {
+		t.Errorf("Expected msg 'structured message', got %v", entry["msg"])
+	}
+	if entry["key1"] != "value1" {
+		t.Errorf("Expected key1 'value1', got %v", entry["key1"])
+	}
+	if entry["key2"] != float64(42) {
+		t.Errorf("Expected key2 42, got %v", entry["key2"])
+	}
+	if entry["level"] != "INFO" {
+		t.Errorf("Expected level INFO, got %v", entry["level"])
+	}
+	if _, ok := entry["timestamp"]; !ok {
+		t.Error("Expected timestamp key in JSON log line")
+	}
+}
+
 func TestLogLevels_Filtering(t *testing.T) {
 	tmpDir := t.TempDir()
 	logPath := filepath.Join(tmpDir, "test.log")
@@ -328,6 +385,44 @@ func TestFilePermissions(t *testing.T) {
 	}
 }
 
+func TestInitializeWithConfig_Rotation(t *testing.T) {
+	tmpDir := t.TempDir()
+	logPath := filepath.Join(tmpDir, "test.log")
+
+	InitializeWithConfig(config.LogConfig{
+		Level:      "info",
+		File:       logPath,
+		MaxSizeMB:  1,
+		MaxBackups: 2,
+	})
+
+	// Write a little over 1MB so the active file is rotated at least once
+	line := strings.Repeat("x", 1024)
+	for range 1100 {
+		Info("%s", line)
+	}
+
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to read log directory: %v", err)
+	}
+	if len(entries) < 2 {
+		t.Fatalf("Expected a rotated backup alongside the active log file, got %d files", len(entries))
+	}
+
+	// The rotated-to active file keeps the original permissions
+	fileInfo, err := os.Stat(logPath)
+	if err != nil {
+		t.Fatalf("Failed to stat log file: %v", err)
+	}
+	if fileInfo.Mode().Perm() != 0o600 {
+		t.Errorf("Expected log file permissions 0600, got %o", fileInfo.Mode().Perm())
+	}
+	if fileInfo.Size() >= 1024*1024 {
+		t.Errorf("Expected active log file to be smaller than 1MB after rotation, got %d bytes", fileInfo.Size())
+	}
+}
+
 func TestConcurrentLogging(t *testing.T) {
 	tmpDir := t.TempDir()
 	logPath := filepath.Join(tmpDir, "test.log")
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/penwern/curate-preservation-api/pkg/logger"
+)
+
+// AccessLogger creates middleware that logs one line per request at info level through the
+// application logger, so access logs share the level, format and destination of the other
+// logs. Each line records the method, path, status, response size, duration and client IP,
+// along with the request ID carried by the request's logger. It must run after RealIP so
+// the client IP is the one forwarded by a trusted proxy.
+func AccessLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+
+		defer func() {
+			status := ww.Status()
+			if status == 0 {
+				// Nothing was written, which net/http answers with 200
+				status = http.StatusOK
+			}
+			logger.FromContext(r.Context()).Infow("Access",
+				"method", r.Method,
+				"path", r.URL.RequestURI(),
+				"proto", r.Proto,
+				"status", status,
+				"bytes", ww.BytesWritten(),
+				"duration", time.Since(start),
+				"client_ip", getClientIP(r),
+			)
+		}()
+
+		next.ServeHTTP(ww, r)
+	})
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/penwern/curate-preservation-api/pkg/config"
+	"github.com/penwern/curate-preservation-api/pkg/logger"
+)
+
+func TestAccessLogger(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "test.log")
+	logger.InitializeWithConfig(config.LogConfig{Level: "info", File: logPath, Format: "json"})
+	defer logger.Initialize("debug", "/tmp/curate-preservation-api.log")
+
+	_, proxy, _ := net.ParseCIDR("10.0.0.0/8")
+	handler := middleware.RequestID(requestLogger(RealIP([]*net.IPNet{proxy})(AccessLogger(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			respondWithJSON(w, http.StatusTeapot, map[string]string{"status": "brewing"})
+		}),
+	))))
+
+	req := httptest.NewRequest("GET", "/api/v1/preservation-configs?limit=5", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	content, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	var entry map[string]any
+	for line := range strings.SplitSeq(strings.TrimSpace(string(content)), "\n") {
+		var candidate map[string]any
+		if err := json.Unmarshal([]byte(line), &candidate); err == nil && candidate["msg"] == "Access" {
+			entry = candidate
+		}
+	}
+	if entry == nil {
+		t.Fatalf("Expected an access log entry, got %s", content)
+	}
+
+	want := map[string]any{
+		"method":    "GET",
+		"path":      "/api/v1/preservation-configs?limit=5",
+		"status":    float64(http.StatusTeapot),
+		"bytes":     float64(rr.Body.Len()),
+		"client_ip": "203.0.113.7",
+	}
+	for field, value := range want {
+		if entry[field] != value {
+			t.Errorf("Expected %s %v, got %v", field, value, entry[field])
+		}
+	}
+	if id, _ := entry["request_id"].(string); id == "" {
+		t.Error("Expected the access log entry to carry the request ID")
+	}
+	if _, ok := entry["duration"]; !ok {
+		t.Error("Expected the access log entry to record the duration")
+	}
+}
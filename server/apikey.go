@@ -0,0 +1,87 @@
+// Package server – static API key authentication for service clients
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const (
+	// apiKeyHeader is the request header carrying a static API key
+	apiKeyHeader = "X-API-Key"
+	// apiKeyHashPrefix marks a configured key given as a hex SHA-256 digest rather than plaintext
+	apiKeyHashPrefix = "sha256:"
+)
+
+// APIKeySet holds the SHA-256 digests of the configured API keys
+type APIKeySet struct {
+	digests [][sha256.Size]byte
+}
+
+// NewAPIKeySet parses configured API keys. Each key is either the plaintext key or
+// "sha256:<hex digest>" so that deployments need not store keys in the clear.
+func NewAPIKeySet(keys []string) (*APIKeySet, error) {
+	set := &APIKeySet{}
+	for _, key := range keys {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+
+		if hexDigest, hashed := strings.CutPrefix(key, apiKeyHashPrefix); hashed {
+			b, err := hex.DecodeString(hexDigest)
+			if err != nil || len(b) != sha256.Size {
+				return nil, fmt.Errorf("invalid hashed API key %q: expected %d hex-encoded bytes", key, sha256.Size)
+			}
+			var digest [sha256.Size]byte
+			copy(digest[:], b)
+			set.digests = append(set.digests, digest)
+			continue
+		}
+
+		set.digests = append(set.digests, sha256.Sum256([]byte(key)))
+	}
+	return set, nil
+}
+
+// Empty reports whether no API keys are configured
+func (s *APIKeySet) Empty() bool {
+	return s == nil || len(s.digests) == 0
+}
+
+// Match reports whether key is one of the configured API keys and returns a short,
+// non-secret identifier for it. Digests are compared in constant time.
+func (s *APIKeySet) Match(key string) (string, bool) {
+	if s.Empty() || key == "" {
+		return "", false
+	}
+
+	digest := sha256.Sum256([]byte(key))
+	matched := -1
+	for i := range s.digests {
+		if subtle.ConstantTimeCompare(digest[:], s.digests[i][:]) == 1 {
+			matched = i
+		}
+	}
+	if matched < 0 {
+		return "", false
+	}
+	return hex.EncodeToString(s.digests[matched][:4]), true
+}
+
+// apiKeyUserInfo returns the synthetic user for a request authenticated by API key
+func apiKeyUserInfo(keyID string) *UserInfo {
+	return &UserInfo{
+		Sub:           "api-key:" + keyID,
+		Email:         "service@internal",
+		Name:          "API Key Service User",
+		PreferredName: "service",
+		Login:         "api-key",
+		UUID:          "api-key:" + keyID,
+		GroupPath:     "/service",
+		Roles:         []UserRole{{Label: "service", UUID: "api-key-role"}},
+	}
+}
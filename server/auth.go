@@ -4,15 +4,18 @@ package server
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/penwern/curate-preservation-api/pkg/clock"
 	"github.com/penwern/curate-preservation-api/pkg/logger"
 )
 
@@ -43,6 +46,9 @@ type UserInfo struct {
 
 	// Additional fields that might be present
 	Attributes map[string]interface{} `json:"Attributes,omitempty"`
+
+	// SiteDomain is the Cells site domain the token was validated against
+	SiteDomain string `json:"-"`
 }
 
 // PydioUserQuery represents the query structure for Pydio user info
@@ -66,18 +72,30 @@ type CacheEntry struct {
 	ExpiresAt time.Time
 }
 
-// UserInfoCache provides thread-safe caching of user information
+// UserInfoCache provides thread-safe caching of user information.
+// Entries never outlive the token they were validated from, and ttl caps how long
+// any entry is kept.
 type UserInfoCache struct {
 	cache map[string]CacheEntry
 	mutex sync.RWMutex
 	ttl   time.Duration
+	done  chan struct{}
+	once  sync.Once
+	// hits and misses count Get calls, guarded by mutex
+	hits   uint64
+	misses uint64
+	// clock tells when entries expire, guarded by mutex
+	clock clock.Clock
 }
 
-// NewUserInfoCache creates a new user info cache with the specified TTL
+// NewUserInfoCache creates a new user info cache with the specified maximum TTL.
+// Call Stop to release the cleanup goroutine once the cache is no longer used.
 func NewUserInfoCache(ttl time.Duration) *UserInfoCache {
 	cache := &UserInfoCache{
 		cache: make(map[string]CacheEntry),
 		ttl:   ttl,
+		done:  make(chan struct{}),
+		clock: clock.UTC{},
 	}
 
 	// Start cleanup goroutine
@@ -86,49 +104,134 @@ func NewUserInfoCache(ttl time.Duration) *UserInfoCache {
 	return cache
 }
 
+// SetClock replaces the clock deciding when entries expire, which is the wall clock by default
+func (c *UserInfoCache) SetClock(clk clock.Clock) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.clock = clk
+}
+
 // Get retrieves user info from cache if valid
 func (c *UserInfoCache) Get(token string) (UserInfo, bool) {
-	c.mutex.RLock()
-	defer c.mutex.RUnlock()
+	// A write lock, since the hit and miss counters are updated
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 
 	entry, exists := c.cache[token]
-	if !exists || time.Now().After(entry.ExpiresAt) {
+	if !exists || c.clock.Now().After(entry.ExpiresAt) {
+		c.misses++
 		return UserInfo{}, false
 	}
 
+	c.hits++
 	return entry.UserInfo, true
 }
 
+// Hits returns the number of Get calls that found valid cached user info
+func (c *UserInfoCache) Hits() uint64 {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.hits
+}
+
+// Misses returns the number of Get calls that found no valid cached user info
+func (c *UserInfoCache) Misses() uint64 {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.misses
+}
+
+// Len returns the number of cached entries, including expired ones not yet cleaned up
+func (c *UserInfoCache) Len() int {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return len(c.cache)
+}
+
 // Set stores user info in cache with expiration
 func (c *UserInfoCache) Set(token string, userInfo UserInfo) {
+	c.SetWithExpiry(token, userInfo, time.Time{})
+}
+
+// SetWithExpiry stores user info in cache until the token expires, capped at the cache TTL.
+// A zero tokenExpiry means the token expiry is unknown and the cache TTL is used.
+func (c *UserInfoCache) SetWithExpiry(token string, userInfo UserInfo, tokenExpiry time.Time) {
+	c.setFor(token, userInfo, tokenExpiry, c.ttl)
+}
+
+// setFor is like SetWithExpiry, but keeps the entry for at most ttl when that is shorter
+// than the cache TTL
+func (c *UserInfoCache) setFor(token string, userInfo UserInfo, tokenExpiry time.Time, ttl time.Duration) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
+	expiresAt := c.clock.Now().Add(min(ttl, c.ttl))
+	if !tokenExpiry.IsZero() && tokenExpiry.Before(expiresAt) {
+		expiresAt = tokenExpiry
+	}
+
 	c.cache[token] = CacheEntry{
 		UserInfo:  userInfo,
-		ExpiresAt: time.Now().Add(c.ttl),
+		ExpiresAt: expiresAt,
 	}
 }
 
+// Invalidate removes the cached user info for a token so the next request re-validates upstream
+func (c *UserInfoCache) Invalidate(token string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	delete(c.cache, token)
+}
+
+// Clear removes all cached user info
+func (c *UserInfoCache) Clear() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.cache = make(map[string]CacheEntry)
+}
+
 // cleanup removes expired entries from cache
 func (c *UserInfoCache) cleanup() {
 	ticker := time.NewTicker(time.Minute)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		c.mutex.Lock()
-		now := time.Now()
-		for token, entry := range c.cache {
-			if now.After(entry.ExpiresAt) {
-				delete(c.cache, token)
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			c.mutex.Lock()
+			now := c.clock.Now()
+			removed := 0
+			for token, entry := range c.cache {
+				if now.After(entry.ExpiresAt) {
+					delete(c.cache, token)
+					removed++
+				}
 			}
+			entries, hits, misses := len(c.cache), c.hits, c.misses
+			c.mutex.Unlock()
+
+			logger.Debug("Auth cache: %d entries (%d expired removed), %d hits, %d misses, %.1f%% hit rate",
+				entries, removed, hits, misses, hitRate(hits, misses))
 		}
-		c.mutex.Unlock()
 	}
 }
 
-// Global cache instance
-var userInfoCache = NewUserInfoCache(5 * time.Minute)
+// hitRate returns hits as a percentage of all lookups
+func hitRate(hits, misses uint64) float64 {
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) * 100 / float64(hits+misses)
+}
+
+// Stop terminates the cleanup goroutine
+func (c *UserInfoCache) Stop() {
+	c.once.Do(func() { close(c.done) })
+}
 
 // parseIPOrCIDR parses an IP address or CIDR range
 func parseIPOrCIDR(ipStr string) (*net.IPNet, error) {
@@ -157,56 +260,39 @@ func parseIPOrCIDR(ipStr string) (*net.IPNet, error) {
 }
 
 // isIPTrusted checks if the given IP address is in the trusted IPs list
-func isIPTrusted(clientIP string, trustedIPs []string) bool {
+func isIPTrusted(ctx context.Context, clientIP string, trustedIPs []string) bool {
+	log := logger.FromContext(ctx)
 	if len(trustedIPs) == 0 {
 		return false
 	}
 
 	ip := net.ParseIP(clientIP)
 	if ip == nil {
-		logger.Debug("Auth: failed to parse client IP: %s", clientIP)
+		log.Debugf("Auth: failed to parse client IP: %s", clientIP)
 		return false
 	}
 
 	for _, trustedIP := range trustedIPs {
 		ipNet, err := parseIPOrCIDR(trustedIP)
 		if err != nil {
-			logger.Warn("Auth: failed to parse trusted IP/CIDR '%s': %v", trustedIP, err)
+			log.Warnf("Auth: failed to parse trusted IP/CIDR '%s': %v", trustedIP, err)
 			continue
 		}
 
 		if ipNet.Contains(ip) {
-			logger.Debug("Auth: client IP %s matches trusted IP/CIDR %s", clientIP, trustedIP)
+			log.Debugf("Auth: client IP %s matches trusted IP/CIDR %s", clientIP, trustedIP)
 			return true
 		}
 	}
 
-	logger.Debug("Auth: client IP %s not found in trusted IPs", clientIP)
+	log.Debugf("Auth: client IP %s not found in trusted IPs", clientIP)
 	return false
 }
 
-// getClientIP extracts the real client IP from the request
+// getClientIP returns the client IP of the request. Forwarding headers have already been
+// applied to RemoteAddr by RealIP when the request came through a trusted proxy.
 func getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header first (most common with proxies)
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		// X-Forwarded-For can contain multiple IPs, take the first one
-		ips := strings.Split(xff, ",")
-		if len(ips) > 0 {
-			return strings.TrimSpace(ips[0])
-		}
-	}
-
-	// Check X-Real-IP header (nginx)
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return strings.TrimSpace(xri)
-	}
-
-	// Fall back to RemoteAddr
-	host, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
-	}
-	return host
+	return remoteHost(r.RemoteAddr)
 }
 
 // getConfig returns configuration URLs for the specified site domain
@@ -221,158 +307,299 @@ func getConfig(siteDomain string) (string, string, string) {
 	return siteDomain, userinfoURL, pydioUserInfoURL
 }
 
-// validateTokenAndGetUserInfo validates token and retrieves user information using specified domain
-func validateTokenAndGetUserInfo(token string, siteDomain string, allowInsecureTLS bool) (*UserInfo, error) {
-	logger.Debug("Auth: validating token for domain: %s", siteDomain)
-
-	// Check cache first
-	if userInfo, found := userInfoCache.Get(token); found {
-		logger.Debug("Auth: using cached user info for user: %s", userInfo.Sub)
-		return &userInfo, nil
-	}
-
-	logger.Debug("Auth: no cached user info found, fetching from APIs")
-
-	_, userinfoURL, pydioUserInfoURL := getConfig(siteDomain)
-	logger.Debug("Auth: using OIDC userinfo URL: %s", userinfoURL)
-	logger.Debug("Auth: using Pydio user info URL: %s", pydioUserInfoURL)
-
-	// Step 1: Validate token with OIDC userinfo endpoint
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-		Transport: &http.Transport{
-			// #nosec G402 -- InsecureSkipVerify is configurable via AllowInsecureTLS for development/testing environments
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: allowInsecureTLS},
-		},
-	}
+// getIssuer returns the expected OIDC issuer for the specified site domain
+func getIssuer(siteDomain string) string {
+	siteDomain, _, _ = getConfig(siteDomain)
+	return fmt.Sprintf("%s/oidc", siteDomain)
+}
 
-	logger.Debug("Auth: making OIDC userinfo request")
-	req, err := http.NewRequest("GET", userinfoURL, nil)
-	if err != nil {
-		logger.Error("Auth: failed to create userinfo request: %v", err)
-		return nil, fmt.Errorf("failed to create userinfo request: %w", err)
-	}
-	req.Header.Set("Authorization", "Bearer "+token)
+// getJWKSURL returns the OIDC JWKS URL for the specified site domain
+func getJWKSURL(siteDomain string) string {
+	return fmt.Sprintf("%s/.well-known/jwks.json", getIssuer(siteDomain))
+}
 
-	resp, err := client.Do(req)
+// fetchOIDCUserInfo validates the token with the OIDC userinfo endpoint
+func fetchOIDCUserInfo(ctx context.Context, client *AuthClient, userinfoURL string, token string) (*UserInfo, error) {
+	log := logger.FromContext(ctx)
+	log.Debugf("Auth: making OIDC userinfo request")
+	resp, err := client.do(ctx, "userinfo", func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", userinfoURL, nil)
+		if err != nil {
+			log.Errorf("Auth: failed to create userinfo request: %v", err)
+			return nil, fmt.Errorf("failed to create userinfo request: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		return req, nil
+	})
 	if err != nil {
-		logger.Error("Auth: userinfo request failed: %v", err)
+		log.Errorf("Auth: userinfo request failed: %v", err)
 		return nil, fmt.Errorf("userinfo request failed: %w", err)
 	}
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
-			logger.Error("Auth: failed to close userinfo response body: %v", err)
+			log.Errorf("Auth: failed to close userinfo response body: %v", err)
 		}
 	}()
 
-	logger.Debug("Auth: OIDC userinfo response status: %d", resp.StatusCode)
+	log.Debugf("Auth: OIDC userinfo response status: %d", resp.StatusCode)
 
 	if resp.StatusCode != http.StatusOK {
-		logger.Error("Auth: userinfo request failed with status: %d", resp.StatusCode)
+		log.Errorf("Auth: userinfo request failed with status: %d", resp.StatusCode)
 		return nil, fmt.Errorf("userinfo request failed with status: %d", resp.StatusCode)
 	}
 
 	var oidcUserInfo UserInfo
 	if err := json.NewDecoder(resp.Body).Decode(&oidcUserInfo); err != nil {
-		logger.Error("Auth: failed to decode userinfo response: %v", err)
+		log.Errorf("Auth: failed to decode userinfo response: %v", err)
 		return nil, fmt.Errorf("failed to decode userinfo response: %w", err)
 	}
 
-	logger.Debug("Auth: OIDC user info retrieved for user: %s (email: %s, name: %s)", oidcUserInfo.Sub, oidcUserInfo.Email, oidcUserInfo.Name)
-
-	// Step 2: Get detailed user info from Pydio Cells
-	if oidcUserInfo.Sub == "" {
-		logger.Error("Auth: user UUID not found in OIDC user info")
-		return nil, fmt.Errorf("user UUID not found in OIDC user info")
-	}
+	log.Debugf("Auth: OIDC user info retrieved for user: %s (email: %s, name: %s)", oidcUserInfo.Sub, oidcUserInfo.Email, oidcUserInfo.Name)
+	return &oidcUserInfo, nil
+}
 
-	logger.Debug("Auth: making Pydio user info request for UUID: %s", oidcUserInfo.Sub)
+// fetchPydioUserInfo retrieves the detailed user info (roles, group) from Pydio Cells
+func fetchPydioUserInfo(ctx context.Context, client *AuthClient, pydioUserInfoURL string, token string, sub string) (*UserInfo, error) {
+	log := logger.FromContext(ctx)
+	log.Debugf("Auth: making Pydio user info request for UUID: %s", sub)
 
 	pydioQuery := PydioUserQuery{
-		Queries: []PydioQuery{{UUID: oidcUserInfo.Sub}},
+		Queries: []PydioQuery{{UUID: sub}},
 	}
 
 	queryBytes, err := json.Marshal(pydioQuery)
 	if err != nil {
-		logger.Error("Auth: failed to marshal Pydio query: %v", err)
+		log.Errorf("Auth: failed to marshal Pydio query: %v", err)
 		return nil, fmt.Errorf("failed to marshal Pydio query: %w", err)
 	}
 
-	logger.Debug("Auth: Pydio query payload: %s", string(queryBytes))
+	log.Debugf("Auth: Pydio query payload: %s", string(queryBytes))
 
-	pydioReq, err := http.NewRequest("POST", pydioUserInfoURL, bytes.NewBuffer(queryBytes))
-	if err != nil {
-		logger.Error("Auth: failed to create Pydio request: %v", err)
-		return nil, fmt.Errorf("failed to create Pydio request: %w", err)
-	}
-	pydioReq.Header.Set("Authorization", "Bearer "+token)
-	pydioReq.Header.Set("Content-Type", "application/json")
-
-	logger.Debug("Auth: making Pydio user info request")
-	logger.Debug("Auth: Pydio request headers: %v", pydioReq.Header)
-
-	pydioResp, err := client.Do(pydioReq)
+	log.Debugf("Auth: making Pydio user info request")
+	pydioResp, err := client.do(ctx, "pydio", func() (*http.Request, error) {
+		pydioReq, err := http.NewRequestWithContext(ctx, "POST", pydioUserInfoURL, bytes.NewReader(queryBytes))
+		if err != nil {
+			log.Errorf("Auth: failed to create Pydio request: %v", err)
+			return nil, fmt.Errorf("failed to create Pydio request: %w", err)
+		}
+		pydioReq.Header.Set("Authorization", "Bearer "+token)
+		pydioReq.Header.Set("Content-Type", "application/json")
+		return pydioReq, nil
+	})
 	if err != nil {
-		logger.Error("Auth: pydio request failed: %v", err)
+		log.Errorf("Auth: pydio request failed: %v", err)
 		return nil, fmt.Errorf("pydio request failed: %w", err)
 	}
 	defer func() {
 		if err := pydioResp.Body.Close(); err != nil {
-			logger.Error("Auth: failed to close Pydio response body: %v", err)
+			log.Errorf("Auth: failed to close Pydio response body: %v", err)
 		}
 	}()
 
-	logger.Debug("Auth: Pydio user info response status: %d", pydioResp.StatusCode)
+	log.Debugf("Auth: Pydio user info response status: %d", pydioResp.StatusCode)
 
 	if pydioResp.StatusCode != http.StatusOK {
-		logger.Error("Auth: pydio request failed with status: %d", pydioResp.StatusCode)
+		log.Errorf("Auth: pydio request failed with status: %d", pydioResp.StatusCode)
 		return nil, fmt.Errorf("pydio request failed with status: %d", pydioResp.StatusCode)
 	}
 
 	var pydioUserInfo PydioUserResponse
 	if err := json.NewDecoder(pydioResp.Body).Decode(&pydioUserInfo); err != nil {
-		logger.Error("Auth: failed to decode Pydio response: %v", err)
+		log.Errorf("Auth: failed to decode Pydio response: %v", err)
 		return nil, fmt.Errorf("failed to decode Pydio response: %w", err)
 	}
 
-	logger.Debug("Auth: Pydio user info retrieved, found %d users", len(pydioUserInfo.Users))
+	log.Debugf("Auth: Pydio user info retrieved, found %d users", len(pydioUserInfo.Users))
 
-	// Combine user info
 	if len(pydioUserInfo.Users) == 0 {
-		logger.Error("Auth: user not found in Pydio Cells")
+		log.Errorf("Auth: user not found in Pydio Cells")
 		return nil, fmt.Errorf("user not found in Pydio Cells")
 	}
+	if len(pydioUserInfo.Users) > 1 {
+		log.Warnf("Auth: Pydio returned %d users for UUID %s", len(pydioUserInfo.Users), sub)
+	}
+
+	userInfo, err := matchPydioUser(pydioUserInfo.Users, sub)
+	if err != nil {
+		log.Errorf("Auth: %v", err)
+		return nil, err
+	}
+	log.Debugf("Auth: Pydio user details - Login: %s, UUID: %s, GroupPath: %s", userInfo.Login, userInfo.UUID, userInfo.GroupPath)
+	return userInfo, nil
+}
+
+// matchPydioUser returns the one user among those Pydio returned whose UUID is sub. Pydio
+// should only return that user, so anything else is an error rather than a guess, as
+// taking another user would authenticate the wrong identity.
+func matchPydioUser(users []UserInfo, sub string) (*UserInfo, error) {
+	var match *UserInfo
+	for i := range users {
+		if users[i].UUID != sub {
+			continue
+		}
+		if match != nil {
+			return nil, fmt.Errorf("pydio returned several users with UUID %s", sub)
+		}
+		match = &users[i]
+	}
+	if match == nil {
+		return nil, fmt.Errorf("pydio returned no user with UUID %s", sub)
+	}
+	return match, nil
+}
+
+// degradedUserInfoTTL is how long user info without the Pydio roles, served while Cells is
+// unavailable, is cached
+const degradedUserInfoTTL = 30 * time.Second
+
+// validateTokenAndGetUserInfo validates token and retrieves user information using specified domain.
+// Signed JWTs are verified locally against the OIDC JWKS, which skips the userinfo round-trip;
+// other tokens are validated against the OIDC userinfo endpoint. Upstream requests are made with client.
+func validateTokenAndGetUserInfo(ctx context.Context, cache *UserInfoCache, client *AuthClient, token string, siteDomain string, audience string) (*UserInfo, error) {
+	log := logger.FromContext(ctx)
+	log.Debugf("Auth: validating token for domain: %s", siteDomain)
+
+	// Check cache first
+	if userInfo, found := cachedUserInfo(cache, token, siteDomain); found {
+		log.Debugf("Auth: using cached user info for user: %s", userInfo.Sub)
+		return &userInfo, nil
+	}
 
-	userInfo := pydioUserInfo.Users[0]
-	logger.Debug("Auth: Pydio user details - Login: %s, UUID: %s, GroupPath: %s", userInfo.Login, userInfo.UUID, userInfo.GroupPath)
+	log.Debugf("Auth: no cached user info found, fetching from APIs")
+
+	_, userinfoURL, pydioUserInfoURL := getConfig(siteDomain)
+	log.Debugf("Auth: using OIDC userinfo URL: %s", userinfoURL)
+	log.Debugf("Auth: using Pydio user info URL: %s", pydioUserInfoURL)
+
+	// Step 1: Validate the token, locally if it is a JWT we can verify, otherwise with the OIDC userinfo endpoint
+	var oidcUserInfo *UserInfo
+	claims, err := validateJWTLocally(ctx, client, token, siteDomain, audience)
+	switch {
+	case err == nil:
+		log.Debugf("Auth: token validated locally for user: %s", claims.Subject)
+		oidcUserInfo = &UserInfo{
+			Sub:           claims.Subject,
+			Email:         claims.Email,
+			Name:          claims.Name,
+			PreferredName: claims.PreferredUsername,
+		}
+	case errors.Is(err, errTokenExpired):
+		log.Errorf("Auth: locally validated token has expired")
+		return nil, err
+	default:
+		log.Debugf("Auth: local JWT validation not possible, falling back to userinfo endpoint: %v", err)
+		oidcUserInfo, err = fetchOIDCUserInfo(ctx, client, userinfoURL, token)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Step 2: Get detailed user info from Pydio Cells
+	if oidcUserInfo.Sub == "" {
+		log.Errorf("Auth: user UUID not found in OIDC user info")
+		return nil, fmt.Errorf("user UUID not found in OIDC user info")
+	}
+
+	degraded := false
+	userInfo, err := fetchPydioUserInfo(ctx, client, pydioUserInfoURL, token, oidcUserInfo.Sub)
+	if err != nil {
+		if claims == nil {
+			return nil, err
+		}
+		// The token signature is already verified, so keep serving the user while Cells is
+		// unavailable. The user is only cached briefly, so that the roles are restored soon
+		// after Cells is back.
+		degraded = true
+		log.Warnf("Auth: Pydio user info unavailable for locally validated user %s, continuing without roles: %v", claims.Subject, err)
+		userInfo = &UserInfo{
+			Login: claims.PreferredUsername,
+			UUID:  claims.Subject,
+		}
+	}
 
 	// Merge OIDC info
 	userInfo.Sub = oidcUserInfo.Sub
 	userInfo.Email = oidcUserInfo.Email
 	userInfo.Name = oidcUserInfo.Name
 	userInfo.PreferredName = oidcUserInfo.PreferredName
+	userInfo.SiteDomain = siteDomain
+
+	// Cache the result until the token expires
+	var tokenExpiry time.Time
+	if claims != nil {
+		tokenExpiry = claims.ExpiresAt.Time
+	} else if exp, ok := unverifiedTokenExpiry(token); ok {
+		// Upstream has just accepted the token, so its exp claim can be trusted for cache expiry
+		tokenExpiry = exp
+	}
+	log.Debugf("Auth: combined user info - storing in cache (token expiry: %v)", tokenExpiry)
+	if degraded {
+		cache.setFor(token, *userInfo, tokenExpiry, degradedUserInfoTTL)
+	} else {
+		cache.SetWithExpiry(token, *userInfo, tokenExpiry)
+	}
 
-	logger.Debug("Auth: combined user info - storing in cache")
-	// Cache the result
-	userInfoCache.Set(token, userInfo)
+	log.Debugf("Auth: user validation complete for: %s", userInfo.Sub)
+	return userInfo, nil
+}
+
+// cachedUserInfo returns the cached user info for token, if it was validated against
+// siteDomain. A token issued by one Cells instance is no proof of identity on another.
+func cachedUserInfo(cache *UserInfoCache, token, siteDomain string) (UserInfo, bool) {
+	userInfo, found := cache.Get(token)
+	return userInfo, found && userInfo.SiteDomain == siteDomain
+}
+
+var (
+	errMissingAuthHeader = errors.New("missing authorization header")
+	errInvalidAuthHeader = errors.New("invalid Authorization header format")
+)
+
+// bearerToken extracts the bearer token from the Authorization header
+func bearerToken(r *http.Request) (string, error) {
+	log := logger.FromContext(r.Context())
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return "", errMissingAuthHeader
+	}
+
+	log.Debugf("Auth: authorization header present (length: %d)", len(authHeader))
+
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+		log.Debugf("Auth: invalid Authorization header format: '%s'", authHeader)
+		return "", errInvalidAuthHeader
+	}
 
-	logger.Debug("Auth: user validation complete for: %s", userInfo.Sub)
-	return &userInfo, nil
+	return parts[1], nil
 }
 
-// TokenRequired creates a middleware that validates tokens using specified domain
-func TokenRequired(siteDomain string, trustedIPs []string, allowInsecureTLS bool) func(http.Handler) http.Handler {
+// TokenRequired creates a middleware that validates tokens against the site domain each
+// request selects from siteDomains, caching validated user info in the provided cache.
+// Requests naming a site domain that isn't allowed are rejected with 403. Requests carrying a configured
+// X-API-Key are authenticated as a service user without contacting OIDC/Pydio.
+// Clients that repeatedly fail validation are throttled by the limiter until its window ends.
+// Upstream JWKS, OIDC and Pydio requests are made with client.
+func TokenRequired(cache *UserInfoCache, client *AuthClient, limiter *AuthFailureLimiter, apiKeys *APIKeySet, siteDomains *SiteDomains, audience string, trustedIPs []string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			logger.Debug("Auth: starting authentication for %s %s", r.Method, r.URL.Path)
-			logger.Debug("Auth: site domain: '%s'", siteDomain)
+			log := logger.FromContext(r.Context())
+			log.Debugf("Auth: starting authentication for %s %s", r.Method, r.URL.Path)
+
+			siteDomain, err := siteDomains.Select(r)
+			if err != nil {
+				log.Warnf("Auth: rejecting request for unknown site domain %q", r.Header.Get(siteDomainHeader))
+				respondWithError(w, http.StatusForbidden, "Site domain not allowed")
+				return
+			}
+			log.Debugf("Auth: site domain: '%s'", siteDomain)
 
 			// Check if the client IP is trusted
 			clientIP := getClientIP(r)
-			logger.Debug("Auth: client IP: %s", clientIP)
+			log.Debugf("Auth: client IP: %s", clientIP)
 
-			if isIPTrusted(clientIP, trustedIPs) {
-				logger.Info("Auth: allowing trusted IP %s to bypass authentication", clientIP)
+			if isIPTrusted(r.Context(), clientIP, trustedIPs) {
+				log.Infof("Auth: allowing trusted IP %s to bypass authentication", clientIP)
 				// Create a minimal user info for trusted IPs
 				trustedUserInfo := &UserInfo{
 					Sub:           "trusted-ip:" + clientIP,
@@ -391,36 +618,63 @@ func TokenRequired(siteDomain string, trustedIPs []string, allowInsecureTLS bool
 				return
 			}
 
-			// Extract token from Authorization header
-			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" {
-				logger.Error("Auth failed: missing authorization header")
-				respondWithError(w, http.StatusUnauthorized, "Missing authorization header")
+			// Static API keys take precedence over bearer tokens
+			if apiKey := r.Header.Get(apiKeyHeader); apiKey != "" && !apiKeys.Empty() {
+				if retryAfter, blocked := limiter.Blocked(clientIP); blocked {
+					log.Warnf("Auth: throttling %s after repeated authentication failures", clientIP)
+					respondThrottled(w, retryAfter)
+					return
+				}
+
+				keyID, ok := apiKeys.Match(apiKey)
+				if !ok {
+					log.Errorf("Auth failed: invalid API key from %s", clientIP)
+					limiter.RecordFailure(clientIP)
+					respondWithError(w, http.StatusUnauthorized, "Invalid API key")
+					return
+				}
+
+				log.Debugf("Auth: authenticated API key %s from %s", keyID, clientIP)
+				ctx := context.WithValue(r.Context(), userInfoContextKey, apiKeyUserInfo(keyID))
+				next.ServeHTTP(w, r.WithContext(ctx))
 				return
 			}
 
-			logger.Debug("Auth: authorization header present (length: %d)", len(authHeader))
-
-			parts := strings.Split(authHeader, " ")
-			if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
-				logger.Error("Auth failed: invalid Authorization header format: '%s'", authHeader)
-				respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header format")
+			// Extract token from Authorization header
+			token, err := bearerToken(r)
+			if err != nil {
+				log.Errorf("Auth failed: %v", err)
+				if errors.Is(err, errMissingAuthHeader) {
+					respondWithError(w, http.StatusUnauthorized, "Missing authorization header")
+				} else {
+					respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header format")
+				}
 				return
 			}
 
-			token := parts[1]
-			logger.Debug("Auth: extracted bearer token (length: %d)", len(token))
+			log.Debugf("Auth: extracted bearer token (length: %d)", len(token))
+
+			// Throttle clients with too many recent failures, unless they present an
+			// already validated token, so upstream endpoints aren't hit on their behalf
+			if retryAfter, blocked := limiter.Blocked(clientIP); blocked {
+				if _, found := cachedUserInfo(cache, token, siteDomain); !found {
+					log.Warnf("Auth: throttling %s after repeated authentication failures", clientIP)
+					respondThrottled(w, retryAfter)
+					return
+				}
+			}
 
 			// Validate token and get user info
-			userInfo, err := validateTokenAndGetUserInfo(token, siteDomain, allowInsecureTLS)
+			userInfo, err := validateTokenAndGetUserInfo(r.Context(), cache, client, token, siteDomain, audience)
 			if err != nil {
-				logger.Error("Auth failed: %v", err)
+				log.Errorf("Auth failed: %v", err)
+				limiter.RecordFailure(clientIP)
 				respondWithError(w, http.StatusUnauthorized, "Invalid or expired token")
 				return
 			}
 
-			logger.Debug("Auth: token validation successful for user: %s (login: %s)", userInfo.Sub, userInfo.Login)
-			logger.Debug("Auth: authentication successful for user: %s, proceeding to handler", userInfo.Sub)
+			log.Debugf("Auth: token validation successful for user: %s (login: %s)", userInfo.Sub, userInfo.Login)
+			log.Debugf("Auth: authentication successful for user: %s, proceeding to handler", userInfo.Sub)
 
 			// Add user info to request context
 			ctx := context.WithValue(r.Context(), userInfoContextKey, userInfo)
@@ -429,9 +683,31 @@ func TokenRequired(siteDomain string, trustedIPs []string, allowInsecureTLS bool
 	}
 }
 
-// Auth creates middleware that validates tokens using specified domain
-func Auth(siteDomain string, trustedIPs []string, allowInsecureTLS bool) func(http.Handler) http.Handler {
-	return TokenRequired(siteDomain, trustedIPs, allowInsecureTLS)
+// respondThrottled writes a 429 response telling the client when it may retry
+func respondThrottled(w http.ResponseWriter, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	respondWithError(w, http.StatusTooManyRequests, "Too many failed authentication attempts")
+}
+
+// Auth creates middleware that validates tokens against the allowed site domains
+func Auth(cache *UserInfoCache, client *AuthClient, limiter *AuthFailureLimiter, apiKeys *APIKeySet, siteDomains *SiteDomains, audience string, trustedIPs []string) func(http.Handler) http.Handler {
+	return TokenRequired(cache, client, limiter, apiKeys, siteDomains, audience, trustedIPs)
+}
+
+// TrustedIPOnly creates middleware that only admits requests from trusted IPs
+func TrustedIPOnly(trustedIPs []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			log := logger.FromContext(r.Context())
+			clientIP := getClientIP(r)
+			if !isIPTrusted(r.Context(), clientIP, trustedIPs) {
+				log.Warnf("Auth: rejecting %s %s from untrusted IP %s", r.Method, r.URL.Path, clientIP)
+				respondWithError(w, http.StatusForbidden, "Forbidden")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
 }
 
 // GetUserInfo retrieves user info from request context
@@ -1,10 +1,27 @@
 package server
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/penwern/curate-preservation-api/pkg/clock"
 	"github.com/penwern/curate-preservation-api/pkg/config"
 	"github.com/penwern/curate-preservation-api/pkg/logger"
 )
@@ -131,10 +148,877 @@ func TestIPParsingFunctions(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := isIPTrusted(tt.clientIP, []string{tt.ipStr})
+			result := isIPTrusted(context.Background(), tt.clientIP, []string{tt.ipStr})
 			if result != tt.expected {
 				t.Errorf("Expected %v, got %v for IP %s against %s", tt.expected, result, tt.clientIP, tt.ipStr)
 			}
 		})
 	}
 }
+
+// newTestCellsServer starts a fake Pydio Cells instance serving the OIDC JWKS,
+// userinfo and Pydio user endpoints. userinfoHits counts userinfo requests.
+func newTestCellsServer(t *testing.T, key *rsa.PrivateKey, kid string, userinfoHits *int32) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oidc/.well-known/jwks.json", func(w http.ResponseWriter, _ *http.Request) {
+		respondWithJSON(w, http.StatusOK, map[string]any{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": kid,
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/oidc/userinfo", func(w http.ResponseWriter, _ *http.Request) {
+		atomic.AddInt32(userinfoHits, 1)
+		respondWithJSON(w, http.StatusOK, map[string]string{"sub": "user-uuid"})
+	})
+	mux.HandleFunc("/a/user", func(w http.ResponseWriter, _ *http.Request) {
+		respondWithJSON(w, http.StatusOK, map[string]any{
+			"Users": []map[string]any{{
+				"Login":     "jdoe",
+				"Uuid":      "user-uuid",
+				"GroupPath": "/",
+				"Roles":     []map[string]string{{"Label": "Admin", "Uuid": "ADMINS"}},
+			}},
+		})
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// signTestToken signs a JWT for the given site domain with the provided key
+func signTestToken(t *testing.T, key *rsa.PrivateKey, kid, siteDomain string, expiresAt time.Time) string {
+	t.Helper()
+
+	claims := JWTClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "user-uuid",
+			Issuer:    siteDomain + "/oidc",
+			Audience:  jwt.ClaimStrings{"cells-frontend"},
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+		Email:             "jdoe@example.com",
+		Name:              "John Doe",
+		PreferredUsername: "jdoe",
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return signed
+}
+
+func TestMatchPydioUser(t *testing.T) {
+	tests := []struct {
+		name      string
+		users     []UserInfo
+		wantLogin string
+		wantErr   bool
+	}{
+		{name: "single match", users: []UserInfo{{Login: "jdoe", UUID: "user-uuid"}}, wantLogin: "jdoe"},
+		{name: "match among others", users: []UserInfo{{Login: "admin", UUID: "admin-uuid"}, {Login: "jdoe", UUID: "user-uuid"}}, wantLogin: "jdoe"},
+		{name: "mismatched UUID", users: []UserInfo{{Login: "admin", UUID: "admin-uuid"}}, wantErr: true},
+		{name: "missing UUID", users: []UserInfo{{Login: "admin"}}, wantErr: true},
+		{name: "ambiguous", users: []UserInfo{{Login: "jdoe", UUID: "user-uuid"}, {Login: "jdoe2", UUID: "user-uuid"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := matchPydioUser(tt.users, "user-uuid")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("matchPydioUser() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && user.Login != tt.wantLogin {
+				t.Errorf("Expected user %s, got %s", tt.wantLogin, user.Login)
+			}
+		})
+	}
+}
+
+func TestFetchPydioUserInfo_MismatchedUser(t *testing.T) {
+	pydio := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		respondWithJSON(w, http.StatusOK, map[string]any{
+			"Users": []map[string]any{{"Login": "admin", "Uuid": "admin-uuid"}},
+		})
+	}))
+	defer pydio.Close()
+
+	_, err := fetchPydioUserInfo(context.Background(), newAuthClient(pydio.Client(), 1), pydio.URL+"/a/user", "token", "user-uuid")
+	if err == nil {
+		t.Fatal("Expected an error when Pydio returns a different user")
+	}
+}
+
+func TestValidateJWTLocally(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	var userinfoHits int32
+	cells := newTestCellsServer(t, key, "test-key", &userinfoHits)
+
+	tests := []struct {
+		name        string
+		token       string
+		audience    string
+		expectError bool
+	}{
+		{
+			name:     "Valid token",
+			token:    signTestToken(t, key, "test-key", cells.URL, time.Now().Add(time.Hour)),
+			audience: "cells-frontend",
+		},
+		{
+			name:        "Expired token",
+			token:       signTestToken(t, key, "test-key", cells.URL, time.Now().Add(-time.Hour)),
+			expectError: true,
+		},
+		{
+			name:        "Wrong issuer",
+			token:       signTestToken(t, key, "test-key", "https://other.example.com", time.Now().Add(time.Hour)),
+			expectError: true,
+		},
+		{
+			name:        "Wrong audience",
+			token:       signTestToken(t, key, "test-key", cells.URL, time.Now().Add(time.Hour)),
+			audience:    "another-client",
+			expectError: true,
+		},
+		{
+			name:        "Unknown key ID",
+			token:       signTestToken(t, key, "rotated-key", cells.URL, time.Now().Add(time.Hour)),
+			expectError: true,
+		},
+		{
+			name:        "Opaque token",
+			token:       "not-a-jwt",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := validateJWTLocally(context.Background(), newAuthClient(cells.Client(), 1), tt.token, cells.URL, tt.audience)
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected validation error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected token to validate, got: %v", err)
+			}
+			if claims.Subject != "user-uuid" {
+				t.Errorf("Expected subject 'user-uuid', got '%s'", claims.Subject)
+			}
+		})
+	}
+}
+
+func TestJWKSCache_SharesFetchAndRemembersFailures(t *testing.T) {
+	var hits int32
+	release := make(chan struct{})
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		<-release
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer jwks.Close()
+
+	cache := NewJWKSCache(time.Hour)
+	client := newAuthClient(jwks.Client(), 1)
+
+	// A request that gives up waiting doesn't hold up the others
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := cache.Key(ctx, client, jwks.URL, "test-key"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected waiting for the JWKS to stop with the request, got %v", err)
+	}
+
+	// Requests arriving while the fetch is in flight wait for it rather than fetching again
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := cache.Key(context.Background(), client, jwks.URL, "test-key"); err == nil {
+				t.Error("Expected an error from an unavailable JWKS endpoint")
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	// The failure is remembered, so the endpoint isn't asked again straight away
+	if _, err := cache.Key(context.Background(), client, jwks.URL, "test-key"); err == nil {
+		t.Error("Expected the remembered failure to be returned")
+	}
+	if n := atomic.LoadInt32(&hits); n != 1 {
+		t.Errorf("Expected a single JWKS fetch, got %d", n)
+	}
+}
+
+func TestJWKSCache_UsesAuthClient(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	jwks := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		respondWithJSON(w, http.StatusOK, map[string]any{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test-key",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	}))
+	defer jwks.Close()
+
+	// Only the test server's own client trusts its certificate
+	got, err := NewJWKSCache(time.Hour).Key(context.Background(), newAuthClient(jwks.Client(), 1), jwks.URL, "test-key")
+	if err != nil {
+		t.Fatalf("Expected the JWKS to be fetched with the auth client, got %v", err)
+	}
+	if pub, ok := got.(*rsa.PublicKey); !ok || pub.N.Cmp(key.N) != 0 {
+		t.Errorf("Expected the published key, got %v", got)
+	}
+}
+
+func TestValidateTokenAndGetUserInfo_LocalJWT(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	var userinfoHits int32
+	cells := newTestCellsServer(t, key, "test-key", &userinfoHits)
+	token := signTestToken(t, key, "test-key", cells.URL, time.Now().Add(time.Hour))
+
+	userInfo, err := validateTokenAndGetUserInfo(context.Background(), NewUserInfoCache(time.Minute), newAuthClient(cells.Client(), 1), token, cells.URL, "")
+	if err != nil {
+		t.Fatalf("Expected token to validate, got: %v", err)
+	}
+
+	if hits := atomic.LoadInt32(&userinfoHits); hits != 0 {
+		t.Errorf("Expected no userinfo requests for a locally validated JWT, got %d", hits)
+	}
+
+	if userInfo.Email != "jdoe@example.com" {
+		t.Errorf("Expected email from JWT claims, got '%s'", userInfo.Email)
+	}
+
+	if userInfo.Login != "jdoe" || len(userInfo.Roles) != 1 {
+		t.Errorf("Expected Pydio login and roles to be merged, got %+v", userInfo)
+	}
+}
+
+func TestValidateTokenAndGetUserInfo_PydioRecovers(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	var userinfoHits int32
+	upstream := newTestCellsServer(t, key, "test-key", &userinfoHits)
+	upstreamURL, err := url.Parse(upstream.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse upstream URL: %v", err)
+	}
+	// Cells whose Pydio user endpoint can be taken down
+	var pydioDown atomic.Bool
+	proxy := httputil.NewSingleHostReverseProxy(upstreamURL)
+	cells := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if pydioDown.Load() && r.URL.Path == "/a/user" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		proxy.ServeHTTP(w, r)
+	}))
+	defer cells.Close()
+
+	cache := NewUserInfoCache(time.Hour)
+	defer cache.Stop()
+	fake := clock.NewFake(time.Now())
+	cache.SetClock(fake)
+	client := newAuthClient(cells.Client(), 1)
+	token := signTestToken(t, key, "test-key", cells.URL, time.Now().Add(time.Hour))
+
+	// While Pydio is down, the verified user is served without roles
+	pydioDown.Store(true)
+	userInfo, err := validateTokenAndGetUserInfo(context.Background(), cache, client, token, cells.URL, "")
+	if err != nil {
+		t.Fatalf("Expected the locally validated token to be accepted, got: %v", err)
+	}
+	if len(userInfo.Roles) != 0 {
+		t.Fatalf("Expected no roles while Pydio is down, got %+v", userInfo.Roles)
+	}
+
+	// Once Pydio is back, the roles are restored as soon as the degraded entry expires
+	pydioDown.Store(false)
+	fake.Advance(degradedUserInfoTTL + time.Second)
+	userInfo, err = validateTokenAndGetUserInfo(context.Background(), cache, client, token, cells.URL, "")
+	if err != nil {
+		t.Fatalf("Expected token to validate, got: %v", err)
+	}
+	if len(userInfo.Roles) != 1 {
+		t.Errorf("Expected the Pydio roles once Cells recovered, got %+v", userInfo)
+	}
+
+	// The complete user info is cached for longer
+	fake.Advance(degradedUserInfoTTL + time.Second)
+	if cached, found := cache.Get(token); !found || len(cached.Roles) != 1 {
+		t.Errorf("Expected the user with roles to be cached, got %+v (found: %v)", cached, found)
+	}
+}
+
+func TestValidateTokenAndGetUserInfo_OpaqueTokenFallsBack(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	var userinfoHits int32
+	cells := newTestCellsServer(t, key, "test-key", &userinfoHits)
+
+	if _, err := validateTokenAndGetUserInfo(context.Background(), NewUserInfoCache(time.Minute), newAuthClient(cells.Client(), 1), "opaque-access-token", cells.URL, ""); err != nil {
+		t.Fatalf("Expected opaque token to validate upstream, got: %v", err)
+	}
+
+	if hits := atomic.LoadInt32(&userinfoHits); hits != 1 {
+		t.Errorf("Expected 1 userinfo request for an opaque token, got %d", hits)
+	}
+}
+
+func TestUserInfoCache_Stats(t *testing.T) {
+	cache := NewUserInfoCache(5 * time.Minute)
+	defer cache.Stop()
+
+	cache.Set("token-a", UserInfo{Sub: "a"})
+	cache.SetWithExpiry("token-expired", UserInfo{Sub: "b"}, time.Now().Add(-time.Second))
+
+	cache.Get("token-a")
+	cache.Get("token-a")
+	cache.Get("token-missing")
+	cache.Get("token-expired")
+
+	if hits := cache.Hits(); hits != 2 {
+		t.Errorf("Expected 2 hits, got %d", hits)
+	}
+	if misses := cache.Misses(); misses != 2 {
+		t.Errorf("Expected 2 misses, got %d", misses)
+	}
+	// The expired entry is counted until the cleanup cycle removes it
+	if n := cache.Len(); n != 2 {
+		t.Errorf("Expected 2 entries, got %d", n)
+	}
+
+	cache.Invalidate("token-a")
+	if n := cache.Len(); n != 1 {
+		t.Errorf("Expected 1 entry after invalidation, got %d", n)
+	}
+
+	if rate := hitRate(2, 2); rate != 50 {
+		t.Errorf("Expected a 50%% hit rate, got %.1f", rate)
+	}
+	if rate := hitRate(0, 0); rate != 0 {
+		t.Errorf("Expected a 0%% hit rate with no lookups, got %.1f", rate)
+	}
+}
+
+func TestUserInfoCache_ConcurrentStats(t *testing.T) {
+	cache := NewUserInfoCache(5 * time.Minute)
+	defer cache.Stop()
+	cache.Set("token", UserInfo{Sub: "user"})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				cache.Get("token")
+				cache.Get("missing")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if hits, misses := cache.Hits(), cache.Misses(); hits != 1000 || misses != 1000 {
+		t.Errorf("Expected 1000 hits and 1000 misses, got %d and %d", hits, misses)
+	}
+}
+
+func TestUserInfoCache_SetWithExpiry(t *testing.T) {
+	cache := NewUserInfoCache(5 * time.Minute)
+	defer cache.Stop()
+	userInfo := UserInfo{Sub: "user-uuid"}
+
+	tests := []struct {
+		name          string
+		tokenExpiry   time.Time
+		expectCached  bool
+		maxExpiryFrom time.Duration
+	}{
+		{
+			name:          "Unknown expiry uses cache TTL",
+			tokenExpiry:   time.Time{},
+			expectCached:  true,
+			maxExpiryFrom: 5 * time.Minute,
+		},
+		{
+			name:          "Short-lived token expires with the token",
+			tokenExpiry:   time.Now().Add(30 * time.Second),
+			expectCached:  true,
+			maxExpiryFrom: 30 * time.Second,
+		},
+		{
+			name:          "Long-lived token is capped at cache TTL",
+			tokenExpiry:   time.Now().Add(24 * time.Hour),
+			expectCached:  true,
+			maxExpiryFrom: 5 * time.Minute,
+		},
+		{
+			name:         "Expired token is not served from cache",
+			tokenExpiry:  time.Now().Add(-time.Second),
+			expectCached: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := "token-" + tt.name
+			cache.SetWithExpiry(token, userInfo, tt.tokenExpiry)
+
+			_, found := cache.Get(token)
+			if found != tt.expectCached {
+				t.Fatalf("Expected cached=%v, got %v", tt.expectCached, found)
+			}
+
+			if tt.expectCached {
+				entry := cache.cache[token]
+				if entry.ExpiresAt.After(time.Now().Add(tt.maxExpiryFrom)) {
+					t.Errorf("Expected entry to expire within %v, expires at %v", tt.maxExpiryFrom, entry.ExpiresAt)
+				}
+			}
+		})
+	}
+}
+
+func TestUserInfoCache_Clock(t *testing.T) {
+	cache := NewUserInfoCache(5 * time.Minute)
+	defer cache.Stop()
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	fake := clock.NewFake(now)
+	cache.SetClock(fake)
+
+	cache.Set("ttl-token", UserInfo{Sub: "user-uuid"})
+	cache.SetWithExpiry("short-token", UserInfo{Sub: "user-uuid"}, now.Add(time.Minute))
+	if got, want := cache.cache["ttl-token"].ExpiresAt, now.Add(5*time.Minute); !got.Equal(want) {
+		t.Errorf("Expected the entry to expire at %v, got %v", want, got)
+	}
+
+	// Entries are served until the instant they expire
+	fake.Advance(time.Minute)
+	if _, found := cache.Get("short-token"); !found {
+		t.Error("Expected the short-lived token to be cached until its expiry")
+	}
+	fake.Advance(time.Nanosecond)
+	if _, found := cache.Get("short-token"); found {
+		t.Error("Expected the short-lived token to expire with the token")
+	}
+	if _, found := cache.Get("ttl-token"); !found {
+		t.Error("Expected the other token to still be cached")
+	}
+
+	fake.Set(now.Add(5*time.Minute + time.Nanosecond))
+	if _, found := cache.Get("ttl-token"); found {
+		t.Error("Expected the token to expire after the cache TTL")
+	}
+}
+
+func TestLogout_InvalidatesCachedToken(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	token := "logout-test-token"
+	server.userInfoCache.Set(token, UserInfo{Sub: "user-uuid"})
+
+	req := setupTestRequest("POST", "/api/v1/auth/logout", nil)
+	req.RemoteAddr = "8.8.8.8:12345" // untrusted, so the cached token authenticates the request
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("Expected status %d, got %d", http.StatusNoContent, rr.Code)
+	}
+
+	if _, found := server.userInfoCache.Get(token); found {
+		t.Error("Expected token to be removed from the cache after logout")
+	}
+}
+
+func TestInvalidateTokens(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	tests := []struct {
+		name           string
+		remoteAddr     string
+		body           string
+		expectedStatus int
+		expectCached   bool
+	}{
+		{
+			name:           "Untrusted IP is rejected",
+			remoteAddr:     "8.8.8.8:12345",
+			body:           `{"token":"revoked-token"}`,
+			expectedStatus: http.StatusForbidden,
+			expectCached:   true,
+		},
+		{
+			name:           "Missing token",
+			remoteAddr:     "127.0.0.1:12345",
+			body:           `{}`,
+			expectedStatus: http.StatusBadRequest,
+			expectCached:   true,
+		},
+		{
+			name:           "Single token",
+			remoteAddr:     "127.0.0.1:12345",
+			body:           `{"token":"revoked-token"}`,
+			expectedStatus: http.StatusNoContent,
+			expectCached:   false,
+		},
+		{
+			name:           "All tokens",
+			remoteAddr:     "127.0.0.1:12345",
+			body:           `{"all":true}`,
+			expectedStatus: http.StatusNoContent,
+			expectCached:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server.userInfoCache.Set("revoked-token", UserInfo{Sub: "user-uuid"})
+
+			req := setupTestRequest("POST", "/api/v1/auth/invalidate", bytes.NewBufferString(tt.body))
+			req.RemoteAddr = tt.remoteAddr
+
+			rr := httptest.NewRecorder()
+			server.router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+
+			if _, found := server.userInfoCache.Get("revoked-token"); found != tt.expectCached {
+				t.Errorf("Expected cached=%v, got %v", tt.expectCached, found)
+			}
+		})
+	}
+}
+
+func TestServer_UserInfoCacheIsPerServer(t *testing.T) {
+	newServer := func(ttl time.Duration) *Server {
+		cfg := config.Config{
+			DBType:       testDBType,
+			DBConnection: filepath.Join(t.TempDir(), "test.db"),
+			Port:         8080,
+			AuthCacheTTL: ttl,
+		}
+		server, err := New(cfg)
+		if err != nil {
+			t.Fatalf("Failed to create test server: %v", err)
+		}
+		return server
+	}
+
+	first := newServer(time.Minute)
+	defer first.Shutdown()
+	second := newServer(0)
+	defer second.Shutdown()
+
+	if first.userInfoCache.ttl != time.Minute {
+		t.Errorf("Expected configured TTL %v, got %v", time.Minute, first.userInfoCache.ttl)
+	}
+	if second.userInfoCache.ttl != defaultAuthCacheTTL {
+		t.Errorf("Expected default TTL %v, got %v", defaultAuthCacheTTL, second.userInfoCache.ttl)
+	}
+
+	first.userInfoCache.Set("shared-token", UserInfo{Sub: "user-uuid"})
+	if _, found := second.userInfoCache.Get("shared-token"); found {
+		t.Error("Expected token cached on one server not to be visible on another")
+	}
+}
+
+func TestAuthFailureLimiter(t *testing.T) {
+	limiter := NewAuthFailureLimiter(2, time.Minute)
+	defer limiter.Stop()
+
+	if _, blocked := limiter.Blocked("8.8.8.8"); blocked {
+		t.Fatal("Expected new client not to be blocked")
+	}
+
+	limiter.RecordFailure("8.8.8.8")
+	if _, blocked := limiter.Blocked("8.8.8.8"); blocked {
+		t.Fatal("Expected client under the limit not to be blocked")
+	}
+
+	limiter.RecordFailure("8.8.8.8")
+	retryAfter, blocked := limiter.Blocked("8.8.8.8")
+	if !blocked {
+		t.Fatal("Expected client at the limit to be blocked")
+	}
+	if retryAfter <= 0 || retryAfter > time.Minute {
+		t.Errorf("Expected retry after within the window, got %v", retryAfter)
+	}
+
+	if _, blocked := limiter.Blocked("8.8.4.4"); blocked {
+		t.Error("Expected other clients not to be blocked")
+	}
+
+	// An expired window unblocks the client
+	limiter.records["8.8.8.8"].windowStart = time.Now().Add(-2 * time.Minute)
+	if _, blocked := limiter.Blocked("8.8.8.8"); blocked {
+		t.Error("Expected client to be unblocked after the window ends")
+	}
+}
+
+func TestTokenRequired_ThrottlesFailedAttempts(t *testing.T) {
+	// Point auth at an upstream that rejects everything so unknown tokens fail
+	cells := httptest.NewServer(http.NotFoundHandler())
+	defer cells.Close()
+
+	server, err := New(config.Config{
+		DBType:            testDBType,
+		DBConnection:      filepath.Join(t.TempDir(), "test.db"),
+		Port:              8080,
+		SiteDomain:        cells.URL,
+		AuthFailureLimit:  2,
+		AuthFailureWindow: time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create test server: %v", err)
+	}
+	defer server.Shutdown()
+
+	server.userInfoCache.Set("valid-token", UserInfo{Sub: "user-uuid", SiteDomain: cells.URL})
+
+	doRequest := func(token string) *httptest.ResponseRecorder {
+		req := setupTestRequest("GET", "/api/v1/preservation-configs", nil)
+		req.RemoteAddr = "8.8.8.8:12345"
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for i := 0; i < 2; i++ {
+		if rr := doRequest("guessed-token"); rr.Code != http.StatusUnauthorized {
+			t.Fatalf("Attempt %d: expected status %d, got %d", i+1, http.StatusUnauthorized, rr.Code)
+		}
+	}
+
+	rr := doRequest("guessed-token")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status %d, got %d", http.StatusTooManyRequests, rr.Code)
+	}
+	if rr.Header().Get("Retry-After") == "" {
+		t.Error("Expected Retry-After header on throttled response")
+	}
+
+	if rr := doRequest("valid-token"); rr.Code != http.StatusOK {
+		t.Errorf("Expected already validated token to be exempt, got status %d", rr.Code)
+	}
+}
+
+func TestSiteDomains_Select(t *testing.T) {
+	domains := NewSiteDomains("https://cells.example.com", []string{"https://tenant-b.example.com/", "https://Tenant-C.example.com"})
+
+	tests := []struct {
+		name    string
+		header  string
+		origin  string
+		want    string
+		wantErr bool
+	}{
+		{name: "default", want: "https://cells.example.com"},
+		{name: "header", header: "https://tenant-b.example.com", want: "https://tenant-b.example.com"},
+		{name: "header normalized", header: "HTTPS://tenant-c.example.com/", want: "https://Tenant-C.example.com"},
+		{name: "header naming the default", header: "https://cells.example.com", want: "https://cells.example.com"},
+		{name: "unlisted header", header: "https://evil.example.com", wantErr: true},
+		{name: "unlisted header with listed origin", header: "https://evil.example.com", origin: "https://tenant-b.example.com", wantErr: true},
+		{name: "origin", origin: "https://tenant-b.example.com", want: "https://tenant-b.example.com"},
+		{name: "unlisted origin", origin: "https://ui.example.com", want: "https://cells.example.com"},
+		{name: "header over origin", header: "https://tenant-c.example.com", origin: "https://tenant-b.example.com", want: "https://Tenant-C.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/preservation-configs", nil)
+			if tt.header != "" {
+				req.Header.Set(siteDomainHeader, tt.header)
+			}
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			got, err := domains.Select(req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Select() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Select() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenRequired_SiteDomains(t *testing.T) {
+	// Both Cells instances reject every token, so only cached tokens authenticate
+	tenantA := httptest.NewServer(http.NotFoundHandler())
+	defer tenantA.Close()
+	tenantB := httptest.NewServer(http.NotFoundHandler())
+	defer tenantB.Close()
+
+	server, err := New(config.Config{
+		DBType:       testDBType,
+		DBConnection: filepath.Join(t.TempDir(), "test.db"),
+		SiteDomain:   tenantA.URL,
+		SiteDomains:  []string{tenantB.URL},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create test server: %v", err)
+	}
+	defer server.Shutdown()
+
+	server.userInfoCache.Set("token-a", UserInfo{Sub: "user-a", SiteDomain: tenantA.URL})
+	server.userInfoCache.Set("token-b", UserInfo{Sub: "user-b", SiteDomain: tenantB.URL})
+
+	doRequest := func(token, domain string) int {
+		req := setupTestRequest("GET", "/api/v1/preservation-configs", nil)
+		req.RemoteAddr = "8.8.8.8:12345"
+		req.Header.Set("Authorization", "Bearer "+token)
+		if domain != "" {
+			req.Header.Set(siteDomainHeader, domain)
+		}
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		domain string
+		want   int
+	}{
+		{"default domain", "token-a", "", http.StatusOK},
+		{"selected domain", "token-b", tenantB.URL, http.StatusOK},
+		{"token from another domain", "token-a", tenantB.URL, http.StatusUnauthorized},
+		{"token for a selected domain without the header", "token-b", "", http.StatusUnauthorized},
+		{"unlisted domain", "token-a", "https://evil.example.com", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := doRequest(tt.token, tt.domain); got != tt.want {
+				t.Errorf("Expected status %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNew_InvalidSiteDomains(t *testing.T) {
+	_, err := New(config.Config{
+		DBType:       testDBType,
+		DBConnection: filepath.Join(t.TempDir(), "test.db"),
+		SiteDomains:  []string{"tenant-b.example.com"},
+	})
+	if err == nil {
+		t.Fatal("Expected an error for a site domain without a scheme")
+	}
+}
+
+func TestAPIKeySet(t *testing.T) {
+	hashed := sha256.Sum256([]byte("hashed-key"))
+	keys, err := NewAPIKeySet([]string{"plain-key", "sha256:" + hex.EncodeToString(hashed[:]), " "})
+	if err != nil {
+		t.Fatalf("Failed to parse API keys: %v", err)
+	}
+
+	for _, key := range []string{"plain-key", "hashed-key"} {
+		if _, ok := keys.Match(key); !ok {
+			t.Errorf("Expected key %q to match", key)
+		}
+	}
+	for _, key := range []string{"", "plain", "wrong-key"} {
+		if _, ok := keys.Match(key); ok {
+			t.Errorf("Expected key %q not to match", key)
+		}
+	}
+
+	if _, err := NewAPIKeySet([]string{"sha256:not-hex"}); err == nil {
+		t.Error("Expected error for malformed hashed key")
+	}
+}
+
+func TestTokenRequired_APIKey(t *testing.T) {
+	server, err := New(config.Config{
+		DBType:       testDBType,
+		DBConnection: filepath.Join(t.TempDir(), "test.db"),
+		Port:         8080,
+		APIKeys:      []string{"ci-secret"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create test server: %v", err)
+	}
+	defer server.Shutdown()
+
+	tests := []struct {
+		name           string
+		apiKey         string
+		expectedStatus int
+	}{
+		{name: "Valid API key", apiKey: "ci-secret", expectedStatus: http.StatusOK},
+		{name: "Invalid API key", apiKey: "guess", expectedStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := setupTestRequest("GET", "/api/v1/preservation-configs", nil)
+			req.RemoteAddr = "8.8.8.8:12345"
+			req.Header.Set("X-API-Key", tt.apiKey)
+
+			rr := httptest.NewRecorder()
+			server.router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+		})
+	}
+}
+
+func TestNew_InvalidAPIKey(t *testing.T) {
+	_, err := New(config.Config{
+		DBType:       testDBType,
+		DBConnection: filepath.Join(t.TempDir(), "test.db"),
+		APIKeys:      []string{"sha256:abc"},
+	})
+	if err == nil {
+		t.Error("Expected error for malformed hashed API key")
+	}
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/penwern/curate-preservation-api/pkg/logger"
+)
+
+// Upstream auth client defaults. A request to OIDC or Pydio that fails transiently is retried
+// after authRetryBackoff, doubling each time, until the attempts are used up.
+const (
+	defaultAuthTimeout             = 10 * time.Second
+	defaultAuthMaxIdleConnsPerHost = 10
+	authIdleConnTimeout            = 90 * time.Second
+	defaultAuthRetryAttempts       = 3
+	authRetryBackoff               = 200 * time.Millisecond
+)
+
+// AuthClient makes the OIDC and Pydio requests of token validation. It is shared by all
+// requests, so connections to Cells are kept alive and reused rather than set up, with a
+// TLS handshake, on every cache miss. Transient failures are retried so that a brief
+// upstream hiccup doesn't reject a valid token.
+type AuthClient struct {
+	client   *http.Client
+	attempts int
+	backoff  time.Duration
+}
+
+// NewAuthClient creates an AuthClient whose requests each take at most timeout, keeping up
+// to maxIdleConnsPerHost idle connections to each Cells host and making up to attempts tries
+// per request. Zero or fewer for any of them uses the default. TLS certificates are not
+// verified when allowInsecureTLS is set.
+func NewAuthClient(allowInsecureTLS bool, timeout time.Duration, maxIdleConnsPerHost, attempts int) *AuthClient {
+	if timeout <= 0 {
+		timeout = defaultAuthTimeout
+	}
+	if maxIdleConnsPerHost <= 0 {
+		maxIdleConnsPerHost = defaultAuthMaxIdleConnsPerHost
+	}
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// #nosec G402 -- InsecureSkipVerify is configurable via AllowInsecureTLS for development/testing environments
+			TLSClientConfig:     &tls.Config{InsecureSkipVerify: allowInsecureTLS},
+			MaxIdleConnsPerHost: maxIdleConnsPerHost,
+			IdleConnTimeout:     authIdleConnTimeout,
+		},
+	}
+	return newAuthClient(client, attempts)
+}
+
+// newAuthClient returns an AuthClient making up to attempts tries per request with client.
+// Zero or fewer attempts uses defaultAuthRetryAttempts.
+func newAuthClient(client *http.Client, attempts int) *AuthClient {
+	if attempts <= 0 {
+		attempts = defaultAuthRetryAttempts
+	}
+	return &AuthClient{client: client, attempts: attempts, backoff: authRetryBackoff}
+}
+
+// CloseIdleConnections closes the connections kept alive for reuse
+func (c *AuthClient) CloseIdleConnections() {
+	c.client.CloseIdleConnections()
+}
+
+// do sends the request made by newRequest, building it afresh for each attempt. Network
+// errors and 5xx responses are retried; any other response, including 401 and 403, is
+// returned at once, as is the last response once the attempts are used up. Retrying also
+// stops when the next attempt couldn't start within the client timeout of the first, so
+// retries never take longer than a single slow request could.
+//
+// The request ID of ctx, either the client's X-Request-Id or the one generated for it, is
+// sent as X-Request-Id so the upstream logs can be matched with ours.
+func (c *AuthClient) do(ctx context.Context, name string, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	log := logger.FromContext(ctx)
+	requestID := middleware.GetReqID(ctx)
+	var deadline time.Time
+	if c.client.Timeout > 0 {
+		deadline = time.Now().Add(c.client.Timeout)
+	}
+
+	backoff := c.backoff
+	for attempt := 1; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+		if requestID != "" {
+			req.Header.Set(middleware.RequestIDHeader, requestID)
+		}
+		log.Debugf("Auth: sending %s request to %s (%s: %s)", name, req.URL.Host, middleware.RequestIDHeader, requestID)
+		resp, err := c.client.Do(req)
+		if err == nil && resp.StatusCode < http.StatusInternalServerError {
+			return resp, nil
+		}
+
+		if attempt >= c.attempts || ctx.Err() != nil || (!deadline.IsZero() && time.Now().Add(backoff).After(deadline)) {
+			return resp, err
+		}
+
+		reason := err
+		if err == nil {
+			reason = fmt.Errorf("status %d", resp.StatusCode)
+			// Read the body so the connection can be reused
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+		log.Warnf("Auth: %s request failed (attempt %d of %d), retrying in %s: %v", name, attempt, c.attempts, backoff, reason)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		backoff *= 2
+	}
+}
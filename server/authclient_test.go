@@ -0,0 +1,152 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+func TestAuthClient_Retries(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantStatus   int
+		wantRequests int32
+	}{
+		{"success", []int{http.StatusOK}, http.StatusOK, 1},
+		{"recovers from 5xx", []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusOK}, http.StatusOK, 3},
+		{"gives up after the attempts", []int{http.StatusInternalServerError}, http.StatusInternalServerError, 3},
+		{"unauthorized is not retried", []int{http.StatusUnauthorized}, http.StatusUnauthorized, 1},
+		{"forbidden is not retried", []int{http.StatusForbidden}, http.StatusForbidden, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				n := int(requests.Add(1))
+				w.WriteHeader(tt.statuses[min(n, len(tt.statuses))-1])
+			}))
+			defer upstream.Close()
+
+			client := newAuthClient(upstream.Client(), 3)
+			client.backoff = time.Millisecond
+			resp, err := client.do(context.Background(), "test", func() (*http.Request, error) {
+				return http.NewRequest("GET", upstream.URL, nil)
+			})
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, resp.StatusCode)
+			}
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Errorf("Expected %d requests, got %d", tt.wantRequests, got)
+			}
+		})
+	}
+}
+
+func TestAuthClient_RetriesNetworkErrors(t *testing.T) {
+	upstream := httptest.NewServer(http.NotFoundHandler())
+	url := upstream.URL
+	upstream.Close()
+
+	var requests int
+	client := newAuthClient(&http.Client{Timeout: time.Second}, 2)
+	client.backoff = time.Millisecond
+	_, err := client.do(context.Background(), "test", func() (*http.Request, error) {
+		requests++
+		return http.NewRequest("GET", url, nil)
+	})
+	if err == nil {
+		t.Fatal("Expected an error from an unreachable upstream")
+	}
+	if requests != 2 {
+		t.Errorf("Expected 2 attempts, got %d", requests)
+	}
+}
+
+func TestAuthClient_StopsWithinTimeout(t *testing.T) {
+	var requests atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer upstream.Close()
+
+	// The backoff would run past the client timeout, so there is no second attempt
+	client := newAuthClient(&http.Client{Timeout: 50 * time.Millisecond}, 3)
+	client.backoff = time.Second
+	resp, err := client.do(context.Background(), "test", func() (*http.Request, error) {
+		return http.NewRequest("GET", upstream.URL, nil)
+	})
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if got := requests.Load(); got != 1 {
+		t.Errorf("Expected a single attempt, got %d", got)
+	}
+}
+
+func TestAuthClient_PropagatesRequestID(t *testing.T) {
+	var received string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get(middleware.RequestIDHeader)
+	}))
+	defer upstream.Close()
+
+	// The request ID middleware keeps an incoming X-Request-Id
+	incoming := httptest.NewRequest("GET", "/api/v1/preservation-configs", nil)
+	incoming.Header.Set(middleware.RequestIDHeader, "trace-1234")
+	var ctx context.Context
+	middleware.RequestID(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		ctx = r.Context()
+	})).ServeHTTP(httptest.NewRecorder(), incoming)
+
+	resp, err := newAuthClient(upstream.Client(), 1).do(ctx, "test", func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, "GET", upstream.URL, nil)
+	})
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if received != "trace-1234" {
+		t.Errorf("Expected the upstream to receive request ID trace-1234, got %q", received)
+	}
+}
+
+func TestAuthClient_ReusesConnections(t *testing.T) {
+	var conns atomic.Int32
+	upstream := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		respondWithJSON(w, http.StatusOK, map[string]string{"sub": "user-uuid"})
+	}))
+	upstream.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	upstream.Start()
+	defer upstream.Close()
+
+	client := NewAuthClient(false, time.Second, 2, 1)
+	defer client.CloseIdleConnections()
+	for i := 0; i < 3; i++ {
+		if _, err := fetchOIDCUserInfo(context.Background(), client, upstream.URL, "token"); err != nil {
+			t.Fatalf("Request %d failed: %v", i+1, err)
+		}
+	}
+
+	if got := conns.Load(); got != 1 {
+		t.Errorf("Expected the requests to share 1 connection, got %d", got)
+	}
+}
@@ -0,0 +1,56 @@
+// Package server – load shedding by limiting how many requests are served at once
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/penwern/curate-preservation-api/pkg/logger"
+)
+
+const (
+	// defaultConcurrencyQueueTimeout is how long a request over the limit waits for a slot
+	defaultConcurrencyQueueTimeout = time.Second
+	// concurrencyRetryAfter is the Retry-After, in seconds, sent with requests shed under load
+	concurrencyRetryAfter = 1
+)
+
+// ConcurrencyLimit creates middleware that serves at most limit requests at once, so that a
+// burst of requests can't exhaust the database pool and slow every request down. A request
+// arriving while limit are in flight waits up to queueTimeout for one of them to finish,
+// after which it is answered with 503 Service Unavailable and Retry-After. A limit of zero
+// or less serves every request.
+func ConcurrencyLimit(limit int, queueTimeout time.Duration) func(http.Handler) http.Handler {
+	if limit <= 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+
+	slots := make(chan struct{}, limit)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case slots <- struct{}{}:
+			default:
+				// All slots are taken; wait briefly for one to free up
+				timer := time.NewTimer(queueTimeout)
+				select {
+				case slots <- struct{}{}:
+					timer.Stop()
+				case <-r.Context().Done():
+					// The client went away; there is no one to respond to
+					timer.Stop()
+					return
+				case <-timer.C:
+					logger.FromContext(r.Context()).Warnf("Shedding %s %s: %d requests already in flight", r.Method, r.URL.Path, limit)
+					w.Header().Set("Retry-After", strconv.Itoa(concurrencyRetryAfter))
+					respondWithError(w, http.StatusServiceUnavailable, "The server is too busy to handle the request; try again shortly")
+					return
+				}
+			}
+			defer func() { <-slots }()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestConcurrencyLimit(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := ConcurrencyLimit(1, 20*time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(started)
+			<-release
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	slow := make(chan *httptest.ResponseRecorder)
+	go func() {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", "/slow", nil))
+		slow <- rr
+	}()
+	<-started
+
+	// The only slot is taken, so the request is shed once its wait runs out
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/fast", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 while saturated, got %d", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") != "1" {
+		t.Errorf("Expected Retry-After 1, got %q", rr.Header().Get("Retry-After"))
+	}
+
+	close(release)
+	if rr := <-slow; rr.Code != http.StatusOK {
+		t.Errorf("Expected the slow request to succeed, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/fast", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200 once the slot is free, got %d", rr.Code)
+	}
+}
+
+func TestConcurrencyLimit_Queues(t *testing.T) {
+	started := make(chan struct{})
+	handler := ConcurrencyLimit(1, time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(started)
+			time.Sleep(20 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	go handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/slow", nil))
+	<-started
+
+	// A slot frees up within the wait, so the request is served rather than shed
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/fast", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected the queued request to be served, got %d", rr.Code)
+	}
+}
+
+func TestConcurrencyLimit_Unlimited(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := ConcurrencyLimit(0, time.Second)(next)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200 without a limit, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/go-chi/cors"
+	"github.com/penwern/curate-preservation-api/pkg/config"
+)
+
+// defaultCORSOrigins are allowed when no origins are configured, for a local Pydio Cells
+var defaultCORSOrigins = []string{
+	"https://localhost:8080",
+	"http://localhost:8080",
+}
+
+// corsOptions builds the CORS options from cfg, using the defaults for anything left unset.
+// With CORSAllowAll every origin is allowed: the request origin is reflected back and
+// credentials are disabled, since browsers reject credentialed responses to any origin.
+func corsOptions(cfg config.Config) cors.Options {
+	options := cors.Options{
+		AllowedOrigins:   withDefault(cfg.CORSOrigins, defaultCORSOrigins),
+		AllowedMethods:   withDefault(cfg.CORSMethods, config.DefaultCORSMethods),
+		AllowedHeaders:   withDefault(cfg.CORSHeaders, config.DefaultCORSHeaders),
+		ExposedHeaders:   withDefault(cfg.CORSExposedHeaders, config.DefaultCORSExposedHeaders),
+		AllowCredentials: true,
+		MaxAge:           int(config.DefaultCORSMaxAge.Seconds()),
+	}
+	if cfg.CORSMaxAge > 0 {
+		options.MaxAge = int(cfg.CORSMaxAge.Seconds())
+	}
+
+	if cfg.CORSAllowAll {
+		options.AllowedOrigins = nil
+		options.AllowOriginFunc = func(*http.Request, string) bool { return true }
+		options.AllowCredentials = false
+	}
+	return options
+}
+
+// withDefault returns values, or defaults if values is empty
+func withDefault(values, defaults []string) []string {
+	if len(values) == 0 {
+		return defaults
+	}
+	return values
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/penwern/curate-preservation-api/pkg/logger"
+	"go.uber.org/zap/zapcore"
+)
+
+// maxLoggedBodySize is the most of a request or response body that is logged
+const maxLoggedBodySize = 4096
+
+// redactedHeaders carry credentials and are never logged
+var redactedHeaders = []string{"Authorization", "X-API-Key", "Cookie", "Set-Cookie"}
+
+// BodyLogger creates middleware that, when the log level is debug, logs each request's
+// headers and body along with the response status and body. At most maxLoggedBodySize
+// bytes of each body are captured: the request body is read only that far and then handed
+// to the handler intact, so large uploads are never buffered. Credential headers are redacted.
+func BodyLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context())
+		if !log.Desugar().Core().Enabled(zapcore.DebugLevel) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var requestBody []byte
+		if r.Body != nil && r.Body != http.NoBody {
+			var err error
+			requestBody, err = io.ReadAll(io.LimitReader(r.Body, maxLoggedBodySize+1))
+			if err != nil {
+				log.Debugf("Failed to read request body for logging: %v", err)
+			}
+			// Put back what was read ahead of the rest of the body
+			r.Body = readCloser{io.MultiReader(bytes.NewReader(requestBody), r.Body), r.Body}
+		}
+
+		log.Debugw("Request",
+			"method", r.Method,
+			"path", r.URL.RequestURI(),
+			"headers", redactHeaders(r.Header),
+			"body", truncateBody(requestBody),
+		)
+
+		responseBody := &cappedBuffer{limit: maxLoggedBodySize + 1}
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		ww.Tee(responseBody)
+		start := time.Now()
+
+		next.ServeHTTP(ww, r)
+
+		log.Debugw("Response",
+			"method", r.Method,
+			"path", r.URL.RequestURI(),
+			"status", ww.Status(),
+			"headers", redactHeaders(ww.Header()),
+			"body", truncateBody(responseBody.Bytes()),
+			"duration", time.Since(start),
+		)
+	})
+}
+
+// readCloser reads from one reader and closes another
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// cappedBuffer keeps the first limit bytes written to it and discards the rest
+type cappedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+// Write buffers as much of p as fits; it always reports success so the response isn't affected
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.limit - b.Len(); remaining > 0 {
+		if len(p) > remaining {
+			b.Buffer.Write(p[:remaining])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+// redactHeaders returns a copy of h with credential headers replaced by a placeholder
+func redactHeaders(h http.Header) http.Header {
+	redacted := h.Clone()
+	for _, name := range redactedHeaders {
+		if _, ok := redacted[http.CanonicalHeaderKey(name)]; ok {
+			redacted.Set(name, "[REDACTED]")
+		}
+	}
+	return redacted
+}
+
+// truncateBody returns body as a string, cut to maxLoggedBodySize with a marker when longer
+func truncateBody(body []byte) string {
+	if len(body) <= maxLoggedBodySize {
+		return string(body)
+	}
+	return strings.ToValidUTF8(string(body[:maxLoggedBodySize]), "") + "...(truncated)"
+}
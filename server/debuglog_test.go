@@ -0,0 +1,104 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/penwern/curate-preservation-api/pkg/config"
+	"github.com/penwern/curate-preservation-api/pkg/logger"
+)
+
+func TestBodyLogger(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "test.log")
+	logger.InitializeWithConfig(config.LogConfig{Level: "debug", File: logPath, Format: "json"})
+	defer logger.Initialize("debug", "/tmp/curate-preservation-api.log")
+
+	largeBody := strings.Repeat("a", maxLoggedBodySize*2)
+	var received string
+	handler := BodyLogger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatalf("Failed to read body in handler: %v", err)
+		}
+		received = string(body)
+		w.Header().Set("Set-Cookie", "session=secret-session")
+		respondWithJSON(w, http.StatusCreated, map[string]string{"status": "created"})
+	}))
+
+	req := httptest.NewRequest("POST", "/api/v1/preservation-configs", bytes.NewBufferString(largeBody))
+	req.Header.Set("Authorization", "Bearer secret-token")
+	req.Header.Set("X-API-Key", "secret-key")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if received != largeBody {
+		t.Errorf("Expected handler to receive the full %d byte body, got %d bytes", len(largeBody), len(received))
+	}
+	if rr.Code != http.StatusCreated {
+		t.Errorf("Expected status %d, got %d", http.StatusCreated, rr.Code)
+	}
+
+	content, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	logged := string(content)
+
+	for _, secret := range []string{"secret-token", "secret-key", "secret-session"} {
+		if strings.Contains(logged, secret) {
+			t.Errorf("Expected %q to be redacted from the log", secret)
+		}
+	}
+	if !strings.Contains(logged, "[REDACTED]") {
+		t.Error("Expected redacted headers to be logged with a placeholder")
+	}
+	if !strings.Contains(logged, "...(truncated)") {
+		t.Error("Expected the large request body to be truncated")
+	}
+	if strings.Contains(logged, largeBody) {
+		t.Error("Expected no more than the capped body size to be logged")
+	}
+	if !strings.Contains(logged, `{\"status\":\"created\"}`) {
+		t.Errorf("Expected the response body to be logged, got %s", logged)
+	}
+}
+
+func TestBodyLogger_NotDebug(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "test.log")
+	logger.InitializeWithConfig(config.LogConfig{Level: "info", File: logPath, Format: "json"})
+	defer logger.Initialize("debug", "/tmp/curate-preservation-api.log")
+
+	handler := BodyLogger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	}))
+
+	req := httptest.NewRequest("POST", "/api/v1/preservation-configs", bytes.NewBufferString(`{"name":"Test"}`))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	content, err := os.ReadFile(logPath)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	if strings.Contains(string(content), `\"name\":\"Test\"`) {
+		t.Error("Expected bodies not to be logged above debug level")
+	}
+}
+
+func TestCappedBuffer(t *testing.T) {
+	buf := &cappedBuffer{limit: 4}
+	for _, s := range []string{"ab", "cdef", "gh"} {
+		if n, err := buf.Write([]byte(s)); n != len(s) || err != nil {
+			t.Errorf("Expected Write to report %d bytes and no error, got %d, %v", len(s), n, err)
+		}
+	}
+	if got := buf.String(); got != "abcd" {
+		t.Errorf("Expected 'abcd', got %q", got)
+	}
+}
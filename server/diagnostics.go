@@ -0,0 +1,118 @@
+// Package server – diagnostics summarising the health of the server's subsystems
+package server
+
+import (
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/penwern/curate-preservation-api/database"
+	"github.com/penwern/curate-preservation-api/pkg/logger"
+	"github.com/penwern/curate-preservation-api/pkg/version"
+)
+
+// diagnosticsResponse summarises the state of the server for triage
+type diagnosticsResponse struct {
+	Build         versionResponse      `json:"build"`
+	Uptime        string               `json:"uptime"`
+	UptimeSeconds int64                `json:"uptime_seconds"`
+	Goroutines    int                  `json:"goroutines"`
+	ReadOnly      bool                 `json:"read_only"`
+	Database      databaseDiagnostics  `json:"database"`
+	Migrations    migrationDiagnostics `json:"migrations"`
+	AuthCache     authCacheDiagnostics `json:"auth_cache"`
+}
+
+// databaseDiagnostics reports whether the database answers and how quickly
+type databaseDiagnostics struct {
+	Type        string  `json:"type"`
+	Reachable   bool    `json:"reachable"`
+	LatencyMS   float64 `json:"latency_ms"`
+	ConfigCount *int64  `json:"config_count"`
+	Error       string  `json:"error,omitempty"`
+}
+
+// migrationDiagnostics reports the state of the database schema
+type migrationDiagnostics struct {
+	Done bool `json:"done"`
+	database.MigrationStatus
+	Error string `json:"error,omitempty"`
+}
+
+// authCacheDiagnostics reports the use of the validated user info cache
+type authCacheDiagnostics struct {
+	Size    int     `json:"size"`
+	Hits    uint64  `json:"hits"`
+	Misses  uint64  `json:"misses"`
+	HitRate float64 `json:"hit_rate"`
+}
+
+// handleDiagnostics returns a handler summarising subsystem health in one response that can
+// be pasted into a ticket. It only reads, with a ping and two small queries to the database,
+// and always answers 200: failures are reported in the body.
+func (s *Server) handleDiagnostics() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context())
+		uptime := time.Since(s.startedAt)
+		response := diagnosticsResponse{
+			Build: versionResponse{
+				Version:   version.Version(),
+				Commit:    version.Commit(),
+				BuildTime: version.BuildTime(),
+				GoVersion: runtime.Version(),
+			},
+			Uptime:        uptime.Round(time.Second).String(),
+			UptimeSeconds: int64(uptime.Seconds()),
+			Goroutines:    runtime.NumGoroutine(),
+			ReadOnly:      s.readOnly.Load(),
+			Database:      databaseDiagnostics{Type: s.config.DBType},
+		}
+
+		start := time.Now()
+		if err := s.db.Ping(r.Context()); err != nil {
+			log.Warnf("Diagnostics: database ping failed: %v", err)
+			response.Database.Error = err.Error()
+		} else {
+			response.Database.Reachable = true
+		}
+		response.Database.LatencyMS = float64(time.Since(start).Microseconds()) / 1000
+
+		done, migrationErr := s.migrationsDone()
+		response.Migrations.Done = done
+		if migrationErr != nil {
+			response.Migrations.Error = migrationErr.Error()
+		}
+		if response.Database.Reachable {
+			status, err := s.db.MigrationStatus(r.Context())
+			if err != nil {
+				log.Warnf("Diagnostics: %v", err)
+				if response.Migrations.Error == "" {
+					response.Migrations.Error = err.Error()
+				}
+			}
+			response.Migrations.MigrationStatus = status
+
+			// The table may not exist until migrations have finished
+			if done && migrationErr == nil {
+				if count, err := s.db.CountConfigsContext(r.Context()); err != nil {
+					log.Warnf("Diagnostics: failed to count configs: %v", err)
+					response.Database.Error = err.Error()
+				} else {
+					response.Database.ConfigCount = &count
+				}
+			}
+		}
+
+		hits, misses := s.userInfoCache.Hits(), s.userInfoCache.Misses()
+		response.AuthCache = authCacheDiagnostics{
+			Size:   s.userInfoCache.Len(),
+			Hits:   hits,
+			Misses: misses,
+		}
+		if hits+misses > 0 {
+			response.AuthCache.HitRate = float64(hits) / float64(hits+misses)
+		}
+
+		respondWithJSON(w, http.StatusOK, response)
+	}
+}
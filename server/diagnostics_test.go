@@ -0,0 +1,54 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServer_HandleDiagnostics(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	server.userInfoCache.Set("token", UserInfo{Sub: "user"})
+	server.userInfoCache.Get("token")
+	server.userInfoCache.Get("unknown")
+
+	req := setupTestRequest("GET", "/api/v1/admin/diagnostics", nil)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var diagnostics diagnosticsResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &diagnostics); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if !diagnostics.Database.Reachable || diagnostics.Database.Type != testDBType {
+		t.Errorf("Expected a reachable %s database, got %+v", testDBType, diagnostics.Database)
+	}
+	// The seeded default config
+	if diagnostics.Database.ConfigCount == nil || *diagnostics.Database.ConfigCount != 1 {
+		t.Errorf("Expected 1 config, got %v", diagnostics.Database.ConfigCount)
+	}
+	if !diagnostics.Migrations.Done || diagnostics.Migrations.Version == 0 || diagnostics.Migrations.Dirty {
+		t.Errorf("Expected finished, clean migrations, got %+v", diagnostics.Migrations)
+	}
+	if diagnostics.AuthCache.Size != 1 || diagnostics.AuthCache.Hits != 1 || diagnostics.AuthCache.Misses != 1 || diagnostics.AuthCache.HitRate != 0.5 {
+		t.Errorf("Expected one cached user with a hit rate of 0.5, got %+v", diagnostics.AuthCache)
+	}
+	if diagnostics.Goroutines == 0 || diagnostics.Build.GoVersion == "" {
+		t.Errorf("Expected runtime details, got %+v", diagnostics)
+	}
+
+	// Only trusted IPs may read it
+	req = setupTestRequest("GET", "/api/v1/admin/diagnostics", nil)
+	req.RemoteAddr = "203.0.113.7:1234"
+	rr = httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 from an untrusted IP, got %d", rr.Code)
+	}
+}
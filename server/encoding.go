@@ -0,0 +1,323 @@
+// Package server – JSON/YAML request decoding and response encoding
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/penwern/curate-preservation-api/models"
+	"github.com/penwern/curate-preservation-api/pkg/logger"
+	"gopkg.in/yaml.v3"
+)
+
+// yamlContentType is the media type used for YAML responses
+const yamlContentType = "application/yaml"
+
+// maxDecompressedBodySize bounds a gzip request body once decompressed, so that a small
+// upload can't expand without limit
+const maxDecompressedBodySize = 32 << 20
+
+// isYAMLMediaType reports whether the media type names YAML
+func isYAMLMediaType(mediaType string) bool {
+	switch strings.ToLower(mediaType) {
+	case "application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml":
+		return true
+	default:
+		return false
+	}
+}
+
+// isYAMLRequest reports whether the request body is declared as YAML
+func isYAMLRequest(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && isYAMLMediaType(mediaType)
+}
+
+// isSupportedRequestMediaType reports whether a request body of the media type can be decoded
+func isSupportedRequestMediaType(mediaType string) bool {
+	return strings.EqualFold(mediaType, "application/json") || isYAMLMediaType(mediaType)
+}
+
+// RequireContentType creates middleware that rejects request bodies declared as anything other
+// than JSON or YAML with 415 Unsupported Media Type, before they are decoded. When strict is
+// false every request is passed through and the body is decoded as JSON unless it is declared as YAML.
+func RequireContentType(strict bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !strict {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			contentType := r.Header.Get("Content-Type")
+			mediaType, _, err := mime.ParseMediaType(contentType)
+			if err != nil || !isSupportedRequestMediaType(mediaType) {
+				logger.FromContext(r.Context()).Warnf("Rejecting %s %s with unsupported Content-Type %q", r.Method, r.URL.Path, contentType)
+				respondWithError(w, http.StatusUnsupportedMediaType, "Unsupported Content-Type: use application/json or application/yaml")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// DecompressBody creates middleware that decompresses request bodies sent with
+// Content-Encoding: gzip, so handlers decode them as usual. At most maxDecompressedBodySize
+// bytes are read from the decompressed body; handlers fail to decode anything longer.
+// A body that isn't gzip is rejected with 400, and any other encoding with 415.
+func DecompressBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := strings.TrimSpace(r.Header.Get("Content-Encoding"))
+		switch {
+		case encoding == "" || strings.EqualFold(encoding, "identity"):
+			next.ServeHTTP(w, r)
+			return
+		case !strings.EqualFold(encoding, "gzip"):
+			logger.FromContext(r.Context()).Warnf("Rejecting %s %s with unsupported Content-Encoding %q", r.Method, r.URL.Path, encoding)
+			w.Header().Set("Accept-Encoding", "gzip")
+			respondWithError(w, http.StatusUnsupportedMediaType, "Unsupported Content-Encoding: use gzip or none")
+			return
+		}
+
+		reader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			logger.FromContext(r.Context()).Warnf("Invalid gzip body in %s %s: %v", r.Method, r.URL.Path, err)
+			respondWithError(w, http.StatusBadRequest, "Invalid gzip request body")
+			return
+		}
+		defer reader.Close()
+
+		r.Body = http.MaxBytesReader(w, reader, maxDecompressedBodySize)
+		r.Header.Del("Content-Encoding")
+		r.Header.Del("Content-Length")
+		r.ContentLength = -1
+		next.ServeHTTP(w, r)
+	})
+}
+
+// wantsYAML reports whether the client asked for a YAML response. The first JSON or
+// YAML media type listed in the Accept header wins; anything else means JSON.
+func wantsYAML(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if isYAMLMediaType(mediaType) {
+			return true
+		}
+		if mediaType == "application/json" {
+			return false
+		}
+	}
+	return false
+}
+
+// protobufContentType is the media type used for binary protobuf responses
+const protobufContentType = "application/x-protobuf"
+
+// isProtobufMediaType reports whether the media type names binary protobuf
+func isProtobufMediaType(mediaType string) bool {
+	switch strings.ToLower(mediaType) {
+	case "application/x-protobuf", "application/protobuf", "application/vnd.google.protobuf":
+		return true
+	default:
+		return false
+	}
+}
+
+// wantsProtobuf reports whether the client asked for a binary protobuf response. The first
+// protobuf, JSON or YAML media type listed in the Accept header wins.
+func wantsProtobuf(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if isProtobufMediaType(mediaType) {
+			return true
+		}
+		if mediaType == "application/json" || isYAMLMediaType(mediaType) {
+			return false
+		}
+	}
+	return false
+}
+
+// duplicateKeyError reports a JSON object that repeats a key, which decoding would
+// otherwise resolve silently to the last value
+type duplicateKeyError struct {
+	// path locates the key, e.g. "a3m_config.normalize" or "[1].name"
+	path string
+}
+
+func (e *duplicateKeyError) Error() string {
+	return fmt.Sprintf("duplicate field %q", e.path)
+}
+
+// checkDuplicateKeys scans the JSON document in data and returns a *duplicateKeyError for
+// the first object found repeating a key. Malformed JSON is left for the decoder to report.
+func checkDuplicateKeys(data []byte) error {
+	err := scanDuplicateKeys(json.NewDecoder(bytes.NewReader(data)), "")
+	var dupErr *duplicateKeyError
+	if errors.As(err, &dupErr) {
+		return err
+	}
+	return nil
+}
+
+// scanDuplicateKeys reads the next value from dec, descending into objects and arrays
+func scanDuplicateKeys(dec *json.Decoder, path string) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch tok {
+	case json.Delim('{'):
+		// A3M fields may be named in snake_case or lowerCamelCase, so two spellings of
+		// the same field repeat it just as the same spelling twice does
+		a3m := path == "a3m_config" || strings.HasSuffix(path, ".a3m_config")
+		seen := make(map[string]bool)
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			key, _ := keyTok.(string)
+			name := key
+			if a3m {
+				name = models.A3MFieldName(key)
+			}
+			if seen[name] {
+				if path != "" {
+					name = path + "." + name
+				}
+				return &duplicateKeyError{path: name}
+			}
+			seen[name] = true
+			keyPath := key
+			if path != "" {
+				keyPath = path + "." + key
+			}
+			if err := scanDuplicateKeys(dec, keyPath); err != nil {
+				return err
+			}
+		}
+	case json.Delim('['):
+		for i := 0; dec.More(); i++ {
+			if err := scanDuplicateKeys(dec, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	default:
+		return nil
+	}
+	// The closing delimiter
+	_, err = dec.Token()
+	return err
+}
+
+// respondWithDecodeError answers a request whose body decodeBody rejected with 400, naming
+// the repeated field if that was the problem
+func respondWithDecodeError(w http.ResponseWriter, err error) {
+	var dupErr *duplicateKeyError
+	if errors.As(err, &dupErr) {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload: "+dupErr.Error())
+		return
+	}
+	respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+}
+
+// decodeBody decodes the request body into v as JSON, or as YAML when the Content-Type says so.
+// YAML is converted to JSON first so that v's JSON tags and unmarshalers, including the
+// protojson-based A3M config, apply identically to both formats. JSON objects repeating a
+// key are rejected with a *duplicateKeyError, as are objects in either format naming an
+// A3M field twice in different spellings; the YAML decoder rejects exact repeats itself.
+func decodeBody(r *http.Request, v any) error {
+	if !isYAMLRequest(r) {
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			return fmt.Errorf("failed to read request body: %w", err)
+		}
+		if err := checkDuplicateKeys(data); err != nil {
+			return err
+		}
+		return json.NewDecoder(bytes.NewReader(data)).Decode(v)
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	var doc any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("invalid YAML: %w", err)
+	}
+
+	jsonData, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("failed to convert YAML to JSON: %w", err)
+	}
+	// The YAML decoder only catches a key spelt the same way twice
+	if err := checkDuplicateKeys(jsonData); err != nil {
+		return err
+	}
+	return json.Unmarshal(jsonData, v)
+}
+
+// preferenceAppliedHeader reports the Prefer preferences honoured by a response (RFC 7240)
+const preferenceAppliedHeader = "Preference-Applied"
+
+// prefersMinimal reports whether the client sent Prefer: return=minimal, asking for
+// writes to be acknowledged without the resource in the body
+func prefersMinimal(r *http.Request) bool {
+	for _, header := range r.Header.Values("Prefer") {
+		for _, preference := range strings.Split(header, ",") {
+			// Parameters after a semicolon don't apply to return
+			token, _, _ := strings.Cut(preference, ";")
+			name, value, ok := strings.Cut(strings.TrimSpace(token), "=")
+			if ok && strings.EqualFold(strings.TrimSpace(name), "return") &&
+				strings.EqualFold(strings.Trim(strings.TrimSpace(value), `"`), "minimal") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// respond writes payload as YAML if the client asked for it and as JSON otherwise
+func respond(w http.ResponseWriter, r *http.Request, code int, payload any) {
+	if !wantsYAML(r) {
+		respondWithJSON(w, code, payload)
+		return
+	}
+
+	// Go through JSON so the output uses the same field names and A3M encoding as JSON responses
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	var doc any
+	if err := json.Unmarshal(jsonData, &doc); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	b, err := yaml.Marshal(doc)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	w.Header().Set("Content-Type", yamlContentType)
+	w.WriteHeader(code)
+	if _, err := w.Write(b); err != nil {
+		logger.Error("Failed to write response: %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+// Package server – replay protection for config creation via the Idempotency-Key header
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// idempotencyKeyHeader names the header clients send to make a create safe to retry
+	idempotencyKeyHeader = "Idempotency-Key"
+	// idempotencyReplayedHeader marks a response that repeats an earlier create's result
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+	// defaultIdempotencyTTL is how long a key is remembered after its create finished
+	defaultIdempotencyTTL = time.Hour
+	// maxIdempotencyKeyLength bounds the keys accepted, in bytes
+	maxIdempotencyKeyLength = 255
+)
+
+// idempotencyRecord is the state of a single idempotency key. A record without a
+// config ID belongs to a create that is still in progress.
+type idempotencyRecord struct {
+	fingerprint string
+	configID    int64
+	done        bool
+	expiresAt   time.Time
+}
+
+// IdempotencyStore provides thread-safe tracking of the Idempotency-Key values seen on
+// create requests, remembering for each the config it created so that a retried request
+// receives the original result instead of creating a duplicate. Keys are scoped to the
+// user that sent them and forgotten once the TTL has passed.
+type IdempotencyStore struct {
+	records map[string]*idempotencyRecord
+	mutex   sync.Mutex
+	ttl     time.Duration
+	done    chan struct{}
+	once    sync.Once
+}
+
+// NewIdempotencyStore creates a store that remembers keys for ttl.
+// Call Stop to release the cleanup goroutine once the store is no longer used.
+func NewIdempotencyStore(ttl time.Duration) *IdempotencyStore {
+	store := &IdempotencyStore{
+		records: make(map[string]*idempotencyRecord),
+		ttl:     ttl,
+		done:    make(chan struct{}),
+	}
+
+	// Start cleanup goroutine
+	go store.cleanup()
+
+	return store
+}
+
+// idempotencyStatus is the outcome of reserving an idempotency key
+type idempotencyStatus int
+
+const (
+	// idempotencyNew means the key was unused and is now reserved for the caller
+	idempotencyNew idempotencyStatus = iota
+	// idempotencyDone means an earlier request with the key created a config
+	idempotencyDone
+	// idempotencyInProgress means an earlier request with the key is still running
+	idempotencyInProgress
+	// idempotencyMismatch means the key was used before for a different request body
+	idempotencyMismatch
+)
+
+// Reserve claims key for a create of the request identified by fingerprint. If the key
+// was already used for the same request and that create finished, the ID of the config
+// it created is returned with idempotencyDone. A caller given idempotencyNew must call
+// either Complete or Release for the key.
+func (s *IdempotencyStore) Reserve(key, fingerprint string) (int64, idempotencyStatus) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	record, exists := s.records[key]
+	if exists && record.done && time.Now().After(record.expiresAt) {
+		exists = false
+	}
+	switch {
+	case !exists:
+		s.records[key] = &idempotencyRecord{fingerprint: fingerprint}
+		return 0, idempotencyNew
+	case record.fingerprint != fingerprint:
+		return 0, idempotencyMismatch
+	case !record.done:
+		return 0, idempotencyInProgress
+	default:
+		return record.configID, idempotencyDone
+	}
+}
+
+// Complete records that the create reserving key made the config configID
+func (s *IdempotencyStore) Complete(key string, configID int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if record, exists := s.records[key]; exists {
+		record.configID = configID
+		record.done = true
+		record.expiresAt = time.Now().Add(s.ttl)
+	}
+}
+
+// Release frees key after the create reserving it failed, so that it may be retried
+func (s *IdempotencyStore) Release(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if record, exists := s.records[key]; exists && !record.done {
+		delete(s.records, key)
+	}
+}
+
+// cleanup removes keys whose TTL has passed
+func (s *IdempotencyStore) cleanup() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.mutex.Lock()
+			now := time.Now()
+			for key, record := range s.records {
+				if record.done && now.After(record.expiresAt) {
+					delete(s.records, key)
+				}
+			}
+			s.mutex.Unlock()
+		}
+	}
+}
+
+// Stop terminates the cleanup goroutine
+func (s *IdempotencyStore) Stop() {
+	s.once.Do(func() { close(s.done) })
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/penwern/curate-preservation-api/models"
+)
+
+func TestIdempotencyStore(t *testing.T) {
+	store := NewIdempotencyStore(time.Hour)
+	defer store.Stop()
+
+	if _, status := store.Reserve("key", "body"); status != idempotencyNew {
+		t.Fatalf("Expected an unused key to be reserved, got status %d", status)
+	}
+	if _, status := store.Reserve("key", "body"); status != idempotencyInProgress {
+		t.Errorf("Expected a reserved key to be in progress, got status %d", status)
+	}
+
+	// A failed create frees the key for a retry
+	store.Release("key")
+	if _, status := store.Reserve("key", "body"); status != idempotencyNew {
+		t.Fatalf("Expected a released key to be reserved again, got status %d", status)
+	}
+
+	store.Complete("key", 42)
+	if id, status := store.Reserve("key", "body"); status != idempotencyDone || id != 42 {
+		t.Errorf("Expected the completed key to give config 42, got %d with status %d", id, status)
+	}
+	if _, status := store.Reserve("key", "other body"); status != idempotencyMismatch {
+		t.Errorf("Expected a different body to mismatch, got status %d", status)
+	}
+
+	// Releasing a completed key keeps it
+	store.Release("key")
+	if _, status := store.Reserve("key", "body"); status != idempotencyDone {
+		t.Errorf("Expected the completed key to be kept, got status %d", status)
+	}
+}
+
+func TestIdempotencyStore_Expiry(t *testing.T) {
+	store := NewIdempotencyStore(10 * time.Millisecond)
+	defer store.Stop()
+
+	store.Reserve("key", "body")
+	store.Complete("key", 1)
+	time.Sleep(20 * time.Millisecond)
+
+	if _, status := store.Reserve("key", "other body"); status != idempotencyNew {
+		t.Errorf("Expected an expired key to be reserved anew, got status %d", status)
+	}
+}
+
+func TestServer_HandleCreateConfig_IdempotencyKey(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	create := func(key, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := setupTestRequest("POST", "/api/v1/preservation-configs", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set(idempotencyKeyHeader, key)
+		}
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		return rr
+	}
+	createdID := func(rr *httptest.ResponseRecorder) int64 {
+		t.Helper()
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("Expected status 201, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var config models.PreservationConfig
+		if err := json.Unmarshal(rr.Body.Bytes(), &config); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		return config.ID
+	}
+
+	body := `{"name": "Nightly", "description": "Created by automation"}`
+	first := create("retry-1", body)
+	id := createdID(first)
+	if first.Header().Get(idempotencyReplayedHeader) != "" {
+		t.Error("Expected the first create not to be marked as replayed")
+	}
+
+	// The same body with keys in another order is the same request
+	replay := create("retry-1", `{"description": "Created by automation", "name": "Nightly"}`)
+	if got := createdID(replay); got != id {
+		t.Errorf("Expected the replay to return config %d, got %d", id, got)
+	}
+	if replay.Header().Get(idempotencyReplayedHeader) != "true" {
+		t.Error("Expected the replay to be marked as replayed")
+	}
+
+	count, err := server.db.CountConfigs()
+	if err != nil {
+		t.Fatalf("Failed to count configs: %v", err)
+	}
+	// The seeded default config and the one created
+	if count != 2 {
+		t.Errorf("Expected 2 configs after the replay, got %d", count)
+	}
+
+	if rr := create("retry-1", `{"name": "Weekly"}`); rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422 for a reused key, got %d", rr.Code)
+	}
+	if got := createdID(create("retry-2", body)); got == id {
+		t.Error("Expected a new key to create another config")
+	}
+	if rr := create(strings.Repeat("k", maxIdempotencyKeyLength+1), body); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an overlong key, got %d", rr.Code)
+	}
+
+	// A rejected create doesn't use up the key
+	if rr := create("retry-3", `{"name": ""}`); rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected status 422 for an invalid config, got %d", rr.Code)
+	}
+	createdID(create("retry-3", `{"name": "Fixed"}`))
+}
@@ -0,0 +1,321 @@
+// Package server – local JWT validation against the Pydio Cells OIDC JWKS
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/penwern/curate-preservation-api/pkg/logger"
+)
+
+const (
+	// jwksTTL is how long a fetched key set is trusted before it is refreshed
+	jwksTTL = time.Hour
+	// jwksMinRefreshInterval limits refetches triggered by unknown key IDs
+	jwksMinRefreshInterval = time.Minute
+)
+
+// errTokenExpired is returned when a locally validated JWT has expired.
+// Unlike other local validation failures it is final and does not fall back to upstream.
+var errTokenExpired = errors.New("token has expired")
+
+// JWTClaims represents the claims we read from a Pydio Cells OIDC access token
+type JWTClaims struct {
+	jwt.RegisteredClaims
+	Email             string `json:"email"`
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+}
+
+// jsonWebKey represents a single key from a JWKS document
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// jwksDocument represents the JWKS endpoint response
+type jwksDocument struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+// keySet holds the parsed public keys for a single JWKS URL
+type keySet struct {
+	keys      map[string]any
+	fetchedAt time.Time
+	// checkedAt is when the key set was last fetched, successfully or not; err is the
+	// error of that fetch if it failed
+	checkedAt time.Time
+	err       error
+}
+
+// jwksFetch is a fetch of a key set in progress, shared by every request waiting for it.
+// keys and err are set before done is closed.
+type jwksFetch struct {
+	done chan struct{}
+	keys map[string]any
+	err  error
+}
+
+// JWKSCache provides thread-safe caching of public keys fetched from JWKS endpoints
+type JWKSCache struct {
+	sets    map[string]*keySet
+	fetches map[string]*jwksFetch
+	mutex   sync.Mutex
+	ttl     time.Duration
+}
+
+// NewJWKSCache creates a new JWKS cache with the specified TTL
+func NewJWKSCache(ttl time.Duration) *JWKSCache {
+	return &JWKSCache{
+		sets:    make(map[string]*keySet),
+		fetches: make(map[string]*jwksFetch),
+		ttl:     ttl,
+	}
+}
+
+// Key returns the public key with the given key ID, fetching the key set when it is
+// missing, stale, or does not contain the requested key. A key set is fetched at most
+// once per jwksMinRefreshInterval, so that neither unknown key IDs nor an unavailable
+// JWKS endpoint hammer the provider, and concurrent requests share a single fetch.
+// Key sets are fetched with client, and waiting for the fetch stops when ctx is done.
+func (c *JWKSCache) Key(ctx context.Context, client *AuthClient, jwksURL, kid string) (any, error) {
+	c.mutex.Lock()
+	set, exists := c.sets[jwksURL]
+	if exists {
+		if key, found := set.keys[kid]; found && time.Since(set.fetchedAt) < c.ttl {
+			c.mutex.Unlock()
+			return key, nil
+		}
+		if time.Since(set.checkedAt) < jwksMinRefreshInterval {
+			c.mutex.Unlock()
+			if set.err != nil {
+				return nil, set.err
+			}
+			return nil, fmt.Errorf("key %q not found in JWKS", kid)
+		}
+	}
+
+	fetch, inFlight := c.fetches[jwksURL]
+	if !inFlight {
+		fetch = &jwksFetch{done: make(chan struct{})}
+		c.fetches[jwksURL] = fetch
+		// The fetch is shared, so it isn't cut short when the request starting it is
+		go c.refresh(context.WithoutCancel(ctx), client, jwksURL, fetch)
+	}
+	c.mutex.Unlock()
+
+	select {
+	case <-fetch.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if fetch.err != nil {
+		return nil, fetch.err
+	}
+	key, found := fetch.keys[kid]
+	if !found {
+		return nil, fmt.Errorf("key %q not found in JWKS", kid)
+	}
+	return key, nil
+}
+
+// refresh fetches the key set at jwksURL into the cache, recording a failure so that it
+// isn't retried before jwksMinRefreshInterval, and completes fetch with the outcome
+func (c *JWKSCache) refresh(ctx context.Context, client *AuthClient, jwksURL string, fetch *jwksFetch) {
+	logger.Debug("Auth: fetching JWKS from %s", jwksURL)
+	keys, err := fetchJWKS(ctx, client, jwksURL)
+
+	c.mutex.Lock()
+	set, exists := c.sets[jwksURL]
+	if !exists {
+		set = &keySet{}
+		c.sets[jwksURL] = set
+	}
+	set.checkedAt = time.Now()
+	set.err = err
+	if err == nil {
+		set.keys = keys
+		set.fetchedAt = set.checkedAt
+	} else {
+		logger.Warn("Auth: failed to fetch JWKS from %s: %v", jwksURL, err)
+	}
+	delete(c.fetches, jwksURL)
+	c.mutex.Unlock()
+
+	fetch.keys, fetch.err = keys, err
+	close(fetch.done)
+}
+
+// Global JWKS cache instance
+var jwksCache = NewJWKSCache(jwksTTL)
+
+// fetchJWKS downloads and parses the key set at the given URL with client
+func fetchJWKS(ctx context.Context, client *AuthClient, jwksURL string) (map[string]any, error) {
+	resp, err := client.do(ctx, "jwks", func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, jwksURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create JWKS request: %w", err)
+		}
+		return req, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("JWKS request failed: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			logger.Error("Auth: failed to close JWKS response body: %v", err)
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("JWKS request failed with status: %d", resp.StatusCode)
+	}
+
+	var doc jwksDocument
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to decode JWKS response: %w", err)
+	}
+
+	keys := make(map[string]any, len(doc.Keys))
+	for _, jwk := range doc.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			logger.Warn("Auth: skipping JWKS key %q: %v", jwk.Kid, err)
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	logger.Debug("Auth: loaded %d signing keys from JWKS", len(keys))
+	return keys, nil
+}
+
+// publicKey converts a JWK into an *rsa.PublicKey or *ecdsa.PublicKey
+func (k jsonWebKey) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBase64URLInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus: %w", err)
+		}
+		e, err := decodeBase64URLInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent: %w", err)
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("exponent out of range")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve: %s", k.Crv)
+		}
+		x, err := decodeBase64URLInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x coordinate: %w", err)
+		}
+		y, err := decodeBase64URLInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y coordinate: %w", err)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s", k.Kty)
+	}
+}
+
+// decodeBase64URLInt decodes an unpadded base64url big-endian integer
+func decodeBase64URLInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// looksLikeJWT reports whether the token has the three-part compact JWS shape
+func looksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// validateJWTLocally verifies the token signature against the OIDC JWKS for the
+// site domain and checks the exp, iss and aud claims. The JWKS is fetched with client, and
+// waiting for it stops when ctx is done.
+func validateJWTLocally(ctx context.Context, client *AuthClient, token string, siteDomain string, audience string) (*JWTClaims, error) {
+	if !looksLikeJWT(token) {
+		return nil, errors.New("token is not a JWT")
+	}
+
+	jwksURL := getJWKSURL(siteDomain)
+	issuer := getIssuer(siteDomain)
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuer(issuer),
+	}
+	if audience != "" {
+		options = append(options, jwt.WithAudience(audience))
+	}
+
+	claims := &JWTClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return jwksCache.Key(ctx, client, jwksURL, kid)
+	}, options...)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, errTokenExpired
+		}
+		return nil, err
+	}
+
+	if claims.Subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+
+	return claims, nil
+}
+
+// unverifiedTokenExpiry reads the exp claim of a JWT without verifying its signature.
+// It must only be used for tokens that have already been validated upstream.
+func unverifiedTokenExpiry(token string) (time.Time, bool) {
+	if !looksLikeJWT(token) {
+		return time.Time{}, false
+	}
+
+	claims := &jwt.RegisteredClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil || claims.ExpiresAt == nil {
+		return time.Time{}, false
+	}
+	return claims.ExpiresAt.Time, true
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/penwern/curate-preservation-api/models"
+	"github.com/penwern/curate-preservation-api/pkg/logger"
+)
+
+// migrationRetryAfter is the Retry-After, in seconds, sent while migrations are running
+const migrationRetryAfter = 5
+
+// runMigrations applies pending migrations and then seeds defaultConfig, if one is given.
+// With SkipMigrations, it instead checks that the schema is already current. It records the
+// outcome and closes s.migrated when done, so it can run in the background while the server
+// already accepts connections. Once ctx is done, no further migrations are started.
+func (s *Server) runMigrations(ctx context.Context, defaultConfig *models.PreservationConfig) {
+	defer close(s.migrated)
+
+	if s.config.SkipMigrations {
+		logger.Info("Skipping database migrations; checking the schema is current...")
+		if err := s.db.CheckSchema(); err != nil {
+			logger.Error("Database schema check failed: %v", err)
+			s.migrationErr = err
+			return
+		}
+		logger.Info("Database schema is current")
+	} else {
+		logger.Info("Running database migrations...")
+		if err := s.db.MigrateUpContext(ctx); err != nil {
+			logger.Error("Database migrations failed: %v", err)
+			s.migrationErr = err
+			return
+		}
+		logger.Info("Database migrations completed successfully")
+	}
+
+	s.db.WarnIfReadOnly(context.Background())
+
+	if defaultConfig != nil {
+		if _, err := s.db.SeedDefaultConfigContext(context.Background(), defaultConfig); err != nil {
+			logger.Error("Failed to seed default config: %v", err)
+			s.migrationErr = fmt.Errorf("failed to seed default config: %w", err)
+		}
+	}
+}
+
+// migrationsDone reports whether startup migrations have finished and, if so, whether they
+// failed. migrationErr is written before migrated is closed, so reading it afterwards is safe.
+func (s *Server) migrationsDone() (bool, error) {
+	select {
+	case <-s.migrated:
+		return true, s.migrationErr
+	default:
+		return false, nil
+	}
+}
+
+// RequireMigrations creates middleware that answers 503 Service Unavailable until startup
+// migrations have finished, with a Retry-After while they are still running
+func (s *Server) RequireMigrations(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		done, err := s.migrationsDone()
+		if !done {
+			w.Header().Set("Retry-After", strconv.Itoa(migrationRetryAfter))
+			respondWithError(w, http.StatusServiceUnavailable, "Database migrations in progress")
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusServiceUnavailable, "Database migrations failed")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
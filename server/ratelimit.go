@@ -0,0 +1,107 @@
+// Package server – per-client throttling of failed authentication attempts
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// defaultAuthFailureLimit is the number of failed attempts allowed per window
+	defaultAuthFailureLimit = 10
+	// defaultAuthFailureWindow is the period over which failed attempts are counted
+	defaultAuthFailureWindow = time.Minute
+)
+
+// failureRecord tracks failed attempts for a single client within the current window
+type failureRecord struct {
+	count       int
+	windowStart time.Time
+}
+
+// AuthFailureLimiter provides thread-safe counting of failed authentication attempts per client IP.
+// A client is blocked once it reaches the limit and stays blocked until its window ends.
+type AuthFailureLimiter struct {
+	records map[string]*failureRecord
+	mutex   sync.Mutex
+	limit   int
+	window  time.Duration
+	done    chan struct{}
+	once    sync.Once
+}
+
+// NewAuthFailureLimiter creates a limiter allowing limit failures per window.
+// Call Stop to release the cleanup goroutine once the limiter is no longer used.
+func NewAuthFailureLimiter(limit int, window time.Duration) *AuthFailureLimiter {
+	limiter := &AuthFailureLimiter{
+		records: make(map[string]*failureRecord),
+		limit:   limit,
+		window:  window,
+		done:    make(chan struct{}),
+	}
+
+	// Start cleanup goroutine
+	go limiter.cleanup()
+
+	return limiter
+}
+
+// Blocked reports whether the client has exhausted its failures for the current window,
+// and if so how long until it may try again
+func (l *AuthFailureLimiter) Blocked(clientIP string) (time.Duration, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	record, exists := l.records[clientIP]
+	if !exists || record.count < l.limit {
+		return 0, false
+	}
+
+	retryAfter := time.Until(record.windowStart.Add(l.window))
+	if retryAfter <= 0 {
+		delete(l.records, clientIP)
+		return 0, false
+	}
+	return retryAfter, true
+}
+
+// RecordFailure counts a failed authentication attempt for the client
+func (l *AuthFailureLimiter) RecordFailure(clientIP string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	record, exists := l.records[clientIP]
+	if !exists || now.Sub(record.windowStart) >= l.window {
+		l.records[clientIP] = &failureRecord{count: 1, windowStart: now}
+		return
+	}
+	record.count++
+}
+
+// cleanup removes records whose window has ended
+func (l *AuthFailureLimiter) cleanup() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-ticker.C:
+			l.mutex.Lock()
+			now := time.Now()
+			for clientIP, record := range l.records {
+				if now.Sub(record.windowStart) >= l.window {
+					delete(l.records, clientIP)
+				}
+			}
+			l.mutex.Unlock()
+		}
+	}
+}
+
+// Stop terminates the cleanup goroutine
+func (l *AuthFailureLimiter) Stop() {
+	l.once.Do(func() { close(l.done) })
+}
@@ -0,0 +1,77 @@
+// Package server – read-only maintenance mode
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/penwern/curate-preservation-api/pkg/logger"
+)
+
+// readOnlyRetryAfter is the Retry-After, in seconds, sent with writes rejected in read-only mode
+const readOnlyRetryAfter = 60
+
+// isWriteMethod reports whether a request with the method may change configs
+func isWriteMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// RejectWritesWhenReadOnly creates middleware that answers POST, PUT, PATCH and DELETE
+// requests with 503 Service Unavailable while the server is in read-only maintenance mode.
+// Other requests are served as usual.
+func (s *Server) RejectWritesWhenReadOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.readOnly.Load() && isWriteMethod(r.Method) {
+			logger.FromContext(r.Context()).Infof("Rejecting %s %s in read-only mode", r.Method, r.URL.Path)
+			w.Header().Set("Retry-After", strconv.Itoa(readOnlyRetryAfter))
+			respondWithError(w, http.StatusServiceUnavailable, "The API is in read-only maintenance mode; changes are not accepted")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// readOnlyState is the body of the read-only mode endpoint, both ways
+type readOnlyState struct {
+	ReadOnly *bool `json:"read_only"`
+}
+
+// handleGetReadOnly returns a handler reporting whether read-only mode is on
+func (s *Server) handleGetReadOnly() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		readOnly := s.readOnly.Load()
+		respond(w, r, http.StatusOK, readOnlyState{ReadOnly: &readOnly})
+	}
+}
+
+// handleSetReadOnly returns a handler that turns read-only mode on or off at runtime
+func (s *Server) handleSetReadOnly() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context())
+		var req readOnlyState
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Warnf("Invalid request payload in set read-only: %v", err)
+			respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+		if req.ReadOnly == nil {
+			respondWithError(w, http.StatusBadRequest, "read_only is required")
+			return
+		}
+
+		if s.readOnly.Swap(*req.ReadOnly) != *req.ReadOnly {
+			if *req.ReadOnly {
+				log.Warnf("Read-only maintenance mode turned on; config writes are rejected")
+			} else {
+				log.Infof("Read-only maintenance mode turned off")
+			}
+		}
+		respond(w, r, http.StatusOK, req)
+	}
+}
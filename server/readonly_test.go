@@ -0,0 +1,94 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/penwern/curate-preservation-api/pkg/config"
+)
+
+func TestServer_ReadOnlyMode(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	serve := func(method, url, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := setupTestRequest(method, url, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := serve("PUT", "/api/v1/admin/read-only", `{"read_only": true}`); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 turning read-only mode on, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr := serve("POST", "/api/v1/preservation-configs", `{"name": "Blocked"}`)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 for a write in read-only mode, got %d", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") == "" {
+		t.Error("Expected a Retry-After header on a rejected write")
+	}
+	if rr := serve("DELETE", "/api/v1/preservation-configs/1", ""); rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 for a delete in read-only mode, got %d", rr.Code)
+	}
+	if rr := serve("GET", "/api/v1/preservation-configs/1", ""); rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for a read in read-only mode, got %d", rr.Code)
+	}
+	if rr := serve("GET", "/api/v1/admin/read-only", ""); !strings.Contains(rr.Body.String(), `"read_only":true`) {
+		t.Errorf("Expected read-only mode to be reported on, got %s", rr.Body.String())
+	}
+
+	if rr := serve("PUT", "/api/v1/admin/read-only", `{}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 without read_only, got %d", rr.Code)
+	}
+	if rr := serve("PUT", "/api/v1/admin/read-only", `{"read_only": false}`); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 turning read-only mode off, got %d", rr.Code)
+	}
+	if rr := serve("POST", "/api/v1/preservation-configs", `{"name": "Allowed"}`); rr.Code != http.StatusCreated {
+		t.Errorf("Expected status 201 for a write after read-only mode, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestServer_ReadOnlyDatabase(t *testing.T) {
+	// Create the database, then reopen it read-only, as SQLite does without write permission
+	writable := setupTestServer(t)
+	dbPath := writable.config.DBConnection
+	writable.Shutdown()
+
+	server, err := New(config.Config{
+		DBType:       testDBType,
+		DBConnection: "file:" + dbPath + "?mode=ro",
+		Port:         8080,
+		TrustedIPs:   []string{"127.0.0.1", "::1"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create test server: %v", err)
+	}
+	defer server.Shutdown()
+
+	serve := func(method, url, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := setupTestRequest(method, url, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := serve("GET", "/api/v1/preservation-configs", ""); rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200 reading configs, got %d: %s", rr.Code, rr.Body.String())
+	}
+	rr := serve("POST", "/api/v1/preservation-configs", `{"name": "Blocked"}`)
+	if rr.Code != http.StatusServiceUnavailable || !strings.Contains(rr.Body.String(), "read-only") {
+		t.Errorf("Expected status 503 explaining the database is read-only, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := serve("PUT", "/api/v1/preservation-configs/1", `{"description": "Blocked"}`); rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 updating a config, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// parseTrustedProxies parses a list of proxy IP addresses/CIDR ranges
+func parseTrustedProxies(proxies []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		ipNet, err := parseIPOrCIDR(strings.TrimSpace(proxy))
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy '%s': %w", proxy, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// ipInNets reports whether ip is within any of nets
+func ipInNets(ip net.IP, nets []*net.IPNet) bool {
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// RealIP creates middleware that sets the request's RemoteAddr to the client address given by
+// X-Forwarded-For or X-Real-IP, but only when the connection comes from one of trustedProxies.
+// From any other peer the headers are ignored, so a client cannot claim to be a trusted IP.
+// X-Forwarded-For is read from the right, skipping trusted proxies, so that only addresses
+// appended by our own proxies are believed.
+func RealIP(trustedProxies []*net.IPNet) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if clientIP := forwardedClientIP(r, trustedProxies); clientIP != "" {
+				r.RemoteAddr = clientIP
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// forwardedClientIP returns the client IP forwarded by a trusted proxy, or "" if the
+// forwarding headers should not be used
+func forwardedClientIP(r *http.Request, trustedProxies []*net.IPNet) string {
+	if len(trustedProxies) == 0 {
+		return ""
+	}
+
+	peer := net.ParseIP(remoteHost(r.RemoteAddr))
+	if peer == nil || !ipInNets(peer, trustedProxies) {
+		return ""
+	}
+
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		hops := strings.Split(xff, ",")
+		var clientIP string
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(hops[i]))
+			if ip == nil {
+				// Anything left of a malformed entry can't be trusted
+				break
+			}
+			clientIP = ip.String()
+			if !ipInNets(ip, trustedProxies) {
+				break
+			}
+		}
+		return clientIP
+	}
+
+	if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+		return ip.String()
+	}
+	return ""
+}
+
+// remoteHost returns the host part of a RemoteAddr, which may or may not include a port
+func remoteHost(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
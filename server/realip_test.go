@@ -0,0 +1,94 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/penwern/curate-preservation-api/pkg/config"
+)
+
+func TestForwardedClientIP(t *testing.T) {
+	proxies, err := parseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.1"})
+	if err != nil {
+		t.Fatalf("Failed to parse trusted proxies: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        string
+		xRealIP    string
+		expected   string
+	}{
+		{"untrusted peer is ignored", "203.0.113.5:1234", "127.0.0.1", "", ""},
+		{"untrusted peer X-Real-IP is ignored", "203.0.113.5:1234", "", "127.0.0.1", ""},
+		{"trusted proxy", "10.1.2.3:1234", "198.51.100.7", "", "198.51.100.7"},
+		{"spoofed entry left of the real client", "10.1.2.3:1234", "127.0.0.1, 198.51.100.7", "", "198.51.100.7"},
+		{"chain of trusted proxies", "10.1.2.3:1234", "198.51.100.7, 192.0.2.1, 10.9.9.9", "", "198.51.100.7"},
+		{"malformed entry", "10.1.2.3:1234", "127.0.0.1, not-an-ip, 10.9.9.9", "", "10.9.9.9"},
+		{"X-Real-IP from trusted proxy", "192.0.2.1:1234", "", "198.51.100.7", "198.51.100.7"},
+		{"no forwarding headers", "10.1.2.3:1234", "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.xff != "" {
+				req.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			if tt.xRealIP != "" {
+				req.Header.Set("X-Real-IP", tt.xRealIP)
+			}
+
+			if got := forwardedClientIP(req, proxies); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+
+	if _, err := parseTrustedProxies([]string{"not-a-proxy"}); err == nil {
+		t.Error("Expected an error for an invalid trusted proxy")
+	}
+}
+
+func TestServer_SpoofedForwardedForIsNotTrusted(t *testing.T) {
+	server, err := New(config.Config{
+		DBType:         testDBType,
+		DBConnection:   filepath.Join(t.TempDir(), "test.db"),
+		Port:           8080,
+		TrustedIPs:     []string{"127.0.0.1"},
+		TrustedProxies: []string{"10.0.0.1"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer server.Shutdown()
+
+	tests := []struct {
+		name           string
+		remoteAddr     string
+		xff            string
+		expectedStatus int
+	}{
+		{"spoofed header from untrusted peer", "203.0.113.5:1234", "127.0.0.1", http.StatusUnauthorized},
+		{"trusted IP forwarded by trusted proxy", "10.0.0.1:1234", "127.0.0.1", http.StatusOK},
+		{"untrusted IP forwarded by trusted proxy", "10.0.0.1:1234", "203.0.113.5", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/preservation-configs", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set("X-Forwarded-For", tt.xff)
+			rr := httptest.NewRecorder()
+			server.router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+		})
+	}
+}
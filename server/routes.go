@@ -2,44 +2,205 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"reflect"
+	"runtime"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/mitchellh/mapstructure"
 	"github.com/penwern/curate-preservation-api/database"
 	"github.com/penwern/curate-preservation-api/models"
 	"github.com/penwern/curate-preservation-api/pkg/logger"
+	"github.com/penwern/curate-preservation-api/pkg/version"
+	"google.golang.org/protobuf/proto"
 )
 
-// routes registers the API routes
+// apiPrefix is the path of the current API version, below the configured base path
+const apiPrefix = "/api/v1"
+
+// routes registers the API routes under the configured base path
 func (s *Server) routes() {
-	// API version prefix
-	s.router.Route("/api/v1", func(r chi.Router) {
-		// Health check (public, no auth required)
-		r.Method("GET", "/health", s.handleHealth())
-		r.Method("HEAD", "/health", s.handleHealth())
+	// Set before mounting so that the API subrouters inherit them
+	s.router.NotFound(s.handleNotFound())
+	s.router.MethodNotAllowed(s.handleMethodNotAllowed())
+
+	// Requests are cancelled after the request timeout, except the bulk routes of the
+	// preservation configs, which may handle many configs and are given their own
+	timeout := Timeout(s.requestTimeout)
+	bulkTimeout := Timeout(s.bulkRequestTimeout)
+
+	queueTimeout := s.config.ConcurrencyQueueTimeout
+	if queueTimeout <= 0 {
+		queueTimeout = defaultConcurrencyQueueTimeout
+	}
+
+	s.router.Route(s.basePath+apiPrefix, func(r chi.Router) {
+		r.Group(func(r chi.Router) {
+			r.Use(timeout)
+
+			s.publicRoutes(r)
+
+			// Token cache invalidation pushed by Pydio Cells (trusted IPs only)
+			r.With(TrustedIPOnly(s.config.TrustedIPs)).Post("/auth/invalidate", s.handleInvalidateTokens())
+
+			// Read-only maintenance mode (trusted IPs only)
+			r.With(TrustedIPOnly(s.config.TrustedIPs)).Get("/admin/read-only", s.handleGetReadOnly())
+			r.With(TrustedIPOnly(s.config.TrustedIPs)).Put("/admin/read-only", s.handleSetReadOnly())
+
+			// Subsystem health for triage (trusted IPs only)
+			r.With(TrustedIPOnly(s.config.TrustedIPs)).Get("/admin/diagnostics", s.handleDiagnostics())
+		})
 
 		// Protected routes
 		r.Group(func(r chi.Router) {
-			// Apply authentication middleware to protected routes with configured site domain and trusted IPs
-			r.Use(Auth(s.config.SiteDomain, s.config.TrustedIPs, s.config.AllowInsecureTLS))
+			// Shed load before doing any work; the public routes above are left out so that
+			// probes are still answered when the server is saturated
+			r.Use(ConcurrencyLimit(s.config.MaxConcurrentRequests, queueTimeout))
+
+			// Authenticate with the configured site domains and trusted IPs. Each group applies
+			// its timeout first, so that validating a token upstream counts against it.
+			authenticated := chi.Chain(
+				Auth(s.userInfoCache, s.authClient, s.authFailureLimiter, s.apiKeys, s.siteDomains, s.config.OIDCAudience, s.config.TrustedIPs),
+				s.RequireMigrations,
+			)
+
+			r.Group(func(r chi.Router) {
+				r.Use(timeout)
+				r.Use(authenticated...)
+
+				r.Post("/auth/logout", s.handleLogout())
+
+				r.Get("/presets", s.handleListPresets())
+			})
 
 			// Preservation configurations
 			r.Route("/preservation-configs", func(r chi.Router) {
-				r.Get("/", s.handleListConfigs())
-				r.Post("/", s.handleCreateConfig())
+				// Run once authenticated, after the timeout of each group
+				configs := chi.Chain(s.RejectWritesWhenReadOnly, DecompressBody)
+
+				// Routes that decode a request body check its Content-Type when configured to
+				requireContentType := RequireContentType(s.config.StrictContentType)
+
+				// Bulk routes
+				r.Group(func(r chi.Router) {
+					r.Use(bulkTimeout)
+					r.Use(authenticated...)
+					r.Use(configs...)
+
+					r.With(requireContentType).Post("/bulk", s.handleBulkCreateConfigs())
+					r.With(requireContentType).Post("/bulk-delete", s.handleBulkDeleteConfigs())
+					r.With(requireContentType).Post("/import", s.handleImportConfigs())
+				})
+
+				// The export streams its response, so it can't be buffered for a timeout response
+				r.With(Deadline(s.bulkRequestTimeout)).With(authenticated...).With(configs...).Get("/export", s.handleExportConfigs())
+
+				r.Group(func(r chi.Router) {
+					r.Use(timeout)
+					r.Use(authenticated...)
+					r.Use(configs...)
+
+					r.Get("/", s.handleListConfigs())
+					r.With(requireContentType).Post("/", s.handleCreateConfig())
+					r.With(requireContentType).Post("/validate", s.handleValidateConfig())
+					r.Get("/count", s.handleCountConfigs())
+					r.Get("/search", s.handleSearchConfigs())
+					r.Get("/schema", s.handleConfigSchema())
 
-				r.Route("/{id}", func(r chi.Router) {
-					r.Get("/", s.handleGetConfig())
-					r.Put("/", s.handleUpdateConfig())
-					r.Delete("/", s.handleDeleteConfig())
+					r.Route("/{id}", func(r chi.Router) {
+						r.Get("/", s.handleGetConfig())
+						r.With(requireContentType).Put("/", s.handleUpdateConfig())
+						r.Delete("/", s.handleDeleteConfig())
+						r.With(requireContentType).Put("/active", s.handleSetConfigActive())
+						r.Get("/a3m", s.handleGetA3MConfig())
+						r.Get("/diff/{otherId}", s.handleDiffConfigs())
+					})
 				})
 			})
 		})
 	})
+
+	// Probes that can't be configured with the base path can still reach the public routes
+	if s.basePath != "" && s.config.HealthAtRoot {
+		s.router.Route(apiPrefix, func(r chi.Router) {
+			r.Use(timeout)
+			s.publicRoutes(r)
+		})
+	}
+
+	s.routeIndex = indexRoutes(s.router)
+}
+
+// indexRoutes copies every route of router into a single flat router, so that the methods
+// a path allows can be looked up without descending through mounted subrouters
+func indexRoutes(router chi.Routes) *chi.Mux {
+	index := chi.NewRouter()
+	noop := func(http.ResponseWriter, *http.Request) {}
+	err := chi.Walk(router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		index.MethodFunc(method, route, noop)
+		// A subrouter's "/" route is also served without the trailing slash
+		if trimmed := strings.TrimSuffix(route, "/"); trimmed != route && trimmed != "" {
+			index.MethodFunc(method, trimmed, noop)
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Error("Failed to index routes: %v", err)
+	}
+	return index
+}
+
+// publicRoutes registers the health, readiness and version routes, which need no auth
+func (s *Server) publicRoutes(r chi.Router) {
+	// Health check (public, no auth required)
+	r.Method("GET", "/health", s.handleHealth())
+	r.Method("HEAD", "/health", s.handleHealth())
+
+	// Readiness check, reporting whether the database can be reached (public, no auth required)
+	r.Method("GET", "/ready", s.handleReady())
+	r.Method("HEAD", "/ready", s.handleReady())
+
+	// Build information (public, no auth required)
+	r.Get("/version", s.handleVersion())
+}
+
+// routeMethods are the methods checked when listing those a route allows
+var routeMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+
+// handleNotFound returns a handler answering requests to unknown routes with a JSON 404
+func (s *Server) handleNotFound() http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		respondWithError(w, http.StatusNotFound, "Resource not found")
+	}
+}
+
+// handleMethodNotAllowed returns a handler answering requests with a method the route doesn't
+// support with a JSON 405 and an Allow header listing the methods it does
+func (s *Server) handleMethodNotAllowed() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.RawPath
+		if path == "" {
+			path = r.URL.Path
+		}
+		var allowed []string
+		for _, method := range routeMethods {
+			if s.routeIndex.Match(chi.NewRouteContext(), method, path) {
+				allowed = append(allowed, method)
+			}
+		}
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		respondWithError(w, http.StatusMethodNotAllowed, fmt.Sprintf("Method %s not allowed", r.Method))
+	}
 }
 
 // handleHealth returns a health check handler
@@ -49,277 +210,1601 @@ func (s *Server) handleHealth() http.HandlerFunc {
 	}
 }
 
+// readyResponse is the body of the readiness check
+type readyResponse struct {
+	Status    string                    `json:"status"`
+	Error     string                    `json:"error,omitempty"`
+	Migration *database.MigrationStatus `json:"migration,omitempty"`
+}
+
+// handleReady returns a readiness check handler that pings the database, reconnecting if need be,
+// and reports the schema version against the one the binary expects. It reports 503 with a
+// Retry-After while startup migrations are still running, and 503 if the last migration
+// failed part way, leaving the schema dirty.
+func (s *Server) handleReady() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context())
+		done, err := s.migrationsDone()
+		if !done {
+			w.Header().Set("Retry-After", strconv.Itoa(migrationRetryAfter))
+			respondWithJSON(w, http.StatusServiceUnavailable, readyResponse{Status: "migrating"})
+			return
+		}
+		if err != nil {
+			respondWithJSON(w, http.StatusServiceUnavailable, readyResponse{Status: "unavailable", Error: "database migrations failed"})
+			return
+		}
+
+		if err := s.db.Ping(r.Context()); err != nil {
+			log.Errorf("Readiness check failed: %v", err)
+			respondWithJSON(w, http.StatusServiceUnavailable, readyResponse{Status: "unavailable", Error: "database unavailable"})
+			return
+		}
+
+		status, err := s.db.MigrationStatus(r.Context())
+		if err != nil {
+			log.Errorf("Readiness check failed: %v", err)
+			respondWithJSON(w, http.StatusServiceUnavailable, readyResponse{Status: "unavailable", Error: "database schema version unavailable"})
+			return
+		}
+		if status.Dirty {
+			log.Errorf("Readiness check failed: migration %d is dirty", status.Version)
+			respondWithJSON(w, http.StatusServiceUnavailable, readyResponse{Status: "unavailable", Error: "database migration is dirty", Migration: &status})
+			return
+		}
+		respondWithJSON(w, http.StatusOK, readyResponse{Status: "ready", Migration: &status})
+	}
+}
+
+// versionResponse describes the running build
+type versionResponse struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+}
+
+// handleVersion returns a handler reporting the version of the running build
+func (s *Server) handleVersion() http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		respondWithJSON(w, http.StatusOK, versionResponse{
+			Version:   version.Version(),
+			Commit:    version.Commit(),
+			BuildTime: version.BuildTime(),
+			GoVersion: runtime.Version(),
+		})
+	}
+}
+
+// presetResponse describes a named A3M configuration template
+type presetResponse struct {
+	Name        string                      `json:"name"`
+	Description string                      `json:"description"`
+	A3MConfig   *models.A3MProcessingConfig `json:"a3m_config"`
+}
+
+// handleListPresets returns a handler listing the A3M configuration presets
+func (s *Server) handleListPresets() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		names := models.PresetNames()
+		presets := make([]presetResponse, 0, len(names))
+		for _, name := range names {
+			a3mConfig, _ := models.NewPresetA3MConfig(name)
+			presets = append(presets, presetResponse{
+				Name:        name,
+				Description: models.PresetDescription(name),
+				A3MConfig:   a3mConfig,
+			})
+		}
+		respond(w, r, http.StatusOK, presets)
+	}
+}
+
+// handleConfigSchema returns a handler that describes the config model as a JSON Schema,
+// for clients that build config forms from it
+func (s *Server) handleConfigSchema() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		respondWithJSON(w, http.StatusOK, models.ConfigSchema(s.maxNameLength, s.maxDescriptionLength))
+	}
+}
+
+// handleLogout returns a handler that drops the caller's token from the auth cache
+func (s *Server) handleLogout() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context())
+		// Trusted IP callers may not present a token, in which case there is nothing to invalidate
+		if token, err := bearerToken(r); err == nil {
+			s.userInfoCache.Invalidate(token)
+			log.Infof("Logged out user: %s", GetUserInfo(r).Sub)
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// invalidateTokensRequest is the body accepted by the token invalidation endpoint
+type invalidateTokensRequest struct {
+	Token string `json:"token"`
+	All   bool   `json:"all"`
+}
+
+// handleInvalidateTokens returns a handler that removes revoked tokens from the auth cache
+func (s *Server) handleInvalidateTokens() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context())
+		var req invalidateTokensRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Warnf("Invalid request payload in token invalidation: %v", err)
+			respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+
+		switch {
+		case req.All:
+			s.userInfoCache.Clear()
+			log.Infof("Cleared all cached user info")
+		case req.Token != "":
+			s.userInfoCache.Invalidate(req.Token)
+			log.Infof("Invalidated cached user info for revoked token")
+		default:
+			respondWithError(w, http.StatusBadRequest, "Either token or all is required")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
 // handleListConfigs returns a handler to list all preservation configs
 func (s *Server) handleListConfigs() http.HandlerFunc {
-	return func(w http.ResponseWriter, _ *http.Request) {
-		logger.Info("Fetching all preservation configs")
-		configs, err := s.db.ListConfigs()
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context())
+		query := r.URL.Query()
+		limit, err := queryInt(query, "limit")
+		if err != nil {
+			log.Warnf("Invalid limit in list configs request: %v", err)
+			respondWithError(w, http.StatusBadRequest, "Invalid limit: must be a non-negative integer")
+			return
+		}
+		offset, err := queryInt(query, "offset")
 		if err != nil {
-			logger.Error("Failed to fetch configs: %v", err)
+			log.Warnf("Invalid offset in list configs request: %v", err)
+			respondWithError(w, http.StatusBadRequest, "Invalid offset: must be a non-negative integer")
+			return
+		}
+
+		view, err := parseConfigView(query)
+		if err != nil {
+			log.Warnf("Invalid view in list configs request: %v", err)
+			respondWithError(w, http.StatusBadRequest, "Invalid query: "+err.Error())
+			return
+		}
+
+		// A cursor switches to keyset pagination, which always walks the configs in id order
+		if query.Has("after_id") {
+			s.listConfigsAfter(w, r, limit, view)
+			return
+		}
+
+		filters, err := queryFilters(query)
+		if err != nil {
+			log.Warnf("Invalid filter in list configs request: %v", err)
+			respondWithError(w, http.StatusBadRequest, "Invalid filter: "+err.Error())
+			return
+		}
+		tag := ""
+		if query.Has("tag") {
+			tags, err := models.NormalizeTags([]string{query.Get("tag")})
+			if err != nil {
+				log.Warnf("Invalid tag in list configs request: %v", err)
+				respondWithError(w, http.StatusBadRequest, "Invalid tag: "+err.Error())
+				return
+			}
+			tag = tags[0]
+		}
+		createdAfter, err := queryTime(query, "created_after")
+		if err != nil {
+			log.Warnf("Invalid created_after in list configs request: %v", err)
+			respondWithError(w, http.StatusBadRequest, "Invalid created_after: "+err.Error())
+			return
+		}
+		createdBefore, err := queryTime(query, "created_before")
+		if err != nil {
+			log.Warnf("Invalid created_before in list configs request: %v", err)
+			respondWithError(w, http.StatusBadRequest, "Invalid created_before: "+err.Error())
+			return
+		}
+		if !createdAfter.IsZero() && !createdBefore.IsZero() && !createdAfter.Before(createdBefore) {
+			log.Warnf("Empty date range in list configs request: %s to %s", createdAfter, createdBefore)
+			respondWithError(w, http.StatusBadRequest, "Invalid date range: created_after must be before created_before")
+			return
+		}
+		filter := database.ConfigFilter{Fields: filters, Tag: tag, CreatedAfter: createdAfter, CreatedBefore: createdBefore}
+		envelope, err := queryBool(query, "envelope")
+		if err != nil {
+			log.Warnf("Invalid envelope in list configs request: %v", err)
+			respondWithError(w, http.StatusBadRequest, "Invalid envelope: must be true or false")
+			return
+		}
+
+		if s.collectionNotModified(w, r) {
+			return
+		}
+
+		sortField, order := query.Get("sort"), query.Get("order")
+		log.Infof("Fetching preservation configs (filter: %+v, sort: %s, order: %s, limit: %d, offset: %d)", filter, sortField, order, limit, offset)
+		configs, err := s.db.FilterConfigsContext(r.Context(), filter, sortField, order, limit, offset)
+		if errors.Is(err, database.ErrInvalidSort) {
+			log.Warnf("Invalid sort in list configs request: %v", err)
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid sort: sort must be one of %v and order 'asc' or 'desc'", database.SortFields()))
+			return
+		}
+		if err != nil {
+			log.Errorf("Failed to fetch configs: %v", err)
 			respondWithError(w, http.StatusInternalServerError, "Failed to fetch configs")
 			return
 		}
 
-		logger.Debug("Successfully fetched %d configs", len(configs))
-		respondWithJSON(w, http.StatusOK, configs)
+		total, err := s.db.CountFilteredConfigsContext(r.Context(), filter)
+		if err != nil {
+			log.Errorf("Failed to count configs: %v", err)
+			respondWithError(w, http.StatusInternalServerError, "Failed to fetch configs")
+			return
+		}
+
+		log.Debugf("Successfully fetched %d of %d configs", len(configs), total)
+		w.Header().Set("X-Total-Count", strconv.FormatInt(total, 10))
+		if !envelope {
+			respondWithView(w, r, configs, view)
+			return
+		}
+
+		list := configList{
+			Data: configs,
+			Pagination: pagination{
+				Total:   total,
+				Limit:   limit,
+				Offset:  offset,
+				HasMore: int64(offset+len(configs)) < total,
+			},
+		}
+		if list.Data == nil {
+			list.Data = []*models.PreservationConfig{}
+		}
+		respondWithView(w, r, list, view)
+	}
+}
+
+// pagination describes where a page of configs lies in the whole list. A zero limit means
+// the page wasn't limited.
+type pagination struct {
+	Total   int64 `json:"total"`
+	Limit   int   `json:"limit"`
+	Offset  int   `json:"offset"`
+	HasMore bool  `json:"has_more"`
+}
+
+// configList is the list response returned instead of a bare array with ?envelope=true
+type configList struct {
+	Data       []*models.PreservationConfig `json:"data"`
+	Pagination pagination                   `json:"pagination"`
+}
+
+// collectionNotModified sets Last-Modified to when the configs last changed and, if that is
+// no later than the request's If-Modified-Since, responds 304 Not Modified and returns true.
+// Failing to look up the time only costs the validator, so the list is still served.
+func (s *Server) collectionNotModified(w http.ResponseWriter, r *http.Request) bool {
+	modified, err := s.db.MaxUpdatedAtContext(r.Context())
+	if err != nil {
+		logger.FromContext(r.Context()).Errorf("Failed to get configs last modified time: %v", err)
+		return false
+	}
+	return checkLastModified(w, r, modified, time.Now())
+}
+
+// checkLastModified sets Last-Modified from modified and answers a matching If-Modified-Since
+// with 304 Not Modified, returning true if it did. HTTP dates have whole seconds, so modified
+// is rounded up, and no validator is given until a second after that: until then, a further
+// change could be stamped with the same second, and clients would miss it.
+func checkLastModified(w http.ResponseWriter, r *http.Request, modified, now time.Time) bool {
+	if modified.IsZero() {
+		return false
+	}
+	stamp := modified.Truncate(time.Second)
+	if stamp.Before(modified) {
+		stamp = stamp.Add(time.Second)
+	}
+	if now.Before(stamp.Add(time.Second)) {
+		return false
+	}
+
+	w.Header().Set("Last-Modified", stamp.UTC().Format(http.TimeFormat))
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || stamp.After(since) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// defaultCursorLimit is the page size for keyset pagination when no limit is given
+const defaultCursorLimit = 20
+
+// configPage is one page of configs from keyset pagination. NextCursor is the after_id
+// that fetches the following page, or null on the last page.
+type configPage struct {
+	Items      []*models.PreservationConfig `json:"items"`
+	NextCursor *int64                       `json:"next_cursor"`
+}
+
+// listConfigsAfter responds with the page of configs following the after_id cursor,
+// shown as view asks
+func (s *Server) listConfigsAfter(w http.ResponseWriter, r *http.Request, limit int, view configView) {
+	log := logger.FromContext(r.Context())
+	query := r.URL.Query()
+
+	afterID, err := strconv.ParseInt(query.Get("after_id"), 10, 64)
+	if err != nil || afterID < 0 {
+		log.Warnf("Invalid after_id in list configs request: %q", query.Get("after_id"))
+		respondWithError(w, http.StatusBadRequest, "Invalid after_id: must be a non-negative integer")
+		return
+	}
+	for _, param := range append([]string{"offset", "sort", "order", "envelope", "tag", "created_after", "created_before"}, database.FilterFields()...) {
+		if query.Has(param) {
+			log.Warnf("List configs request combines after_id with %s", param)
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("after_id cannot be combined with %s", param))
+			return
+		}
+	}
+	if limit == 0 {
+		limit = defaultCursorLimit
+	}
+	if s.collectionNotModified(w, r) {
+		return
+	}
+
+	log.Infof("Fetching preservation configs after ID %d (limit: %d)", afterID, limit)
+	// Fetch one extra config to learn whether another page follows
+	configs, err := s.db.ListConfigsAfterContext(r.Context(), afterID, limit+1)
+	if err != nil {
+		log.Errorf("Failed to fetch configs: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch configs")
+		return
+	}
+
+	page := configPage{Items: configs}
+	if page.Items == nil {
+		page.Items = []*models.PreservationConfig{}
+	}
+	if len(configs) > limit {
+		page.Items = configs[:limit]
+		nextCursor := page.Items[limit-1].ID
+		page.NextCursor = &nextCursor
+	}
+
+	log.Debugf("Successfully fetched %d configs after ID %d", len(page.Items), afterID)
+	respondWithView(w, r, page, view)
+}
+
+// countResponse reports the total number of preservation configs
+type countResponse struct {
+	Count int64 `json:"count"`
+}
+
+// handleCountConfigs returns a handler reporting the total number of preservation configs
+func (s *Server) handleCountConfigs() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context())
+		count, err := s.db.CountConfigsContext(r.Context())
+		if err != nil {
+			log.Errorf("Failed to count configs: %v", err)
+			respondWithError(w, http.StatusInternalServerError, "Failed to count configs")
+			return
+		}
+
+		respond(w, r, http.StatusOK, countResponse{Count: count})
+	}
+}
+
+// handleGetA3MConfig returns a handler responding with just the A3M processing config of a
+// preservation config, in the transfer service's proto JSON form with enums as numbers, or
+// as binary protobuf when the client accepts application/x-protobuf
+func (s *Server) handleGetA3MConfig() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context())
+		id, ok := s.idParam(w, r)
+		if !ok {
+			return
+		}
+		enumNames, err := parseEnumFormat(r.URL.Query().Get("enum_format"))
+		if err != nil {
+			log.Warnf("Invalid enum format in get A3M config request: %v", err)
+			respondWithError(w, http.StatusBadRequest, "Invalid query: "+err.Error())
+			return
+		}
+
+		config, err := s.db.GetConfigContext(r.Context(), id)
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				log.Warnf("Preservation config not found: %d", id)
+				respondWithError(w, http.StatusNotFound, "Preservation config not found")
+				return
+			}
+			log.Errorf("Failed to fetch config %d: %v", id, err)
+			respondWithError(w, http.StatusInternalServerError, "Failed to fetch config")
+			return
+		}
+
+		w.Header().Set("Vary", "Accept")
+		if wantsProtobuf(r) {
+			b, err := proto.Marshal(config.ToA3MConfig())
+			if err != nil {
+				log.Errorf("Failed to marshal A3M config %d as protobuf: %v", id, err)
+				respondWithError(w, http.StatusInternalServerError, "Failed to encode A3M config")
+				return
+			}
+			w.Header().Set("Content-Type", protobufContentType)
+			w.WriteHeader(http.StatusOK)
+			if _, err := w.Write(b); err != nil {
+				log.Errorf("Failed to write response: %v", err)
+			}
+			return
+		}
+
+		// Marshal through the model so the protojson options match the rest of the API
+		var a3m any = (*models.A3MProcessingConfig)(config.ToA3MConfig())
+		if enumNames {
+			doc, err := toJSONDocument(a3m)
+			if err != nil {
+				log.Errorf("Failed to encode A3M config %d: %v", id, err)
+				respondWithError(w, http.StatusInternalServerError, "Failed to encode A3M config")
+				return
+			}
+			if fields, ok := doc.(map[string]any); ok {
+				models.NameA3MEnums(fields)
+			}
+			a3m = doc
+		}
+		respond(w, r, http.StatusOK, a3m)
+	}
+}
+
+// handleSearchConfigs returns a handler searching config names for the q parameter, or the
+// fields listed in the in parameter instead, e.g. in=name,description. With fts=true
+// the backend's full-text index is used, matching word prefixes; otherwise every word must
+// appear as a substring.
+func (s *Server) handleSearchConfigs() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context())
+		query := r.URL.Query()
+		term := strings.TrimSpace(query.Get("q"))
+		if term == "" {
+			log.Warnf("Search configs request missing q parameter")
+			respondWithError(w, http.StatusBadRequest, "Search term q is required")
+			return
+		}
+
+		fullText := false
+		if value := query.Get("fts"); value != "" {
+			var err error
+			if fullText, err = strconv.ParseBool(value); err != nil {
+				log.Warnf("Invalid fts in search configs request: %q", value)
+				respondWithError(w, http.StatusBadRequest, "Invalid fts: must be true or false")
+				return
+			}
+		}
+
+		var fields []string
+		if query.Has("in") {
+			for _, field := range strings.Split(query.Get("in"), ",") {
+				fields = append(fields, strings.TrimSpace(field))
+			}
+		}
+
+		log.Infof("Searching preservation configs for %q (fields: %v, full-text: %v)", term, fields, fullText)
+		var configs []*models.PreservationConfig
+		var err error
+		if fullText {
+			configs, err = s.db.FullTextSearchContext(r.Context(), term, fields)
+		} else {
+			configs, err = s.db.SearchConfigsContext(r.Context(), term, fields)
+		}
+		if errors.Is(err, database.ErrUnknownSearchField) {
+			log.Warnf("Invalid in in search configs request: %v", err)
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid in: fields must be among %v", database.SearchFields()))
+			return
+		}
+		if errors.Is(err, database.ErrInvalidSearch) {
+			log.Warnf("Invalid search configs request: %v", err)
+			respondWithError(w, http.StatusBadRequest, "Invalid search: q has nothing to search for")
+			return
+		}
+		if err != nil {
+			log.Errorf("Failed to search configs: %v", err)
+			respondWithError(w, http.StatusInternalServerError, "Failed to search configs")
+			return
+		}
+		if configs == nil {
+			configs = []*models.PreservationConfig{}
+		}
+
+		log.Debugf("Search for %q matched %d configs", term, len(configs))
+		respond(w, r, http.StatusOK, configs)
+	}
+}
+
+// annotatedConfig is a config with the A3M fields it changes from the system defaults,
+// returned by GET with annotate=true
+type annotatedConfig struct {
+	*models.PreservationConfig
+	NonDefaultFields []string `json:"non_default_fields"`
+}
+
+// handleGetConfig returns a handler to get a specific preservation config
+func (s *Server) handleGetConfig() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context())
+		id, ok := s.idParam(w, r)
+		if !ok {
+			return
+		}
+		enumNames, err := parseEnumFormat(r.URL.Query().Get("enum_format"))
+		if err != nil {
+			log.Warnf("Invalid enum format in get config request: %v", err)
+			respondWithError(w, http.StatusBadRequest, "Invalid query: "+err.Error())
+			return
+		}
+		annotate, err := queryBool(r.URL.Query(), "annotate")
+		if err != nil {
+			log.Warnf("Invalid annotate in get config request: %v", err)
+			respondWithError(w, http.StatusBadRequest, "Invalid query: "+err.Error())
+			return
+		}
+
+		log.Infof("Fetching preservation config with ID: %d", id)
+		config, err := s.db.GetConfigContext(r.Context(), id)
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				log.Warnf("Preservation config not found: %d", id)
+				respondWithError(w, http.StatusNotFound, "Preservation config not found")
+				return
+			}
+			log.Errorf("Failed to fetch config %d: %v", id, err)
+			respondWithError(w, http.StatusInternalServerError, "Failed to fetch config")
+			return
+		}
+
+		log.Debugf("Successfully fetched config: %s (ID: %d)", config.Name, config.ID)
+
+		// The version changes on every update, so it identifies the config's state
+		etag := configETag(config)
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Vary", "Accept")
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			log.Debugf("Config %d not modified", config.ID)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		var payload any = config
+		if annotate {
+			payload = annotatedConfig{
+				PreservationConfig: config,
+				NonDefaultFields:   models.NonDefaultA3MFields(&config.A3MConfig),
+			}
+		}
+		respondWithView(w, r, payload, configView{enumNames: enumNames})
+
+		log.Debugf("Config: %+v", config)
+	}
+}
+
+// handleCreateConfig returns a handler to create a new preservation config
+func (s *Server) handleCreateConfig() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context())
+		// An optional preset replaces the defaults that the body overrides
+		preset := r.URL.Query().Get("preset")
+		if _, ok := models.NewPresetA3MConfig(preset); preset != "" && !ok {
+			log.Warnf("Create config request with unknown preset: %s", preset)
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Unknown preset: %s", preset))
+			return
+		}
+
+		// Parse the raw JSON to detect which fields are provided
+		var rawInput map[string]any
+		if err := decodeBody(r, &rawInput); err != nil {
+			log.Warnf("Invalid request payload in create config: %v", err)
+			respondWithDecodeError(w, err)
+			return
+		}
+		if !s.rejectUnknownFields(w, r, rawInput) {
+			return
+		}
+
+		log.Debugf("Raw input: %v", rawInput)
+
+		config, fieldErrs := s.ValidateConfigInput(r.Context(), rawInput, preset)
+		if len(fieldErrs) > 0 {
+			log.Warnf("Create config request is invalid: %s", joinFieldErrors(fieldErrs))
+			respondWithFieldErrors(w, fieldErrs)
+			return
+		}
+
+		// A retried request with the same Idempotency-Key gets the config created the first time
+		idempotencyKey, handled := s.reserveIdempotencyKey(w, r, preset, rawInput)
+		if handled {
+			return
+		}
+		if idempotencyKey != "" {
+			// Frees the key unless the create below completes it
+			defer s.idempotency.Release(idempotencyKey)
+		}
+
+		log.Infof("Creating new preservation config: %s", config.Name)
+
+		log.Debugf("Updated Config: %+v", config)
+
+		if err := s.db.CreateConfigContext(r.Context(), config); err != nil {
+			if errors.Is(err, database.ErrQuotaExceeded) {
+				log.Warnf("Rejected config '%s': %v", config.Name, err)
+				respondWithQuotaExceeded(w, err)
+				return
+			}
+			if errors.Is(err, database.ErrReadOnly) {
+				log.Errorf("Cannot create config '%s': %v", config.Name, err)
+				respondWithReadOnlyDatabase(w)
+				return
+			}
+			log.Errorf("Failed to create config '%s': %v", config.Name, err)
+			respondWithError(w, http.StatusInternalServerError, "Failed to create config")
+			return
+		}
+		if idempotencyKey != "" {
+			s.idempotency.Complete(idempotencyKey, config.ID)
+		}
+		s.notifyConfigChange(r, EventConfigCreated, config.ID)
+
+		// Fetch the created config from the database to ensure we return the actual saved data
+		createdConfig, err := s.db.GetConfigContext(r.Context(), config.ID)
+		if err != nil {
+			log.Errorf("Failed to fetch created config %d: %v", config.ID, err)
+			respondWithError(w, http.StatusInternalServerError, "Failed to fetch created config")
+			return
+		}
+
+		log.Debugf("Created Config: %+v", createdConfig)
+
+		log.Infof("Successfully created preservation config: %s (ID: %d)", createdConfig.Name, createdConfig.ID)
+		s.respondCreated(w, r, createdConfig)
+	}
+}
+
+// respondCreated answers a create with 201, a Location header pointing at the config and
+// the config itself, or an empty body if the client sent Prefer: return=minimal
+func (s *Server) respondCreated(w http.ResponseWriter, r *http.Request, config *models.PreservationConfig) {
+	w.Header().Set("Location", fmt.Sprintf("%s%s/preservation-configs/%d", s.basePath, apiPrefix, config.ID))
+	if prefersMinimal(r) {
+		w.Header().Set(preferenceAppliedHeader, "return=minimal")
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+	respond(w, r, http.StatusCreated, config)
+}
+
+// reserveIdempotencyKey claims the request's Idempotency-Key, scoped to its user, for
+// creating the config described by preset and rawInput, returning the scoped key to
+// complete or release. It returns true if it has already responded instead: with the
+// config an earlier request with the key created, or with an error if the key is in use
+// by a request still running or was used for a different config. Without the header it
+// returns an empty key.
+func (s *Server) reserveIdempotencyKey(w http.ResponseWriter, r *http.Request, preset string, rawInput map[string]any) (string, bool) {
+	log := logger.FromContext(r.Context())
+	key := r.Header.Get(idempotencyKeyHeader)
+	if key == "" {
+		return "", false
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		log.Warnf("Create config request with an idempotency key of %d bytes", len(key))
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid %s: must be at most %d characters", idempotencyKeyHeader, maxIdempotencyKeyLength))
+		return "", true
+	}
+
+	scope := ""
+	if userInfo := GetUserInfo(r); userInfo != nil {
+		scope = userInfo.Sub
+	}
+	scopedKey := scope + "\x00" + key
+
+	// Maps marshal with sorted keys, so the same body always gives the same fingerprint
+	body, err := json.Marshal(rawInput)
+	if err != nil {
+		log.Errorf("Failed to fingerprint create config request: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to create config")
+		return "", true
+	}
+	fingerprint := preset + "\x00" + string(body)
+
+	configID, status := s.idempotency.Reserve(scopedKey, fingerprint)
+	switch status {
+	case idempotencyInProgress:
+		log.Warnf("Create config request repeats idempotency key %q while it is in progress", key)
+		respondWithError(w, http.StatusConflict, "A request with this Idempotency-Key is still in progress")
+		return "", true
+	case idempotencyMismatch:
+		log.Warnf("Create config request reuses idempotency key %q for a different config", key)
+		respondWithError(w, http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request")
+		return "", true
+	case idempotencyDone:
+		log.Infof("Replaying create of config %d for idempotency key %q", configID, key)
+		config, err := s.db.GetConfigContext(r.Context(), configID)
+		if errors.Is(err, database.ErrNotFound) {
+			respondWithError(w, http.StatusNotFound, "Preservation config created with this Idempotency-Key no longer exists")
+			return "", true
+		}
+		if err != nil {
+			log.Errorf("Failed to fetch config %d for idempotency key replay: %v", configID, err)
+			respondWithError(w, http.StatusInternalServerError, "Failed to fetch created config")
+			return "", true
+		}
+		w.Header().Set(idempotencyReplayedHeader, "true")
+		s.respondCreated(w, r, config)
+		return "", true
+	}
+	return scopedKey, false
+}
+
+// handleValidateConfig returns a handler that validates a config the way create does, without saving it
+func (s *Server) handleValidateConfig() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context())
+		var rawInput map[string]any
+		if err := decodeBody(r, &rawInput); err != nil {
+			log.Warnf("Invalid request payload in validate config: %v", err)
+			respondWithDecodeError(w, err)
+			return
+		}
+		if !s.rejectUnknownFields(w, r, rawInput) {
+			return
+		}
+
+		if _, fieldErrs := s.ValidateConfigInput(r.Context(), rawInput, r.URL.Query().Get("preset")); len(fieldErrs) > 0 {
+			log.Debugf("Config failed validation: %s", joinFieldErrors(fieldErrs))
+			respondWithFieldErrors(w, fieldErrs)
+			return
+		}
+
+		respond(w, r, http.StatusOK, validationResponse{Valid: true})
 	}
 }
 
-// handleGetConfig returns a handler to get a specific preservation config
-func (s *Server) handleGetConfig() http.HandlerFunc {
+// handleBulkCreateConfigs returns a handler that creates several preservation configs in one transaction
+func (s *Server) handleBulkCreateConfigs() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		idStr := chi.URLParam(r, "id")
-		if idStr == "" {
-			logger.Warn("Get config request missing ID parameter")
-			respondWithError(w, http.StatusBadRequest, "ID is required")
+		log := logger.FromContext(r.Context())
+		var rawInputs []map[string]any
+		if err := decodeBody(r, &rawInputs); err != nil {
+			log.Warnf("Invalid request payload in bulk create configs: %v", err)
+			respondWithDecodeError(w, err)
 			return
 		}
-
-		id, err := strconv.ParseInt(idStr, 10, 64)
-		if err != nil {
-			logger.Warn("Invalid ID format in get config request: %s", idStr)
-			respondWithError(w, http.StatusBadRequest, "Invalid ID format")
+		if len(rawInputs) == 0 {
+			respondWithError(w, http.StatusBadRequest, "At least one config is required")
 			return
 		}
 
-		logger.Info("Fetching preservation config with ID: %d", id)
-		config, err := s.db.GetConfig(id)
-		if err != nil {
-			if errors.Is(err, database.ErrNotFound) {
-				logger.Warn("Preservation config not found: %d", id)
-				respondWithError(w, http.StatusNotFound, "Preservation config not found")
+		// Validate every config before touching the database so the batch is all-or-nothing
+		configs := make([]*models.PreservationConfig, 0, len(rawInputs))
+		for i, rawInput := range rawInputs {
+			config, fieldErrs := s.ValidateConfigInput(r.Context(), rawInput, "")
+			if len(fieldErrs) > 0 {
+				log.Warnf("Bulk create config rejected at index %d: %s", i, joinFieldErrors(fieldErrs))
+				respondWithJSON(w, http.StatusBadRequest, map[string]any{
+					"error":  fmt.Sprintf("Invalid config at index %d: %s", i, joinFieldErrors(fieldErrs)),
+					"index":  i,
+					"errors": fieldErrs,
+				})
 				return
 			}
-			logger.Error("Failed to fetch config %d: %v", id, err)
-			respondWithError(w, http.StatusInternalServerError, "Failed to fetch config")
+			configs = append(configs, config)
+		}
+
+		log.Infof("Bulk creating %d preservation configs", len(configs))
+
+		if err := s.db.CreateConfigsContext(r.Context(), configs); err != nil {
+			if errors.Is(err, database.ErrQuotaExceeded) {
+				log.Warnf("Rejected bulk create of %d configs: %v", len(configs), err)
+				respondWithQuotaExceeded(w, err)
+				return
+			}
+			if errors.Is(err, database.ErrReadOnly) {
+				log.Errorf("Cannot bulk create configs: %v", err)
+				respondWithReadOnlyDatabase(w)
+				return
+			}
+			log.Errorf("Failed to bulk create configs: %v", err)
+			respondWithError(w, http.StatusInternalServerError, "Failed to create configs")
 			return
 		}
+		for _, config := range configs {
+			s.notifyConfigChange(r, EventConfigCreated, config.ID)
+		}
 
-		logger.Debug("Successfully fetched config: %s (ID: %d)", config.Name, config.ID)
-		respondWithJSON(w, http.StatusOK, config)
+		// Fetch the created configs from the database to ensure we return the actual saved data
+		createdConfigs := make([]*models.PreservationConfig, 0, len(configs))
+		for _, config := range configs {
+			createdConfig, err := s.db.GetConfigContext(r.Context(), config.ID)
+			if err != nil {
+				log.Errorf("Failed to fetch created config %d: %v", config.ID, err)
+				respondWithError(w, http.StatusInternalServerError, "Failed to fetch created configs")
+				return
+			}
+			createdConfigs = append(createdConfigs, createdConfig)
+		}
 
-		logger.Debug("Config: %+v", config)
+		log.Infof("Successfully bulk created %d preservation configs", len(createdConfigs))
+		respond(w, r, http.StatusCreated, createdConfigs)
 	}
 }
 
-// handleCreateConfig returns a handler to create a new preservation config
-func (s *Server) handleCreateConfig() http.HandlerFunc {
+// handleExportConfigs returns a handler that downloads all preservation configs as a portable
+// bundle. JSON is streamed as the configs are read, so memory use doesn't grow with their
+// number; YAML is built in full first.
+func (s *Server) handleExportConfigs() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Parse the raw JSON to detect which fields are provided
-		var rawInput map[string]any
-		if err := json.NewDecoder(r.Body).Decode(&rawInput); err != nil {
-			logger.Warn("Invalid request payload in create config: %v", err)
-			respondWithError(w, http.StatusBadRequest, "Invalid request payload")
-			return
-		}
-
-		logger.Debug("Raw input: %v", rawInput)
+		log := logger.FromContext(r.Context())
+		log.Infof("Exporting all preservation configs")
 
-		// Extract name (required)
-		name, nameExists := rawInput["name"]
-		if !nameExists {
-			logger.Warn("Create config request missing required name field")
-			respondWithError(w, http.StatusBadRequest, "Name is required")
+		if !wantsYAML(r) {
+			w.Header().Set("Content-Disposition", "attachment; filename=preservation-configs.json")
+			count, started, err := s.streamConfigBundle(w, r)
+			if err != nil && !started {
+				log.Errorf("Failed to fetch configs for export: %v", err)
+				w.Header().Del("Content-Disposition")
+				respondWithError(w, http.StatusInternalServerError, "Failed to fetch configs")
+				return
+			}
+			if err != nil {
+				// The status has been sent; the unterminated body tells the client the export failed
+				log.Errorf("Export failed after %d configs: %v", count, err)
+				return
+			}
+			log.Debugf("Exported %d configs", count)
 			return
 		}
-		nameStr, ok := name.(string)
-		if !ok || nameStr == "" {
-			logger.Warn("Create config request has invalid name field")
-			respondWithError(w, http.StatusBadRequest, "Name is required and must be a string")
+
+		configs, err := s.db.ListConfigsContext(r.Context())
+		if err != nil {
+			log.Errorf("Failed to fetch configs for export: %v", err)
+			respondWithError(w, http.StatusInternalServerError, "Failed to fetch configs")
 			return
 		}
 
-		// Extract description (optional)
-		description := ""
-		if desc, exists := rawInput["description"]; exists {
-			if descStr, ok := desc.(string); ok {
-				description = descStr
+		log.Debugf("Exporting %d configs", len(configs))
+		w.Header().Set("Content-Disposition", "attachment; filename=preservation-configs.yaml")
+		respond(w, r, http.StatusOK, models.NewConfigBundle(configs))
+	}
+}
+
+// streamConfigBundle writes every config as a JSON models.ConfigBundle, encoding each entry as
+// its row is read. Nothing is written until the first config has been read, or all of them if
+// there are none, so a query that fails outright leaves the response to the caller. It returns
+// the number of configs written and whether the response was started.
+func (s *Server) streamConfigBundle(w http.ResponseWriter, r *http.Request) (int, bool, error) {
+	exportedAt, err := json.Marshal(time.Now().UTC())
+	if err != nil {
+		return 0, false, err
+	}
+	started := false
+	start := func() error {
+		started = true
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, err := fmt.Fprintf(w, `{"schema_version":%d,"exported_at":%s,"configs":[`, models.ConfigBundleSchemaVersion, exportedAt)
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	count := 0
+	err = s.db.ForEachConfigContext(r.Context(), func(config *models.PreservationConfig) error {
+		separator := ","
+		if count == 0 {
+			if err := start(); err != nil {
+				return err
 			}
+			separator = ""
+		}
+		if _, err := io.WriteString(w, separator); err != nil {
+			return err
+		}
+		count++
+		return enc.Encode(models.NewConfigBundleEntry(config))
+	})
+	if err != nil {
+		return count, started, err
+	}
+
+	if !started {
+		if err := start(); err != nil {
+			return 0, true, err
 		}
+	}
+	_, err = io.WriteString(w, "]}\n")
+	return count, true, err
+}
 
-		logger.Info("Creating new preservation config: %s", nameStr)
+// importBundleRequest is the body accepted by the import endpoint, matching models.ConfigBundle.
+// Configs are kept raw so that entries may omit fields and fall back to defaults, as on create.
+type importBundleRequest struct {
+	SchemaVersion int              `json:"schema_version"`
+	Configs       []map[string]any `json:"configs"`
+}
 
-		// Start with default config
-		config := models.NewPreservationConfig(nameStr, description)
+// importItemResult reports the outcome for a single bundle entry
+type importItemResult struct {
+	Index int    `json:"index"`
+	Name  string `json:"name,omitempty"`
+	ID    int64  `json:"id,omitempty"`
+	Error string `json:"error,omitempty"`
+}
 
-		logger.Debug("Default Config: %+v", config)
+// importSummary is the import endpoint response, grouping entries by outcome
+type importSummary struct {
+	Created []importItemResult `json:"created"`
+	Updated []importItemResult `json:"updated"`
+	Skipped []importItemResult `json:"skipped"`
+	Errors  []importItemResult `json:"errors"`
+}
 
-		// Handle compress_aip field if provided
-		if compressAIP, exists := rawInput["compress_aip"]; exists {
-			if compressBool, ok := compressAIP.(bool); ok {
-				config.CompressAIP = compressBool
-			}
+// handleImportConfigs returns a handler that ingests an exported config bundle.
+// Configs are matched by name: new names are created, existing names are skipped,
+// or overwritten when ?mode=upsert is given. The import is applied atomically.
+func (s *Server) handleImportConfigs() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context())
+		mode := r.URL.Query().Get("mode")
+		if mode != "" && mode != "create" && mode != "upsert" {
+			respondWithError(w, http.StatusBadRequest, "Invalid mode, expected create or upsert")
+			return
 		}
+		upsert := mode == "upsert"
 
-		// If A3M config is provided, merge it with defaults
-		if a3mConfig, exists := rawInput["a3m_config"]; exists {
-			if a3mMap, ok := a3mConfig.(map[string]any); ok {
-				updateA3MConfigFromMap(&config.A3MConfig, a3mMap)
-			}
+		var bundle importBundleRequest
+		if err := decodeBody(r, &bundle); err != nil {
+			log.Warnf("Invalid request payload in import configs: %v", err)
+			respondWithDecodeError(w, err)
+			return
+		}
+		if bundle.SchemaVersion != models.ConfigBundleSchemaVersion {
+			log.Warnf("Rejected import with unsupported schema version %d", bundle.SchemaVersion)
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Unsupported bundle schema version %d", bundle.SchemaVersion))
+			return
 		}
 
-		logger.Debug("Updated Config: %+v", config)
+		summary := importSummary{
+			Created: []importItemResult{},
+			Updated: []importItemResult{},
+			Skipped: []importItemResult{},
+			Errors:  []importItemResult{},
+		}
 
-		if err := s.db.CreateConfig(config); err != nil {
-			logger.Error("Failed to create config '%s': %v", nameStr, err)
-			respondWithError(w, http.StatusInternalServerError, "Failed to create config")
+		// Validate every entry up front; any invalid entry rejects the whole bundle
+		configs := make([]*models.PreservationConfig, 0, len(bundle.Configs))
+		for i, rawInput := range bundle.Configs {
+			config, fieldErrs := s.ValidateConfigInput(r.Context(), rawInput, "")
+			if len(fieldErrs) > 0 {
+				name, _ := rawInput["name"].(string)
+				summary.Errors = append(summary.Errors, importItemResult{Index: i, Name: name, Error: joinFieldErrors(fieldErrs)})
+				continue
+			}
+			configs = append(configs, config)
+		}
+		if len(summary.Errors) > 0 {
+			log.Warnf("Rejected import with %d invalid configs", len(summary.Errors))
+			respondWithJSON(w, http.StatusBadRequest, summary)
 			return
 		}
 
-		// Fetch the created config from the database to ensure we return the actual saved data
-		createdConfig, err := s.db.GetConfig(config.ID)
+		log.Infof("Importing %d preservation configs (mode: %s)", len(configs), mode)
+
+		actions, err := s.db.ImportConfigsContext(r.Context(), configs, upsert)
+		if errors.Is(err, database.ErrQuotaExceeded) {
+			log.Warnf("Rejected import of %d configs: %v", len(configs), err)
+			respondWithQuotaExceeded(w, err)
+			return
+		}
+		if errors.Is(err, database.ErrReadOnly) {
+			log.Errorf("Cannot import configs: %v", err)
+			respondWithReadOnlyDatabase(w)
+			return
+		}
 		if err != nil {
-			logger.Error("Failed to fetch created config %d: %v", config.ID, err)
-			respondWithError(w, http.StatusInternalServerError, "Failed to fetch created config")
+			log.Errorf("Failed to import configs: %v", err)
+			respondWithError(w, http.StatusInternalServerError, "Failed to import configs")
 			return
 		}
 
-		logger.Debug("Created Config: %+v", createdConfig)
+		for i, config := range configs {
+			result := importItemResult{Index: i, Name: config.Name, ID: config.ID}
+			switch actions[i] {
+			case database.ImportCreated:
+				summary.Created = append(summary.Created, result)
+				s.notifyConfigChange(r, EventConfigCreated, config.ID)
+			case database.ImportUpdated:
+				summary.Updated = append(summary.Updated, result)
+				s.notifyConfigChange(r, EventConfigUpdated, config.ID)
+			case database.ImportSkipped:
+				summary.Skipped = append(summary.Skipped, result)
+			}
+		}
 
-		logger.Info("Successfully created preservation config: %s (ID: %d)", createdConfig.Name, createdConfig.ID)
-		respondWithJSON(w, http.StatusCreated, createdConfig)
+		log.Infof("Imported preservation configs: %d created, %d updated, %d skipped",
+			len(summary.Created), len(summary.Updated), len(summary.Skipped))
+		respond(w, r, http.StatusOK, summary)
 	}
 }
 
 // handleUpdateConfig returns a handler to update an existing preservation config
 func (s *Server) handleUpdateConfig() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		idStr := chi.URLParam(r, "id")
-		if idStr == "" {
-			logger.Warn("Update config request missing ID parameter")
-			respondWithError(w, http.StatusBadRequest, "ID is required")
+		log := logger.FromContext(r.Context())
+		id, ok := s.idParam(w, r)
+		if !ok {
 			return
 		}
 
-		id, err := strconv.ParseInt(idStr, 10, 64)
-		if err != nil {
-			logger.Warn("Invalid ID format in update config request: %s", idStr)
-			respondWithError(w, http.StatusBadRequest, "Invalid ID format")
+		// With ?return=changes only the fields the update changed are returned
+		returnMode := r.URL.Query().Get("return")
+		if returnMode != "" && returnMode != "changes" {
+			respondWithError(w, http.StatusBadRequest, "Invalid return, expected changes")
 			return
 		}
 
-		logger.Info("Updating preservation config with ID: %d", id)
+		log.Infof("Updating preservation config with ID: %d", id)
 
 		// Get the existing config to verify it exists
-		existingConfig, err := s.db.GetConfig(id)
+		existingConfig, err := s.db.GetConfigContext(r.Context(), id)
 		if err != nil {
 			if errors.Is(err, database.ErrNotFound) {
-				logger.Warn("Attempted to update non-existent config: %d", id)
+				log.Warnf("Attempted to update non-existent config: %d", id)
 				respondWithError(w, http.StatusNotFound, "Preservation config not found")
 				return
 			}
-			logger.Error("Failed to fetch existing config %d for update: %v", id, err)
+			log.Errorf("Failed to fetch existing config %d for update: %v", id, err)
 			respondWithError(w, http.StatusInternalServerError, "Failed to fetch config")
 			return
 		}
+		var originalConfig *models.PreservationConfig
+		if returnMode == "changes" {
+			originalConfig = existingConfig.Clone()
+		}
 
 		// Parse the raw JSON to detect which fields are provided
 		var rawUpdate map[string]any
-		if err := json.NewDecoder(r.Body).Decode(&rawUpdate); err != nil {
-			logger.Warn("Invalid request payload in update config %d: %v", id, err)
-			respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		if err := decodeBody(r, &rawUpdate); err != nil {
+			log.Warnf("Invalid request payload in update config %d: %v", id, err)
+			respondWithDecodeError(w, err)
+			return
+		}
+		if !s.rejectUnknownFields(w, r, rawUpdate) {
 			return
 		}
 
 		// Work with the existing config directly (avoid copying)
 		updatedConfig := existingConfig
 
-		// Update basic fields if provided
-		if name, exists := rawUpdate["name"]; exists {
-			if nameStr, ok := name.(string); ok {
-				updatedConfig.Name = nameStr
-			}
-		}
-		if description, exists := rawUpdate["description"]; exists {
-			if descStr, ok := description.(string); ok {
-				updatedConfig.Description = descStr
-			}
-		}
-		if compressAIP, exists := rawUpdate["compress_aip"]; exists {
-			if compressBool, ok := compressAIP.(bool); ok {
-				updatedConfig.CompressAIP = compressBool
-			}
-		}
-
-		// Handle A3M config updates if provided
-		if a3mConfig, exists := rawUpdate["a3m_config"]; exists {
-			if a3mMap, ok := a3mConfig.(map[string]any); ok {
-				updateA3MConfigFromMap(&updatedConfig.A3MConfig, a3mMap)
-			}
-		}
+		// Update the fields provided, collecting problems to report with the rest
+		fieldErrs := s.applyConfigInput(r.Context(), updatedConfig, rawUpdate)
 
 		// Ensure the ID in the URL matches the ID in the request body (if provided)
 		if idFromBody, exists := rawUpdate["id"]; exists {
 			if idFloat, ok := idFromBody.(float64); ok && int64(idFloat) != id {
-				logger.Warn("ID mismatch in update request: URL=%d, Body=%d", id, int64(idFloat))
+				log.Warnf("ID mismatch in update request: URL=%d, Body=%d", id, int64(idFloat))
 				respondWithError(w, http.StatusBadRequest, "ID in URL does not match ID in request body")
 				return
 			}
 		}
 
+		// Reject updates based on a stale version, given by If-Match or the body
+		expectedVersion, hasVersion, err := requestedVersion(r, rawUpdate)
+		if err != nil {
+			log.Warnf("Invalid version in update config %d: %v", id, err)
+			respondWithError(w, http.StatusBadRequest, "Invalid version: "+err.Error())
+			return
+		}
+		if hasVersion && expectedVersion != existingConfig.Version {
+			log.Warnf("Stale update for config %d: expected version %d, current %d", id, expectedVersion, existingConfig.Version)
+			respondWithError(w, http.StatusConflict, "Preservation config has been modified; fetch the latest version and retry")
+			return
+		}
+
 		// Set the ID (already correct, but ensure it's set)
 		updatedConfig.ID = id
 
-		if err := s.db.UpdateConfig(updatedConfig); err != nil {
-			logger.Error("Failed to update config %d: %v", id, err)
+		if fieldErrs = append(fieldErrs, s.validateConfig(updatedConfig)...); len(fieldErrs) > 0 {
+			log.Warnf("Update config %d is invalid: %s", id, joinFieldErrors(fieldErrs))
+			respondWithFieldErrors(w, fieldErrs)
+			return
+		}
+
+		if err := s.db.UpdateConfigContext(r.Context(), updatedConfig); err != nil {
+			if errors.Is(err, database.ErrVersionConflict) {
+				log.Warnf("Concurrent update of config %d rejected", id)
+				respondWithError(w, http.StatusConflict, "Preservation config has been modified; fetch the latest version and retry")
+				return
+			}
+			if errors.Is(err, database.ErrReadOnly) {
+				log.Errorf("Cannot update config %d: %v", id, err)
+				respondWithReadOnlyDatabase(w)
+				return
+			}
+			log.Errorf("Failed to update config %d: %v", id, err)
 			respondWithError(w, http.StatusInternalServerError, "Failed to update config")
 			return
 		}
+		s.notifyConfigChange(r, EventConfigUpdated, updatedConfig.ID)
+
+		log.Infof("Successfully updated preservation config: %s (ID: %d)", updatedConfig.Name, updatedConfig.ID)
+		w.Header().Set("ETag", configETag(updatedConfig))
+		if originalConfig != nil {
+			response := configChangesResponse{Changed: make(map[string]fieldDiff)}
+			for field, values := range models.DiffConfigs(originalConfig, updatedConfig) {
+				response.Changed[field] = fieldDiff{Old: values[0], New: values[1]}
+			}
+			respond(w, r, http.StatusOK, response)
+			return
+		}
+		if prefersMinimal(r) {
+			w.Header().Set(preferenceAppliedHeader, "return=minimal")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		respond(w, r, http.StatusOK, updatedConfig)
+	}
+}
+
+// configChangesResponse lists the fields an update changed, returned instead of the
+// updated config when asked for with ?return=changes
+type configChangesResponse struct {
+	Changed map[string]fieldDiff `json:"changed"`
+}
+
+// fieldDiff is the before and after value of a single differing field
+type fieldDiff struct {
+	Old any `json:"old"`
+	New any `json:"new"`
+}
+
+// configDiffResponse lists the fields that differ between two configs
+type configDiffResponse struct {
+	FromID      int64                `json:"from_id"`
+	ToID        int64                `json:"to_id"`
+	Differences map[string]fieldDiff `json:"differences"`
+}
+
+// handleDiffConfigs returns a handler comparing two preservation configs field by field
+func (s *Server) handleDiffConfigs() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context())
+		id, ok := s.idParam(w, r)
+		if !ok {
+			return
+		}
+		otherID, err := parseID(chi.URLParam(r, "otherId"))
+		if err != nil {
+			log.Warnf("Invalid other ID in %s %s: %v", r.Method, r.URL.Path, err)
+			respondWithError(w, http.StatusBadRequest, invalidIDMessage)
+			return
+		}
+
+		log.Infof("Comparing preservation configs %d and %d", id, otherID)
+
+		configs := make([]*models.PreservationConfig, 0, 2)
+		for _, configID := range []int64{id, otherID} {
+			config, err := s.db.GetConfigContext(r.Context(), configID)
+			if err != nil {
+				if errors.Is(err, database.ErrNotFound) {
+					log.Warnf("Preservation config not found: %d", configID)
+					respondWithError(w, http.StatusNotFound, "Preservation config not found")
+					return
+				}
+				log.Errorf("Failed to fetch config %d: %v", configID, err)
+				respondWithError(w, http.StatusInternalServerError, "Failed to fetch config")
+				return
+			}
+			configs = append(configs, config)
+		}
 
-		logger.Info("Successfully updated preservation config: %s (ID: %d)", updatedConfig.Name, updatedConfig.ID)
-		respondWithJSON(w, http.StatusOK, updatedConfig)
+		response := configDiffResponse{
+			FromID:      id,
+			ToID:        otherID,
+			Differences: make(map[string]fieldDiff),
+		}
+		for field, values := range models.DiffConfigs(configs[0], configs[1]) {
+			response.Differences[field] = fieldDiff{Old: values[0], New: values[1]}
+		}
+
+		log.Debugf("Configs %d and %d differ in %d fields", id, otherID, len(response.Differences))
+		respond(w, r, http.StatusOK, response)
 	}
 }
 
 // handleDeleteConfig returns a handler to delete a preservation config
 func (s *Server) handleDeleteConfig() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		idStr := chi.URLParam(r, "id")
-		if idStr == "" {
-			logger.Warn("Delete config request missing ID parameter")
-			respondWithError(w, http.StatusBadRequest, "ID is required")
+		log := logger.FromContext(r.Context())
+		id, ok := s.idParam(w, r)
+		if !ok {
+			return
+		}
+
+		log.Infof("Deleting preservation config with ID: %d", id)
+
+		// With If-Match, only delete the config if it is still at the version the client saw
+		deleteConfig := s.db.DeleteConfigContext
+		if ifMatch := strings.TrimSpace(r.Header.Get("If-Match")); ifMatch != "" && ifMatch != "*" {
+			version, _, err := requestedVersion(r, nil)
+			if err != nil {
+				log.Warnf("Invalid version in delete config %d: %v", id, err)
+				respondWithError(w, http.StatusBadRequest, "Invalid version: "+err.Error())
+				return
+			}
+			deleteConfig = func(ctx context.Context, id int64) error {
+				return s.db.DeleteConfigIfVersionContext(ctx, id, version)
+			}
+		}
+
+		if err := deleteConfig(r.Context(), id); err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				log.Warnf("Attempted to delete non-existent config: %d", id)
+				respondWithError(w, http.StatusNotFound, "Preservation config not found")
+				return
+			}
+			if errors.Is(err, database.ErrVersionConflict) {
+				log.Warnf("Delete of config %d rejected: it has been modified since the given version", id)
+				respondWithError(w, http.StatusPreconditionFailed, "Preservation config has been modified; fetch the latest version and retry")
+				return
+			}
+			log.Errorf("Failed to delete config %d: %v", id, err)
+			respondWithError(w, http.StatusInternalServerError, "Failed to delete config")
+			return
+		}
+		s.notifyConfigChange(r, EventConfigDeleted, id)
+
+		log.Infof("Successfully deleted preservation config with ID: %d", id)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// maxBulkDeleteIDs bounds the configs a single bulk delete may name
+const maxBulkDeleteIDs = 100
+
+// bulkDeleteRequest is the body of a request to delete several configs
+type bulkDeleteRequest struct {
+	IDs []int64 `json:"ids"`
+}
+
+// bulkDeleteResponse reports which of the configs named in a bulk delete were deleted
+type bulkDeleteResponse struct {
+	Deleted  []int64 `json:"deleted"`
+	NotFound []int64 `json:"not_found"`
+}
+
+// handleBulkDeleteConfigs returns a handler that deletes several preservation configs in one
+// transaction. IDs of configs that don't exist are reported rather than failing the request.
+func (s *Server) handleBulkDeleteConfigs() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context())
+		var req bulkDeleteRequest
+		if err := decodeBody(r, &req); err != nil {
+			log.Warnf("Invalid request payload in bulk delete configs: %v", err)
+			respondWithDecodeError(w, err)
 			return
 		}
+		if len(req.IDs) == 0 {
+			respondWithError(w, http.StatusBadRequest, "At least one ID is required")
+			return
+		}
+		if len(req.IDs) > maxBulkDeleteIDs {
+			log.Warnf("Bulk delete request names %d configs", len(req.IDs))
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("At most %d IDs may be deleted at once", maxBulkDeleteIDs))
+			return
+		}
+
+		// Repeated IDs are deleted once
+		ids := make([]int64, 0, len(req.IDs))
+		seen := make(map[int64]bool, len(req.IDs))
+		for _, id := range req.IDs {
+			if id <= 0 {
+				log.Warnf("Invalid ID in bulk delete request: %d", id)
+				respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid ID: %d", id))
+				return
+			}
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+
+		log.Infof("Bulk deleting %d preservation configs", len(ids))
 
-		id, err := strconv.ParseInt(idStr, 10, 64)
+		deleted, notFound, err := s.db.DeleteConfigsContext(r.Context(), ids)
 		if err != nil {
-			logger.Warn("Invalid ID format in delete config request: %s", idStr)
-			respondWithError(w, http.StatusBadRequest, "Invalid ID format")
+			log.Errorf("Failed to bulk delete configs: %v", err)
+			respondWithError(w, http.StatusInternalServerError, "Failed to delete configs")
+			return
+		}
+		for _, id := range deleted {
+			s.notifyConfigChange(r, EventConfigDeleted, id)
+		}
+
+		log.Infof("Bulk deleted %d preservation configs; %d not found", len(deleted), len(notFound))
+		respond(w, r, http.StatusOK, bulkDeleteResponse{Deleted: deleted, NotFound: notFound})
+	}
+}
+
+// setActiveRequest is the body of a request to activate or deactivate a config
+type setActiveRequest struct {
+	Active *bool `json:"active"`
+}
+
+// handleSetConfigActive returns a handler that activates or deactivates a preservation config.
+// Deactivated configs are kept and stay readable, but can be left out of lists with ?active=true.
+func (s *Server) handleSetConfigActive() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context())
+		id, ok := s.idParam(w, r)
+		if !ok {
+			return
+		}
+
+		var req setActiveRequest
+		if err := decodeBody(r, &req); err != nil {
+			log.Warnf("Invalid request payload in set active for config %d: %v", id, err)
+			respondWithDecodeError(w, err)
+			return
+		}
+		if req.Active == nil {
+			respondWithError(w, http.StatusBadRequest, "active is required")
 			return
 		}
 
-		logger.Info("Deleting preservation config with ID: %d", id)
+		log.Infof("Setting preservation config %d active: %v", id, *req.Active)
 
-		if err := s.db.DeleteConfig(id); err != nil {
+		if err := s.db.SetConfigActiveContext(r.Context(), id, *req.Active); err != nil {
 			if errors.Is(err, database.ErrNotFound) {
-				logger.Warn("Attempted to delete non-existent config: %d", id)
+				log.Warnf("Attempted to set active on non-existent config: %d", id)
 				respondWithError(w, http.StatusNotFound, "Preservation config not found")
 				return
 			}
-			logger.Error("Failed to delete config %d: %v", id, err)
-			respondWithError(w, http.StatusInternalServerError, "Failed to delete config")
+			log.Errorf("Failed to set active on config %d: %v", id, err)
+			respondWithError(w, http.StatusInternalServerError, "Failed to update config")
 			return
 		}
+		s.notifyConfigChange(r, EventConfigUpdated, id)
 
-		logger.Info("Successfully deleted preservation config with ID: %d", id)
-		w.WriteHeader(http.StatusNoContent)
+		config, err := s.db.GetConfigContext(r.Context(), id)
+		if err != nil {
+			log.Errorf("Failed to fetch config %d after setting active: %v", id, err)
+			respondWithError(w, http.StatusInternalServerError, "Failed to fetch config")
+			return
+		}
+
+		w.Header().Set("ETag", configETag(config))
+		respond(w, r, http.StatusOK, config)
 	}
 }
 
-func updateA3MConfigFromMap(target *models.A3MProcessingConfig, source map[string]any) {
-	config := &mapstructure.DecoderConfig{
-		Result:           target,
-		WeaklyTypedInput: true, // Handles float64 -> int32 conversion
-		TagName:          "json",
+// invalidIDMessage is the error sent for an ID path parameter that parseID rejects
+const invalidIDMessage = "Invalid ID: must be a positive integer"
+
+// errInvalidID is returned for an ID that isn't a positive integer within the range of int64
+var errInvalidID = errors.New("ID must be a positive integer")
+
+// parseID parses a config ID. Empty, non-numeric, zero, negative and out-of-range values
+// are all rejected alike; an ID in range that no config has is left for the database to
+// report as not found.
+func parseID(value string) (int64, error) {
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("%w, got %q", errInvalidID, value)
 	}
+	return id, nil
+}
 
-	decoder, err := mapstructure.NewDecoder(config)
+// parseIDParam parses the request's {id} path parameter with parseID
+func parseIDParam(r *http.Request) (int64, error) {
+	return parseID(chi.URLParam(r, "id"))
+}
+
+// idParam returns the request's {id} path parameter. If it isn't a valid ID it responds
+// with 400 and returns false, so that every handler rejects IDs alike.
+func (s *Server) idParam(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := parseIDParam(r)
 	if err != nil {
-		logger.Error("Failed to create decoder: %v", err)
-		return
+		logger.FromContext(r.Context()).Warnf("Invalid ID in %s %s: %v", r.Method, r.URL.Path, err)
+		respondWithError(w, http.StatusBadRequest, invalidIDMessage)
+		return 0, false
+	}
+	return id, true
+}
+
+// queryInt parses the named query parameter as a non-negative integer, returning 0 if it is absent
+func queryInt(query url.Values, name string) (int, error) {
+	value := query.Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s %q is not a non-negative integer", name, value)
+	}
+	return n, nil
+}
+
+// queryBool parses the named query parameter as a boolean, returning false if it is absent
+func queryBool(query url.Values, name string) (bool, error) {
+	value := query.Get(name)
+	if value == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s must be true or false, got %q", name, value)
+	}
+	return b, nil
+}
+
+// queryTime parses the named query parameter as an RFC 3339 timestamp or a YYYY-MM-DD date,
+// the latter taken as midnight UTC, returning the zero time if it is absent
+func queryTime(query url.Values, name string) (time.Time, error) {
+	value := query.Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be an RFC 3339 timestamp or a YYYY-MM-DD date, got %q", name, value)
+	}
+	return t, nil
+}
+
+// queryFilters collects the boolean field filters accepted by the list endpoint from the query,
+// e.g. normalize=true&examine_contents=false
+func queryFilters(query url.Values) (map[string]bool, error) {
+	var filters map[string]bool
+	for _, field := range database.FilterFields() {
+		value := query.Get(field)
+		if value == "" {
+			continue
+		}
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be true or false, got %q", field, value)
+		}
+		if filters == nil {
+			filters = make(map[string]bool)
+		}
+		filters[field] = b
+	}
+	return filters, nil
+}
+
+// configETag returns the entity tag for a config. It is weak because the same version
+// may be served as JSON or YAML, and its value can be sent back in If-Match.
+func configETag(config *models.PreservationConfig) string {
+	return fmt.Sprintf(`W/"%d"`, config.Version)
+}
+
+// etagMatches reports whether an If-None-Match header matches etag using weak comparison
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// requestedVersion returns the config version the client based its update on, taken from
+// the If-Match header ("3", "\"3\"" or "W/\"3\"") or else the body's version field.
+// If-Match: * matches any current version, so the update is made without a version check,
+// as for a delete.
+func requestedVersion(r *http.Request, rawUpdate map[string]any) (int64, bool, error) {
+	if ifMatch := strings.TrimSpace(r.Header.Get("If-Match")); ifMatch != "" {
+		if ifMatch == "*" {
+			return 0, false, nil
+		}
+		tag := strings.Trim(strings.TrimPrefix(ifMatch, "W/"), `"`)
+		version, err := strconv.ParseInt(tag, 10, 64)
+		if err != nil {
+			return 0, false, fmt.Errorf("if-match header %q is not a config version", ifMatch)
+		}
+		return version, true, nil
+	}
+
+	if v, exists := rawUpdate["version"]; exists {
+		versionFloat, ok := v.(float64)
+		if !ok || versionFloat != float64(int64(versionFloat)) {
+			return 0, false, errors.New("version must be an integer")
+		}
+		return int64(versionFloat), true, nil
+	}
+
+	return 0, false, nil
+}
+
+// respondWithQuotaExceeded writes a 409 response for a create rejected by the config limit,
+// with err giving the limit
+func respondWithQuotaExceeded(w http.ResponseWriter, err error) {
+	respondWithError(w, http.StatusConflict, "Cannot create config: "+err.Error())
+}
+
+// respondWithReadOnlyDatabase reports a write refused because the database only allows reading.
+// It is a 503 rather than a 500 since the fault is in the deployment, not the request.
+func respondWithReadOnlyDatabase(w http.ResponseWriter) {
+	respondWithError(w, http.StatusServiceUnavailable, "The database is read-only; check that the database and, for SQLite, its file and directory are writable")
+}
+
+// a3mBoolHook is a mapstructure decode hook converting the values given for boolean A3M
+// fields with models.A3MBool
+func a3mBoolHook(_ reflect.Type, to reflect.Type, data any) (any, error) {
+	if to.Kind() != reflect.Bool {
+		return data, nil
+	}
+	b, ok := models.A3MBool(data)
+	if !ok {
+		return nil, fmt.Errorf("cannot use %v as a boolean", data)
+	}
+	return b, nil
+}
+
+// updateA3MConfigFromMap sets the fields of target given in source, returning the keys of
+// source that match no A3M field and an error for each value of the wrong type for its field
+func updateA3MConfigFromMap(ctx context.Context, target *models.A3MProcessingConfig, source map[string]any) ([]string, []models.FieldError) {
+	log := logger.FromContext(ctx)
+
+	// Each field is decoded on its own so that a value of the wrong type can be traced to its key
+	keys := make([]string, 0, len(source))
+	for key := range source {
+		keys = append(keys, key)
 	}
+	sort.Strings(keys)
+
+	var unused []string
+	var errs []models.FieldError
+	for _, key := range keys {
+		var metadata mapstructure.Metadata
+		config := &mapstructure.DecoderConfig{
+			Result:           target,
+			Metadata:         &metadata,
+			WeaklyTypedInput: true, // Handles float64 -> int32 conversion
+			// Booleans take only the forms models.A3MBool accepts, not every number or
+			// string that strconv.ParseBool does, so that e.g. 2 or "t" is rejected
+			DecodeHook: a3mBoolHook,
+			TagName:    "json",
+			// Accept the lowerCamelCase keys we emit in responses as well as snake_case
+			MatchName: models.A3MFieldNameMatches,
+		}
 
-	if err := decoder.Decode(source); err != nil {
-		logger.Error("Failed to decode config: %v", err)
+		decoder, err := mapstructure.NewDecoder(config)
+		if err != nil {
+			log.Errorf("Failed to create decoder: %v", err)
+			return nil, nil
+		}
+
+		if err := decoder.Decode(map[string]any{key: source[key]}); err != nil {
+			log.Debugf("Failed to decode a3m_config field %s: %v", key, err)
+			errs = append(errs, models.A3MValueError(key, source[key]))
+		}
+		unused = append(unused, metadata.Unused...)
 	}
+	return unused, errs
 }
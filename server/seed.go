@@ -0,0 +1,51 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/penwern/curate-preservation-api/models"
+	"gopkg.in/yaml.v3"
+)
+
+// loadDefaultConfig reads the preservation config in the JSON or YAML file at path, to be
+// seeded as the default config of a new database. The file takes the same fields as a create
+// request and is validated the same way, so fields it leaves out keep the built-in defaults.
+func (s *Server) loadDefaultConfig(ctx context.Context, path string) (*models.PreservationConfig, error) {
+	rawInput, err := readConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config, fieldErrs := s.ValidateConfigInput(ctx, rawInput, "")
+	if len(fieldErrs) > 0 {
+		return nil, fmt.Errorf("invalid default config %s: %s", path, joinFieldErrors(fieldErrs))
+	}
+	return config, nil
+}
+
+// readConfigFile decodes a JSON or YAML config file into the form decodeBody gives a request
+// body. JSON is valid YAML, so both are parsed as YAML and converted through JSON.
+func readConfigFile(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- path is operator configuration
+	if err != nil {
+		return nil, fmt.Errorf("failed to read default config file: %w", err)
+	}
+
+	var doc any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid default config file %s: %w", path, err)
+	}
+	jsonData, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid default config file %s: %w", path, err)
+	}
+
+	var rawInput map[string]any
+	if err := json.Unmarshal(jsonData, &rawInput); err != nil || rawInput == nil {
+		return nil, fmt.Errorf("invalid default config file %s: must contain a config object", path)
+	}
+	return rawInput, nil
+}
@@ -4,8 +4,11 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -13,54 +16,169 @@ import (
 	"github.com/go-chi/cors"
 	"github.com/go-chi/render"
 	"github.com/penwern/curate-preservation-api/database"
+	"github.com/penwern/curate-preservation-api/models"
 	"github.com/penwern/curate-preservation-api/pkg/config"
 	"github.com/penwern/curate-preservation-api/pkg/logger"
 )
 
 // Server represents the API server
 type Server struct {
-	router *chi.Mux
-	db     *database.Database
-	srv    *http.Server
-	config config.Config
+	router        *chi.Mux
+	db            *database.Database
+	srv           *http.Server
+	config        config.Config
+	userInfoCache *UserInfoCache
+	// authClient makes the OIDC and Pydio requests of token validation, reusing connections
+	authClient *AuthClient
+	// authFailureLimiter throttles clients that repeatedly fail authentication
+	authFailureLimiter *AuthFailureLimiter
+	// apiKeys are the static keys accepted via the X-API-Key header
+	apiKeys *APIKeySet
+	// siteDomains are the Cells site domains requests may authenticate against
+	siteDomains *SiteDomains
+	// readOnly rejects config writes during maintenance; it can be toggled at runtime
+	readOnly atomic.Bool
+	// idempotency remembers the Idempotency-Key of recent creates so retries aren't duplicated
+	idempotency *IdempotencyStore
+	// maxNameLength and maxDescriptionLength bound config fields, in characters
+	maxNameLength        int
+	maxDescriptionLength int
+	// basePath prefixes every route, e.g. "/preservation"; empty serves from the root
+	basePath string
+	// webhook posts config change events; nil when no webhook is configured
+	webhook *WebhookNotifier
+	// routeIndex holds every route in one flat router, to list the methods a path allows
+	routeIndex *chi.Mux
+	// migrated is closed once startup migrations have finished; migrationErr, set before
+	// then, records whether they failed
+	migrated     chan struct{}
+	migrationErr error
+	// stopMigrations stops startup migrations after the one running, for Shutdown
+	stopMigrations context.CancelFunc
+	// requestTimeout bounds most requests; bulkRequestTimeout the bulk create, export and import
+	requestTimeout     time.Duration
+	bulkRequestTimeout time.Duration
+	// shutdownTimeout is how long Shutdown waits for in-flight requests
+	shutdownTimeout time.Duration
+	// openConns counts the client connections not yet closed
+	openConns atomic.Int64
+	// startedAt is when the server was created, for the uptime in diagnostics
+	startedAt time.Time
 }
 
+// defaultAuthCacheTTL is used when no auth cache TTL is configured
+const defaultAuthCacheTTL = 5 * time.Minute
+
+// defaultShutdownTimeout is used when no shutdown timeout is configured
+const defaultShutdownTimeout = 15 * time.Second
+
+// ErrShutdownTimeout is returned by Shutdown when in-flight requests didn't finish in time
+// and their connections were closed forcibly
+var ErrShutdownTimeout = errors.New("shutdown timed out")
+
 // New creates a new server
 func New(cfg config.Config) (*Server, error) {
-	db, err := database.New(cfg.DBType, cfg.DBConnection)
+	apiKeys, err := NewAPIKeySet(cfg.APIKeys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse API keys: %w", err)
+	}
+
+	if err := config.ValidateSiteDomains(cfg.SiteDomains); err != nil {
+		return nil, fmt.Errorf("invalid site domains: %w", err)
+	}
+
+	tlsConfig, err := loadTLSConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure TLS: %w", err)
+	}
+
+	trustedProxies, err := parseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse trusted proxies: %w", err)
+	}
+
+	basePath, err := config.NormalizeBasePath(cfg.BasePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := config.ValidateCORSOrigins(cfg.CORSOrigins); err != nil {
+		return nil, fmt.Errorf("invalid CORS origins: %w", err)
+	}
+	if err := config.ValidateCORSMethods(cfg.CORSMethods); err != nil {
+		return nil, fmt.Errorf("invalid CORS methods: %w", err)
+	}
+	if err := errors.Join(config.ValidateCORSHeaders(cfg.CORSHeaders), config.ValidateCORSHeaders(cfg.CORSExposedHeaders)); err != nil {
+		return nil, fmt.Errorf("invalid CORS headers: %w", err)
+	}
+	if cfg.CORSAllowAll {
+		logger.Warn("CORS allows every origin, without credentials; use this only in development")
+	} else if config.HasWildcardOrigin(cfg.CORSOrigins) {
+		logger.Warn("CORS origin \"*\" is combined with credentials, which browsers reject; list the allowed origins explicitly")
+	}
+
+	if err := config.ValidateWebhookURL(cfg.WebhookURL); err != nil {
+		return nil, err
+	}
+
+	// Migrations are run once the server is built, possibly in the background
+	db, err := database.Open(cfg.DBType, cfg.DBConnection)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize database: %w", err)
 	}
+	db.SetMaxConfigs(cfg.MaxConfigs)
 
 	router := chi.NewRouter()
 
 	// CORS middleware - configure to allow requests from Pydio Cells
-	corsOrigins := cfg.CORSOrigins
-	if len(corsOrigins) == 0 {
-		// Default origins if none specified in config
-		corsOrigins = []string{
-			"https://localhost:8080",
-			"http://localhost:8080",
-		}
-	}
-
-	router.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   corsOrigins,
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
-		AllowCredentials: true,
-		MaxAge:           300, // Maximum value not ignored by any of major browsers
-	}))
+	router.Use(cors.Handler(corsOptions(cfg)))
 
-	// Middleware
-	router.Use(middleware.Logger)
-	router.Use(middleware.Recoverer)
+	// Middleware; RequestID goes first so the access log and handler logs carry the request ID,
+	// and RealIP precedes the access log so it records the forwarded client IP. The access log
+	// wraps Recoverer so requests that panic are logged with their 500.
 	router.Use(middleware.RequestID)
-	router.Use(middleware.RealIP)
-	router.Use(middleware.Timeout(5 * time.Second))
+	router.Use(requestLogger)
+	router.Use(RealIP(trustedProxies))
+	router.Use(AccessLogger)
+	router.Use(middleware.Recoverer)
+	router.Use(BodyLogger)
 	router.Use(render.SetContentType(render.ContentTypeJSON))
 
+	authCacheTTL := cfg.AuthCacheTTL
+	if authCacheTTL <= 0 {
+		authCacheTTL = defaultAuthCacheTTL
+	}
+
+	authFailureLimit := cfg.AuthFailureLimit
+	if authFailureLimit <= 0 {
+		authFailureLimit = defaultAuthFailureLimit
+	}
+	authFailureWindow := cfg.AuthFailureWindow
+	if authFailureWindow <= 0 {
+		authFailureWindow = defaultAuthFailureWindow
+	}
+
+	maxNameLength := cfg.MaxNameLength
+	if maxNameLength <= 0 {
+		maxNameLength = models.DefaultMaxNameLength
+	}
+	maxDescriptionLength := cfg.MaxDescriptionLength
+	if maxDescriptionLength <= 0 {
+		maxDescriptionLength = models.DefaultMaxDescriptionLength
+	}
+	requestTimeout := cfg.RequestTimeout
+	if requestTimeout <= 0 {
+		requestTimeout = defaultRequestTimeout
+	}
+	bulkRequestTimeout := cfg.BulkRequestTimeout
+	if bulkRequestTimeout <= 0 {
+		bulkRequestTimeout = defaultBulkRequestTimeout
+	}
+	shutdownTimeout := cfg.ShutdownTimeout
+	if shutdownTimeout <= 0 {
+		shutdownTimeout = defaultShutdownTimeout
+	}
+
 	server := &Server{
 		router: router,
 		db:     db,
@@ -68,34 +186,153 @@ func New(cfg config.Config) (*Server, error) {
 			Addr:              fmt.Sprintf(":%d", cfg.Port),
 			Handler:           router,
 			ReadHeaderTimeout: 15 * time.Second,
+			TLSConfig:         tlsConfig,
 		},
-		config: cfg,
+		config:               cfg,
+		userInfoCache:        NewUserInfoCache(authCacheTTL),
+		authClient:           NewAuthClient(cfg.AllowInsecureTLS, cfg.AuthTimeout, cfg.AuthMaxIdleConns, cfg.AuthRetryAttempts),
+		authFailureLimiter:   NewAuthFailureLimiter(authFailureLimit, authFailureWindow),
+		apiKeys:              apiKeys,
+		siteDomains:          NewSiteDomains(cfg.SiteDomain, cfg.SiteDomains),
+		idempotency:          NewIdempotencyStore(defaultIdempotencyTTL),
+		maxNameLength:        maxNameLength,
+		maxDescriptionLength: maxDescriptionLength,
+		basePath:             basePath,
+		webhook:              NewWebhookNotifier(cfg.WebhookURL, cfg.WebhookSecret),
+		migrated:             make(chan struct{}),
+		requestTimeout:       requestTimeout,
+		bulkRequestTimeout:   bulkRequestTimeout,
+		shutdownTimeout:      shutdownTimeout,
+		startedAt:            time.Now(),
+	}
+	server.srv.ConnState = server.trackConn
+
+	server.readOnly.Store(cfg.ReadOnly)
+	if cfg.ReadOnly {
+		logger.Warn("Starting in read-only maintenance mode; config writes are rejected")
 	}
 
 	// Register routes
 	server.routes()
 
+	var defaultConfig *models.PreservationConfig
+	if cfg.DefaultConfigFile != "" {
+		defaultConfig, err = server.loadDefaultConfig(context.Background(), cfg.DefaultConfigFile)
+		if err != nil {
+			server.close()
+			return nil, err
+		}
+	}
+
+	migrationCtx, stopMigrations := context.WithCancel(context.Background())
+	server.stopMigrations = stopMigrations
+	if cfg.BackgroundMigrations {
+		// Requests are answered with 503 until the migrations finish
+		go server.runMigrations(migrationCtx, defaultConfig)
+	} else {
+		server.runMigrations(migrationCtx, defaultConfig)
+		if server.migrationErr != nil {
+			server.close()
+			return nil, fmt.Errorf("failed to initialize database: %w", server.migrationErr)
+		}
+	}
+
 	return server, nil
 }
 
-// Start starts the HTTP server
+// Start starts the HTTP server, serving HTTPS when a TLS certificate and key are configured
 func (s *Server) Start() error {
+	if s.srv.TLSConfig != nil {
+		logger.Info("Serving HTTPS with certificate %s", s.config.TLSCertFile)
+		// The certificate is already loaded into TLSConfig
+		return s.srv.ListenAndServeTLS("", "")
+	}
 	return s.srv.ListenAndServe()
 }
 
-// Shutdown gracefully shuts down the server
+// Shutdown gracefully shuts down the server. In-flight requests are drained before
+// the database is closed so that they don't fail on a closed connection. Connections
+// still open after the shutdown timeout are closed forcibly, and the error returned
+// then wraps ErrShutdownTimeout.
 func (s *Server) Shutdown() error {
+	s.userInfoCache.Stop()
+	s.authFailureLimiter.Stop()
+	s.idempotency.Stop()
+
+	// Create a deadline to wait for current connections to complete
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+
+	// Stop accepting connections and wait for active handlers to return
+	var shutdownErr error
+	if err := s.srv.Shutdown(ctx); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			dropped := s.openConns.Load()
+			logger.Warn("Requests still running after %s; forcibly closing %d connections", s.shutdownTimeout, dropped)
+			if err := s.srv.Close(); err != nil {
+				logger.Error("Failed to close connections: %v", err)
+			}
+			shutdownErr = fmt.Errorf("%w after %s: %d connections forcibly closed", ErrShutdownTimeout, s.shutdownTimeout, dropped)
+		} else {
+			shutdownErr = fmt.Errorf("failed to shut down HTTP server: %w", err)
+		}
+	}
+
+	// Requests have finished, so no more events will be queued
+	var webhookErr error
+	if err := s.webhook.Close(ctx); err != nil {
+		webhookErr = fmt.Errorf("failed to deliver webhook events: %w", err)
+	}
+
+	// Let background migrations finish rather than cut them off part way. Past the timeout,
+	// no further migrations are started, but the one running is still waited for so that
+	// the database isn't closed under it and the schema left dirty.
+	select {
+	case <-s.migrated:
+	case <-ctx.Done():
+		logger.Warn("Shutting down before database migrations finished; stopping after the current migration")
+		s.stopMigrations()
+		<-s.migrated
+	}
+
+	s.authClient.CloseIdleConnections()
+
 	// Close the database connection
+	var closeErr error
 	if err := s.db.Close(); err != nil {
-		logger.Error("Error closing database: %v", err)
+		closeErr = fmt.Errorf("failed to close database: %w", err)
 	}
 
-	// Create a deadline to wait for current connections to complete
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-	defer cancel()
+	return errors.Join(shutdownErr, webhookErr, closeErr)
+}
+
+// trackConn keeps count of the open client connections, for reporting those dropped by Shutdown
+func (s *Server) trackConn(_ net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		s.openConns.Add(1)
+	case http.StateClosed, http.StateHijacked:
+		s.openConns.Add(-1)
+	}
+}
+
+// close releases the resources of a server that failed to start
+func (s *Server) close() {
+	s.userInfoCache.Stop()
+	s.authFailureLimiter.Stop()
+	s.idempotency.Stop()
+	if err := s.db.Close(); err != nil {
+		logger.Error("Failed to close database: %v", err)
+	}
+}
 
-	// Shutdown the server
-	return s.srv.Shutdown(ctx)
+// requestLogger stores a logger tagged with the request ID in the request context,
+// so that logger.FromContext returns it to handlers and middleware further down
+func requestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := logger.NewContext(r.Context(), logger.FromContext(r.Context()))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
 }
 
 // respondWithJSON writes a JSON response
@@ -2,22 +2,32 @@ package server
 
 import (
 	"bytes"
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/penwern/curate-preservation-api/database"
 	"github.com/penwern/curate-preservation-api/models"
+	"github.com/penwern/curate-preservation-api/pkg/config"
 )
 
 func TestServer_HandleCreateConfig_LargePayload(t *testing.T) {
 	server := setupTestServer(t)
 	defer server.Shutdown()
 
-	// Create a very large description to test payload limits
-	largeDescription := strings.Repeat("A", 10000)
+	// A description at the default length limit is accepted
+	largeDescription := strings.Repeat("A", models.DefaultMaxDescriptionLength)
 
 	createReq := map[string]string{
 		"name":        "Large Payload Test",
@@ -49,6 +59,112 @@ func TestServer_HandleCreateConfig_LargePayload(t *testing.T) {
 	}
 }
 
+func TestServer_HandleCreateConfig_LengthLimits(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	tests := []struct {
+		name           string
+		input          map[string]string
+		expectedStatus int
+		expectedField  string
+	}{
+		{
+			name:           "Name over limit",
+			input:          map[string]string{"name": strings.Repeat("n", models.DefaultMaxNameLength+1)},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedField:  "name",
+		},
+		{
+			name:           "Description over limit",
+			input:          map[string]string{"name": "Too Long", "description": strings.Repeat("d", models.DefaultMaxDescriptionLength+1)},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedField:  "description",
+		},
+		{
+			// Each character is several bytes, so a byte count would reject this
+			name:           "Multi-byte name at limit",
+			input:          map[string]string{"name": strings.Repeat("🚀", models.DefaultMaxNameLength)},
+			expectedStatus: http.StatusCreated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reqBody, err := json.Marshal(tt.input)
+			if err != nil {
+				t.Fatalf("Failed to marshal request: %v", err)
+			}
+
+			req := setupTestRequest("POST", "/api/v1/preservation-configs", bytes.NewBuffer(reqBody))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			server.router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if tt.expectedField != "" && !strings.Contains(rr.Body.String(), tt.expectedField+" must be at most") {
+				t.Errorf("Expected error naming %s, got %s", tt.expectedField, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestServer_HandleUpdateConfig_LengthLimits(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	config := models.NewPreservationConfig("Update Length Test", "")
+	if err := server.db.CreateConfig(config); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+
+	reqBody, err := json.Marshal(map[string]string{"description": strings.Repeat("d", models.DefaultMaxDescriptionLength+1)})
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %v", err)
+	}
+
+	req := setupTestRequest("PUT", fmt.Sprintf("/api/v1/preservation-configs/%d", config.ID), bytes.NewBuffer(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected status %d, got %d", http.StatusUnprocessableEntity, rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), "description must be at most") {
+		t.Errorf("Expected error naming description, got %s", rr.Body.String())
+	}
+}
+
+func TestServer_HandleCreateConfig_ConfiguredLengthLimits(t *testing.T) {
+	server, err := New(config.Config{
+		DBType:        testDBType,
+		DBConnection:  filepath.Join(t.TempDir(), "test.db"),
+		Port:          8080,
+		TrustedIPs:    []string{"127.0.0.1"},
+		MaxNameLength: 5,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer server.Shutdown()
+
+	reqBody := bytes.NewBufferString(`{"name": "Too long"}`)
+	req := setupTestRequest("POST", "/api/v1/preservation-configs", reqBody)
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected status %d, got %d", http.StatusUnprocessableEntity, rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), "name must be at most 5 characters") {
+		t.Errorf("Expected configured name limit in error, got %s", rr.Body.String())
+	}
+}
+
 func TestServer_HandleCreateConfig_UnicodeCharacters(t *testing.T) {
 	server := setupTestServer(t)
 	defer server.Shutdown()
@@ -116,7 +232,8 @@ func TestServer_HandleCreateConfig_SpecialCharacters(t *testing.T) {
 		t.Fatalf("Failed to unmarshal response: %v", err)
 	}
 
-	if config.Name != createReq["name"] {
+	// Trailing whitespace is trimmed from the name; the special characters are kept
+	if config.Name != "Special \"Chars\" & <Tags>" {
 		t.Errorf("Special characters in name not preserved")
 	}
 
@@ -149,19 +266,17 @@ func TestServer_HandleCreateConfig_JSONNumberFields(t *testing.T) {
 	rr := httptest.NewRecorder()
 	server.router.ServeHTTP(rr, req)
 
-	if status := rr.Code; status != http.StatusCreated {
-		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusCreated)
+	// Out-of-range numbers are rejected, the same as by bulk create and import
+	if status := rr.Code; status != http.StatusUnprocessableEntity {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusUnprocessableEntity)
 	}
 
-	var config models.PreservationConfig
-	err = json.Unmarshal(rr.Body.Bytes(), &config)
-	if err != nil {
+	var response validationResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
 		t.Fatalf("Failed to unmarshal response: %v", err)
 	}
-
-	// Verify extreme numbers are handled
-	if config.A3MConfig.AipCompressionLevel != 999 {
-		t.Errorf("Expected AipCompressionLevel 999, got %d", config.A3MConfig.AipCompressionLevel)
+	if !strings.Contains(response.Error, "aip_compression_level") {
+		t.Errorf("Expected error to name aip_compression_level, got %q", response.Error)
 	}
 }
 
@@ -248,6 +363,80 @@ func TestServer_HandleGetConfig_VeryLargeID(t *testing.T) {
 	}
 }
 
+func TestServer_InvalidIDs(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	// Every malformed ID gets the same 400, whichever handler parses it
+	routes := []struct{ method, suffix string }{
+		{"GET", ""}, {"PUT", ""}, {"DELETE", ""}, {"GET", "/a3m"}, {"PUT", "/active"}, {"GET", "/diff/1"},
+	}
+	for _, id := range []string{"abc", "0", "-1", "1.5", "99999999999999999999"} {
+		for _, route := range routes {
+			method := route.method
+			req := setupTestRequest(method, "/api/v1/preservation-configs/"+id+route.suffix, bytes.NewBufferString(`{}`))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			server.router.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("%s %s: expected status 400, got %d", method, id, rr.Code)
+			}
+			if !strings.Contains(rr.Body.String(), invalidIDMessage) {
+				t.Errorf("%s %s: expected %q, got %s", method, id, invalidIDMessage, rr.Body.String())
+			}
+		}
+	}
+
+	// The other config of a diff is checked the same way
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, setupTestRequest("GET", "/api/v1/preservation-configs/1/diff/0", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid other ID, got %d", rr.Code)
+	}
+}
+
+func TestServer_IDParam(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	tests := []struct {
+		name   string
+		id     string
+		wantID int64
+		wantOK bool
+	}{
+		{"valid", "42", 42, true},
+		{"max int64", "9223372036854775807", 9223372036854775807, true},
+		{"empty", "", 0, false},
+		{"non-numeric", "abc", 0, false},
+		{"zero", "0", 0, false},
+		{"negative", "-1", 0, false},
+		{"overflow", "9223372036854775808", 0, false},
+		{"surrounding space", " 1", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tt.id)
+			req := httptest.NewRequest("GET", "/", nil)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			rr := httptest.NewRecorder()
+
+			id, ok := server.idParam(rr, req)
+			if id != tt.wantID || ok != tt.wantOK {
+				t.Errorf("idParam(%q) = %d, %v; want %d, %v", tt.id, id, ok, tt.wantID, tt.wantOK)
+			}
+			if !ok && rr.Code != http.StatusBadRequest {
+				t.Errorf("Expected status 400 for %q, got %d", tt.id, rr.Code)
+			}
+			if ok && rr.Body.Len() != 0 {
+				t.Errorf("Expected nothing written for a valid ID, got %s", rr.Body.String())
+			}
+		})
+	}
+}
+
 func TestServer_HandleUpdateConfig_EmptyRequestBody(t *testing.T) {
 	server := setupTestServer(t)
 	defer server.Shutdown()
@@ -328,6 +517,121 @@ func TestServer_InvalidContentType(t *testing.T) {
 	}
 }
 
+func TestServer_StrictContentType(t *testing.T) {
+	server, err := New(config.Config{
+		DBType:            testDBType,
+		DBConnection:      filepath.Join(t.TempDir(), "test.db"),
+		Port:              8080,
+		TrustedIPs:        []string{"127.0.0.1"},
+		StrictContentType: true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer server.Shutdown()
+
+	tests := []struct {
+		name           string
+		method         string
+		url            string
+		contentType    string
+		body           string
+		expectedStatus int
+	}{
+		{"JSON", "POST", "/api/v1/preservation-configs", "application/json", `{"name": "Strict JSON"}`, http.StatusCreated},
+		{"JSON with charset", "POST", "/api/v1/preservation-configs", "application/json; charset=utf-8", `{"name": "Strict Charset"}`, http.StatusCreated},
+		{"YAML", "POST", "/api/v1/preservation-configs", "application/yaml", "name: Strict YAML\n", http.StatusCreated},
+		{"Plain text", "POST", "/api/v1/preservation-configs", "text/plain", `{"name": "Plain"}`, http.StatusUnsupportedMediaType},
+		{"Form encoded", "POST", "/api/v1/preservation-configs", "application/x-www-form-urlencoded", "name=Form", http.StatusUnsupportedMediaType},
+		{"Missing", "POST", "/api/v1/preservation-configs", "", `{"name": "Missing"}`, http.StatusUnsupportedMediaType},
+		{"Update", "PUT", "/api/v1/preservation-configs/1", "text/plain", `{"name": "Update"}`, http.StatusUnsupportedMediaType},
+		{"Validate", "POST", "/api/v1/preservation-configs/validate", "text/plain", `{"name": "Validate"}`, http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := setupTestRequest(tt.method, tt.url, bytes.NewBufferString(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rr := httptest.NewRecorder()
+			server.router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestServer_StrictJSON(t *testing.T) {
+	server, err := New(config.Config{
+		DBType:       testDBType,
+		DBConnection: filepath.Join(t.TempDir(), "test.db"),
+		Port:         8080,
+		TrustedIPs:   []string{"127.0.0.1"},
+		StrictJSON:   true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer server.Shutdown()
+
+	tests := []struct {
+		name           string
+		method         string
+		url            string
+		body           string
+		expectedStatus int
+		expectedField  string
+	}{
+		{"Known fields", "POST", "/api/v1/preservation-configs", `{"name": "Strict", "description": "", "compress_aip": true}`, http.StatusCreated, ""},
+		{"Typo", "POST", "/api/v1/preservation-configs", `{"name": "Typo", "complress_aip": true}`, http.StatusBadRequest, "complress_aip"},
+		{"Unknown A3M field", "POST", "/api/v1/preservation-configs", `{"name": "A3M", "a3m_config": {"normalise": false}}`, http.StatusCreated, ""},
+		{"Whole config", "PUT", "/api/v1/preservation-configs/1", `{"id": 1, "name": "Default", "version": 1, "created_at": "2024-01-01T00:00:00Z", "updated_at": "2024-01-01T00:00:00Z"}`, http.StatusOK, ""},
+		{"Update typo", "PUT", "/api/v1/preservation-configs/1", `{"descripton": "Typo"}`, http.StatusBadRequest, "descripton"},
+		{"Validate known fields", "POST", "/api/v1/preservation-configs/validate", `{"name": "Strict", "compress_aip": true}`, http.StatusOK, ""},
+		{"Validate typo", "POST", "/api/v1/preservation-configs/validate", `{"name": "Typo", "complress_aip": true}`, http.StatusBadRequest, "complress_aip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := setupTestRequest(tt.method, tt.url, bytes.NewBufferString(tt.body))
+			rr := httptest.NewRecorder()
+			server.router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if tt.expectedField != "" && !strings.Contains(rr.Body.String(), tt.expectedField) {
+				t.Errorf("Expected error to name %q, got %s", tt.expectedField, rr.Body.String())
+			}
+		})
+	}
+
+	// Unknown fields are ignored when strict JSON is off
+	lenient := setupTestServer(t)
+	defer lenient.Shutdown()
+
+	req := setupTestRequest("POST", "/api/v1/preservation-configs", bytes.NewBufferString(`{"name": "Lenient", "complress_aip": true}`))
+	rr := httptest.NewRecorder()
+	lenient.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Errorf("Expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+}
+
+func TestCheckUnknownFields(t *testing.T) {
+	if err := checkUnknownFields(map[string]any{"name": "Test", "a3m_config": map[string]any{"anything": true}}); err != nil {
+		t.Errorf("Expected known fields to pass, got %v", err)
+	}
+	err := checkUnknownFields(map[string]any{"name": "Test", "nmae": "Typo"})
+	if err == nil || err.Error() != `unknown field "nmae"` {
+		t.Errorf("Expected unknown field \"nmae\" error, got %v", err)
+	}
+}
+
 func TestServer_HandleCreateConfig_MalformedJSON(t *testing.T) {
 	server := setupTestServer(t)
 	defer server.Shutdown()
@@ -408,3 +712,372 @@ func TestServer_RequestMethodNotAllowed(t *testing.T) {
 		})
 	}
 }
+
+func TestServer_UnknownRoutesAndMethods(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	tests := []struct {
+		method, path string
+		wantStatus   int
+		wantAllow    string
+	}{
+		{"GET", "/api/v1/no-such-route", http.StatusNotFound, ""},
+		{"GET", "/no-such-route", http.StatusNotFound, ""},
+		{"PATCH", "/api/v1/preservation-configs", http.StatusMethodNotAllowed, "GET, POST"},
+		{"POST", "/api/v1/preservation-configs/1", http.StatusMethodNotAllowed, "GET, PUT, DELETE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			req := setupTestRequest(tt.method, tt.path, nil)
+			rr := httptest.NewRecorder()
+			server.router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rr.Code)
+			}
+			if got := rr.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("Expected JSON content type, got %q", got)
+			}
+			var body map[string]string
+			if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil || body["error"] == "" {
+				t.Errorf("Expected a JSON error body, got %q", rr.Body.String())
+			}
+			if got := rr.Header().Get("Allow"); got != tt.wantAllow {
+				t.Errorf("Expected Allow %q, got %q", tt.wantAllow, got)
+			}
+		})
+	}
+}
+
+func TestServer_DefaultConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "test.db")
+	configFile := filepath.Join(dir, "default.yaml")
+	if err := os.WriteFile(configFile, []byte("name: House Style\ncompress_aip: true\na3m_config:\n  examine_contents: true\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write default config file: %v", err)
+	}
+
+	newServer := func() *Server {
+		t.Helper()
+		server, err := New(config.Config{
+			DBType:            testDBType,
+			DBConnection:      dbPath,
+			Port:              8080,
+			TrustedIPs:        []string{"127.0.0.1"},
+			DefaultConfigFile: configFile,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create server: %v", err)
+		}
+		return server
+	}
+
+	server := newServer()
+	seeded, err := server.db.GetConfig(1)
+	if err != nil {
+		t.Fatalf("Failed to get seeded config: %v", err)
+	}
+	if seeded.Name != "House Style" || !seeded.CompressAIP || !seeded.A3MConfig.ExamineContents {
+		t.Errorf("Expected the config from the file, got %+v", seeded)
+	}
+	// Fields the file leaves out keep the built-in defaults
+	if !seeded.A3MConfig.Normalize {
+		t.Error("Expected Normalize to keep its default of true")
+	}
+	server.Shutdown()
+
+	// Restarting doesn't seed again
+	server = newServer()
+	defer server.Shutdown()
+	if count, _ := server.db.CountConfigs(); count != 1 {
+		t.Errorf("Expected 1 config after restart, got %d", count)
+	}
+	if config, _ := server.db.GetConfig(1); config.Version != seeded.Version {
+		t.Errorf("Expected the seeded config to be unchanged at version %d, got %d", seeded.Version, config.Version)
+	}
+}
+
+func TestServer_DefaultConfigFile_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"missing name": `{"description": "No name"}`,
+		"out of range": `{"name": "Bad", "a3m_config": {"aip_compression_level": 99}}`,
+		"not a config": `- a list`,
+	}
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			configFile := filepath.Join(dir, strings.ReplaceAll(name, " ", "_")+".json")
+			if err := os.WriteFile(configFile, []byte(content), 0o600); err != nil {
+				t.Fatalf("Failed to write default config file: %v", err)
+			}
+			_, err := New(config.Config{
+				DBType:            testDBType,
+				DBConnection:      filepath.Join(t.TempDir(), "test.db"),
+				DefaultConfigFile: configFile,
+			})
+			if err == nil {
+				t.Error("Expected an invalid default config file to stop startup")
+			}
+		})
+	}
+
+	if _, err := New(config.Config{
+		DBType:            testDBType,
+		DBConnection:      filepath.Join(t.TempDir(), "test.db"),
+		DefaultConfigFile: filepath.Join(dir, "does-not-exist.yaml"),
+	}); err == nil {
+		t.Error("Expected a missing default config file to stop startup")
+	}
+}
+
+func TestServer_MigrationsInProgress(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+	// Pretend migrations are still running
+	migrated := make(chan struct{})
+	server.migrated = migrated
+
+	for _, path := range []string{"/api/v1/ready", "/api/v1/preservation-configs"} {
+		req := setupTestRequest("GET", path, nil)
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected status %d for %s, got %d", http.StatusServiceUnavailable, path, rr.Code)
+		}
+		if got := rr.Header().Get("Retry-After"); got != strconv.Itoa(migrationRetryAfter) {
+			t.Errorf("Expected Retry-After %d for %s, got %q", migrationRetryAfter, path, got)
+		}
+	}
+
+	req := setupTestRequest("GET", "/api/v1/health", nil)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected health to be served during migrations, got status %d", rr.Code)
+	}
+
+	// A failed migration keeps the server unavailable, without inviting a retry
+	server.migrationErr = errors.New("migration failed")
+	close(migrated)
+	for _, path := range []string{"/api/v1/ready", "/api/v1/preservation-configs"} {
+		req := setupTestRequest("GET", path, nil)
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected status %d for %s, got %d", http.StatusServiceUnavailable, path, rr.Code)
+		}
+		if got := rr.Header().Get("Retry-After"); got != "" {
+			t.Errorf("Expected no Retry-After for %s after a failed migration, got %q", path, got)
+		}
+	}
+}
+
+func TestServer_BackgroundMigrations(t *testing.T) {
+	server, err := New(config.Config{
+		DBType:               testDBType,
+		DBConnection:         filepath.Join(t.TempDir(), "test.db"),
+		Port:                 8080,
+		TrustedIPs:           []string{"127.0.0.1"},
+		BackgroundMigrations: true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer server.Shutdown()
+
+	select {
+	case <-server.migrated:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timed out waiting for background migrations")
+	}
+
+	req := setupTestRequest("GET", "/api/v1/ready", nil)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d once migrations finish, got %d", http.StatusOK, rr.Code)
+	}
+
+	// The migration-seeded default config is there
+	req = setupTestRequest("GET", "/api/v1/preservation-configs/1", nil)
+	rr = httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+}
+
+func TestServer_ShutdownDuringMigrations(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	// Hold a write lock on the database so that the migrations wait on it
+	lock, err := sql.Open(testDBType, dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer lock.Close()
+	conn, err := lock.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(context.Background(), `BEGIN IMMEDIATE`); err != nil {
+		t.Fatalf("Failed to lock database: %v", err)
+	}
+
+	server, err := New(config.Config{
+		DBType:               testDBType,
+		DBConnection:         dbPath,
+		Port:                 8080,
+		TrustedIPs:           []string{"127.0.0.1"},
+		BackgroundMigrations: true,
+		ShutdownTimeout:      10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+
+	// Release the lock well after the shutdown timeout has run out
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		conn.ExecContext(context.Background(), `ROLLBACK`)
+	}()
+	server.Shutdown()
+
+	// The database is only closed once the migrations have stopped
+	select {
+	case <-server.migrated:
+	default:
+		t.Fatal("Expected Shutdown to wait for the running migration")
+	}
+	if !errors.Is(server.migrationErr, context.Canceled) {
+		t.Errorf("Expected the migrations to be stopped, got %v", server.migrationErr)
+	}
+
+	db, err := database.Open(testDBType, dbPath)
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer db.Close()
+	if _, dirty, err := db.MigrateVersion(); err != nil || dirty {
+		t.Errorf("Expected a clean schema after shutdown, got dirty %v (error: %v)", dirty, err)
+	}
+}
+
+func TestServer_SkipMigrations(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	cfg := config.Config{
+		DBType:         testDBType,
+		DBConnection:   dbPath,
+		Port:           8080,
+		TrustedIPs:     []string{"127.0.0.1"},
+		SkipMigrations: true,
+	}
+
+	// The schema of a new database is behind, so startup fails rather than migrating it
+	if server, err := New(cfg); err == nil {
+		server.Shutdown()
+		t.Fatal("Expected startup to fail on an unmigrated database")
+	} else if !errors.Is(err, database.ErrSchemaNotCurrent) {
+		t.Errorf("Expected ErrSchemaNotCurrent, got %v", err)
+	}
+
+	// Once migrated separately, the server starts without migrating
+	db, err := database.New(testDBType, dbPath)
+	if err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+	db.Close()
+
+	server, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Expected startup to succeed on a current schema, got %v", err)
+	}
+	defer server.Shutdown()
+
+	req := setupTestRequest("GET", "/api/v1/ready", nil)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+}
+
+func TestCheckDuplicateKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"none", `{"name": "A", "a3m_config": {"normalize": true}}`, ""},
+		{"top level", `{"name": "A", "name": "B"}`, "name"},
+		{"nested", `{"a3m_config": {"normalize": true, "examine_contents": true, "normalize": false}}`, "a3m_config.normalize"},
+		{"in array", `[{"name": "A"}, {"name": "B", "name": "C"}]`, "[1].name"},
+		{"a3m field in both spellings", `{"a3m_config": {"examine_contents": true, "examineContents": false}}`, "a3m_config.examine_contents"},
+		{"a3m field in both spellings in array", `{"configs": [{"a3m_config": {"aipCompressionLevel": 1, "aip_compression_level": 9}}]}`, "configs[0].a3m_config.aip_compression_level"},
+		{"camelCase key outside a3m_config", `{"examine_contents": true, "examineContents": false}`, ""},
+		{"same key in sibling objects", `[{"name": "A"}, {"name": "B"}]`, ""},
+		{"malformed", `{"name": `, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkDuplicateKeys([]byte(tt.body))
+			var dupErr *duplicateKeyError
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("Expected no error, got %v", err)
+			case tt.want != "" && (!errors.As(err, &dupErr) || dupErr.path != tt.want):
+				t.Errorf("Expected duplicate %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestServer_HandleCreateConfig_DuplicateA3MKey(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	body := `{"name": "Duplicated", "a3m_config": {"normalize": true, "normalize": false}}`
+	req := setupTestRequest("POST", "/api/v1/preservation-configs", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), "a3m_config.normalize") {
+		t.Errorf("Expected the error to name the duplicated field, got %s", rr.Body.String())
+	}
+}
+
+func TestServer_HandleCreateConfig_A3MKeyInBothSpellings(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	tests := []struct {
+		contentType string
+		body        string
+	}{
+		{"application/json", `{"name": "Aliased", "a3m_config": {"examine_contents": true, "examineContents": false}}`},
+		{"application/yaml", "name: Aliased\na3m_config:\n  examineContents: false\n  examine_contents: true\n"},
+	}
+	for _, tt := range tests {
+		req := setupTestRequest("POST", "/api/v1/preservation-configs", bytes.NewBufferString(tt.body))
+		req.Header.Set("Content-Type", tt.contentType)
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for %s, got %d", http.StatusBadRequest, tt.contentType, rr.Code)
+			continue
+		}
+		if !strings.Contains(rr.Body.String(), "a3m_config.examine_contents") {
+			t.Errorf("Expected the error to name the field for %s, got %s", tt.contentType, rr.Body.String())
+		}
+	}
+}
@@ -2,17 +2,31 @@ package server
 
 import (
 	"bytes"
+	"compress/gzip"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	transferservice "github.com/penwern/curate-preservation-api/common/proto/a3m/gen/go/a3m/api/transferservice/v1beta1"
 	"github.com/penwern/curate-preservation-api/database"
 	"github.com/penwern/curate-preservation-api/models"
 	"github.com/penwern/curate-preservation-api/pkg/config"
 	"github.com/penwern/curate-preservation-api/pkg/logger"
+	"github.com/penwern/curate-preservation-api/pkg/version"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
 )
 
 const (
@@ -130,6 +144,39 @@ func TestServer_HandleHealth(t *testing.T) {
 	}
 }
 
+func TestServer_HandleVersion(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	// Public like health, so use an address that is not trusted
+	req := httptest.NewRequest("GET", "/api/v1/version", nil)
+	req.RemoteAddr = "203.0.113.10:12345"
+
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var response map[string]string
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if response["version"] != version.Version() {
+		t.Errorf("Expected version '%s', got '%s'", version.Version(), response["version"])
+	}
+	if response["go_version"] != runtime.Version() {
+		t.Errorf("Expected go_version '%s', got '%s'", runtime.Version(), response["go_version"])
+	}
+	for _, key := range []string{"commit", "build_time"} {
+		if _, ok := response[key]; !ok {
+			t.Errorf("Expected '%s' in response", key)
+		}
+	}
+}
+
 func TestServer_HandleListConfigs_Empty(t *testing.T) {
 	server := setupTestServer(t)
 	defer server.Shutdown()
@@ -195,6 +242,60 @@ func TestServer_HandleCreateConfig(t *testing.T) {
 	}
 }
 
+func TestServer_PreferReturnMinimal(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	req := setupTestRequest("POST", "/api/v1/preservation-configs", bytes.NewBufferString(`{"name": "Minimal"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Prefer", "return=minimal")
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr.Body.Len() != 0 {
+		t.Errorf("Expected an empty body, got %s", rr.Body.String())
+	}
+	if got := rr.Header().Get(preferenceAppliedHeader); got != "return=minimal" {
+		t.Errorf("Expected Preference-Applied return=minimal, got %q", got)
+	}
+	location := rr.Header().Get("Location")
+	if !strings.HasPrefix(location, "/api/v1/preservation-configs/") {
+		t.Fatalf("Expected a Location for the created config, got %q", location)
+	}
+
+	// The Location serves the created config
+	rr = httptest.NewRecorder()
+	server.router.ServeHTTP(rr, setupTestRequest("GET", location, nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"Minimal"`) {
+		t.Fatalf("Expected the Location to serve the config, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	req = setupTestRequest("PUT", location, bytes.NewBufferString(`{"description": "Updated"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Prefer", "respond-async, return=minimal")
+	rr = httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("ETag") == "" {
+		t.Error("Expected the minimal update to carry the new ETag")
+	}
+
+	// return=representation keeps the full body
+	req = setupTestRequest("PUT", location, bytes.NewBufferString(`{"description": "Again"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Prefer", "return=representation")
+	rr = httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"Again"`) {
+		t.Errorf("Expected the updated config, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
 func TestServer_HandleCreateAllOnConfig(t *testing.T) {
 	server := setupTestServer(t)
 	defer server.Shutdown()
@@ -441,8 +542,138 @@ func TestServer_HandleCreateConfig_MissingName(t *testing.T) {
 	rr := httptest.NewRecorder()
 	server.router.ServeHTTP(rr, req)
 
-	if status := rr.Code; status != http.StatusBadRequest {
-		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	if status := rr.Code; status != http.StatusUnprocessableEntity {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestServer_HandleCreateConfig_ReportsAllFieldErrors(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	body := `{"description": "No name", "a3m_config": {"aip_compression_level": 12, "thumbnail_mode": 99}}`
+	req := setupTestRequest("POST", "/api/v1/preservation-configs", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusUnprocessableEntity, rr.Code, rr.Body.String())
+	}
+
+	var response validationResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	fields := make([]string, 0, len(response.Errors))
+	for _, fieldErr := range response.Errors {
+		if fieldErr.Message == "" {
+			t.Errorf("Expected a message for field %s", fieldErr.Field)
+		}
+		fields = append(fields, fieldErr.Field)
+	}
+	if expected := "[name aip_compression_level thumbnail_mode]"; fmt.Sprint(fields) != expected {
+		t.Errorf("Expected errors for fields %s, got %v", expected, response.Errors)
+	}
+}
+
+func TestServer_A3MValueErrors(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := setupTestRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		return rr
+	}
+	errorFields := func(rr *httptest.ResponseRecorder) string {
+		t.Helper()
+		if rr.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusUnprocessableEntity, rr.Code, rr.Body.String())
+		}
+		var response validationResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		fields := make([]string, 0, len(response.Errors))
+		for _, fieldErr := range response.Errors {
+			fields = append(fields, fieldErr.Field)
+		}
+		return fmt.Sprint(fields)
+	}
+
+	// A value of the wrong type is reported rather than ignored, on create and on update
+	rr := send("POST", "/api/v1/preservation-configs", `{"name": "Typed", "a3m_config": {"normalize": "abc", "examineContents": true}}`)
+	if got := errorFields(rr); got != "[normalize]" {
+		t.Errorf("Expected an error for normalize, got %s", got)
+	}
+	if !strings.Contains(rr.Body.String(), `normalize must be true or false, got the string \"abc\"`) {
+		t.Errorf("Expected the error to explain the type, got %s", rr.Body.String())
+	}
+	rr = send("PUT", "/api/v1/preservation-configs/1", `{"a3m_config": {"examineContents": [true], "aip_compression_level": "high"}}`)
+	if got := errorFields(rr); got != "[aip_compression_level examine_contents]" {
+		t.Errorf("Expected errors for aip_compression_level and examine_contents, got %s", got)
+	}
+	if got := errorFields(send("POST", "/api/v1/preservation-configs", `{"name": "Typed", "a3m_config": "normalize"}`)); got != "[a3m_config]" {
+		t.Errorf("Expected an error for a3m_config, got %s", got)
+	}
+
+	// Nothing was changed by the rejected update
+	config, err := server.db.GetConfig(1)
+	if err != nil {
+		t.Fatalf("Failed to get config: %v", err)
+	}
+	if config.Version != 1 {
+		t.Errorf("Expected the rejected update to leave the config at version 1, got %d", config.Version)
+	}
+}
+
+func TestServer_A3MBooleanForms(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := setupTestRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := send("POST", "/api/v1/preservation-configs", `{"name": "Loose", "a3m_config": {"normalize": "false", "examine_contents": 1, "extractPackages": "1", "transcribeFiles": 0}}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	var config models.PreservationConfig
+	if err := json.Unmarshal(rr.Body.Bytes(), &config); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	a3m := &config.A3MConfig
+	if a3m.Normalize || !a3m.ExamineContents || !a3m.ExtractPackages || a3m.TranscribeFiles {
+		t.Errorf("Expected the boolean-ish values to be converted, got %v", a3m)
+	}
+
+	rr = send("PUT", fmt.Sprintf("/api/v1/preservation-configs/%d", config.ID), `{"a3m_config": {"normalize": "true", "examine_contents": 0}}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &config); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if !config.A3MConfig.Normalize || config.A3MConfig.ExamineContents {
+		t.Errorf("Expected normalize on and examine_contents off, got %v", &config.A3MConfig)
+	}
+
+	// Values that could mean either are rejected rather than guessed at
+	for _, value := range []string{`"yes"`, `2`, `"t"`, `""`} {
+		rr := send("PUT", fmt.Sprintf("/api/v1/preservation-configs/%d", config.ID), `{"a3m_config": {"normalize": `+value+`}}`)
+		if rr.Code != http.StatusUnprocessableEntity {
+			t.Errorf("Expected status %d for normalize %s, got %d", http.StatusUnprocessableEntity, value, rr.Code)
+		}
 	}
 }
 
@@ -888,6 +1119,279 @@ func TestServer_HandleDeleteConfig(t *testing.T) {
 	}
 }
 
+func TestServer_HandleBulkDeleteConfigs(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	var ids []int64
+	for _, name := range []string{"First", "Second"} {
+		config := models.NewPreservationConfig(name, "")
+		if err := server.db.CreateConfig(config); err != nil {
+			t.Fatalf("Failed to create test config: %v", err)
+		}
+		ids = append(ids, config.ID)
+	}
+
+	bulkDelete := func(body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := setupTestRequest("POST", "/api/v1/preservation-configs/bulk-delete", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := bulkDelete(fmt.Sprintf(`{"ids": [%d, 9999, %d, %d]}`, ids[0], ids[1], ids[0]))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var response bulkDeleteResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if !slices.Equal(response.Deleted, ids) {
+		t.Errorf("Expected %v deleted, got %v", ids, response.Deleted)
+	}
+	if !slices.Equal(response.NotFound, []int64{9999}) {
+		t.Errorf("Expected [9999] not found, got %v", response.NotFound)
+	}
+	for _, id := range ids {
+		if _, err := server.db.GetConfig(id); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("Expected config %d to be deleted, got %v", id, err)
+		}
+	}
+
+	tooMany := make([]string, maxBulkDeleteIDs+1)
+	for i := range tooMany {
+		tooMany[i] = strconv.Itoa(i + 1)
+	}
+	for _, body := range []string{`{"ids": []}`, `{"ids": [0]}`, `{"ids": ["1"]}`, `{"ids": [` + strings.Join(tooMany, ",") + `]}`} {
+		if rr := bulkDelete(body); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %.40s, got %d", body, rr.Code)
+		}
+	}
+}
+
+func TestServer_HandleDeleteConfig_IfMatch(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	config := models.NewPreservationConfig("To Delete", "Will be deleted")
+	if err := server.db.CreateConfig(config); err != nil {
+		t.Fatalf("Failed to create test config: %v", err)
+	}
+
+	deleteConfig := func(ifMatch string) int {
+		t.Helper()
+		req := setupTestRequest("DELETE", fmt.Sprintf("/api/v1/preservation-configs/%d", config.ID), nil)
+		req.Header.Set("If-Match", ifMatch)
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if code := deleteConfig("not-a-version"); code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid If-Match, got %d", code)
+	}
+	if code := deleteConfig(fmt.Sprintf(`W/"%d"`, config.Version+1)); code != http.StatusPreconditionFailed {
+		t.Errorf("Expected status 412 for a stale If-Match, got %d", code)
+	}
+	if _, err := server.db.GetConfig(config.ID); err != nil {
+		t.Fatalf("Expected the config to survive a stale delete: %v", err)
+	}
+
+	if code := deleteConfig(configETag(config)); code != http.StatusNoContent {
+		t.Errorf("Expected status 204 for a matching If-Match, got %d", code)
+	}
+	if _, err := server.db.GetConfig(config.ID); err != database.ErrNotFound {
+		t.Errorf("Expected config to be deleted, but it still exists")
+	}
+}
+
+func TestCheckLastModified(t *testing.T) {
+	modified := time.Date(2025, 3, 1, 12, 0, 0, 250_000_000, time.UTC)
+	later := modified.Add(time.Minute)
+	// Rounded up to the next whole second
+	stamp := "Sat, 01 Mar 2025 12:00:01 GMT"
+
+	tests := []struct {
+		name            string
+		now             time.Time
+		ifModifiedSince string
+		wantHeader      string
+		wantNotModified bool
+	}{
+		{"no validator within the same second", modified.Add(500 * time.Millisecond), "", "", false},
+		{"no If-Modified-Since", later, "", stamp, false},
+		{"unchanged since", later, stamp, stamp, true},
+		{"changed since", later, "Sat, 01 Mar 2025 12:00:00 GMT", stamp, false},
+		{"invalid If-Modified-Since", later, "yesterday", stamp, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/preservation-configs", nil)
+			if tt.ifModifiedSince != "" {
+				req.Header.Set("If-Modified-Since", tt.ifModifiedSince)
+			}
+			rr := httptest.NewRecorder()
+
+			if got := checkLastModified(rr, req, modified, tt.now); got != tt.wantNotModified {
+				t.Errorf("Expected not modified %v, got %v", tt.wantNotModified, got)
+			}
+			if got := rr.Header().Get("Last-Modified"); got != tt.wantHeader {
+				t.Errorf("Expected Last-Modified %q, got %q", tt.wantHeader, got)
+			}
+			if tt.wantNotModified && rr.Code != http.StatusNotModified {
+				t.Errorf("Expected status %d, got %d", http.StatusNotModified, rr.Code)
+			}
+		})
+	}
+}
+
+func TestServer_ListConfigsIfModifiedSince(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	list := func(ifModifiedSince string) *httptest.ResponseRecorder {
+		req := setupTestRequest("GET", "/api/v1/preservation-configs", nil)
+		if ifModifiedSince != "" {
+			req.Header.Set("If-Modified-Since", ifModifiedSince)
+		}
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// A date after every change made so far
+	future := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	past := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+
+	if rr := list(past); rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d for configs changed since, got %d", http.StatusOK, rr.Code)
+	}
+
+	// Wait for the default config's second to pass, so that a validator is given
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(2 * time.Second)))
+	rr := list(future)
+	if rr.Code != http.StatusNotModified {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusNotModified, rr.Code, rr.Body.String())
+	}
+	if rr.Body.Len() != 0 {
+		t.Errorf("Expected no body with 304, got %s", rr.Body.String())
+	}
+	if rr.Header().Get("Last-Modified") == "" {
+		t.Error("Expected a Last-Modified header")
+	}
+}
+
+func TestServer_MaxConfigs(t *testing.T) {
+	server, err := New(config.Config{
+		DBType:       testDBType,
+		DBConnection: filepath.Join(t.TempDir(), "test.db"),
+		TrustedIPs:   []string{"127.0.0.1"},
+		MaxConfigs:   2,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer server.Shutdown()
+
+	create := func(path, body string) *httptest.ResponseRecorder {
+		req := setupTestRequest("POST", path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// The default config takes one of the two places
+	if rr := create("/api/v1/preservation-configs", `{"name": "Second"}`); rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	rr := create("/api/v1/preservation-configs", `{"name": "Third"}`)
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("Expected status %d at the limit, got %d: %s", http.StatusConflict, rr.Code, rr.Body.String())
+	}
+	if !strings.Contains(rr.Body.String(), "at most 2 configs") {
+		t.Errorf("Expected the error to give the limit, got %s", rr.Body.String())
+	}
+	if rr := create("/api/v1/preservation-configs/bulk", `[{"name": "Third"}]`); rr.Code != http.StatusConflict {
+		t.Errorf("Expected status %d for bulk create, got %d: %s", http.StatusConflict, rr.Code, rr.Body.String())
+	}
+	if rr := create("/api/v1/preservation-configs/import", `{"schema_version": 1, "configs": [{"name": "Third"}]}`); rr.Code != http.StatusConflict {
+		t.Errorf("Expected status %d for import, got %d: %s", http.StatusConflict, rr.Code, rr.Body.String())
+	}
+}
+
+func TestServer_HandleSetConfigActive(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	config := models.NewPreservationConfig("Retired", "Kept for audit")
+	if err := server.db.CreateConfig(config); err != nil {
+		t.Fatalf("Failed to create test config: %v", err)
+	}
+	url := fmt.Sprintf("/api/v1/preservation-configs/%d/active", config.ID)
+
+	req := setupTestRequest("PUT", url, bytes.NewBufferString(`{"active": false}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var updated models.PreservationConfig
+	if err := json.Unmarshal(rr.Body.Bytes(), &updated); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if updated.Active {
+		t.Error("Expected config to be inactive")
+	}
+	if rr.Header().Get("ETag") != configETag(&updated) {
+		t.Errorf("Expected ETag %s, got %s", configETag(&updated), rr.Header().Get("ETag"))
+	}
+
+	// The list shows every config unless filtered on active
+	for query, wantListed := range map[string]bool{"": true, "?active=true": false, "?active=false": true} {
+		req := setupTestRequest("GET", "/api/v1/preservation-configs"+query, nil)
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+
+		var configs []*models.PreservationConfig
+		if err := json.Unmarshal(rr.Body.Bytes(), &configs); err != nil {
+			t.Fatalf("Failed to decode list response: %v", err)
+		}
+		listed := false
+		for _, c := range configs {
+			listed = listed || c.ID == config.ID
+		}
+		if listed != wantListed {
+			t.Errorf("List%s: expected inactive config listed %v, got %v", query, wantListed, listed)
+		}
+	}
+
+	tests := []struct {
+		url        string
+		body       string
+		wantStatus int
+	}{
+		{url, `{}`, http.StatusBadRequest},
+		{url, `{"active": "no"}`, http.StatusBadRequest},
+		{"/api/v1/preservation-configs/999/active", `{"active": true}`, http.StatusNotFound},
+		{"/api/v1/preservation-configs/abc/active", `{"active": true}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := setupTestRequest("PUT", tt.url, bytes.NewBufferString(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		if rr.Code != tt.wantStatus {
+			t.Errorf("PUT %s %s: expected status %d, got %d", tt.url, tt.body, tt.wantStatus, rr.Code)
+		}
+	}
+}
+
 func TestServer_Shutdown(t *testing.T) {
 	server := setupTestServer(t)
 
@@ -898,6 +1402,99 @@ func TestServer_Shutdown(t *testing.T) {
 	}
 }
 
+func TestServer_Shutdown_DrainsInFlightRequests(t *testing.T) {
+	server := setupTestServer(t)
+
+	// A slow handler that still needs the database after shutdown has begun
+	started := make(chan struct{})
+	server.router.Get("/slow", func(w http.ResponseWriter, _ *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		if _, err := server.db.ListConfigs(); err != nil {
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	go func() {
+		_ = server.srv.Serve(listener)
+	}()
+
+	statusCh := make(chan int, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String() + "/slow")
+		if err != nil {
+			statusCh <- 0
+			return
+		}
+		defer resp.Body.Close()
+		statusCh <- resp.StatusCode
+	}()
+
+	<-started
+	if err := server.Shutdown(); err != nil {
+		t.Errorf("Shutdown failed: %v", err)
+	}
+
+	if status := <-statusCh; status != http.StatusOK {
+		t.Errorf("Expected in-flight request to complete with status %d, got %d", http.StatusOK, status)
+	}
+}
+
+func TestServer_Shutdown_ForcesCloseAfterTimeout(t *testing.T) {
+	server := setupTestServer(t)
+	server.shutdownTimeout = 50 * time.Millisecond
+
+	// A handler stuck until the test ends
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	server.srv.Handler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		close(started)
+		<-release
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	go func() {
+		_ = server.srv.Serve(listener)
+	}()
+
+	errCh := make(chan error, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String() + "/stuck")
+		if err == nil {
+			resp.Body.Close()
+		}
+		errCh <- err
+	}()
+
+	<-started
+	err = server.Shutdown()
+	if !errors.Is(err, ErrShutdownTimeout) {
+		t.Errorf("Expected ErrShutdownTimeout, got %v", err)
+	}
+	if err != nil && !strings.Contains(err.Error(), "1 connections forcibly closed") {
+		t.Errorf("Expected the error to count the dropped connection, got %v", err)
+	}
+
+	select {
+	case err := <-errCh:
+		if err == nil {
+			t.Error("Expected the stuck request's connection to be closed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the stuck request's connection to be closed promptly")
+	}
+}
+
 func TestServer_Integration_FullWorkflow(t *testing.T) {
 	server := setupTestServer(t)
 	defer server.Shutdown()
@@ -1015,9 +1612,37 @@ func TestServer_HandleCreateConfig_EmptyName(t *testing.T) {
 	server := setupTestServer(t)
 	defer server.Shutdown()
 
+	// Empty and whitespace-only names should fail
+	for _, name := range []string{"", "   ", "\t\n "} {
+		createReq := map[string]any{
+			"name":        name,
+			"description": "Test Description",
+		}
+
+		reqBody, err := json.Marshal(createReq)
+		if err != nil {
+			t.Fatalf("Failed to marshal request: %v", err)
+		}
+
+		req := setupTestRequest("POST", "/api/v1/preservation-configs", bytes.NewBuffer(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusUnprocessableEntity {
+			t.Errorf("Handler returned wrong status code for name %q: got %v want %v", name, status, http.StatusUnprocessableEntity)
+		}
+	}
+}
+
+func TestServer_HandleCreateConfig_NormalizesName(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
 	createReq := map[string]any{
-		"name":        "", // Empty name should fail
-		"description": "Test Description",
+		"name":        "  My   Config \t",
+		"description": "  Indented description",
 	}
 
 	reqBody, err := json.Marshal(createReq)
@@ -1031,58 +1656,235 @@ func TestServer_HandleCreateConfig_EmptyName(t *testing.T) {
 	rr := httptest.NewRecorder()
 	server.router.ServeHTTP(rr, req)
 
-	if status := rr.Code; status != http.StatusBadRequest {
-		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	if status := rr.Code; status != http.StatusCreated {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", status, http.StatusCreated)
+	}
+
+	var config models.PreservationConfig
+	if err := json.Unmarshal(rr.Body.Bytes(), &config); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if config.Name != "My Config" {
+		t.Errorf("Expected name %q, got %q", "My Config", config.Name)
+	}
+	// The description is stored as given
+	if config.Description != "  Indented description" {
+		t.Errorf("Expected description to be untrimmed, got %q", config.Description)
 	}
 }
 
-func TestServer_HandleUpdateConfig_IDMismatch(t *testing.T) {
+func TestServer_HandleUpdateConfig_WhitespaceName(t *testing.T) {
 	server := setupTestServer(t)
 	defer server.Shutdown()
 
-	// First create a config
 	config := models.NewPreservationConfig(testOriginalName, testOriginalDesc)
-	err := server.db.CreateConfig(config)
-	if err != nil {
+	if err := server.db.CreateConfig(config); err != nil {
 		t.Fatalf("Failed to create test config: %v", err)
 	}
 
-	// Update with mismatched ID in body
-	updateReq := map[string]any{
-		"id":          999, // Different from URL
-		"name":        "Updated Name",
-		"description": "Updated Description",
-	}
+	url := fmt.Sprintf("/api/v1/preservation-configs/%d", config.ID)
 
-	reqBody, err := json.Marshal(updateReq)
-	if err != nil {
-		t.Fatalf("Failed to marshal request: %v", err)
+	req := setupTestRequest("PUT", url, bytes.NewBufferString(`{"name": "   "}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status %d for whitespace-only name, got %d", http.StatusUnprocessableEntity, rr.Code)
 	}
 
-	req := setupTestRequest("PUT", fmt.Sprintf("/api/v1/preservation-configs/%d", config.ID), bytes.NewBuffer(reqBody))
+	req = setupTestRequest("PUT", url, bytes.NewBufferString(`{"name": " Renamed  Config "}`))
 	req.Header.Set("Content-Type", "application/json")
-
-	rr := httptest.NewRecorder()
+	rr = httptest.NewRecorder()
 	server.router.ServeHTTP(rr, req)
 
-	if status := rr.Code; status != http.StatusBadRequest {
-		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	var updated models.PreservationConfig
+	if err := json.Unmarshal(rr.Body.Bytes(), &updated); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if updated.Name != "Renamed Config" {
+		t.Errorf("Expected name %q, got %q", "Renamed Config", updated.Name)
 	}
 }
 
-func TestServer_HandleUpdateConfig_NoFieldsProvided(t *testing.T) {
+func TestServer_WrongTypedFields(t *testing.T) {
 	server := setupTestServer(t)
 	defer server.Shutdown()
 
-	// First create a config
 	config := models.NewPreservationConfig(testOriginalName, testOriginalDesc)
-	err := server.db.CreateConfig(config)
-	if err != nil {
+	if err := server.db.CreateConfig(config); err != nil {
 		t.Fatalf("Failed to create test config: %v", err)
 	}
+	url := fmt.Sprintf("/api/v1/preservation-configs/%d", config.ID)
+
+	tests := []struct {
+		name  string
+		body  string
+		field string
+	}{
+		{name: "name", body: `{"name": 5}`, field: "name"},
+		{name: "description", body: `{"description": ["text"]}`, field: "description"},
+		{name: "compress_aip", body: `{"compress_aip": "true"}`, field: "compress_aip"},
+		{name: "active", body: `{"active": "no"}`, field: "active"},
+		{name: "tags", body: `{"tags": "archive"}`, field: "tags"},
+		{name: "a3m_config", body: `{"a3m_config": []}`, field: "a3m_config"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Create and update report the same problem with the field
+			for _, method := range []string{"POST", "PUT"} {
+				path, body := "/api/v1/preservation-configs", tt.body
+				if method == "PUT" {
+					path = url
+				} else if tt.field != "name" {
+					body = `{"name": "Typed", ` + strings.TrimPrefix(body, "{")
+				}
+				req := setupTestRequest(method, path, bytes.NewBufferString(body))
+				req.Header.Set("Content-Type", "application/json")
+				rr := httptest.NewRecorder()
+				server.router.ServeHTTP(rr, req)
 
-	// Update with empty body (should succeed but not change anything)
-	updateReq := map[string]any{}
+				if rr.Code != http.StatusUnprocessableEntity {
+					t.Fatalf("%s: expected status %d, got %d: %s", method, http.StatusUnprocessableEntity, rr.Code, rr.Body.String())
+				}
+				var response validationResponse
+				if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+					t.Fatalf("Failed to unmarshal response: %v", err)
+				}
+				if len(response.Errors) != 1 || response.Errors[0].Field != tt.field {
+					t.Errorf("%s: expected a single error for %s, got %v", method, tt.field, response.Errors)
+				}
+			}
+		})
+	}
+
+	// The config is unchanged by the rejected updates
+	got, err := server.db.GetConfig(config.ID)
+	if err != nil {
+		t.Fatalf("Failed to get config: %v", err)
+	}
+	if got.Name != testOriginalName || got.Description != testOriginalDesc || got.Version != config.Version {
+		t.Errorf("Expected the config to be unchanged, got %+v", got)
+	}
+}
+
+func TestServer_HandleValidateConfig(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	before, err := server.db.CountConfigs()
+	if err != nil {
+		t.Fatalf("Failed to count configs: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedFields []string
+	}{
+		{
+			name:           "Valid config",
+			body:           `{"name": "Valid", "a3m_config": {"aip_compression_level": 5}}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Every problem is reported",
+			body:           `{"compress_aip": true, "a3m_config": {"aip_compression_level": 99, "aip_compression_algorithm": 1}}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedFields: []string{"name", "aip_compression_level", "compress_aip"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := setupTestRequest("POST", "/api/v1/preservation-configs/validate", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			server.router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+
+			var response validationResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if response.Valid != (tt.expectedStatus == http.StatusOK) {
+				t.Errorf("Expected valid %v, got %v", tt.expectedStatus == http.StatusOK, response.Valid)
+			}
+			fields := make([]string, 0, len(response.Errors))
+			for _, fieldErr := range response.Errors {
+				fields = append(fields, fieldErr.Field)
+			}
+			if fmt.Sprint(fields) != fmt.Sprint(tt.expectedFields) {
+				t.Errorf("Expected errors for fields %v, got %v", tt.expectedFields, response.Errors)
+			}
+		})
+	}
+
+	// Validation never writes to the database
+	after, err := server.db.CountConfigs()
+	if err != nil {
+		t.Fatalf("Failed to count configs: %v", err)
+	}
+	if after != before {
+		t.Errorf("Expected %d configs after validation, got %d", before, after)
+	}
+}
+
+func TestServer_HandleUpdateConfig_IDMismatch(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	// First create a config
+	config := models.NewPreservationConfig(testOriginalName, testOriginalDesc)
+	err := server.db.CreateConfig(config)
+	if err != nil {
+		t.Fatalf("Failed to create test config: %v", err)
+	}
+
+	// Update with mismatched ID in body
+	updateReq := map[string]any{
+		"id":          999, // Different from URL
+		"name":        "Updated Name",
+		"description": "Updated Description",
+	}
+
+	reqBody, err := json.Marshal(updateReq)
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %v", err)
+	}
+
+	req := setupTestRequest("PUT", fmt.Sprintf("/api/v1/preservation-configs/%d", config.ID), bytes.NewBuffer(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+}
+
+func TestServer_HandleUpdateConfig_NoFieldsProvided(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	// First create a config
+	config := models.NewPreservationConfig(testOriginalName, testOriginalDesc)
+	err := server.db.CreateConfig(config)
+	if err != nil {
+		t.Fatalf("Failed to create test config: %v", err)
+	}
+
+	// Update with empty body (should succeed but not change anything)
+	updateReq := map[string]any{}
 
 	reqBody, err := json.Marshal(updateReq)
 	if err != nil {
@@ -1113,3 +1915,1779 @@ func TestServer_HandleUpdateConfig_NoFieldsProvided(t *testing.T) {
 		t.Errorf("Expected description to remain '%s', got '%s'", testOriginalDesc, updatedConfig.Description)
 	}
 }
+
+func TestServer_HandleBulkCreateConfigs(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	body := `[
+		{"name": "Bulk 1", "description": "First"},
+		{"name": "Bulk 2", "compress_aip": true, "a3m_config": {"aip_compression_level": 5}}
+	]`
+	req := setupTestRequest("POST", "/api/v1/preservation-configs/bulk", bytes.NewBufferString(body))
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	var created []models.PreservationConfig
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if len(created) != 2 {
+		t.Fatalf("Expected 2 configs, got %d", len(created))
+	}
+	if created[0].ID == 0 || created[1].ID == 0 {
+		t.Error("Expected created configs to have IDs")
+	}
+	if !created[1].CompressAIP || created[1].A3MConfig.AipCompressionLevel != 5 {
+		t.Errorf("Expected second config fields to be applied, got compress_aip=%v level=%d", created[1].CompressAIP, created[1].A3MConfig.AipCompressionLevel)
+	}
+}
+
+func TestServer_HandleBulkCreateConfigs_RejectsInvalidBatch(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	tests := []struct {
+		name          string
+		body          string
+		expectedIndex float64
+	}{
+		{name: "Missing name", body: `[{"name": "Valid"}, {"description": "No name"}]`, expectedIndex: 1},
+		{name: "Compression level out of range", body: `[{"name": "Bad", "a3m_config": {"aip_compression_level": 999}}]`, expectedIndex: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := setupTestRequest("POST", "/api/v1/preservation-configs/bulk", bytes.NewBufferString(tt.body))
+			rr := httptest.NewRecorder()
+			server.router.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusBadRequest {
+				t.Fatalf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+			}
+
+			var response map[string]any
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if response["index"] != tt.expectedIndex {
+				t.Errorf("Expected index %v, got %v", tt.expectedIndex, response["index"])
+			}
+		})
+	}
+
+	// Nothing from the rejected batches should have been stored
+	configs, err := server.db.ListConfigs()
+	if err != nil {
+		t.Fatalf("ListConfigs failed: %v", err)
+	}
+	if len(configs) != 1 {
+		t.Errorf("Expected only the default config, got %d", len(configs))
+	}
+}
+
+func TestServer_HandleExportConfigs_RoundTrip(t *testing.T) {
+	source := setupTestServer(t)
+	defer source.Shutdown()
+
+	config := models.NewPreservationConfig("Exported", "Exported description")
+	config.CompressAIP = true
+	config.A3MConfig.AipCompressionLevel = 7
+	if err := source.db.CreateConfig(config); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+
+	req := setupTestRequest("GET", "/api/v1/preservation-configs/export", nil)
+	rr := httptest.NewRecorder()
+	source.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if disposition := rr.Header().Get("Content-Disposition"); disposition != "attachment; filename=preservation-configs.json" {
+		t.Errorf("Unexpected Content-Disposition: %s", disposition)
+	}
+
+	var bundle map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &bundle); err != nil {
+		t.Fatalf("Failed to unmarshal bundle: %v", err)
+	}
+	if bundle["schema_version"] != float64(models.ConfigBundleSchemaVersion) {
+		t.Errorf("Expected schema version %d, got %v", models.ConfigBundleSchemaVersion, bundle["schema_version"])
+	}
+	entries, ok := bundle["configs"].([]any)
+	if !ok || len(entries) != 2 {
+		t.Fatalf("Expected 2 exported configs, got %v", bundle["configs"])
+	}
+	for _, entry := range entries {
+		fields := entry.(map[string]any)
+		for _, omitted := range []string{"id", "created_at", "updated_at"} {
+			if _, exists := fields[omitted]; exists {
+				t.Errorf("Expected exported config to omit %s", omitted)
+			}
+		}
+	}
+
+	// Importing the exported configs elsewhere reproduces the config set
+	target := setupTestServer(t)
+	defer target.Shutdown()
+
+	configsJSON, err := json.Marshal(bundle["configs"])
+	if err != nil {
+		t.Fatalf("Failed to marshal configs: %v", err)
+	}
+	req = setupTestRequest("POST", "/api/v1/preservation-configs/bulk", bytes.NewBuffer(configsJSON))
+	rr = httptest.NewRecorder()
+	target.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	var imported []*models.PreservationConfig
+	if err := json.Unmarshal(rr.Body.Bytes(), &imported); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	last := imported[len(imported)-1]
+	if last.Name != "Exported" || !last.CompressAIP || last.A3MConfig.AipCompressionLevel != 7 {
+		t.Errorf("Expected exported config to round-trip, got name=%s compress_aip=%v level=%d",
+			last.Name, last.CompressAIP, last.A3MConfig.AipCompressionLevel)
+	}
+}
+
+func TestServer_HandleExportConfigs_Streamed(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	export := func() *httptest.ResponseRecorder {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, setupTestRequest("GET", "/api/v1/preservation-configs/export", nil))
+		return rr
+	}
+	exportedNames := func(rr *httptest.ResponseRecorder) []string {
+		t.Helper()
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		if contentType := rr.Header().Get("Content-Type"); contentType != "application/json" {
+			t.Errorf("Expected Content-Type application/json, got %s", contentType)
+		}
+		var bundle models.ConfigBundle
+		if err := json.Unmarshal(rr.Body.Bytes(), &bundle); err != nil {
+			t.Fatalf("Failed to unmarshal bundle: %v", err)
+		}
+		if bundle.SchemaVersion != models.ConfigBundleSchemaVersion || bundle.ExportedAt.IsZero() || bundle.Configs == nil {
+			t.Errorf("Expected a stamped bundle with a configs array, got %+v", bundle)
+		}
+		names := []string{}
+		for _, entry := range bundle.Configs {
+			names = append(names, entry.Name)
+		}
+		return names
+	}
+
+	want := []string{"Default Configuration"}
+	var configs []*models.PreservationConfig
+	for i := range 150 {
+		config := models.NewPreservationConfig(fmt.Sprintf("Config %03d", i), "")
+		configs = append(configs, config)
+		want = append(want, config.Name)
+	}
+	if err := server.db.CreateConfigs(configs); err != nil {
+		t.Fatalf("Failed to create configs: %v", err)
+	}
+	if got := exportedNames(export()); !slices.Equal(got, want) {
+		t.Errorf("Expected all %d configs in id order, got %d: %v", len(want), len(got), got)
+	}
+
+	// With no configs the bundle is still complete
+	ids := []int64{1}
+	for _, config := range configs {
+		ids = append(ids, config.ID)
+	}
+	if _, _, err := server.db.DeleteConfigs(ids); err != nil {
+		t.Fatalf("Failed to delete configs: %v", err)
+	}
+	if got := exportedNames(export()); len(got) != 0 {
+		t.Errorf("Expected an empty export, got %v", got)
+	}
+
+	// A query that fails before anything is sent still gets an error response
+	server.db.Close()
+	rr := export()
+	if rr.Code != http.StatusInternalServerError || rr.Header().Get("Content-Disposition") != "" {
+		t.Errorf("Expected status 500 without an attachment, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestServer_HandleImportConfigs(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	existing := models.NewPreservationConfig("Existing", "Before import")
+	if err := server.db.CreateConfig(existing); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+
+	bundle := `{
+		"schema_version": 1,
+		"exported_at": "2025-01-01T00:00:00Z",
+		"configs": [
+			{"name": "Existing", "description": "After import"},
+			{"name": "New", "description": "Imported"}
+		]
+	}`
+
+	doImport := func(query string) (int, importSummary) {
+		req := setupTestRequest("POST", "/api/v1/preservation-configs/import"+query, bytes.NewBufferString(bundle))
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+
+		var summary importSummary
+		if err := json.Unmarshal(rr.Body.Bytes(), &summary); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		return rr.Code, summary
+	}
+
+	// Default mode creates new names and skips existing ones
+	code, summary := doImport("")
+	if code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, code)
+	}
+	if len(summary.Created) != 1 || summary.Created[0].Name != "New" {
+		t.Errorf("Expected New to be created, got %+v", summary.Created)
+	}
+	if len(summary.Skipped) != 1 || summary.Skipped[0].ID != existing.ID {
+		t.Errorf("Expected Existing to be skipped, got %+v", summary.Skipped)
+	}
+	unchanged, err := server.db.GetConfig(existing.ID)
+	if err != nil {
+		t.Fatalf("Failed to get config: %v", err)
+	}
+	if unchanged.Description != "Before import" {
+		t.Errorf("Expected skipped config to be unchanged, got description %q", unchanged.Description)
+	}
+
+	// Upsert mode overwrites both, now that New exists too
+	code, summary = doImport("?mode=upsert")
+	if code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, code)
+	}
+	if len(summary.Updated) != 2 || len(summary.Created) != 0 {
+		t.Errorf("Expected 2 updated and 0 created, got %+v", summary)
+	}
+	updated, err := server.db.GetConfig(existing.ID)
+	if err != nil {
+		t.Fatalf("Failed to get config: %v", err)
+	}
+	if updated.Description != "After import" {
+		t.Errorf("Expected upserted description, got %q", updated.Description)
+	}
+}
+
+func TestServer_HandleImportConfigs_Rejected(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	tests := []struct {
+		name  string
+		query string
+		body  string
+	}{
+		{name: "Unknown schema version", body: `{"schema_version": 99, "configs": [{"name": "A"}]}`},
+		{name: "Missing schema version", body: `{"configs": [{"name": "A"}]}`},
+		{name: "Invalid mode", query: "?mode=replace", body: `{"schema_version": 1, "configs": [{"name": "A"}]}`},
+		{name: "Invalid entry", body: `{"schema_version": 1, "configs": [{"name": "A"}, {"description": "No name"}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := setupTestRequest("POST", "/api/v1/preservation-configs/import"+tt.query, bytes.NewBufferString(tt.body))
+			rr := httptest.NewRecorder()
+			server.router.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+			}
+		})
+	}
+
+	// None of the rejected bundles should have been applied
+	configs, err := server.db.ListConfigs()
+	if err != nil {
+		t.Fatalf("ListConfigs failed: %v", err)
+	}
+	if len(configs) != 1 {
+		t.Errorf("Expected only the default config, got %d", len(configs))
+	}
+}
+
+func TestServer_YAMLRequestsAndResponses(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	body := `
+name: YAML Config
+description: Authored in YAML
+compress_aip: true
+a3m_config:
+  aip_compression_level: 3
+  normalize: false
+`
+	req := setupTestRequest("POST", "/api/v1/preservation-configs", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/yaml")
+	req.Header.Set("Accept", "application/yaml")
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	if contentType := rr.Header().Get("Content-Type"); contentType != "application/yaml" {
+		t.Errorf("Expected YAML content type, got %s", contentType)
+	}
+
+	var created map[string]any
+	if err := yaml.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to unmarshal YAML response: %v", err)
+	}
+	a3m := created["a3m_config"].(map[string]any)
+	if created["name"] != "YAML Config" || created["compress_aip"] != true ||
+		a3m["aipCompressionLevel"] != 3 || a3m["normalize"] != false {
+		t.Errorf("Unexpected YAML response: %v", created)
+	}
+
+	// Export as YAML and import it back into a fresh server
+	req = setupTestRequest("GET", "/api/v1/preservation-configs/export", nil)
+	req.Header.Set("Accept", "application/yaml")
+	rr = httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if disposition := rr.Header().Get("Content-Disposition"); disposition != "attachment; filename=preservation-configs.yaml" {
+		t.Errorf("Unexpected Content-Disposition: %s", disposition)
+	}
+
+	target := setupTestServer(t)
+	defer target.Shutdown()
+
+	req = setupTestRequest("POST", "/api/v1/preservation-configs/import", bytes.NewBuffer(rr.Body.Bytes()))
+	req.Header.Set("Content-Type", "application/yaml")
+	rr = httptest.NewRecorder()
+	target.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var summary importSummary
+	if err := json.Unmarshal(rr.Body.Bytes(), &summary); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if len(summary.Created) != 1 || summary.Created[0].Name != "YAML Config" {
+		t.Fatalf("Expected YAML Config to be created, got %+v", summary)
+	}
+
+	imported, err := target.db.GetConfig(summary.Created[0].ID)
+	if err != nil {
+		t.Fatalf("Failed to get imported config: %v", err)
+	}
+	if !imported.CompressAIP || imported.A3MConfig.AipCompressionLevel != 3 || imported.A3MConfig.Normalize {
+		t.Errorf("Expected YAML config to round-trip, got compress_aip=%v level=%d normalize=%v",
+			imported.CompressAIP, imported.A3MConfig.AipCompressionLevel, imported.A3MConfig.Normalize)
+	}
+}
+
+func TestWantsYAML(t *testing.T) {
+	tests := []struct {
+		accept   string
+		expected bool
+	}{
+		{accept: "", expected: false},
+		{accept: "application/json", expected: false},
+		{accept: "application/yaml", expected: true},
+		{accept: "text/yaml; charset=utf-8", expected: true},
+		{accept: "application/json, application/yaml", expected: false},
+		{accept: "text/html, application/x-yaml;q=0.9", expected: true},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", tt.accept)
+		if got := wantsYAML(req); got != tt.expected {
+			t.Errorf("wantsYAML(%q) = %v, expected %v", tt.accept, got, tt.expected)
+		}
+	}
+}
+
+func TestWantsProtobuf(t *testing.T) {
+	tests := []struct {
+		accept   string
+		expected bool
+	}{
+		{accept: "", expected: false},
+		{accept: "application/json", expected: false},
+		{accept: "application/x-protobuf", expected: true},
+		{accept: "application/protobuf", expected: true},
+		{accept: "application/json, application/x-protobuf", expected: false},
+		{accept: "application/yaml, application/x-protobuf", expected: false},
+		{accept: "text/html, application/x-protobuf;q=0.9", expected: true},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", tt.accept)
+		if got := wantsProtobuf(req); got != tt.expected {
+			t.Errorf("wantsProtobuf(%q) = %v, expected %v", tt.accept, got, tt.expected)
+		}
+	}
+}
+
+func TestServer_ConfigSchema(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	req := setupTestRequest("GET", "/api/v1/preservation-configs/schema", nil)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var schema struct {
+		Schema     string   `json:"$schema"`
+		Required   []string `json:"required"`
+		Properties struct {
+			Name struct {
+				MaxLength int `json:"maxLength"`
+			} `json:"name"`
+			A3MConfig struct {
+				Properties map[string]struct {
+					Type      string   `json:"type"`
+					Enum      []int    `json:"enum"`
+					EnumNames []string `json:"x-enumNames"`
+					Minimum   *int     `json:"minimum"`
+					Maximum   *int     `json:"maximum"`
+					Default   any      `json:"default"`
+				} `json:"properties"`
+			} `json:"a3m_config"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &schema); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if schema.Schema != models.JSONSchemaDialect {
+		t.Errorf("Expected $schema %q, got %q", models.JSONSchemaDialect, schema.Schema)
+	}
+	if len(schema.Required) != 1 || schema.Required[0] != "name" {
+		t.Errorf("Expected only name to be required, got %v", schema.Required)
+	}
+	if schema.Properties.Name.MaxLength != models.DefaultMaxNameLength {
+		t.Errorf("Expected name maxLength %d, got %d", models.DefaultMaxNameLength, schema.Properties.Name.MaxLength)
+	}
+
+	a3m := schema.Properties.A3MConfig.Properties
+	if len(a3m) != 17 {
+		t.Errorf("Expected 17 a3m_config properties, got %d", len(a3m))
+	}
+	if normalize := a3m["normalize"]; normalize.Type != "boolean" || normalize.Default != true {
+		t.Errorf("Expected normalize to be a boolean defaulting to true, got %+v", normalize)
+	}
+	level := a3m["aip_compression_level"]
+	if level.Minimum == nil || *level.Minimum != 0 || level.Maximum == nil || *level.Maximum != 9 {
+		t.Errorf("Expected aip_compression_level to range from 0 to 9, got %+v", level)
+	}
+	thumbnails := a3m["thumbnail_mode"]
+	if len(thumbnails.Enum) != 4 || len(thumbnails.EnumNames) != 4 || thumbnails.EnumNames[3] != "THUMBNAIL_MODE_DO_NOT_GENERATE" {
+		t.Errorf("Expected the four thumbnail modes, got %+v", thumbnails)
+	}
+	if algorithms := a3m["aip_compression_algorithm"]; len(algorithms.Enum) != 8 || algorithms.Default != float64(6) {
+		t.Errorf("Expected eight compression algorithms defaulting to S7_BZIP2, got %+v", algorithms)
+	}
+}
+
+func TestServer_Presets(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	req := setupTestRequest("GET", "/api/v1/presets", nil)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	var presets []map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &presets); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if len(presets) != len(models.PresetNames()) {
+		t.Errorf("Expected %d presets, got %d", len(models.PresetNames()), len(presets))
+	}
+
+	// Create from a preset, with the body overriding one of its settings
+	body := `{"name": "Minimal", "a3m_config": {"transcribe_files": true}}`
+	req = setupTestRequest("POST", "/api/v1/preservation-configs?preset=minimal", bytes.NewBufferString(body))
+	rr = httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	var created *models.PreservationConfig
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if created.A3MConfig.Normalize {
+		t.Error("Expected normalize to come from the minimal preset")
+	}
+	if !created.A3MConfig.TranscribeFiles {
+		t.Error("Expected transcribe_files to be overridden by the body")
+	}
+
+	req = setupTestRequest("POST", "/api/v1/preservation-configs?preset=unknown", bytes.NewBufferString(body))
+	rr = httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for unknown preset, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestServer_RejectsCompressionWithCopyAlgorithm(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	body := `{"name": "Copy", "compress_aip": true, "a3m_config": {"aip_compression_algorithm": 5}}`
+	req := setupTestRequest("POST", "/api/v1/preservation-configs", bytes.NewBufferString(body))
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected status %d, got %d", http.StatusUnprocessableEntity, rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), "does not compress") {
+		t.Errorf("Expected conflict to be explained, got %s", rr.Body.String())
+	}
+
+	// Turning compression on for an existing config that uses the copy algorithm is rejected too
+	config := models.NewPreservationConfig("Copy", "")
+	config.A3MConfig.AipCompressionAlgorithm = 5
+	if err := server.db.CreateConfig(config); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+	req = setupTestRequest("PUT", fmt.Sprintf("/api/v1/preservation-configs/%d", config.ID), bytes.NewBufferString(`{"compress_aip": true}`))
+	rr = httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status %d, got %d", http.StatusUnprocessableEntity, rr.Code)
+	}
+}
+
+func TestServer_HandleDiffConfigs(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	first := models.NewPreservationConfig("First", "Same description")
+	second := models.NewPreservationConfig("Second", "Same description")
+	second.A3MConfig.Normalize = false
+	for _, config := range []*models.PreservationConfig{first, second} {
+		if err := server.db.CreateConfig(config); err != nil {
+			t.Fatalf("Failed to create config: %v", err)
+		}
+	}
+
+	req := setupTestRequest("GET", fmt.Sprintf("/api/v1/preservation-configs/%d/diff/%d", first.ID, second.ID), nil)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var response struct {
+		Differences map[string]struct {
+			Old any `json:"old"`
+			New any `json:"new"`
+		} `json:"differences"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if len(response.Differences) != 2 {
+		t.Errorf("Expected 2 differences, got %v", response.Differences)
+	}
+	if d := response.Differences["name"]; d.Old != "First" || d.New != "Second" {
+		t.Errorf("Unexpected name difference: %+v", d)
+	}
+	if d := response.Differences["a3m_config.normalize"]; d.Old != true || d.New != false {
+		t.Errorf("Unexpected normalize difference: %+v", d)
+	}
+
+	req = setupTestRequest("GET", fmt.Sprintf("/api/v1/preservation-configs/%d/diff/99999", first.ID), nil)
+	rr = httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestServer_HandleUpdateConfig_ReturnChanges(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	config := models.NewPreservationConfig("Before", "Unchanged")
+	if err := server.db.CreateConfig(config); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+	url := fmt.Sprintf("/api/v1/preservation-configs/%d?return=changes", config.ID)
+
+	body := `{"name": "After", "description": "Unchanged", "a3m_config": {"normalize": false}}`
+	req := setupTestRequest("PUT", url, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("ETag") == "" {
+		t.Error("Expected an ETag for the updated config")
+	}
+
+	var response struct {
+		Changed map[string]struct {
+			Old any `json:"old"`
+			New any `json:"new"`
+		} `json:"changed"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if len(response.Changed) != 2 {
+		t.Errorf("Expected 2 changed fields, got %v", response.Changed)
+	}
+	if c := response.Changed["name"]; c.Old != "Before" || c.New != "After" {
+		t.Errorf("Unexpected name change: %+v", c)
+	}
+	if c := response.Changed["a3m_config.normalize"]; c.Old != true || c.New != false {
+		t.Errorf("Unexpected normalize change: %+v", c)
+	}
+
+	// Repeating the update changes nothing
+	req = setupTestRequest("PUT", url, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr = httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if got := strings.TrimSpace(rr.Body.String()); got != `{"changed":{}}` {
+		t.Errorf("Expected no changes, got %s", got)
+	}
+
+	req = setupTestRequest("PUT", fmt.Sprintf("/api/v1/preservation-configs/%d?return=everything", config.ID), bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr = httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an unknown return, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestServer_HandleUpdateConfig_VersionConflict(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	config := models.NewPreservationConfig("Versioned", "")
+	if err := server.db.CreateConfig(config); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+	url := fmt.Sprintf("/api/v1/preservation-configs/%d", config.ID)
+
+	tests := []struct {
+		name            string
+		ifMatch         string
+		body            string
+		expectedStatus  int
+		expectedVersion int64
+	}{
+		{name: "Matching If-Match", ifMatch: `"1"`, body: `{"description": "first"}`, expectedStatus: http.StatusOK, expectedVersion: 2},
+		{name: "Stale If-Match", ifMatch: `"1"`, body: `{"description": "stale"}`, expectedStatus: http.StatusConflict},
+		{name: "Matching body version", body: `{"description": "second", "version": 2}`, expectedStatus: http.StatusOK, expectedVersion: 3},
+		{name: "Stale body version", body: `{"description": "stale", "version": 2}`, expectedStatus: http.StatusConflict},
+		{name: "Invalid If-Match", ifMatch: `"abc"`, body: `{"description": "bad"}`, expectedStatus: http.StatusBadRequest},
+		{name: "No version", body: `{"description": "any"}`, expectedStatus: http.StatusOK, expectedVersion: 4},
+		{name: "If-Match any version", ifMatch: "*", body: `{"description": "unconditional"}`, expectedStatus: http.StatusOK, expectedVersion: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := setupTestRequest("PUT", url, bytes.NewBufferString(tt.body))
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			rr := httptest.NewRecorder()
+			server.router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var updated *models.PreservationConfig
+			if err := json.Unmarshal(rr.Body.Bytes(), &updated); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if updated.Version != tt.expectedVersion {
+				t.Errorf("Expected version %d, got %d", tt.expectedVersion, updated.Version)
+			}
+		})
+	}
+
+	final, err := server.db.GetConfig(config.ID)
+	if err != nil {
+		t.Fatalf("Failed to get config: %v", err)
+	}
+	if final.Description != "unconditional" || final.Version != 5 {
+		t.Errorf("Expected stale updates to be rejected, got description %q version %d", final.Description, final.Version)
+	}
+}
+
+func TestServer_HandleGetConfig_ETag(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	config := models.NewPreservationConfig("Cached", "")
+	if err := server.db.CreateConfig(config); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+	url := fmt.Sprintf("/api/v1/preservation-configs/%d", config.ID)
+
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := setupTestRequest("GET", url, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	first := get("")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("Expected 200 with an ETag, got %d and %q", first.Code, etag)
+	}
+	if again := get("").Header().Get("ETag"); again != etag {
+		t.Errorf("Expected ETag to be stable across identical GETs, got %q and %q", etag, again)
+	}
+
+	notModified := get(etag)
+	if notModified.Code != http.StatusNotModified {
+		t.Errorf("Expected status %d, got %d", http.StatusNotModified, notModified.Code)
+	}
+	if notModified.Body.Len() != 0 {
+		t.Errorf("Expected empty body on 304, got %q", notModified.Body.String())
+	}
+
+	// Any change produces a new ETag
+	req := setupTestRequest("PUT", url, bytes.NewBufferString(`{"description": "changed"}`))
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	modified := get(etag)
+	if modified.Code != http.StatusOK {
+		t.Errorf("Expected status %d after modification, got %d", http.StatusOK, modified.Code)
+	}
+	if modified.Header().Get("ETag") == etag {
+		t.Error("Expected ETag to change after modification")
+	}
+}
+
+func TestNew_InvalidCORSOrigin(t *testing.T) {
+	_, err := New(config.Config{
+		DBType:       testDBType,
+		DBConnection: filepath.Join(t.TempDir(), "test.db"),
+		CORSOrigins:  []string{"https://cells.example.com", "cells.example.com"},
+	})
+	if err == nil {
+		t.Fatal("Expected error for CORS origin without a scheme")
+	}
+	if !strings.Contains(err.Error(), "cells.example.com") {
+		t.Errorf("Expected error to name the malformed origin, got %v", err)
+	}
+}
+
+func TestNew_InvalidCORSHeader(t *testing.T) {
+	_, err := New(config.Config{
+		DBType:       testDBType,
+		DBConnection: filepath.Join(t.TempDir(), "test.db"),
+		CORSHeaders:  []string{"X-Request-Id", "X Request"},
+	})
+	if err == nil {
+		t.Fatal("Expected error for a CORS header with a space")
+	}
+	if !strings.Contains(err.Error(), "X Request") {
+		t.Errorf("Expected error to name the malformed header, got %v", err)
+	}
+}
+
+// corsPreflight sends a preflight request from origin asking to send header
+func corsPreflight(server *Server, origin, header string) *httptest.ResponseRecorder {
+	req := setupTestRequest("OPTIONS", "/api/v1/preservation-configs", nil)
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	req.Header.Set("Access-Control-Request-Headers", header)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestServer_CORS_Configured(t *testing.T) {
+	server, err := New(config.Config{
+		DBType:             testDBType,
+		DBConnection:       filepath.Join(t.TempDir(), "test.db"),
+		CORSOrigins:        []string{"https://cells.example.com"},
+		CORSHeaders:        []string{"Content-Type", "X-Request-Id"},
+		CORSExposedHeaders: []string{"X-Request-Id"},
+		CORSMaxAge:         time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer server.Shutdown()
+
+	rr := corsPreflight(server, "https://cells.example.com", "X-Request-Id")
+	if got := rr.Header().Get("Access-Control-Allow-Headers"); got != "X-Request-Id" {
+		t.Errorf("Expected the configured header to be allowed, got %q", got)
+	}
+	if got := rr.Header().Get("Access-Control-Max-Age"); got != "3600" {
+		t.Errorf("Expected max age 3600, got %q", got)
+	}
+	if got := rr.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+		t.Errorf("Expected credentials to be allowed, got %q", got)
+	}
+
+	rr = corsPreflight(server, "https://cells.example.com", "X-API-Key")
+	if got := rr.Header().Get("Access-Control-Allow-Headers"); got != "" {
+		t.Errorf("Expected a default header to be dropped when headers are configured, got %q", got)
+	}
+
+	req := setupTestRequest("GET", "/api/v1/health", nil)
+	req.Header.Set("Origin", "https://cells.example.com")
+	rr = httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	if got := rr.Header().Get("Access-Control-Expose-Headers"); got != "X-Request-Id" {
+		t.Errorf("Expected the configured exposed header, got %q", got)
+	}
+}
+
+func TestServer_CORS_AllowAll(t *testing.T) {
+	server, err := New(config.Config{
+		DBType:       testDBType,
+		DBConnection: filepath.Join(t.TempDir(), "test.db"),
+		CORSAllowAll: true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer server.Shutdown()
+
+	rr := corsPreflight(server, "http://dev.example.test:3000", "Content-Type")
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "http://dev.example.test:3000" {
+		t.Errorf("Expected the request origin to be reflected, got %q", got)
+	}
+	if got := rr.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("Expected credentials not to be allowed, got %q", got)
+	}
+	if got := rr.Header().Get("Access-Control-Max-Age"); got != "300" {
+		t.Errorf("Expected the default max age 300, got %q", got)
+	}
+}
+
+func TestServer_HandleListConfigs_Sorted(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	for _, name := range []string{"Bravo", "Alpha"} {
+		if err := server.db.CreateConfig(models.NewPreservationConfig(name, "")); err != nil {
+			t.Fatalf("Failed to create config %s: %v", name, err)
+		}
+	}
+
+	req := setupTestRequest("GET", "/api/v1/preservation-configs?sort=name&order=desc&limit=2", nil)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var configs []map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &configs); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if len(configs) != 2 {
+		t.Fatalf("Expected 2 configs, got %d", len(configs))
+	}
+	if configs[0]["name"] != "Default Configuration" || configs[1]["name"] != "Bravo" {
+		t.Errorf("Expected [Default Configuration Bravo], got [%v %v]", configs[0]["name"], configs[1]["name"])
+	}
+
+	for _, query := range []string{"sort=password", "sort=name&order=up", "limit=-1", "offset=abc"} {
+		req := setupTestRequest("GET", "/api/v1/preservation-configs?"+query, nil)
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", query, rr.Code)
+		}
+	}
+}
+
+func TestServer_HandleCountConfigs(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	for _, name := range []string{"One", "Two"} {
+		if err := server.db.CreateConfig(models.NewPreservationConfig(name, "")); err != nil {
+			t.Fatalf("Failed to create config %s: %v", name, err)
+		}
+	}
+
+	req := setupTestRequest("GET", "/api/v1/preservation-configs/count", nil)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var response map[string]int64
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if response["count"] != 3 {
+		t.Errorf("Expected count 3, got %d", response["count"])
+	}
+
+	// A page of the list still reports the overall total
+	req = setupTestRequest("GET", "/api/v1/preservation-configs?limit=1", nil)
+	rr = httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if got := rr.Header().Get("X-Total-Count"); got != "3" {
+		t.Errorf("Expected X-Total-Count 3, got '%s'", got)
+	}
+}
+
+func TestServer_HandleListConfigs_Filtered(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	unnormalized := models.NewPreservationConfig("Unnormalized", "")
+	unnormalized.A3MConfig.Normalize = false
+	if err := server.db.CreateConfig(unnormalized); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+
+	req := setupTestRequest("GET", "/api/v1/preservation-configs?normalize=false", nil)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var configs []map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &configs); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if len(configs) != 1 || configs[0]["name"] != "Unnormalized" {
+		t.Errorf("Expected only the unnormalized config, got %d configs", len(configs))
+	}
+	if got := rr.Header().Get("X-Total-Count"); got != "1" {
+		t.Errorf("Expected X-Total-Count 1, got '%s'", got)
+	}
+
+	req = setupTestRequest("GET", "/api/v1/preservation-configs?normalize=maybe", nil)
+	rr = httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a non-boolean filter, got %d", rr.Code)
+	}
+}
+
+func TestServer_ConfigTags(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		var req *http.Request
+		if body == "" {
+			req = setupTestRequest(method, path, nil)
+		} else {
+			req = setupTestRequest(method, path, bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+		}
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := send("POST", "/api/v1/preservation-configs", `{"name": "Legal", "tags": ["Legal", "media", "legal"]}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var created models.PreservationConfig
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if !slices.Equal(created.Tags, []string{"legal", "media"}) {
+		t.Errorf("Expected normalized tags [legal media], got %v", created.Tags)
+	}
+
+	rr = send("GET", "/api/v1/preservation-configs?tag=LEGAL", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var configs []models.PreservationConfig
+	if err := json.Unmarshal(rr.Body.Bytes(), &configs); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if len(configs) != 1 || configs[0].ID != created.ID {
+		t.Errorf("Expected only the legal config, got %d configs", len(configs))
+	}
+	if got := rr.Header().Get("X-Total-Count"); got != "1" {
+		t.Errorf("Expected X-Total-Count 1, got '%s'", got)
+	}
+
+	// Updating the tags replaces them
+	path := fmt.Sprintf("/api/v1/preservation-configs/%d", created.ID)
+	if rr := send("PUT", path, `{"tags": ["internal"]}`); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"tags":["internal"]`) {
+		t.Errorf("Expected the tags to be replaced, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := send("PUT", path, `{"tags": "legal"}`); rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422 for tags that aren't an array, got %d", rr.Code)
+	}
+	if rr := send("POST", "/api/v1/preservation-configs", `{"name": "Bad", "tags": ["two words"]}`); rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422 for an invalid tag, got %d", rr.Code)
+	}
+	if rr := send("GET", "/api/v1/preservation-configs?tag=", ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an empty tag filter, got %d", rr.Code)
+	}
+}
+
+func TestServer_HandleListConfigs_DateRange(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	if err := server.db.CreateConfig(models.NewPreservationConfig("Second", "")); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+	yesterday := time.Now().UTC().AddDate(0, 0, -1)
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1)
+
+	tests := []struct {
+		query      string
+		wantStatus int
+		wantCount  string
+	}{
+		{"created_after=" + yesterday.Format(time.DateOnly), http.StatusOK, "2"},
+		{"created_after=" + yesterday.Format(time.RFC3339) + "&created_before=" + tomorrow.Format(time.RFC3339), http.StatusOK, "2"},
+		{"created_before=" + yesterday.Format(time.DateOnly), http.StatusOK, "0"},
+		{"created_after=" + tomorrow.Format(time.DateOnly) + "&limit=1", http.StatusOK, "0"},
+		{"created_after=January", http.StatusBadRequest, ""},
+		{"created_before=2024-02-30", http.StatusBadRequest, ""},
+		{"created_after=2024-02-01&created_before=2024-01-01", http.StatusBadRequest, ""},
+		{"created_after=2024-01-01&created_before=2024-01-01", http.StatusBadRequest, ""},
+		{"created_after=2024-01-01&after_id=0", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			req := setupTestRequest("GET", "/api/v1/preservation-configs?"+tt.query, nil)
+			rr := httptest.NewRecorder()
+			server.router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+			if got := rr.Header().Get("X-Total-Count"); got != tt.wantCount {
+				t.Errorf("Expected X-Total-Count '%s', got '%s'", tt.wantCount, got)
+			}
+		})
+	}
+}
+
+func TestServer_HandleListConfigs_Envelope(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	// Along with the seeded default config, this makes 3 configs
+	for _, name := range []string{"Second", "Third"} {
+		if err := server.db.CreateConfig(models.NewPreservationConfig(name, "")); err != nil {
+			t.Fatalf("Failed to create config %s: %v", name, err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		count int
+		want  pagination
+	}{
+		{"envelope=true", 3, pagination{Total: 3}},
+		{"envelope=true&limit=2", 2, pagination{Total: 3, Limit: 2, HasMore: true}},
+		{"envelope=true&limit=2&offset=1", 2, pagination{Total: 3, Limit: 2, Offset: 1}},
+		{"envelope=true&offset=5", 0, pagination{Total: 3, Offset: 5}},
+		{"envelope=true&active=false", 0, pagination{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			req := setupTestRequest("GET", "/api/v1/preservation-configs?"+tt.query, nil)
+			rr := httptest.NewRecorder()
+			server.router.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+			}
+			var list configList
+			if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if list.Data == nil || len(list.Data) != tt.count {
+				t.Errorf("Expected %d configs in data, got %v", tt.count, list.Data)
+			}
+			if list.Pagination != tt.want {
+				t.Errorf("Expected pagination %+v, got %+v", tt.want, list.Pagination)
+			}
+		})
+	}
+
+	for _, query := range []string{"envelope=maybe", "envelope=true&after_id=0"} {
+		req := setupTestRequest("GET", "/api/v1/preservation-configs?"+query, nil)
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", query, rr.Code)
+		}
+	}
+}
+
+func TestServer_HandleListConfigs_Fields(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	list := func(query string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := setupTestRequest("GET", "/api/v1/preservation-configs?"+query, nil)
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		return rr
+	}
+	assertKeys := func(items []map[string]any, want ...string) {
+		t.Helper()
+		if len(items) == 0 {
+			t.Fatal("Expected at least one config")
+		}
+		for _, item := range items {
+			var keys []string
+			for key := range item {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			if fmt.Sprint(keys) != fmt.Sprint(want) {
+				t.Errorf("Expected keys %v, got %v", want, keys)
+			}
+		}
+	}
+
+	rr := list("fields=name,id,updated_at,id")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var configs []map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &configs); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	assertKeys(configs, "id", "name", "updated_at")
+	if configs[0]["id"] != float64(1) || configs[0]["name"] != "Default Configuration" {
+		t.Errorf("Expected the default config's id and name, got %v", configs[0])
+	}
+
+	// The envelope and cursor pages select fields of their items only
+	var envelope struct {
+		Data       []map[string]any `json:"data"`
+		Pagination map[string]any   `json:"pagination"`
+	}
+	if err := json.Unmarshal(list("fields=a3m_config&envelope=true").Body.Bytes(), &envelope); err != nil {
+		t.Fatalf("Failed to unmarshal envelope: %v", err)
+	}
+	assertKeys(envelope.Data, "a3m_config")
+	if envelope.Pagination["total"] != float64(1) {
+		t.Errorf("Expected the pagination to be kept, got %v", envelope.Pagination)
+	}
+
+	var page struct {
+		Items      []map[string]any `json:"items"`
+		NextCursor any              `json:"next_cursor"`
+	}
+	if err := json.Unmarshal(list("fields=id&after_id=0").Body.Bytes(), &page); err != nil {
+		t.Fatalf("Failed to unmarshal page: %v", err)
+	}
+	assertKeys(page.Items, "id")
+
+	for _, query := range []string{"fields=id,password", "fields=id,", "fields=A3M_CONFIG"} {
+		if rr := list(query); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", query, rr.Code)
+		}
+	}
+}
+
+func TestServer_HandleListConfigs_Cursor(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	// Along with the seeded default config, this makes IDs 1 to 5
+	for _, name := range []string{"Second", "Third", "Fourth", "Fifth"} {
+		if err := server.db.CreateConfig(models.NewPreservationConfig(name, "")); err != nil {
+			t.Fatalf("Failed to create config %s: %v", name, err)
+		}
+	}
+
+	fetchPage := func(url string) configPage {
+		t.Helper()
+		req := setupTestRequest("GET", url, nil)
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var page configPage
+		if err := json.Unmarshal(rr.Body.Bytes(), &page); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		return page
+	}
+
+	// Walk every page, following next_cursor until it is null
+	var ids []int64
+	url := "/api/v1/preservation-configs?after_id=0&limit=2"
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("Expected the cursor to reach the last page")
+		}
+		page := fetchPage(url)
+		for _, config := range page.Items {
+			ids = append(ids, config.ID)
+		}
+		if page.NextCursor == nil {
+			break
+		}
+		url = fmt.Sprintf("/api/v1/preservation-configs?after_id=%d&limit=2", *page.NextCursor)
+	}
+	if fmt.Sprint(ids) != "[1 2 3 4 5]" {
+		t.Errorf("Expected IDs [1 2 3 4 5], got %v", ids)
+	}
+
+	// A full final page has no next cursor, and a cursor past the end gives an empty page
+	if page := fetchPage("/api/v1/preservation-configs?after_id=3&limit=2"); len(page.Items) != 2 || page.NextCursor != nil {
+		t.Errorf("Expected 2 configs and no next cursor, got %d configs and cursor %v", len(page.Items), page.NextCursor)
+	}
+	if page := fetchPage("/api/v1/preservation-configs?after_id=5"); page.Items == nil || len(page.Items) != 0 {
+		t.Errorf("Expected an empty page, got %v", page.Items)
+	}
+
+	for _, url := range []string{
+		"/api/v1/preservation-configs?after_id=abc",
+		"/api/v1/preservation-configs?after_id=-1",
+		"/api/v1/preservation-configs?after_id=1&offset=2",
+		"/api/v1/preservation-configs?after_id=1&sort=name",
+		"/api/v1/preservation-configs?after_id=1&normalize=true",
+	} {
+		req := setupTestRequest("GET", url, nil)
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", url, rr.Code)
+		}
+	}
+}
+
+func TestServer_HandleReady(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	ready := func() int {
+		req := setupTestRequest("GET", "/api/v1/ready", nil)
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if code := ready(); code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", code)
+	}
+
+	req := setupTestRequest("GET", "/api/v1/ready", nil)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	var body readyResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if body.Migration == nil || !body.Migration.Current() || body.Migration.Version == 0 {
+		t.Errorf("Expected a current schema to be reported, got %+v", body.Migration)
+	}
+
+	// A migration that failed part way leaves the schema dirty and the server not ready
+	conn, err := sql.Open(testDBType, server.config.DBConnection)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Exec(`UPDATE schema_migrations SET dirty = 1`); err != nil {
+		t.Fatalf("Failed to mark the schema dirty: %v", err)
+	}
+	if code := ready(); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 with a dirty schema, got %d", code)
+	}
+	if _, err := conn.Exec(`UPDATE schema_migrations SET dirty = 0`); err != nil {
+		t.Fatalf("Failed to mark the schema clean: %v", err)
+	}
+
+	// Once the database is closed the server is no longer ready
+	if err := server.db.Close(); err != nil {
+		t.Fatalf("Failed to close database: %v", err)
+	}
+	if code := ready(); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 with the database closed, got %d", code)
+	}
+}
+
+func TestServer_HandleSearchConfigs(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	for _, name := range []string{"Photographs", "Audio recordings"} {
+		if err := server.db.CreateConfig(models.NewPreservationConfig(name, "")); err != nil {
+			t.Fatalf("Failed to create config %s: %v", name, err)
+		}
+	}
+	if err := server.db.CreateConfig(models.NewPreservationConfig("Scans", "Flatbed scanner output")); err != nil {
+		t.Fatalf("Failed to create config Scans: %v", err)
+	}
+
+	tests := []struct {
+		url      string
+		expected []string
+	}{
+		{"/api/v1/preservation-configs/search?q=graph", []string{"Photographs"}},
+		// Only the name is searched by default
+		{"/api/v1/preservation-configs/search?q=flatbed", []string{}},
+		{"/api/v1/preservation-configs/search?q=flatbed&in=name", []string{}},
+		{"/api/v1/preservation-configs/search?q=flatbed&in=description", []string{"Scans"}},
+		{"/api/v1/preservation-configs/search?q=scan&in=name,+description", []string{"Scans"}},
+		{"/api/v1/preservation-configs/search?q=flatbed&in=name&fts=true", []string{}},
+		{"/api/v1/preservation-configs/search?q=photo&fts=true", []string{"Photographs"}},
+		{"/api/v1/preservation-configs/search?q=flatbed&in=description&fts=true", []string{"Scans"}},
+		// Full-text search matches word prefixes, not substrings
+		{"/api/v1/preservation-configs/search?q=graph&fts=true", []string{}},
+		{"/api/v1/preservation-configs/search?q=default+configuration&fts=true", []string{"Default Configuration"}},
+	}
+	for _, tt := range tests {
+		req := setupTestRequest("GET", tt.url, nil)
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d: %s", tt.url, rr.Code, rr.Body.String())
+		}
+		var configs []models.PreservationConfig
+		if err := json.Unmarshal(rr.Body.Bytes(), &configs); err != nil {
+			t.Fatalf("%s: failed to unmarshal response: %v", tt.url, err)
+		}
+		names := []string{}
+		for i := range configs {
+			names = append(names, configs[i].Name)
+		}
+		if strings.Join(names, ",") != strings.Join(tt.expected, ",") {
+			t.Errorf("%s: expected %v, got %v", tt.url, tt.expected, names)
+		}
+	}
+
+	for _, url := range []string{
+		"/api/v1/preservation-configs/search",
+		"/api/v1/preservation-configs/search?q=+",
+		"/api/v1/preservation-configs/search?q=photo&fts=maybe",
+		"/api/v1/preservation-configs/search?q=photo&in=tags",
+		"/api/v1/preservation-configs/search?q=photo&in=",
+	} {
+		req := setupTestRequest("GET", url, nil)
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", url, rr.Code)
+		}
+	}
+
+	req := setupTestRequest("GET", "/api/v1/preservation-configs/search?q=photo&in=tags", nil)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	if !strings.Contains(rr.Body.String(), "Invalid in: fields must be among [description name]") {
+		t.Errorf("Expected an unknown field to be reported as an invalid in, got %s", rr.Body.String())
+	}
+}
+
+func TestServer_BasePath(t *testing.T) {
+	for _, healthAtRoot := range []bool{false, true} {
+		t.Run(fmt.Sprintf("health_at_root=%v", healthAtRoot), func(t *testing.T) {
+			server, err := New(config.Config{
+				DBType:       testDBType,
+				DBConnection: filepath.Join(t.TempDir(), "test.db"),
+				Port:         8080,
+				TrustedIPs:   []string{"127.0.0.1"},
+				BasePath:     "preservation/",
+				HealthAtRoot: healthAtRoot,
+			})
+			if err != nil {
+				t.Fatalf("Failed to create server: %v", err)
+			}
+			defer server.Shutdown()
+
+			rootStatus := http.StatusNotFound
+			if healthAtRoot {
+				rootStatus = http.StatusOK
+			}
+			tests := []struct {
+				url            string
+				expectedStatus int
+			}{
+				{"/preservation/api/v1/health", http.StatusOK},
+				{"/preservation/api/v1/version", http.StatusOK},
+				{"/preservation/api/v1/preservation-configs/1", http.StatusOK},
+				{"/api/v1/health", rootStatus},
+				{"/api/v1/ready", rootStatus},
+				// Only the public routes are served without the base path
+				{"/api/v1/preservation-configs/1", http.StatusNotFound},
+			}
+			for _, tt := range tests {
+				req := setupTestRequest("GET", tt.url, nil)
+				rr := httptest.NewRecorder()
+				server.router.ServeHTTP(rr, req)
+
+				if rr.Code != tt.expectedStatus {
+					t.Errorf("%s: expected status %d, got %d", tt.url, tt.expectedStatus, rr.Code)
+				}
+			}
+		})
+	}
+
+	if _, err := New(config.Config{
+		DBType:       testDBType,
+		DBConnection: filepath.Join(t.TempDir(), "test.db"),
+		BasePath:     "/preservation?x=1",
+	}); err == nil {
+		t.Error("Expected an invalid base path to be rejected")
+	}
+}
+
+func TestServer_HandleGetA3MConfig(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	config := models.NewPreservationConfig("A3M", "")
+	config.A3MConfig.ThumbnailMode = transferservice.ProcessingConfig_THUMBNAIL_MODE_DO_NOT_GENERATE
+	if err := server.db.CreateConfig(config); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+
+	req := setupTestRequest("GET", fmt.Sprintf("/api/v1/preservation-configs/%d/a3m", config.ID), nil)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var raw map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &raw); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if _, exists := raw["name"]; exists {
+		t.Error("Expected only the A3M config, without the wrapper fields")
+	}
+	// Enums are numbers and unset fields are included, as in the rest of the API
+	if raw["thumbnailMode"] != float64(3) {
+		t.Errorf("Expected thumbnailMode 3, got %v", raw["thumbnailMode"])
+	}
+	if raw["examineContents"] != false {
+		t.Errorf("Expected examineContents false to be emitted, got %v", raw["examineContents"])
+	}
+
+	var a3m models.A3MProcessingConfig
+	if err := json.Unmarshal(rr.Body.Bytes(), &a3m); err != nil {
+		t.Fatalf("Failed to unmarshal A3M config: %v", err)
+	}
+	if !a3m.Equal(&config.A3MConfig) {
+		t.Error("Expected the response to match the stored A3M config")
+	}
+
+	for url, expectedStatus := range map[string]int{
+		"/api/v1/preservation-configs/999/a3m": http.StatusNotFound,
+		"/api/v1/preservation-configs/abc/a3m": http.StatusBadRequest,
+	} {
+		req := setupTestRequest("GET", url, nil)
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+
+		if rr.Code != expectedStatus {
+			t.Errorf("%s: expected status %d, got %d", url, expectedStatus, rr.Code)
+		}
+	}
+}
+
+func TestServer_EnumFormatName(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	config := models.NewPreservationConfig("Named", "")
+	config.A3MConfig.ThumbnailMode = transferservice.ProcessingConfig_THUMBNAIL_MODE_DO_NOT_GENERATE
+	if err := server.db.CreateConfig(config); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+
+	get := func(url string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := setupTestRequest("GET", url, nil)
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d: %s", url, rr.Code, rr.Body.String())
+		}
+		return rr
+	}
+
+	var single struct {
+		Name      string         `json:"name"`
+		A3MConfig map[string]any `json:"a3m_config"`
+	}
+	if err := json.Unmarshal(get(fmt.Sprintf("/api/v1/preservation-configs/%d?enum_format=name", config.ID)).Body.Bytes(), &single); err != nil {
+		t.Fatalf("Failed to unmarshal config: %v", err)
+	}
+	if single.Name != "Named" || single.A3MConfig["thumbnailMode"] != "THUMBNAIL_MODE_DO_NOT_GENERATE" {
+		t.Errorf("Expected the thumbnail mode by name, got %v", single.A3MConfig["thumbnailMode"])
+	}
+
+	var list []struct {
+		A3MConfig map[string]any `json:"a3m_config"`
+	}
+	if err := json.Unmarshal(get("/api/v1/preservation-configs?enum_format=name&fields=a3m_config").Body.Bytes(), &list); err != nil {
+		t.Fatalf("Failed to unmarshal list: %v", err)
+	}
+	if len(list) != 2 || list[1].A3MConfig["thumbnailMode"] != "THUMBNAIL_MODE_DO_NOT_GENERATE" {
+		t.Errorf("Expected the list to name enums, got %v", list)
+	}
+
+	var a3m map[string]any
+	if err := json.Unmarshal(get(fmt.Sprintf("/api/v1/preservation-configs/%d/a3m?enum_format=name", config.ID)).Body.Bytes(), &a3m); err != nil {
+		t.Fatalf("Failed to unmarshal A3M config: %v", err)
+	}
+	if a3m["thumbnailMode"] != "THUMBNAIL_MODE_DO_NOT_GENERATE" {
+		t.Errorf("Expected the A3M thumbnail mode by name, got %v", a3m["thumbnailMode"])
+	}
+	// Names are valid proto JSON, so the response still decodes into the model
+	var decoded models.A3MProcessingConfig
+	if err := json.Unmarshal(get(fmt.Sprintf("/api/v1/preservation-configs/%d/a3m?enum_format=name", config.ID)).Body.Bytes(), &decoded); err != nil || !decoded.Equal(&config.A3MConfig) {
+		t.Errorf("Expected the named A3M config to decode to the stored one, got error %v", err)
+	}
+
+	// Numbers stay the default
+	if body := get(fmt.Sprintf("/api/v1/preservation-configs/%d?enum_format=number", config.ID)).Body.String(); !strings.Contains(body, `"thumbnailMode":3`) {
+		t.Errorf("Expected numeric enums, got %s", body)
+	}
+
+	for _, url := range []string{
+		"/api/v1/preservation-configs?enum_format=label",
+		fmt.Sprintf("/api/v1/preservation-configs/%d?enum_format=NAME", config.ID),
+		fmt.Sprintf("/api/v1/preservation-configs/%d/a3m?enum_format=text", config.ID),
+	} {
+		req := setupTestRequest("GET", url, nil)
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", url, rr.Code)
+		}
+	}
+}
+
+func TestServer_HandleGetA3MConfig_Protobuf(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	config := models.NewPreservationConfig("Protobuf", "")
+	config.A3MConfig.AipCompressionLevel = 9
+	config.A3MConfig.Normalize = false
+	if err := server.db.CreateConfig(config); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+
+	req := setupTestRequest("GET", fmt.Sprintf("/api/v1/preservation-configs/%d/a3m", config.ID), nil)
+	req.Header.Set("Accept", "application/x-protobuf")
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/x-protobuf" {
+		t.Errorf("Expected Content-Type application/x-protobuf, got %s", ct)
+	}
+
+	var a3m transferservice.ProcessingConfig
+	if err := proto.Unmarshal(rr.Body.Bytes(), &a3m); err != nil {
+		t.Fatalf("Failed to unmarshal protobuf response: %v", err)
+	}
+	if !config.A3MConfig.Equal((*models.A3MProcessingConfig)(&a3m)) {
+		t.Error("Expected the protobuf response to match the stored A3M config")
+	}
+
+	// JSON stays the default
+	req = setupTestRequest("GET", fmt.Sprintf("/api/v1/preservation-configs/%d/a3m", config.ID), nil)
+	rr = httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("Expected JSON by default, got %s", ct)
+	}
+}
+
+func TestServer_HandleGetConfig_Annotate(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	config := models.NewPreservationConfig("Annotated", "")
+	config.A3MConfig.ExamineContents = !config.A3MConfig.ExamineContents
+	config.A3MConfig.AipCompressionLevel = 9
+	if err := server.db.CreateConfig(config); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+
+	get := func(query string) map[string]any {
+		t.Helper()
+		req := setupTestRequest("GET", fmt.Sprintf("/api/v1/preservation-configs/%d%s", config.ID, query), nil)
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var body map[string]any
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		return body
+	}
+
+	if _, ok := get("")["non_default_fields"]; ok {
+		t.Error("Expected no non_default_fields without annotate")
+	}
+
+	body := get("?annotate=true")
+	fields, _ := body["non_default_fields"].([]any)
+	if len(fields) != 2 || fields[0] != "aip_compression_level" || fields[1] != "examine_contents" {
+		t.Errorf("Expected the changed A3M fields, got %v", body["non_default_fields"])
+	}
+	if body["name"] != "Annotated" || body["a3m_config"] == nil {
+		t.Errorf("Expected the annotated response to include the config, got %v", body)
+	}
+
+	req := setupTestRequest("GET", fmt.Sprintf("/api/v1/preservation-configs/%d?annotate=maybe", config.ID), nil)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid annotate, got %d", rr.Code)
+	}
+}
+
+func TestServer_HandleCreateConfig_Gzip(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Shutdown()
+
+	post := func(body []byte, encoding string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := setupTestRequest("POST", "/api/v1/preservation-configs", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Encoding", encoding)
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if _, err := gz.Write([]byte(`{"name": "Compressed", "description": "Sent gzipped"}`)); err != nil {
+		t.Fatalf("Failed to compress body: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("Failed to compress body: %v", err)
+	}
+
+	rr := post(compressed.Bytes(), "gzip")
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var config models.PreservationConfig
+	if err := json.Unmarshal(rr.Body.Bytes(), &config); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if config.Name != "Compressed" || config.Description != "Sent gzipped" {
+		t.Errorf("Expected the decompressed config, got %q / %q", config.Name, config.Description)
+	}
+
+	if rr := post([]byte(`{"name": "Not gzip"}`), "gzip"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a malformed gzip body, got %d", rr.Code)
+	}
+	if rr := post([]byte(`{"name": "Brotli"}`), "br"); rr.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected status 415 for an unsupported encoding, got %d", rr.Code)
+	}
+
+	// A body that expands past the limit is rejected rather than read in full
+	compressed.Reset()
+	gz = gzip.NewWriter(&compressed)
+	if _, err := gz.Write([]byte(`{"name": "Bomb", "description": "`)); err != nil {
+		t.Fatalf("Failed to compress body: %v", err)
+	}
+	if _, err := gz.Write(bytes.Repeat([]byte("a"), maxDecompressedBodySize)); err != nil {
+		t.Fatalf("Failed to compress body: %v", err)
+	}
+	if _, err := gz.Write([]byte(`"}`)); err != nil {
+		t.Fatalf("Failed to compress body: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("Failed to compress body: %v", err)
+	}
+	if rr := post(compressed.Bytes(), "gzip"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a body over the decompressed limit, got %d", rr.Code)
+	}
+}
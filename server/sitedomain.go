@@ -0,0 +1,65 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// siteDomainHeader names the Cells site domain a request's token was issued by, when the
+// API serves more than one
+const siteDomainHeader = "X-Cells-Domain"
+
+// errUnknownSiteDomain is returned for a request naming a site domain that isn't configured
+var errUnknownSiteDomain = errors.New("site domain is not allowed")
+
+// SiteDomains is the allowlist of Pydio Cells site domains that tokens are validated against.
+// Requests select one with the X-Cells-Domain header, or else by coming from one of them as
+// their Origin; any other request uses the default domain.
+type SiteDomains struct {
+	defaultDomain string
+	// allowed maps each normalized domain to the domain as configured
+	allowed map[string]string
+}
+
+// NewSiteDomains creates an allowlist of defaultDomain and the others given
+func NewSiteDomains(defaultDomain string, others []string) *SiteDomains {
+	domains := &SiteDomains{
+		defaultDomain: defaultDomain,
+		allowed:       make(map[string]string, len(others)+1),
+	}
+	for _, domain := range append([]string{defaultDomain}, others...) {
+		if key := normalizeSiteDomain(domain); key != "" {
+			if _, exists := domains.allowed[key]; !exists {
+				domains.allowed[key] = strings.TrimRight(domain, "/")
+			}
+		}
+	}
+	return domains
+}
+
+// Select returns the site domain to validate the request's token against. A request naming
+// a domain outside the allowlist in X-Cells-Domain gets errUnknownSiteDomain rather than the
+// default, so that it can't be mistaken for a user of another tenant. An Origin outside the
+// allowlist is ignored, as browsers send one for every cross-origin request.
+func (d *SiteDomains) Select(r *http.Request) (string, error) {
+	if header := r.Header.Get(siteDomainHeader); header != "" {
+		domain, ok := d.allowed[normalizeSiteDomain(header)]
+		if !ok {
+			return "", errUnknownSiteDomain
+		}
+		return domain, nil
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		if domain, ok := d.allowed[normalizeSiteDomain(origin)]; ok {
+			return domain, nil
+		}
+	}
+	return d.defaultDomain, nil
+}
+
+// normalizeSiteDomain returns domain in the form it is compared in: without surrounding
+// space or a trailing slash, and in lower case, as scheme and host are case-insensitive
+func normalizeSiteDomain(domain string) string {
+	return strings.ToLower(strings.TrimRight(strings.TrimSpace(domain), "/"))
+}
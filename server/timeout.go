@@ -0,0 +1,142 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/penwern/curate-preservation-api/pkg/logger"
+)
+
+// Request timeout defaults. Bulk routes, which may create or export many configs at once,
+// are given longer than the CRUD routes.
+const (
+	defaultRequestTimeout     = 5 * time.Second
+	defaultBulkRequestTimeout = time.Minute
+)
+
+// timeoutError is the body of the response sent when a request times out
+type timeoutError struct {
+	Error timeoutErrorDetail `json:"error"`
+}
+
+// timeoutErrorDetail describes a request timeout
+type timeoutErrorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Timeout creates middleware that cancels the request context after timeout. Handlers
+// write to a buffer; if they don't finish in time the buffered response is discarded
+// and the client receives 503 with a TIMEOUT error instead. Handlers should pass the
+// request context to the database so that the query is cancelled as well.
+func Timeout(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			r = r.WithContext(ctx)
+
+			start := time.Now()
+			tw := &timeoutWriter{header: make(http.Header)}
+			done := make(chan struct{})
+			panicChan := make(chan any, 1)
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panicChan <- p
+					}
+				}()
+				next.ServeHTTP(tw, r)
+				close(done)
+			}()
+
+			select {
+			case p := <-panicChan:
+				// Re-panic on the request goroutine so the Recoverer middleware handles it
+				panic(p)
+			case <-done:
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				dst := w.Header()
+				for key, values := range tw.header {
+					dst[key] = values
+				}
+				if tw.code == 0 {
+					tw.code = http.StatusOK
+				}
+				w.WriteHeader(tw.code)
+				if _, err := w.Write(tw.buf.Bytes()); err != nil {
+					logger.FromContext(ctx).Errorf("Failed to write response: %v", err)
+				}
+			case <-ctx.Done():
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				tw.timedOut = true
+				if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					// The client went away; there is no one to respond to
+					return
+				}
+				logger.FromContext(ctx).Warnf("Request %s %s timed out after %s", r.Method, r.URL.Path, time.Since(start).Round(time.Millisecond))
+				respondWithJSON(w, http.StatusServiceUnavailable, timeoutError{Error: timeoutErrorDetail{
+					Code:    "TIMEOUT",
+					Message: fmt.Sprintf("Request did not complete within %s", timeout),
+				}})
+			}
+		})
+	}
+}
+
+// Deadline creates middleware that cancels the request context after timeout, like Timeout,
+// but lets the handler write straight to the client. It is for handlers that stream responses
+// too large to buffer; a stream still running at the deadline is cut short.
+func Deadline(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// timeoutWriter buffers a handler's response so that Timeout can discard it
+type timeoutWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	buf      bytes.Buffer
+	code     int
+	timedOut bool
+}
+
+// Header returns the buffered response headers
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+// Write buffers b, failing once the request has timed out
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.code == 0 {
+		tw.code = http.StatusOK
+	}
+	return tw.buf.Write(b)
+}
+
+// WriteHeader records the status code; only the first call has an effect
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.code != 0 {
+		return
+	}
+	tw.code = code
+}
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/penwern/curate-preservation-api/pkg/config"
+)
+
+func TestTimeout_SlowHandler(t *testing.T) {
+	cancelled := make(chan struct{})
+	handler := Timeout(20 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		close(cancelled)
+		// Writes after the timeout are discarded
+		respondWithJSON(w, http.StatusOK, map[string]string{"status": "late"})
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/slow", nil))
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d, got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+	var body timeoutError
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if body.Error.Code != "TIMEOUT" || body.Error.Message == "" {
+		t.Errorf("Expected a TIMEOUT error with a message, got %+v", body.Error)
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Error("Expected the handler's context to be cancelled")
+	}
+}
+
+func TestTimeout_FastHandler(t *testing.T) {
+	handler := Timeout(time.Second)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("X-Test", "yes")
+		respondWithJSON(w, http.StatusCreated, map[string]string{"status": "ok"})
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/fast", nil))
+
+	if rr.Code != http.StatusCreated {
+		t.Errorf("Expected status %d, got %d", http.StatusCreated, rr.Code)
+	}
+	if rr.Header().Get("X-Test") != "yes" {
+		t.Errorf("Expected handler headers to be passed through, got %v", rr.Header())
+	}
+	if rr.Body.String() != `{"status":"ok"}` {
+		t.Errorf("Expected handler body, got %s", rr.Body.String())
+	}
+}
+
+func TestTimeout_Panic(t *testing.T) {
+	handler := Timeout(time.Second)(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		panic("boom")
+	}))
+
+	defer func() {
+		if p := recover(); p != "boom" {
+			t.Errorf("Expected the handler panic to propagate, got %v", p)
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/panic", nil))
+}
+
+func TestDeadline(t *testing.T) {
+	handler := Deadline(20 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write([]byte("partial")); err != nil {
+			t.Errorf("Failed to write: %v", err)
+		}
+		<-r.Context().Done()
+		if !errors.Is(r.Context().Err(), context.DeadlineExceeded) {
+			t.Errorf("Expected the deadline to pass, got %v", r.Context().Err())
+		}
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/stream", nil))
+
+	// What was written before the deadline reaches the client unbuffered
+	if rr.Code != http.StatusOK || rr.Body.String() != "partial" {
+		t.Errorf("Expected status 200 with the partial body, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestServer_BulkRequestTimeout(t *testing.T) {
+	// A request timeout too short for any request to finish in
+	server, err := New(config.Config{
+		DBType:         testDBType,
+		DBConnection:   filepath.Join(t.TempDir(), "test.db"),
+		Port:           8080,
+		TrustedIPs:     []string{"127.0.0.1"},
+		RequestTimeout: time.Nanosecond,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer server.Shutdown()
+
+	get := func(path string) int {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, setupTestRequest("GET", path, nil))
+		return rr.Code
+	}
+
+	// Export has the bulk timeout, so it isn't cut short
+	if code := get("/api/v1/preservation-configs/export"); code != http.StatusOK {
+		t.Errorf("Expected export to succeed under the bulk timeout, got status %d", code)
+	}
+	if code := get("/api/v1/preservation-configs/1"); code == http.StatusOK {
+		t.Error("Expected a get to be cut short by the request timeout")
+	}
+}
+
+func TestServer_RequestTimeoutBoundsAuth(t *testing.T) {
+	// An upstream that takes far longer than the request timeout to validate a token
+	release := make(chan struct{})
+	cells := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer cells.Close()
+	defer close(release)
+
+	server, err := New(config.Config{
+		DBType:         testDBType,
+		DBConnection:   filepath.Join(t.TempDir(), "test.db"),
+		Port:           8080,
+		SiteDomain:     cells.URL,
+		RequestTimeout: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer server.Shutdown()
+
+	req := setupTestRequest("GET", "/api/v1/preservation-configs", nil)
+	req.RemoteAddr = "8.8.8.8:12345"
+	req.Header.Set("Authorization", "Bearer uncached-token")
+	rr := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		server.router.ServeHTTP(rr, req)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected validating the token to be cut short by the request timeout")
+	}
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,49 @@
+// Package server – TLS configuration for serving HTTPS directly
+package server
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+
+	"github.com/penwern/curate-preservation-api/pkg/config"
+)
+
+// parseTLSMinVersion converts a version string such as "1.2" to its tls constant.
+// An empty string selects TLS 1.2.
+func parseTLSMinVersion(version string) (uint16, error) {
+	switch version {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported TLS minimum version %q (expected 1.2 or 1.3)", version)
+	}
+}
+
+// loadTLSConfig builds the server TLS configuration from the certificate and key files.
+// It returns nil when TLS is not configured, in which case the server listens in plaintext.
+func loadTLSConfig(cfg config.Config) (*tls.Config, error) {
+	if cfg.TLSCertFile == "" && cfg.TLSKeyFile == "" {
+		return nil, nil
+	}
+	if cfg.TLSCertFile == "" || cfg.TLSKeyFile == "" {
+		return nil, errors.New("both TLS certificate and key files must be set to enable HTTPS")
+	}
+
+	minVersion, err := parseTLSMinVersion(cfg.TLSMinVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate %s and key %s: %w", cfg.TLSCertFile, cfg.TLSKeyFile, err)
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   minVersion,
+	}, nil
+}
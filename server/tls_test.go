@@ -0,0 +1,131 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/penwern/curate-preservation-api/pkg/config"
+)
+
+// writeTestCertificate writes a self-signed certificate and key to dir and returns their paths
+func writeTestCertificate(t *testing.T, dir string) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	return certFile, keyFile
+}
+
+func TestLoadTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCertificate(t, dir)
+
+	tests := []struct {
+		name        string
+		cfg         config.Config
+		expectTLS   bool
+		expectError bool
+		minVersion  uint16
+	}{
+		{
+			name: "TLS not configured",
+			cfg:  config.Config{},
+		},
+		{
+			name:       "Certificate and key",
+			cfg:        config.Config{TLSCertFile: certFile, TLSKeyFile: keyFile},
+			expectTLS:  true,
+			minVersion: tls.VersionTLS12,
+		},
+		{
+			name:       "TLS 1.3 minimum",
+			cfg:        config.Config{TLSCertFile: certFile, TLSKeyFile: keyFile, TLSMinVersion: "1.3"},
+			expectTLS:  true,
+			minVersion: tls.VersionTLS13,
+		},
+		{
+			name:        "Unsupported minimum version",
+			cfg:         config.Config{TLSCertFile: certFile, TLSKeyFile: keyFile, TLSMinVersion: "1.0"},
+			expectError: true,
+		},
+		{
+			name:        "Key without certificate",
+			cfg:         config.Config{TLSKeyFile: keyFile},
+			expectError: true,
+		},
+		{
+			name:        "Missing certificate file",
+			cfg:         config.Config{TLSCertFile: filepath.Join(dir, "missing.pem"), TLSKeyFile: keyFile},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tlsConfig, err := loadTLSConfig(tt.cfg)
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if (tlsConfig != nil) != tt.expectTLS {
+				t.Fatalf("Expected TLS configured=%v, got %v", tt.expectTLS, tlsConfig != nil)
+			}
+			if tt.expectTLS && tlsConfig.MinVersion != tt.minVersion {
+				t.Errorf("Expected min version %x, got %x", tt.minVersion, tlsConfig.MinVersion)
+			}
+		})
+	}
+}
+
+func TestNew_InvalidTLSConfig(t *testing.T) {
+	_, err := New(config.Config{
+		DBType:       testDBType,
+		DBConnection: filepath.Join(t.TempDir(), "test.db"),
+		TLSCertFile:  "/nonexistent/cert.pem",
+		TLSKeyFile:   "/nonexistent/key.pem",
+	})
+	if err == nil {
+		t.Error("Expected error for missing TLS files")
+	}
+}
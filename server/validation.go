@@ -0,0 +1,237 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/penwern/curate-preservation-api/models"
+	"github.com/penwern/curate-preservation-api/pkg/logger"
+)
+
+var (
+	errNameRequired       = errors.New("name is required")
+	errNameInvalid        = errors.New("name must be a non-empty string")
+	errDescriptionInvalid = errors.New("description must be a string")
+	errCompressAIPInvalid = errors.New("compress_aip must be true or false")
+	errActiveInvalid      = errors.New("active must be true or false")
+	errTagsInvalid        = errors.New("tags must be an array of strings")
+	errA3MConfigInvalid   = errors.New("a3m_config must be an object")
+)
+
+// validationResponse reports whether a config is valid and, if not, every problem found
+type validationResponse struct {
+	Valid  bool                `json:"valid"`
+	Error  string              `json:"error,omitempty"`
+	Errors []models.FieldError `json:"errors,omitempty"`
+}
+
+// respondWithFieldErrors writes a 422 response listing every validation problem
+func respondWithFieldErrors(w http.ResponseWriter, errs []models.FieldError) {
+	respondWithJSON(w, http.StatusUnprocessableEntity, validationResponse{
+		Error:  "Invalid config: " + joinFieldErrors(errs),
+		Errors: errs,
+	})
+}
+
+// joinFieldErrors joins the messages of errs into one line
+func joinFieldErrors(errs []models.FieldError) string {
+	messages := make([]string, 0, len(errs))
+	for _, err := range errs {
+		messages = append(messages, err.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// ValidateConfigInput builds a config from a decoded request body, starting from the named
+// preset (or the defaults when preset is empty) and applying the fields provided with
+// applyConfigInput. It returns the config along with every problem found, rather than
+// stopping at the first; the config is nil only when the preset is unknown. Nothing is
+// written to the database.
+func (s *Server) ValidateConfigInput(ctx context.Context, rawInput map[string]any, preset string) (*models.PreservationConfig, []models.FieldError) {
+	var errs []models.FieldError
+
+	// A new config needs a name; applyConfigInput checks one that is given
+	if _, exists := rawInput["name"]; !exists {
+		errs = append(errs, models.FieldError{Field: "name", Message: errNameRequired.Error()})
+	}
+
+	// Start with default config, or the requested preset
+	config := models.NewPreservationConfig("", "")
+	if preset != "" {
+		var ok bool
+		if config, ok = models.NewPreservationConfigFromPreset("", "", preset); !ok {
+			return nil, append(errs, models.FieldError{Field: "preset", Message: fmt.Sprintf("unknown preset: %s", preset)})
+		}
+	}
+
+	logger.FromContext(ctx).Debugf("Default Config: %+v", config)
+
+	errs = append(errs, s.applyConfigInput(ctx, config, rawInput)...)
+	return config, append(errs, s.validateConfig(config)...)
+}
+
+// applyConfigInput applies the name, description, compress_aip, active, tags and a3m_config
+// fields of a decoded request body to config, leaving fields that aren't given unchanged.
+// Create and update share it, so both accept the same values. Every field of the wrong
+// type is reported, rather than ignored; a null description, compress_aip, active or
+// a3m_config counts as not given.
+func (s *Server) applyConfigInput(ctx context.Context, config *models.PreservationConfig, rawInput map[string]any) []models.FieldError {
+	var errs []models.FieldError
+
+	if value, exists := rawInput["name"]; exists {
+		if name, err := configNameFromInput(value); err != nil {
+			errs = append(errs, models.FieldError{Field: "name", Message: err.Error()})
+		} else {
+			config.Name = name
+		}
+	}
+
+	if value, exists := rawInput["description"]; exists && value != nil {
+		if description, ok := value.(string); ok {
+			config.Description = description
+		} else {
+			errs = append(errs, models.FieldError{Field: "description", Message: errDescriptionInvalid.Error()})
+		}
+	}
+
+	if value, exists := rawInput["compress_aip"]; exists && value != nil {
+		if compressAIP, ok := value.(bool); ok {
+			config.CompressAIP = compressAIP
+		} else {
+			errs = append(errs, models.FieldError{Field: "compress_aip", Message: errCompressAIPInvalid.Error()})
+		}
+	}
+
+	if value, exists := rawInput["active"]; exists && value != nil {
+		if active, ok := value.(bool); ok {
+			config.Active = active
+		} else {
+			errs = append(errs, models.FieldError{Field: "active", Message: errActiveInvalid.Error()})
+		}
+	}
+
+	if value, exists := rawInput["tags"]; exists {
+		if tags, err := tagsFromInput(value); err != nil {
+			errs = append(errs, models.FieldError{Field: "tags", Message: err.Error()})
+		} else {
+			config.Tags = tags
+		}
+	}
+
+	// A3M fields given are merged into the existing A3M config
+	if value, exists := rawInput["a3m_config"]; exists && value != nil {
+		if a3mMap, ok := value.(map[string]any); ok {
+			unknown, a3mErrs := updateA3MConfigFromMap(ctx, &config.A3MConfig, a3mMap)
+			s.warnUnknownA3MFields(ctx, unknown)
+			errs = append(errs, a3mErrs...)
+		} else {
+			errs = append(errs, models.FieldError{Field: "a3m_config", Message: errA3MConfigInvalid.Error()})
+		}
+	}
+
+	return errs
+}
+
+// validateConfig checks a config about to be saved against the A3M value ranges, the
+// compression settings and the configured length limits. The name is checked by callers,
+// since create requires it while update only checks it when it is given.
+func (s *Server) validateConfig(config *models.PreservationConfig) []models.FieldError {
+	errs := config.A3MConfig.FieldErrors()
+	if err := config.ValidateCompression(); err != nil {
+		errs = append(errs, models.FieldError{Field: "compress_aip", Message: err.Error()})
+	}
+	return append(errs, config.LengthErrors(s.maxNameLength, s.maxDescriptionLength)...)
+}
+
+// configNameFromInput returns the normalized name from the name field of a decoded request body
+func configNameFromInput(value any) (string, error) {
+	nameStr, ok := value.(string)
+	if !ok {
+		return "", errNameInvalid
+	}
+	// A name of only whitespace is as empty as a missing one
+	nameStr = models.NormalizeName(nameStr)
+	if nameStr == "" {
+		return "", errNameInvalid
+	}
+	return nameStr, nil
+}
+
+// tagsFromInput returns the normalized tags from the tags field of a decoded request body.
+// A null value clears the tags.
+func tagsFromInput(value any) ([]string, error) {
+	if value == nil {
+		return []string{}, nil
+	}
+	items, ok := value.([]any)
+	if !ok {
+		return nil, errTagsInvalid
+	}
+	tags := make([]string, 0, len(items))
+	for _, item := range items {
+		tag, ok := item.(string)
+		if !ok {
+			return nil, errTagsInvalid
+		}
+		tags = append(tags, tag)
+	}
+	return models.NormalizeTags(tags)
+}
+
+// configRequestFields lists the top-level fields of a config request body, so that
+// checkUnknownFields can reject any others
+type configRequestFields struct {
+	ID          json.RawMessage `json:"id"`
+	Name        json.RawMessage `json:"name"`
+	Description json.RawMessage `json:"description"`
+	CompressAIP json.RawMessage `json:"compress_aip"`
+	Active      json.RawMessage `json:"active"`
+	Tags        json.RawMessage `json:"tags"`
+	A3MConfig   json.RawMessage `json:"a3m_config"`
+	Version     json.RawMessage `json:"version"`
+	CreatedAt   json.RawMessage `json:"created_at"`
+	UpdatedAt   json.RawMessage `json:"updated_at"`
+}
+
+// checkUnknownFields returns an error naming the first top-level field of a decoded
+// request body that a config doesn't have, such as a misspelt "complress_aip"
+func checkUnknownFields(rawInput map[string]any) error {
+	data, err := json.Marshal(rawInput)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var fields configRequestFields
+	if err := decoder.Decode(&fields); err != nil {
+		return errors.New(strings.TrimPrefix(err.Error(), "json: "))
+	}
+	return nil
+}
+
+// rejectUnknownFields responds with 400 and returns false when strict JSON is configured
+// and the request body has a top-level field a config doesn't have
+func (s *Server) rejectUnknownFields(w http.ResponseWriter, r *http.Request, rawInput map[string]any) bool {
+	if !s.config.StrictJSON {
+		return true
+	}
+	if err := checkUnknownFields(rawInput); err != nil {
+		logger.FromContext(r.Context()).Warnf("Rejecting %s %s: %v", r.Method, r.URL.Path, err)
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// warnUnknownA3MFields logs a3m_config fields that were ignored when strict JSON is configured.
+// Unlike top-level fields they are not rejected, matching how protojson discards them.
+func (s *Server) warnUnknownA3MFields(ctx context.Context, unknown []string) {
+	if s.config.StrictJSON && len(unknown) > 0 {
+		logger.FromContext(ctx).Warnf("Ignoring unknown a3m_config fields: %s", strings.Join(unknown, ", "))
+	}
+}
@@ -0,0 +1,170 @@
+// Package server – client-selected views of configs in responses: sparse fieldsets and enum names
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/penwern/curate-preservation-api/models"
+	"github.com/penwern/curate-preservation-api/pkg/logger"
+)
+
+// configView is how the client asked for configs to be shown. The zero value is the
+// full config with A3M enums as numbers.
+type configView struct {
+	// fields are the top-level fields to keep, or nil for all of them
+	fields []string
+	// enumNames shows A3M enums by name, such as THUMBNAIL_MODE_GENERATE, rather than number
+	enumNames bool
+}
+
+// parseConfigView reads the fields and enum_format query parameters
+func parseConfigView(query url.Values) (configView, error) {
+	fields, err := parseFields(query.Get("fields"))
+	if err != nil {
+		return configView{}, fmt.Errorf("invalid fields: %w", err)
+	}
+	enumNames, err := parseEnumFormat(query.Get("enum_format"))
+	if err != nil {
+		return configView{}, err
+	}
+	return configView{fields: fields, enumNames: enumNames}, nil
+}
+
+// parseEnumFormat reports whether an enum_format parameter asks for enum names. It may be
+// empty or "number" for the default numbers, or "name".
+func parseEnumFormat(value string) (bool, error) {
+	switch value {
+	case "", "number":
+		return false, nil
+	case "name":
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid enum_format %q: must be number or name", value)
+	}
+}
+
+// configFields are the top-level JSON fields of a config that ?fields= may select
+var configFields = jsonFieldNames(reflect.TypeOf(models.PreservationConfig{}))
+
+// jsonFieldNames returns the JSON names of a struct type's fields, in declaration order
+func jsonFieldNames(t reflect.Type) []string {
+	var names []string
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if field.IsExported() && name != "-" && name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// parseFields parses a comma-separated fields parameter, such as "id,name,updated_at",
+// checking each name against configFields. It returns nil if value is empty, meaning
+// every field.
+func parseFields(value string) ([]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var fields []string
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if !slices.Contains(configFields, field) {
+			return nil, fmt.Errorf("unknown field %q, must be one of %v", field, configFields)
+		}
+		if !slices.Contains(fields, field) {
+			fields = append(fields, field)
+		}
+	}
+	return fields, nil
+}
+
+// apply returns payload with every config in it shown as the view asks. The configs are
+// either the payload itself, the items of a list, or the items of the list under its "data"
+// or "items" key, as in the envelope and cursor responses. The default view returns payload
+// as it is.
+func (v configView) apply(payload any) (any, error) {
+	if v.fields == nil && !v.enumNames {
+		return payload, nil
+	}
+
+	doc, err := toJSONDocument(payload)
+	if err != nil {
+		return nil, err
+	}
+	for _, config := range configObjects(doc) {
+		if a3m, ok := config["a3m_config"].(map[string]any); ok && v.enumNames {
+			models.NameA3MEnums(a3m)
+		}
+		if v.fields != nil {
+			for key := range config {
+				if !slices.Contains(v.fields, key) {
+					delete(config, key)
+				}
+			}
+		}
+	}
+	return doc, nil
+}
+
+// toJSONDocument converts payload to the generic form it has as JSON, keeping numbers as
+// json.Number so that large IDs don't pass through float64
+func toJSONDocument(payload any) (any, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc any
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// configObjects returns the config objects in a decoded response, as described for apply
+func configObjects(doc any) []map[string]any {
+	var items []any
+	switch doc := doc.(type) {
+	case []any:
+		items = doc
+	case map[string]any:
+		data, isData := doc["data"].([]any)
+		page, isPage := doc["items"].([]any)
+		switch {
+		case isData:
+			items = data
+		case isPage:
+			items = page
+		default:
+			return []map[string]any{doc}
+		}
+	}
+
+	configs := make([]map[string]any, 0, len(items))
+	for _, item := range items {
+		if config, ok := item.(map[string]any); ok {
+			configs = append(configs, config)
+		}
+	}
+	return configs
+}
+
+// respondWithView responds 200 with payload, showing its configs as view asks
+func respondWithView(w http.ResponseWriter, r *http.Request, payload any, view configView) {
+	payload, err := view.apply(payload)
+	if err != nil {
+		logger.FromContext(r.Context()).Errorf("Failed to apply config view: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to encode configs")
+		return
+	}
+	respond(w, r, http.StatusOK, payload)
+}
@@ -0,0 +1,205 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/penwern/curate-preservation-api/pkg/logger"
+)
+
+// Config change event types sent to the webhook
+const (
+	EventConfigCreated = "config.created"
+	EventConfigUpdated = "config.updated"
+	EventConfigDeleted = "config.deleted"
+)
+
+// webhookSignatureHeader carries the HMAC-SHA256 of the event body, as "sha256=<hex>"
+const webhookSignatureHeader = "X-Webhook-Signature"
+
+// Webhook delivery defaults. Each attempt may take webhookTimeout; a failed attempt is
+// retried after webhookBackoff, doubling each time, until webhookMaxAttempts is reached.
+const (
+	webhookTimeout     = 5 * time.Second
+	webhookMaxAttempts = 4
+	webhookBackoff     = time.Second
+)
+
+// ConfigEvent describes a change to a preservation config, as posted to the webhook
+type ConfigEvent struct {
+	Type      string    `json:"type"`
+	ID        int64     `json:"id"`
+	Actor     string    `json:"actor"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// WebhookNotifier posts config events to a webhook in the background, so that a slow or
+// failing receiver never holds up or fails the API request that caused the event. Failed
+// deliveries are retried with exponential backoff, then logged and dropped. A nil
+// *WebhookNotifier is valid and sends nothing.
+type WebhookNotifier struct {
+	url         string
+	secret      []byte
+	client      *http.Client
+	maxAttempts int
+	backoff     time.Duration
+
+	// pending tracks deliveries in flight, and stop cuts their retries short on Close. mu
+	// guards closed, so that no delivery is added to pending once Close has started waiting.
+	pending sync.WaitGroup
+	stop    chan struct{}
+	mu      sync.Mutex
+	closed  bool
+}
+
+// NewWebhookNotifier creates a notifier posting to url, signing each body with secret when
+// one is given. It returns nil if url is empty, disabling notifications.
+func NewWebhookNotifier(url, secret string) *WebhookNotifier {
+	if url == "" {
+		return nil
+	}
+	return &WebhookNotifier{
+		url:         url,
+		secret:      []byte(secret),
+		client:      &http.Client{Timeout: webhookTimeout},
+		maxAttempts: webhookMaxAttempts,
+		backoff:     webhookBackoff,
+		stop:        make(chan struct{}),
+	}
+}
+
+// Notify sends event in the background and returns immediately
+func (n *WebhookNotifier) Notify(event ConfigEvent) {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		logger.Warn("Webhook notifier is closed, dropping %s event for config %d", event.Type, event.ID)
+		return
+	}
+	n.pending.Add(1)
+	n.mu.Unlock()
+
+	go func() {
+		defer n.pending.Done()
+		n.deliver(event)
+	}()
+}
+
+// deliver posts event, retrying failed attempts with backoff
+func (n *WebhookNotifier) deliver(event ConfigEvent) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		logger.Error("Failed to encode %s webhook event: %v", event.Type, err)
+		return
+	}
+
+	backoff := n.backoff
+	for attempt := 1; ; attempt++ {
+		err := n.post(body, event.Type)
+		if err == nil {
+			logger.Debug("Delivered %s webhook event for config %d", event.Type, event.ID)
+			return
+		}
+		if attempt >= n.maxAttempts {
+			logger.Error("Dropping %s webhook event for config %d after %d attempts: %v", event.Type, event.ID, attempt, err)
+			return
+		}
+		logger.Warn("Webhook delivery of %s event for config %d failed (attempt %d of %d), retrying in %s: %v",
+			event.Type, event.ID, attempt, n.maxAttempts, backoff, err)
+
+		select {
+		case <-time.After(backoff):
+		case <-n.stop:
+			logger.Error("Dropping %s webhook event for config %d at shutdown: %v", event.Type, event.ID, err)
+			return
+		}
+		backoff *= 2
+	}
+}
+
+// post makes a single delivery attempt, succeeding on any 2xx response
+func (n *WebhookNotifier) post(body []byte, eventType string) error {
+	req, err := http.NewRequest(http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Event", eventType)
+	if len(n.secret) > 0 {
+		req.Header.Set(webhookSignatureHeader, signWebhookBody(n.secret, body))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Close stops retrying failed deliveries and waits, until ctx is done, for attempts
+// already in flight to finish
+func (n *WebhookNotifier) Close(ctx context.Context) error {
+	if n == nil {
+		return nil
+	}
+	n.mu.Lock()
+	if !n.closed {
+		n.closed = true
+		close(n.stop)
+	}
+	n.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		n.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("webhook deliveries still in flight: %w", ctx.Err())
+	}
+}
+
+// signWebhookBody returns the signature header value for body: the hex HMAC-SHA256 keyed
+// with secret, prefixed with "sha256=". Receivers recompute it over the raw body to check
+// that the event came from this server.
+func signWebhookBody(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// notifyConfigChange sends a config event for the change a request made, attributed to the
+// authenticated user
+func (s *Server) notifyConfigChange(r *http.Request, eventType string, id int64) {
+	if s.webhook == nil {
+		return
+	}
+	actor := ""
+	if userInfo := GetUserInfo(r); userInfo != nil {
+		actor = userInfo.Sub
+	}
+	s.webhook.Notify(ConfigEvent{
+		Type:      eventType,
+		ID:        id,
+		Actor:     actor,
+		Timestamp: time.Now().UTC(),
+	})
+}
@@ -0,0 +1,223 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/penwern/curate-preservation-api/pkg/config"
+)
+
+// webhookRequest is a request received by a test webhook receiver
+type webhookRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// newWebhookReceiver starts a webhook receiver that answers with the given statuses in turn,
+// then 200 OK, and passes every request it gets to the returned channel
+func newWebhookReceiver(t *testing.T, statuses ...int) (*httptest.Server, <-chan webhookRequest) {
+	t.Helper()
+	received := make(chan webhookRequest, 10)
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- webhookRequest{header: r.Header.Clone(), body: body}
+		if call := int(calls.Add(1)); call <= len(statuses) {
+			w.WriteHeader(statuses[call-1])
+		}
+	}))
+	t.Cleanup(receiver.Close)
+	return receiver, received
+}
+
+func waitForWebhook(t *testing.T, received <-chan webhookRequest) webhookRequest {
+	t.Helper()
+	select {
+	case req := <-received:
+		return req
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for webhook delivery")
+		return webhookRequest{}
+	}
+}
+
+func TestServer_WebhookOnConfigChanges(t *testing.T) {
+	receiver, received := newWebhookReceiver(t)
+	secret := "webhook-secret"
+
+	server, err := New(config.Config{
+		DBType:        testDBType,
+		DBConnection:  filepath.Join(t.TempDir(), "test.db"),
+		TrustedIPs:    []string{"127.0.0.1"},
+		WebhookURL:    receiver.URL,
+		WebhookSecret: secret,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer server.Shutdown()
+
+	body := bytes.NewBufferString(`{"name": "Webhook Config"}`)
+	req := setupTestRequest("POST", "/api/v1/preservation-configs", body)
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	var created struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	delivery := waitForWebhook(t, received)
+	var event ConfigEvent
+	if err := json.Unmarshal(delivery.body, &event); err != nil {
+		t.Fatalf("Failed to unmarshal event: %v", err)
+	}
+	if event.Type != EventConfigCreated || event.ID != created.ID {
+		t.Errorf("Expected %s event for config %d, got %+v", EventConfigCreated, created.ID, event)
+	}
+	if event.Actor != "trusted-ip:127.0.0.1" {
+		t.Errorf("Expected the trusted IP user as actor, got %q", event.Actor)
+	}
+	if event.Timestamp.IsZero() {
+		t.Error("Expected the event to have a timestamp")
+	}
+	if got, want := delivery.header.Get(webhookSignatureHeader), signWebhookBody([]byte(secret), delivery.body); got != want {
+		t.Errorf("Expected signature %q, got %q", want, got)
+	}
+
+	req = setupTestRequest("DELETE", fmt.Sprintf("/api/v1/preservation-configs/%d", created.ID), nil)
+	rr = httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+	}
+	if err := json.Unmarshal(waitForWebhook(t, received).body, &event); err != nil {
+		t.Fatalf("Failed to unmarshal event: %v", err)
+	}
+	if event.Type != EventConfigDeleted || event.ID != created.ID {
+		t.Errorf("Expected %s event for config %d, got %+v", EventConfigDeleted, created.ID, event)
+	}
+}
+
+func TestWebhookNotifier_RetriesFailedDeliveries(t *testing.T) {
+	receiver, received := newWebhookReceiver(t, http.StatusInternalServerError, http.StatusBadGateway)
+
+	notifier := NewWebhookNotifier(receiver.URL, "")
+	notifier.backoff = time.Millisecond
+	notifier.Notify(ConfigEvent{Type: EventConfigUpdated, ID: 7})
+
+	for range 3 {
+		delivery := waitForWebhook(t, received)
+		if delivery.header.Get(webhookSignatureHeader) != "" {
+			t.Error("Expected no signature without a secret")
+		}
+	}
+	if err := notifier.Close(context.Background()); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+	select {
+	case <-received:
+		t.Error("Expected no further attempts after a successful delivery")
+	default:
+	}
+}
+
+func TestWebhookNotifier_DropsAfterMaxAttempts(t *testing.T) {
+	receiver, received := newWebhookReceiver(t,
+		http.StatusInternalServerError, http.StatusInternalServerError,
+		http.StatusInternalServerError, http.StatusInternalServerError)
+
+	notifier := NewWebhookNotifier(receiver.URL, "")
+	notifier.backoff = time.Millisecond
+	notifier.Notify(ConfigEvent{Type: EventConfigDeleted, ID: 3})
+
+	for range webhookMaxAttempts {
+		waitForWebhook(t, received)
+	}
+	if err := notifier.Close(context.Background()); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+	if len(received) != 0 {
+		t.Errorf("Expected %d attempts, got more", webhookMaxAttempts)
+	}
+}
+
+func TestWebhookNotifier_CloseStopsRetries(t *testing.T) {
+	receiver, received := newWebhookReceiver(t, http.StatusServiceUnavailable)
+
+	notifier := NewWebhookNotifier(receiver.URL, "")
+	notifier.backoff = time.Hour
+	notifier.Notify(ConfigEvent{Type: EventConfigCreated, ID: 1})
+	waitForWebhook(t, received)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := notifier.Close(ctx); err != nil {
+		t.Errorf("Expected Close to cut the retry backoff short, got %v", err)
+	}
+
+	// Events after Close are dropped
+	notifier.Notify(ConfigEvent{Type: EventConfigCreated, ID: 2})
+	if err := notifier.Close(ctx); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+	if len(received) != 0 {
+		t.Error("Expected no delivery after Close")
+	}
+}
+
+func TestWebhookNotifier_NotifyDuringClose(t *testing.T) {
+	var delivered atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		delivered.Add(1)
+	}))
+	defer receiver.Close()
+
+	notifier := NewWebhookNotifier(receiver.URL, "")
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			notifier.Notify(ConfigEvent{Type: EventConfigCreated, ID: int64(i)})
+		}()
+	}
+	if err := notifier.Close(context.Background()); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+
+	// Every event accepted before Close has been delivered by the time it returns
+	closedWith := delivered.Load()
+	wg.Wait()
+	time.Sleep(50 * time.Millisecond)
+	if got := delivered.Load(); got != closedWith {
+		t.Errorf("Expected no deliveries after Close returned, got %d more", got-closedWith)
+	}
+}
+
+func TestWebhookNotifier_Disabled(t *testing.T) {
+	notifier := NewWebhookNotifier("", "secret")
+	if notifier != nil {
+		t.Fatal("Expected no notifier without a URL")
+	}
+	// A nil notifier is safe to use
+	notifier.Notify(ConfigEvent{Type: EventConfigCreated, ID: 1})
+	if err := notifier.Close(context.Background()); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+}